@@ -0,0 +1,57 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownBucket is returned when a bucket name is not configured in INFLUX_BUCKETS.
+var ErrUnknownBucket = errors.New("unknown bucket")
+
+// parseBuckets reads "name:bucket" pairs separated by commas.
+func parseBuckets(raw string) (map[string]string, error) {
+	buckets := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, bucket, ok := strings.Cut(entry, ":")
+		name, bucket = strings.TrimSpace(name), strings.TrimSpace(bucket)
+		if !ok || name == "" || bucket == "" {
+			return nil, fmt.Errorf("expected name:bucket, got %q", entry)
+		}
+		if _, dup := buckets[name]; dup {
+			return nil, fmt.Errorf("bucket name %q listed twice", name)
+		}
+		buckets[name] = bucket
+	}
+	return buckets, nil
+}
+
+// ResolveBucket maps a configured bucket name to its bucket. The empty name
+// resolves to the default bucket.
+func (c *Client) ResolveBucket(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	cfg := c.Config()
+	if name == "" {
+		return cfg.Bucket, nil
+	}
+	if bucket, ok := cfg.Buckets[name]; ok {
+		return bucket, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownBucket, name)
+}
+
+// BucketNames lists the configured bucket names in order.
+func (c *Client) BucketNames() []string {
+	buckets := c.Config().Buckets
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -18,11 +21,35 @@ type Config struct {
 	Org     string
 	Bucket  string
 	Timeout time.Duration
+	// Buckets maps names accepted by the *FromBucket methods to bucket names.
+	// The empty name always refers to Bucket.
+	Buckets map[string]string
+	// DefaultLookback is the window queried when a caller passes no lookback
+	// or start time. Zero means fallbackLookback.
+	DefaultLookback time.Duration
+}
+
+// fallbackLookback applies when Config.DefaultLookback is unset.
+const fallbackLookback = time.Hour
+
+// Lookback returns lookback when it is positive and the configured default
+// otherwise.
+func (cfg Config) Lookback(lookback time.Duration) time.Duration {
+	switch {
+	case lookback > 0:
+		return lookback
+	case cfg.DefaultLookback > 0:
+		return cfg.DefaultLookback
+	default:
+		return fallbackLookback
+	}
 }
 
 // FromEnv loads configuration values from environment variables.
 // INFLUX_URL, INFLUX_TOKEN, INFLUX_ORG, and INFLUX_BUCKET are required.
 // INFLUX_TIMEOUT is optional and defaults to 5s when not provided.
+// INFLUX_BUCKETS optionally names extra buckets as "name:bucket,name:bucket".
+// INFLUX_DEFAULT_LOOKBACK optionally sets DefaultLookback, 1h when not provided.
 func FromEnv() (Config, error) {
 	cfg := Config{
 		URL:    os.Getenv("INFLUX_URL"),
@@ -35,6 +62,12 @@ func FromEnv() (Config, error) {
 		return Config{}, fmt.Errorf("missing InfluxDB configuration, ensure INFLUX_URL, INFLUX_TOKEN, INFLUX_ORG, and INFLUX_BUCKET are set")
 	}
 
+	buckets, err := parseBuckets(os.Getenv("INFLUX_BUCKETS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid INFLUX_BUCKETS: %w", err)
+	}
+	cfg.Buckets = buckets
+
 	timeout := os.Getenv("INFLUX_TIMEOUT")
 	switch {
 	case timeout == "":
@@ -47,13 +80,28 @@ func FromEnv() (Config, error) {
 		cfg.Timeout = dur
 	}
 
+	if raw := os.Getenv("INFLUX_DEFAULT_LOOKBACK"); raw != "" {
+		dur, err := time.ParseDuration(raw)
+		if err != nil || dur <= 0 {
+			return Config{}, fmt.Errorf("invalid INFLUX_DEFAULT_LOOKBACK: must be a positive duration such as 30m or 2h")
+		}
+		cfg.DefaultLookback = dur
+	} else {
+		cfg.DefaultLookback = fallbackLookback
+	}
+
 	return cfg, nil
 }
 
-// Client wraps the InfluxDB client with project-specific defaults.
+// Client wraps the InfluxDB client with project-specific defaults. The
+// underlying connection can be replaced at runtime with Reconnect.
 type Client struct {
-	cfg    Config
-	client influxdb2.Client
+	mu       sync.RWMutex
+	conn     *connection
+	batching atomic.Bool
+	// connected is false while the client runs on an unverified connection
+	// from NewUnverified.
+	connected atomic.Bool
 }
 
 // SensorReading represents a single measurement row returned from InfluxDB.
@@ -66,8 +114,20 @@ type SensorReading struct {
 }
 
 // New establishes a new InfluxDB client based on the provided configuration.
-// A ping is issued to ensure the connection is healthy before returning.
+// A ping is issued and the bucket is looked up before returning, so failures
+// surface as ErrInfluxUnreachable, ErrInfluxUnauthorized or ErrInfluxBucketNotFound.
 func New(ctx context.Context, cfg Config) (*Client, error) {
+	client, err := dial(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: &connection{cfg: cfg, client: client}}
+	c.connected.Store(true)
+	return c, nil
+}
+
+// dial creates an underlying client for cfg and verifies it as New describes.
+func dial(ctx context.Context, cfg Config) (influxdb2.Client, error) {
 	client := influxdb2.NewClient(cfg.URL, cfg.Token)
 
 	ctxPing := ctx
@@ -80,34 +140,64 @@ func New(ctx context.Context, cfg Config) (*Client, error) {
 	ok, err := client.Ping(ctxPing)
 	if err != nil {
 		client.Close()
-		return nil, fmt.Errorf("ping InfluxDB: %w", err)
+		kind := errorKind(err)
+		if kind == nil {
+			kind = ErrInfluxUnreachable
+		}
+		return nil, fmt.Errorf("ping InfluxDB: %w: %v", kind, err)
 	}
 	if !ok {
 		client.Close()
-		return nil, fmt.Errorf("influxdb ping failed")
+		return nil, fmt.Errorf("influxdb ping failed: %w", ErrInfluxUnreachable)
 	}
 
-	return &Client{cfg: cfg, client: client}, nil
-}
+	// /ping is unauthenticated; the bucket lookup verifies the token, org and bucket.
+	bucket, err := client.BucketsAPI().FindBucketByName(ctxPing, cfg.Bucket)
+	if err != nil {
+		client.Close()
+		kind := errorKind(err)
+		if kind == nil && strings.Contains(err.Error(), "not found") {
+			kind = ErrInfluxBucketNotFound
+		}
+		if kind == nil {
+			return nil, fmt.Errorf("find bucket %q: %w", cfg.Bucket, err)
+		}
+		return nil, fmt.Errorf("find bucket %q: %w: %v", cfg.Bucket, kind, err)
+	}
+	if bucket == nil {
+		client.Close()
+		return nil, fmt.Errorf("find bucket %q: %w", cfg.Bucket, ErrInfluxBucketNotFound)
+	}
 
-// WriteAPI returns the blocking write API bound to the configured org and bucket.
-func (c *Client) WriteAPI() api.WriteAPIBlocking {
-	return c.client.WriteAPIBlocking(c.cfg.Org, c.cfg.Bucket)
+	return client, nil
 }
 
-// QueryAPI returns the query API bound to the configured org.
-func (c *Client) QueryAPI() api.QueryAPI {
-	return c.client.QueryAPI(c.cfg.Org)
+// WriteAPI returns a blocking write API bound to the configured org and bucket.
+// It follows Reconnect, so callers may keep it for the life of the client.
+func (c *Client) WriteAPI() api.WriteAPIBlocking {
+	return writeAPI{c: c}
 }
 
-// Config exposes the immutable client configuration.
+// Config returns the configuration of the current connection.
 func (c *Client) Config() Config {
-	return c.cfg
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn.cfg
 }
 
-// RecentSensorReadings fetches the newest sensor values within the provided lookback window.
+// RecentSensorReadings fetches the newest sensor values within the provided
+// lookback window; a non-positive lookback uses Config.DefaultLookback.
 func (c *Client) RecentSensorReadings(ctx context.Context, measurement string, lookback time.Duration, limit int) ([]SensorReading, error) {
-	return c.recentSensorReadings(ctx, measurement, nil, lookback, limit)
+	return c.recentSensorReadings(ctx, c.Config().Bucket, measurement, nil, lookback, limit)
+}
+
+// RecentSensorReadingsFromBucket is RecentSensorReadings against the named bucket.
+func (c *Client) RecentSensorReadingsFromBucket(ctx context.Context, bucketName, measurement string, lookback time.Duration, limit int) ([]SensorReading, error) {
+	bucket, err := c.ResolveBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return c.recentSensorReadings(ctx, bucket, measurement, nil, lookback, limit)
 }
 
 // RecentSensorReadingsByMachine fetches filtered data for a specific machine tag.
@@ -116,7 +206,7 @@ func (c *Client) RecentSensorReadingsByMachine(ctx context.Context, measurement,
 		return nil, fmt.Errorf("machine name is required")
 	}
 	f := map[string]string{"machine_name": machineName}
-	return c.recentSensorReadings(ctx, measurement, f, lookback, limit)
+	return c.recentSensorReadings(ctx, c.Config().Bucket, measurement, f, lookback, limit)
 }
 
 // RecentSensorReadingsByMachineAndSensor fetches filtered data for a specific machine and sensor.
@@ -131,110 +221,292 @@ func (c *Client) RecentSensorReadingsByMachineAndSensor(ctx context.Context, mea
 		"machine_name": machineName,
 		"sensor_name":  sensorName,
 	}
-	return c.recentSensorReadings(ctx, measurement, f, lookback, limit)
+	return c.recentSensorReadings(ctx, c.Config().Bucket, measurement, f, lookback, limit)
 }
 
-func (c *Client) recentSensorReadings(ctx context.Context, measurement string, filters map[string]string, lookback time.Duration, limit int) ([]SensorReading, error) {
+func (c *Client) recentSensorReadings(ctx context.Context, bucket, measurement string, filters map[string]string, lookback time.Duration, limit int) ([]SensorReading, error) {
 	if measurement == "" {
 		return nil, fmt.Errorf("measurement is required")
 	}
-	if lookback <= 0 {
-		lookback = time.Hour
+	lookback = c.Config().Lookback(lookback)
+
+	flux := newFluxQuery(bucket).
+		RangeLookback(lookback).
+		FilterMeasurement(measurement).
+		FilterFields("value", "status").
+		FilterTags(filters).
+		PivotFields().
+		Sort("_time", true).
+		Limit(limit)
+
+	return c.querySensorReadings(ctx, flux.String(), limit)
+}
+
+// smoothingStep is how often smoothed readings are sampled; it matches the
+// simulator's default tick so smoothed series keep the raw point density.
+const smoothingStep = time.Second
+
+// SensorReadingsSince fetches sensor values recorded after the provided start
+// timestamp, or within Config.DefaultLookback for a zero start. A positive
+// smooth replaces raw values with a per-sensor moving average over that period,
+// sampled every smoothingStep up to the last complete step. A positive limit
+// returns only the oldest limit readings across all series.
+func (c *Client) SensorReadingsSince(ctx context.Context, measurement string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]SensorReading, error) {
+	return c.sensorReadingsSince(ctx, c.Config().Bucket, measurement, nil, start, filters, smooth, limit)
+}
+
+// SensorReadingsSinceFromBucket is SensorReadingsSince against the named bucket.
+func (c *Client) SensorReadingsSinceFromBucket(ctx context.Context, bucketName, measurement string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]SensorReading, error) {
+	bucket, err := c.ResolveBucket(bucketName)
+	if err != nil {
+		return nil, err
 	}
+	return c.sensorReadingsSince(ctx, bucket, measurement, nil, start, filters, smooth, limit)
+}
 
-	flux := fmt.Sprintf(`from(bucket: %q)
-|> range(start: -%s)
-|> filter(fn: (r) => r["_measurement"] == %q)
-|> filter(fn: (r) => r["_field"] == "value")`, c.cfg.Bucket, toFluxDuration(lookback), measurement)
+// SensorReadingsSinceForMachines is SensorReadingsSinceFromBucket for readings
+// of any of machines in one query. With a positive limit the machines' readings
+// are interleaved oldest first; callers demultiplex on MachineName.
+func (c *Client) SensorReadingsSinceForMachines(ctx context.Context, bucketName, measurement string, machines []string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]SensorReading, error) {
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("at least one machine is required")
+	}
+	bucket, err := c.ResolveBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return c.sensorReadingsSince(ctx, bucket, measurement, machines, start, filters, smooth, limit)
+}
 
-	for key, value := range filters {
-		flux = fmt.Sprintf("%s\n|> filter(fn: (r) => r[%q] == %s)", flux, key, fluxStringLiteral(value))
+// sensorReadingsSince narrows to machines when it is non-empty, on top of filters.
+func (c *Client) sensorReadingsSince(ctx context.Context, bucket, measurement string, machines []string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]SensorReading, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
 	}
+	if start.IsZero() {
+		start = time.Now().Add(-c.Config().Lookback(0))
+	}
+
+	if smooth <= 0 {
+		flux := newFluxQuery(bucket).
+			Range(start, time.Time{}).
+			FilterMeasurement(measurement).
+			FilterFields("value", "status").
+			FilterTagIn("machine_name", machines).
+			FilterTags(filters).
+			PivotFields()
+		return c.querySensorReadings(ctx, oldestFirst(flux, limit).String(), limit)
+	}
+
+	every := min(smooth, smoothingStep)
+	stop := time.Now().Truncate(every)
+	if !start.Before(stop) {
+		return nil, nil
+	}
+	// Read one period before start so the first averages cover a full window.
+	flux := newFluxQuery(bucket).
+		Range(start.Add(-smooth), stop).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTagIn("machine_name", machines).
+		FilterTags(filters).
+		Group("_measurement", "_field", "machine_name", "sensor_name").
+		TimedMovingAverage(every, smooth).
+		FilterTimeFrom(start)
+
+	return c.querySensorReadings(ctx, oldestFirst(flux, limit).String(), limit)
+}
 
-	flux += "\n|> sort(columns: [\"_time\"], desc: true)"
+// oldestFirst sorts each series by time. With a positive limit the series are
+// merged first so the limit keeps the oldest rows overall rather than per series.
+func oldestFirst(flux *fluxQueryBuilder, limit int) *fluxQueryBuilder {
 	if limit > 0 {
-		flux = fmt.Sprintf("%s\n|> limit(n:%d)", flux, limit)
+		flux = flux.Group()
+	}
+	return flux.Sort("_time", false).Limit(limit)
+}
+
+// SensorReadingsBetween returns up to perSensor of the newest readings of each
+// sensor on a machine within [start, stop), newest first within each sensor. A
+// non-positive perSensor returns every reading.
+func (c *Client) SensorReadingsBetween(ctx context.Context, measurement, machineName string, start, stop time.Time, perSensor int) ([]SensorReading, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
 	}
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterFields("value", "status").
+		FilterTag("machine_name", machineName).
+		PivotFields().
+		Sort("_time", true).
+		Limit(perSensor)
 
-	result, err := c.QueryAPI().Query(ctx, flux)
+	return c.querySensorReadings(ctx, flux.String(), 0)
+}
+
+// MeanBySensor returns the mean value per sensor_name for a machine within [start, stop].
+func (c *Client) MeanBySensor(ctx context.Context, measurement, machineName string, start, stop time.Time) (map[string]float64, error) {
+	return c.aggregateBySensor(ctx, c.Config().Bucket, measurement, machineName, start, stop, (*fluxQueryBuilder).Mean)
+}
+
+// MeanBySensorFromBucket is MeanBySensor against the named bucket.
+func (c *Client) MeanBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (map[string]float64, error) {
+	bucket, err := c.ResolveBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return c.aggregateBySensor(ctx, bucket, measurement, machineName, start, stop, (*fluxQueryBuilder).Mean)
+}
+
+// MinMaxBySensor returns the lowest and highest value per sensor_name for a machine
+// within [start, stop].
+func (c *Client) MinMaxBySensor(ctx context.Context, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error) {
+	return c.minMaxBySensor(ctx, c.Config().Bucket, measurement, machineName, start, stop)
+}
+
+// MinMaxBySensorFromBucket is MinMaxBySensor against the named bucket.
+func (c *Client) MinMaxBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error) {
+	bucket, err := c.ResolveBucket(bucketName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.minMaxBySensor(ctx, bucket, measurement, machineName, start, stop)
+}
+
+func (c *Client) minMaxBySensor(ctx context.Context, bucket, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error) {
+	mins, err = c.aggregateBySensor(ctx, bucket, measurement, machineName, start, stop, (*fluxQueryBuilder).Min)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxs, err = c.aggregateBySensor(ctx, bucket, measurement, machineName, start, stop, (*fluxQueryBuilder).Max)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mins, maxs, nil
+}
+
+// aggregateBySensor groups a machine's values by sensor_name and applies reduce to
+// each group. On an iteration error the values read so far are returned with it.
+func (c *Client) aggregateBySensor(ctx context.Context, bucket, measurement, machineName string, start, stop time.Time, reduce func(*fluxQueryBuilder) *fluxQueryBuilder) (map[string]float64, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+
+	flux := newFluxQuery(bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTag("machine_name", machineName).
+		Group("sensor_name")
+	flux = reduce(flux).Keep("sensor_name", "_value")
+
+	result, err := c.query(ctx, flux.String())
 	if err != nil {
-		return nil, fmt.Errorf("query influx: %w", err)
+		return nil, fmt.Errorf("query influx: %w", classifyError(err))
 	}
 	defer result.Close()
 
-	readings := make([]SensorReading, 0, max(limit, 0))
+	values := map[string]float64{}
 	for result.Next() {
 		record := result.Record()
-		value, ok := record.Value().(float64)
+		value, ok := toFloat(record.Value())
 		if !ok {
-			switch v := record.Value().(type) {
-			case int64:
-				value = float64(v)
-			case uint64:
-				value = float64(v)
-			default:
-				continue
-			}
+			continue
+		}
+		if name := stringify(record.ValueByKey("sensor_name")); name != "" {
+			values[name] = value
 		}
-
-		readings = append(readings, SensorReading{
-			Time:        record.Time(),
-			MachineName: stringify(record.ValueByKey("machine_name")),
-			SensorName:  stringify(record.ValueByKey("sensor_name")),
-			Status:      stringify(record.ValueByKey("status")),
-			Value:       value,
-		})
 	}
-
 	if err := result.Err(); err != nil {
-		return nil, fmt.Errorf("iterate influx result: %w", err)
+		return values, fmt.Errorf("iterate influx result: %w", err)
 	}
-
-	return readings, nil
+	return values, nil
 }
 
-// SensorReadingsSince fetches sensor values recorded after the provided start timestamp.
-func (c *Client) SensorReadingsSince(ctx context.Context, measurement string, start time.Time, filters map[string]string, limit int) ([]SensorReading, error) {
+// LatestPerSensor returns the most recent reading of every sensor on a machine,
+// however old it is. Sensors are ordered by name; a machine without data yields
+// an empty slice.
+func (c *Client) LatestPerSensor(ctx context.Context, measurement, machineName string) ([]SensorReading, error) {
 	if measurement == "" {
 		return nil, fmt.Errorf("measurement is required")
 	}
-	if start.IsZero() {
-		start = time.Now().Add(-time.Hour)
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
 	}
 
-	flux := fmt.Sprintf(`from(bucket: %q)
-|> range(start: time(v: %q))
-|> filter(fn: (r) => r["_measurement"] == %q)
-|> filter(fn: (r) => r["_field"] == "value")`, c.cfg.Bucket, start.UTC().Format(time.RFC3339Nano), measurement)
+	// group() merges series without ordering rows, so sort by time before last().
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(time.Unix(0, 0), time.Time{}).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTag("machine_name", machineName).
+		Group("sensor_name").
+		Sort("_time", false).
+		Last().
+		Group().
+		Sort("sensor_name", false)
+
+	return c.querySensorReadings(ctx, flux.String(), 0)
+}
 
-	for key, value := range filters {
-		flux = fmt.Sprintf("%s\n|> filter(fn: (r) => r[%q] == %s)", flux, key, fluxStringLiteral(value))
+// LatestPerSensorForMachines is LatestPerSensor for several machines in one
+// query, ordered by machine and then sensor.
+func (c *Client) LatestPerSensorForMachines(ctx context.Context, measurement string, machines []string) ([]SensorReading, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("at least one machine is required")
 	}
 
-	flux += "\n|> sort(columns: [\"_time\"])"
-	if limit > 0 {
-		flux = fmt.Sprintf("%s\n|> limit(n:%d)", flux, limit)
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(time.Unix(0, 0), time.Time{}).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTagIn("machine_name", machines).
+		Group("machine_name", "sensor_name").
+		Sort("_time", false).
+		Last().
+		Group()
+
+	readings, err := c.querySensorReadings(ctx, flux.String(), 0)
+	if err != nil {
+		return nil, err
 	}
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].MachineName != readings[j].MachineName {
+			return readings[i].MachineName < readings[j].MachineName
+		}
+		return readings[i].SensorName < readings[j].SensorName
+	})
+	return readings, nil
+}
 
-	result, err := c.QueryAPI().Query(ctx, flux)
+// querySensorReadings reads one SensorReading per row. Queries that pivot the
+// value and status fields into columns yield readings with a Status; the others
+// read the value from _value and leave Status empty.
+func (c *Client) querySensorReadings(ctx context.Context, flux string, limit int) ([]SensorReading, error) {
+	result, err := c.query(ctx, flux)
 	if err != nil {
-		return nil, fmt.Errorf("query influx: %w", err)
+		return nil, fmt.Errorf("query influx: %w", classifyError(err))
 	}
 	defer result.Close()
 
 	readings := make([]SensorReading, 0, max(limit, 0))
 	for result.Next() {
 		record := result.Record()
-		value, ok := record.Value().(float64)
+		raw, pivoted := record.Values()["value"]
+		if !pivoted {
+			raw = record.Value()
+		}
+		value, ok := toFloat(raw)
 		if !ok {
-			switch v := record.Value().(type) {
-			case int64:
-				value = float64(v)
-			case uint64:
-				value = float64(v)
-			default:
-				continue
-			}
+			continue
 		}
 
 		readings = append(readings, SensorReading{
@@ -255,19 +527,26 @@ func (c *Client) SensorReadingsSince(ctx context.Context, measurement string, st
 
 // Ping checks the InfluxDB availability using the wrapped client.
 func (c *Client) Ping(ctx context.Context) error {
-	ok, err := c.client.Ping(ctx)
+	if !c.Connected() {
+		return fmt.Errorf("influxdb connection not established: %w", ErrInfluxUnreachable)
+	}
+	conn, release := c.acquire()
+	defer release()
+	ok, err := conn.client.Ping(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrInfluxUnreachable, err)
 	}
 	if !ok {
-		return fmt.Errorf("influxdb ping failed")
+		return fmt.Errorf("influxdb ping failed: %w", ErrInfluxUnreachable)
 	}
 	return nil
 }
 
 // Close releases resources held by the underlying client.
 func (c *Client) Close() {
-	c.client.Close()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.conn.client.Close()
 }
 
 func toFluxDuration(d time.Duration) string {
@@ -294,10 +573,17 @@ func stringify(v interface{}) string {
 	return fmt.Sprint(v)
 }
 
-func fluxStringLiteral(s string) string {
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	return fmt.Sprintf("\"%s\"", s)
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int64:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	default:
+		return 0, false
+	}
 }
 
 func max(a, b int) int {
@@ -0,0 +1,112 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pivotedReadings is the annotated CSV InfluxDB returns for a query that
+// pivots the value and status fields into columns. The second Pressure row has
+// no status field, as the simulator omits it when empty.
+const pivotedReadings = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,string,string,string,double,string
+#group,false,false,true,true,false,true,true,true,false,false
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_measurement,machine_name,sensor_name,value,status
+,,0,2024-06-15T11:00:00Z,2024-06-15T12:00:00Z,2024-06-15T11:59:50Z,sensor_data,Oven-01,Pressure,0.4,down
+,,0,2024-06-15T11:00:00Z,2024-06-15T12:00:00Z,2024-06-15T11:59:40Z,sensor_data,Oven-01,Pressure,3.2,
+,,1,2024-06-15T11:00:00Z,2024-06-15T12:00:00Z,2024-06-15T11:59:50Z,sensor_data,Oven-01,Temperature,180.5,running
+
+`
+
+// queryServer answers /api/v2/query with csv and records the Flux it was sent.
+func queryServer(t *testing.T, csv string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/query" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		queries = append(queries, body.Query)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		fmt.Fprint(w, csv)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestSensorReadingsCarryStatus(t *testing.T) {
+	srv, queries := queryServer(t, pivotedReadings)
+	client := NewUnverified(Config{URL: srv.URL, Token: "token", Org: "org", Bucket: "sensors", Timeout: 5 * time.Second})
+	defer client.Close()
+	ctx := context.Background()
+	stop := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name  string
+		fetch func() ([]SensorReading, error)
+	}{
+		{"recent by machine", func() ([]SensorReading, error) {
+			return client.RecentSensorReadingsByMachine(ctx, "sensor_data", "Oven-01", time.Hour, 5)
+		}},
+		{"between", func() ([]SensorReading, error) {
+			return client.SensorReadingsBetween(ctx, "sensor_data", "Oven-01", stop.Add(-time.Hour), stop, 5)
+		}},
+		{"since", func() ([]SensorReading, error) {
+			return client.SensorReadingsSince(ctx, "sensor_data", stop.Add(-time.Hour), map[string]string{"machine_name": "Oven-01"}, 0, 5)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, err := tt.fetch()
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			want := []SensorReading{
+				{Time: stop.Add(-10 * time.Second), MachineName: "Oven-01", SensorName: "Pressure", Status: "down", Value: 0.4},
+				{Time: stop.Add(-20 * time.Second), MachineName: "Oven-01", SensorName: "Pressure", Value: 3.2},
+				{Time: stop.Add(-10 * time.Second), MachineName: "Oven-01", SensorName: "Temperature", Status: "running", Value: 180.5},
+			}
+			if len(readings) != len(want) {
+				t.Fatalf("got %d readings, want %d: %+v", len(readings), len(want), readings)
+			}
+			for i := range want {
+				got := readings[i]
+				if !got.Time.Equal(want[i].Time) || got.MachineName != want[i].MachineName || got.SensorName != want[i].SensorName ||
+					got.Status != want[i].Status || got.Value != want[i].Value {
+					t.Errorf("reading %d = %+v, want %+v", i, got, want[i])
+				}
+			}
+
+			sent := queries()
+			flux := sent[len(sent)-1]
+			for _, part := range []string{`r["_field"] == "value" or r["_field"] == "status"`, "pivot(rowKey: [\"_time\"]"} {
+				if !strings.Contains(flux, part) {
+					t.Errorf("query does not contain %s:\n%s", part, flux)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	ihttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+var (
+	// ErrInfluxUnauthorized indicates the token was rejected or lacks permission.
+	ErrInfluxUnauthorized = errors.New("influxdb rejected the token")
+	// ErrInfluxUnreachable indicates the server could not be contacted.
+	ErrInfluxUnreachable = errors.New("influxdb is unreachable")
+	// ErrInfluxBucketNotFound indicates the configured bucket does not exist in the org.
+	ErrInfluxBucketNotFound = errors.New("influxdb bucket not found")
+)
+
+// classifyError wraps err with the matching sentinel error, or returns it unchanged
+// when the failure is not one of the known kinds.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if kind := errorKind(err); kind != nil {
+		return fmt.Errorf("%w: %v", kind, err)
+	}
+	return err
+}
+
+func errorKind(err error) error {
+	var httpErr *ihttp.Error
+	if errors.As(err, &httpErr) && httpErr.StatusCode != 0 {
+		switch httpErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrInfluxUnauthorized
+		case http.StatusNotFound:
+			return ErrInfluxBucketNotFound
+		}
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrInfluxUnreachable
+	}
+
+	// The generated management API flattens server errors into plain messages
+	// such as "unauthorized: ..." or "401 Unauthorized: ...".
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.HasPrefix(msg, "unauthorized"), strings.HasPrefix(msg, "forbidden"),
+		strings.HasPrefix(msg, "401 "), strings.HasPrefix(msg, "403 "):
+		return ErrInfluxUnauthorized
+	case strings.HasPrefix(msg, "not found"), strings.HasPrefix(msg, "404 "):
+		return ErrInfluxBucketNotFound
+	}
+	return nil
+}
+
+// IsQueryError reports whether err is InfluxDB rejecting or failing a query
+// itself, such as invalid Flux, rather than a connection, permission or
+// cancellation failure that rerunning a corrected query would not fix. It
+// accepts errors the client already classified.
+func IsQueryError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	for _, kind := range []error{ErrInfluxUnauthorized, ErrInfluxUnreachable, ErrInfluxBucketNotFound} {
+		if errors.Is(err, kind) {
+			return false
+		}
+	}
+	return errorKind(err) == nil
+}
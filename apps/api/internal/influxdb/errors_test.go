@@ -0,0 +1,162 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	ihttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+// influxServer answers /ping and replies to the bucket lookup with status and
+// body, as InfluxDB 2 does.
+func influxServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ping":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/buckets":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDialClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{
+			name:   "token rejected",
+			status: http.StatusUnauthorized,
+			body:   `{"code":"unauthorized","message":"unauthorized access"}`,
+			want:   ErrInfluxUnauthorized,
+		},
+		{
+			name:   "token lacks permission",
+			status: http.StatusForbidden,
+			body:   `{"code":"forbidden","message":"insufficient permissions for read:buckets"}`,
+			want:   ErrInfluxUnauthorized,
+		},
+		{
+			name:   "bucket lookup 404",
+			status: http.StatusNotFound,
+			body:   `{"code":"not found","message":"bucket \"sensors\" not found"}`,
+			want:   ErrInfluxBucketNotFound,
+		},
+		{
+			name:   "no bucket with the name",
+			status: http.StatusOK,
+			body:   `{"buckets":[]}`,
+			want:   ErrInfluxBucketNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := influxServer(t, tt.status, tt.body)
+			_, err := dial(context.Background(), Config{URL: srv.URL, Token: "token", Org: "org", Bucket: "sensors", Timeout: 5 * time.Second})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("dial error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialFindsBucket(t *testing.T) {
+	srv := influxServer(t, http.StatusOK, `{"buckets":[{"id":"0123456789abcdef","orgID":"0123456789abcdef","name":"sensors","retentionRules":[]}]}`)
+	client, err := dial(context.Background(), Config{URL: srv.URL, Token: "token", Org: "org", Bucket: "sensors", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	client.Close()
+}
+
+func TestDialUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+	_, err := dial(context.Background(), Config{URL: addr, Token: "token", Bucket: "sensors", Timeout: time.Second})
+	if !errors.Is(err, ErrInfluxUnreachable) {
+		t.Fatalf("dial error = %v, want ErrInfluxUnreachable", err)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	plain := errors.New("invalid flux: expected RPAREN")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"http 401", &ihttp.Error{StatusCode: http.StatusUnauthorized, Code: "unauthorized"}, ErrInfluxUnauthorized},
+		{"http 403", &ihttp.Error{StatusCode: http.StatusForbidden, Code: "forbidden"}, ErrInfluxUnauthorized},
+		{"http 404", &ihttp.Error{StatusCode: http.StatusNotFound, Code: "not found"}, ErrInfluxBucketNotFound},
+		{"flattened 401", errors.New("401 Unauthorized: unauthorized access"), ErrInfluxUnauthorized},
+		{"flattened not found", errors.New("not found: bucket \"sensors\" not found"), ErrInfluxBucketNotFound},
+		{"connection refused", &url.Error{Op: "Get", URL: "http://influx:8086/ping", Err: errors.New("connection refused")}, ErrInfluxUnreachable},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), ErrInfluxUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); !errors.Is(got, tt.want) {
+				t.Errorf("classifyError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("query error", func(t *testing.T) {
+		bad := &ihttp.Error{StatusCode: http.StatusBadRequest, Code: "invalid", Message: "compilation failed"}
+		if got := classifyError(bad); got != error(bad) {
+			t.Errorf("classifyError(400) = %v, want it unchanged", got)
+		}
+		if got := classifyError(plain); got != plain {
+			t.Errorf("classifyError(%v) = %v, want it unchanged", plain, got)
+		}
+	})
+
+	if classifyError(nil) != nil {
+		t.Error("classifyError(nil) != nil")
+	}
+	if strings.Contains(classifyError(plain).Error(), "influxdb") {
+		t.Error("an unclassified error was wrapped")
+	}
+}
+
+func TestIsQueryError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"invalid flux", &ihttp.Error{StatusCode: http.StatusBadRequest, Code: "invalid", Message: "compilation failed"}, true},
+		{"runtime failure", errors.New("runtime error @1:1-1:20: from: bucket not specified"), true},
+		{"canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"raw 401", &ihttp.Error{StatusCode: http.StatusUnauthorized}, false},
+		{"classified 401", fmt.Errorf("query influx: %w", classifyError(&ihttp.Error{StatusCode: http.StatusUnauthorized})), false},
+		{"classified 404", fmt.Errorf("query influx: %w", classifyError(&ihttp.Error{StatusCode: http.StatusNotFound})), false},
+		{"classified unreachable", fmt.Errorf("query influx: %w", classifyError(&url.Error{Op: "Post", URL: "http://influx:8086", Err: errors.New("connection refused")})), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsQueryError(tt.err); got != tt.want {
+				t.Errorf("IsQueryError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package influxdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fluxQueryBuilder assembles a piped Flux query with consistent literal escaping.
+type fluxQueryBuilder struct {
+	stages []string
+}
+
+// newFluxQuery starts a query reading from bucket.
+func newFluxQuery(bucket string) *fluxQueryBuilder {
+	return &fluxQueryBuilder{stages: []string{fmt.Sprintf("from(bucket: %s)", fluxStringLiteral(bucket))}}
+}
+
+func (b *fluxQueryBuilder) pipe(stage string) *fluxQueryBuilder {
+	b.stages = append(b.stages, stage)
+	return b
+}
+
+// Range bounds the query by absolute timestamps. A zero stop leaves the range open-ended.
+func (b *fluxQueryBuilder) Range(start, stop time.Time) *fluxQueryBuilder {
+	if stop.IsZero() {
+		return b.pipe(fmt.Sprintf("range(start: %s)", fluxTimeLiteral(start)))
+	}
+	return b.pipe(fmt.Sprintf("range(start: %s, stop: %s)", fluxTimeLiteral(start), fluxTimeLiteral(stop)))
+}
+
+// RangeLookback bounds the query to the window ending now.
+func (b *fluxQueryBuilder) RangeLookback(lookback time.Duration) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("range(start: -%s)", toFluxDuration(lookback)))
+}
+
+// FilterMeasurement keeps rows of the given measurement.
+func (b *fluxQueryBuilder) FilterMeasurement(measurement string) *fluxQueryBuilder {
+	return b.FilterTag("_measurement", measurement)
+}
+
+// FilterField keeps rows of the given field.
+func (b *fluxQueryBuilder) FilterField(field string) *fluxQueryBuilder {
+	return b.FilterTag("_field", field)
+}
+
+// FilterFields keeps rows of any of the given fields.
+func (b *fluxQueryBuilder) FilterFields(fields ...string) *fluxQueryBuilder {
+	clauses := make([]string, len(fields))
+	for i, field := range fields {
+		clauses[i] = fmt.Sprintf("r[\"_field\"] == %s", fluxStringLiteral(field))
+	}
+	return b.pipe(fmt.Sprintf("filter(fn: (r) => %s)", strings.Join(clauses, " or ")))
+}
+
+// FilterTag keeps rows whose column key equals value.
+func (b *fluxQueryBuilder) FilterTag(key, value string) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("filter(fn: (r) => r[%s] == %s)", fluxStringLiteral(key), fluxStringLiteral(value)))
+}
+
+// FilterTags applies FilterTag for every entry, in key order so queries are stable.
+func (b *fluxQueryBuilder) FilterTags(tags map[string]string) *fluxQueryBuilder {
+	for _, key := range sortedKeys(tags) {
+		b.FilterTag(key, tags[key])
+	}
+	return b
+}
+
+// FilterTagIn keeps rows whose column key is one of values; an empty values
+// adds no stage.
+func (b *fluxQueryBuilder) FilterTagIn(key string, values []string) *fluxQueryBuilder {
+	if len(values) == 0 {
+		return b
+	}
+	return b.pipe(fmt.Sprintf("filter(fn: (r) => contains(value: r[%s], set: %s))", fluxStringLiteral(key), fluxStringArray(values)))
+}
+
+// FilterTimeFrom keeps rows at or after start.
+func (b *fluxQueryBuilder) FilterTimeFrom(start time.Time) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("filter(fn: (r) => r._time >= %s)", fluxTimeLiteral(start)))
+}
+
+// Group regroups rows by the given columns.
+func (b *fluxQueryBuilder) Group(columns ...string) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("group(columns: %s)", fluxStringArray(columns)))
+}
+
+// TimedMovingAverage replaces each table's values with the mean over the
+// trailing period, emitted every step.
+func (b *fluxQueryBuilder) TimedMovingAverage(every, period time.Duration) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("timedMovingAverage(every: %s, period: %s)", toFluxDuration(every), toFluxDuration(period)))
+}
+
+// AggregateWindow replaces each table's values with fn applied over fixed
+// windows of length every. Empty windows are dropped rather than filled.
+func (b *fluxQueryBuilder) AggregateWindow(every time.Duration, fn string) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("aggregateWindow(every: %s, fn: %s, createEmpty: false)", toFluxDuration(every), fn))
+}
+
+// Mean reduces each table to its mean value.
+func (b *fluxQueryBuilder) Mean() *fluxQueryBuilder {
+	return b.pipe("mean()")
+}
+
+// Min reduces each table to its row with the lowest value.
+func (b *fluxQueryBuilder) Min() *fluxQueryBuilder {
+	return b.pipe("min()")
+}
+
+// Max reduces each table to its row with the highest value.
+func (b *fluxQueryBuilder) Max() *fluxQueryBuilder {
+	return b.pipe("max()")
+}
+
+// Count reduces each table to its number of rows.
+func (b *fluxQueryBuilder) Count() *fluxQueryBuilder {
+	return b.pipe("count()")
+}
+
+// First reduces each table to its first row.
+func (b *fluxQueryBuilder) First() *fluxQueryBuilder {
+	return b.pipe("first()")
+}
+
+// Last reduces each table to its final row.
+func (b *fluxQueryBuilder) Last() *fluxQueryBuilder {
+	return b.pipe("last()")
+}
+
+// ElapsedOver keeps the rows that follow the previous row of their table by
+// more than d, with that span in milliseconds in an "elapsed" column. The first
+// row of each table has no predecessor and is dropped.
+func (b *fluxQueryBuilder) ElapsedOver(d time.Duration) *fluxQueryBuilder {
+	b.pipe("elapsed(unit: 1ms)")
+	return b.pipe(fmt.Sprintf("filter(fn: (r) => r.elapsed > %d)", d.Milliseconds()))
+}
+
+// Duplicate copies column into a new column named as.
+func (b *fluxQueryBuilder) Duplicate(column, as string) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("duplicate(column: %s, as: %s)", fluxStringLiteral(column), fluxStringLiteral(as)))
+}
+
+// PivotFields turns each field into a column, one row per timestamp and series.
+func (b *fluxQueryBuilder) PivotFields() *fluxQueryBuilder {
+	return b.pipe(`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`)
+}
+
+// Keep drops every column not listed.
+func (b *fluxQueryBuilder) Keep(columns ...string) *fluxQueryBuilder {
+	return b.pipe(fmt.Sprintf("keep(columns: %s)", fluxStringArray(columns)))
+}
+
+// Sort orders rows by column.
+func (b *fluxQueryBuilder) Sort(column string, desc bool) *fluxQueryBuilder {
+	if desc {
+		return b.pipe(fmt.Sprintf("sort(columns: [%s], desc: true)", fluxStringLiteral(column)))
+	}
+	return b.pipe(fmt.Sprintf("sort(columns: [%s])", fluxStringLiteral(column)))
+}
+
+// Limit caps the number of rows per table; non-positive values are ignored.
+func (b *fluxQueryBuilder) Limit(n int) *fluxQueryBuilder {
+	if n <= 0 {
+		return b
+	}
+	return b.pipe(fmt.Sprintf("limit(n:%d)", n))
+}
+
+// String renders the query.
+func (b *fluxQueryBuilder) String() string {
+	return strings.Join(b.stages, "\n|> ")
+}
+
+func fluxTimeLiteral(t time.Time) string {
+	return fmt.Sprintf("time(v: %s)", fluxStringLiteral(t.UTC().Format(time.RFC3339Nano)))
+}
+
+func fluxStringArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fluxStringLiteral(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func fluxStringLiteral(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	s = strings.ReplaceAll(s, "\r", "\\r")
+	s = strings.ReplaceAll(s, "\t", "\\t")
+	s = strings.ReplaceAll(s, "${", "\\${")
+	return fmt.Sprintf("\"%s\"", s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Gap is a span with no readings from a sensor.
+type Gap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration is the length of the gap.
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// SensorGaps lists the gaps of one sensor within a range. First and Last are
+// its first and last readings in the range.
+type SensorGaps struct {
+	SensorName string
+	First      time.Time
+	Last       time.Time
+	Gaps       []Gap
+}
+
+// SensorGaps finds, per sensor of a machine, the spans within [start, stop)
+// longer than threshold without a "value" reading: between consecutive
+// readings, from start to the first reading and from the last reading to stop
+// (or now, when stop is in the future). Gaps are in time order and sensors in
+// name order; sensors without readings in the range are not listed. The
+// differences are taken in Influx with elapsed(), so only gaps and each
+// sensor's first and last readings are transferred.
+func (c *Client) SensorGaps(ctx context.Context, measurement, machineName string, start, stop time.Time, threshold time.Duration) ([]SensorGaps, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+	if threshold <= 0 {
+		return nil, fmt.Errorf("threshold must be positive")
+	}
+
+	base := func() *fluxQueryBuilder {
+		return newFluxQuery(c.Config().Bucket).
+			Range(start, stop).
+			FilterMeasurement(measurement).
+			FilterField("value").
+			FilterTag("machine_name", machineName).
+			Group("sensor_name").
+			Keep("_time", "_value", "sensor_name")
+	}
+
+	bySensor := map[string]*SensorGaps{}
+	for _, reduce := range []struct {
+		flux  *fluxQueryBuilder
+		first bool
+	}{{base().First(), true}, {base().Last(), false}} {
+		err := c.eachSensorRow(ctx, reduce.flux.String(), func(sensor string, at time.Time, _ int64) {
+			entry, ok := bySensor[sensor]
+			if !ok {
+				entry = &SensorGaps{SensorName: sensor}
+				bySensor[sensor] = entry
+			}
+			if reduce.first {
+				entry.First = at
+			} else {
+				entry.Last = at
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	inner := map[string][]Gap{}
+	err := c.eachSensorRow(ctx, base().Sort("_time", false).ElapsedOver(threshold).String(), func(sensor string, at time.Time, elapsedMs int64) {
+		inner[sensor] = append(inner[sensor], Gap{Start: at.Add(-time.Duration(elapsedMs) * time.Millisecond), End: at})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	end := stop
+	if now := time.Now(); end.IsZero() || end.After(now) {
+		end = now
+	}
+	result := make([]SensorGaps, 0, len(bySensor))
+	for sensor, entry := range bySensor {
+		if lead := (Gap{Start: start, End: entry.First}); lead.Duration() > threshold {
+			entry.Gaps = append(entry.Gaps, lead)
+		}
+		entry.Gaps = append(entry.Gaps, inner[sensor]...)
+		if trail := (Gap{Start: entry.Last, End: end}); trail.Duration() > threshold {
+			entry.Gaps = append(entry.Gaps, trail)
+		}
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].SensorName < result[j].SensorName })
+	return result, nil
+}
+
+// eachSensorRow runs a query over rows keyed by sensor_name and calls fn with
+// each row's sensor, time and "elapsed" column (zero when absent).
+func (c *Client) eachSensorRow(ctx context.Context, flux string, fn func(sensor string, at time.Time, elapsed int64)) error {
+	result, err := c.query(ctx, flux)
+	if err != nil {
+		return fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+	for result.Next() {
+		record := result.Record()
+		elapsed, _ := record.ValueByKey("elapsed").(int64)
+		fn(stringify(record.ValueByKey("sensor_name")), record.Time(), elapsed)
+	}
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("iterate influx result: %w", err)
+	}
+	return nil
+}
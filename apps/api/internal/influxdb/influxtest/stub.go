@@ -0,0 +1,518 @@
+// Package influxtest provides a canned-data stand-in for influxdb.Client, so
+// the completion detector and the HTTP handlers can be tested without InfluxDB.
+package influxtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+)
+
+// Stub answers the influxdb.Client query methods from Readings. Every reading
+// is treated as belonging to the requested measurement and bucket, smoothing
+// and downsampling are not applied, and Flux queries return the canned
+// FluxRecords or FluxRaw. Err, when set, fails every query. The zero value
+// serves no readings; Stub is safe for concurrent use.
+type Stub struct {
+	mu sync.Mutex
+
+	// Readings is the sensor history, in any order. Status doubles as the
+	// written "status" field for StatusChanges and MachineUtilization.
+	Readings []influxdb.SensorReading
+	// Cfg is returned by Config and replaced by Reconnect.
+	Cfg influxdb.Config
+	// Now anchors lookback queries; it defaults to time.Now.
+	Now func() time.Time
+
+	FluxRecords []map[string]any
+	FluxRaw     string
+	// Queries records every Flux query passed to QueryRaw and QueryRecords.
+	Queries []string
+
+	Err     error
+	PingErr error
+}
+
+// New returns a Stub serving readings.
+func New(readings ...influxdb.SensorReading) *Stub {
+	return &Stub{Readings: readings}
+}
+
+// Add appends readings, as the simulator writing new points would.
+func (s *Stub) Add(readings ...influxdb.SensorReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Readings = append(s.Readings, readings...)
+}
+
+func (s *Stub) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// selectReadings returns the readings matching filters within [start, stop),
+// oldest first. A zero stop is open-ended.
+func (s *Stub) selectReadings(filters map[string]string, start, stop time.Time) ([]influxdb.SensorReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var selected []influxdb.SensorReading
+	for _, r := range s.Readings {
+		if r.Time.Before(start) || (!stop.IsZero() && !r.Time.Before(stop)) {
+			continue
+		}
+		if v, ok := filters["machine_name"]; ok && r.MachineName != v {
+			continue
+		}
+		if v, ok := filters["sensor_name"]; ok && r.SensorName != v {
+			continue
+		}
+		selected = append(selected, r)
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Time.Before(selected[j].Time) })
+	return selected, nil
+}
+
+func machineFilter(machineName string) (map[string]string, error) {
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+	return map[string]string{"machine_name": machineName}, nil
+}
+
+// newestPerSensor keeps up to n of each sensor's newest readings, newest first
+// within each sensor. A non-positive n keeps every reading.
+func newestPerSensor(readings []influxdb.SensorReading, n int) []influxdb.SensorReading {
+	kept := map[string]int{}
+	var out []influxdb.SensorReading
+	for i := len(readings) - 1; i >= 0; i-- {
+		r := readings[i]
+		key := r.MachineName + "\x00" + r.SensorName
+		if n > 0 && kept[key] >= n {
+			continue
+		}
+		kept[key]++
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].MachineName != out[j].MachineName {
+			return out[i].MachineName < out[j].MachineName
+		}
+		return out[i].SensorName < out[j].SensorName
+	})
+	return out
+}
+
+// Ping returns PingErr.
+func (s *Stub) Ping(ctx context.Context) error {
+	return s.PingErr
+}
+
+// Config returns Cfg.
+func (s *Stub) Config() influxdb.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Cfg
+}
+
+// Reconnect replaces Cfg.
+func (s *Stub) Reconnect(ctx context.Context, cfg influxdb.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Cfg = cfg
+	return nil
+}
+
+// ResolveBucket maps a name through Cfg.Buckets like the real client.
+func (s *Stub) ResolveBucket(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	cfg := s.Config()
+	if name == "" {
+		return cfg.Bucket, nil
+	}
+	if bucket, ok := cfg.Buckets[name]; ok {
+		return bucket, nil
+	}
+	return "", fmt.Errorf("%w: %q", influxdb.ErrUnknownBucket, name)
+}
+
+// BucketNames lists the names in Cfg.Buckets in order.
+func (s *Stub) BucketNames() []string {
+	buckets := s.Config().Buckets
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecentSensorReadingsByMachine returns up to limit of each sensor's newest
+// readings within lookback of Now, or Cfg's default lookback when it is not positive.
+func (s *Stub) RecentSensorReadingsByMachine(ctx context.Context, measurement, machineName string, lookback time.Duration, limit int) ([]influxdb.SensorReading, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	lookback = s.Config().Lookback(lookback)
+	readings, err := s.selectReadings(filters, s.now().Add(-lookback), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return newestPerSensor(readings, limit), nil
+}
+
+// SensorReadingsSince returns readings from start on, oldest first, capped at
+// limit when positive. A zero start means Cfg's default lookback before Now.
+// smooth is ignored.
+func (s *Stub) SensorReadingsSince(ctx context.Context, measurement string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]influxdb.SensorReading, error) {
+	if start.IsZero() {
+		start = s.now().Add(-s.Config().Lookback(0))
+	}
+	readings, err := s.selectReadings(filters, start, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+// SensorReadingsSinceFromBucket is SensorReadingsSince after resolving the bucket.
+func (s *Stub) SensorReadingsSinceFromBucket(ctx context.Context, bucketName, measurement string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]influxdb.SensorReading, error) {
+	if _, err := s.ResolveBucket(bucketName); err != nil {
+		return nil, err
+	}
+	return s.SensorReadingsSince(ctx, measurement, start, filters, smooth, limit)
+}
+
+// SensorReadingsSinceForMachines is SensorReadingsSinceFromBucket for readings
+// of any of machines.
+func (s *Stub) SensorReadingsSinceForMachines(ctx context.Context, bucketName, measurement string, machines []string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]influxdb.SensorReading, error) {
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("at least one machine is required")
+	}
+	if _, err := s.ResolveBucket(bucketName); err != nil {
+		return nil, err
+	}
+	readings, err := s.selectReadings(filters, start, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	readings = ofMachines(readings, machines)
+	if limit > 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+func ofMachines(readings []influxdb.SensorReading, machines []string) []influxdb.SensorReading {
+	kept := readings[:0]
+	for _, r := range readings {
+		for _, machine := range machines {
+			if r.MachineName == machine {
+				kept = append(kept, r)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// SensorReadingsBetween returns up to perSensor of each sensor's newest
+// readings within [start, stop).
+func (s *Stub) SensorReadingsBetween(ctx context.Context, measurement, machineName string, start, stop time.Time, perSensor int) ([]influxdb.SensorReading, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	return newestPerSensor(readings, perSensor), nil
+}
+
+// LatestPerSensor returns each sensor's newest reading, ordered by sensor.
+func (s *Stub) LatestPerSensor(ctx context.Context, measurement, machineName string) ([]influxdb.SensorReading, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := s.selectReadings(filters, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return newestPerSensor(readings, 1), nil
+}
+
+// LatestPerSensorForMachines returns each sensor's newest reading on any of
+// machines, ordered by machine and then sensor.
+func (s *Stub) LatestPerSensorForMachines(ctx context.Context, measurement string, machines []string) ([]influxdb.SensorReading, error) {
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("at least one machine is required")
+	}
+	readings, err := s.selectReadings(nil, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return newestPerSensor(ofMachines(readings, machines), 1), nil
+}
+
+// MachineTrace returns a machine's readings within [start, stop), oldest
+// first, capped at limit when positive.
+func (s *Stub) MachineTrace(ctx context.Context, measurement, machineName string, start, stop time.Time, limit int) ([]influxdb.SensorReading, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+// SampledReadings returns every reading within [start, stop); buckets is ignored.
+func (s *Stub) SampledReadings(ctx context.Context, measurement string, start, stop time.Time, filters map[string]string, buckets int) ([]influxdb.SensorReading, error) {
+	return s.selectReadings(filters, start, stop)
+}
+
+func (s *Stub) bySensor(machineName string, start, stop time.Time, each func(sensor string, value float64)) error {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return err
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return err
+	}
+	for _, r := range readings {
+		each(r.SensorName, r.Value)
+	}
+	return nil
+}
+
+// MeanBySensor averages each sensor's readings within [start, stop).
+func (s *Stub) MeanBySensor(ctx context.Context, measurement, machineName string, start, stop time.Time) (map[string]float64, error) {
+	sums, counts := map[string]float64{}, map[string]int{}
+	err := s.bySensor(machineName, start, stop, func(sensor string, value float64) {
+		sums[sensor] += value
+		counts[sensor]++
+	})
+	if err != nil {
+		return nil, err
+	}
+	for sensor, n := range counts {
+		sums[sensor] /= float64(n)
+	}
+	return sums, nil
+}
+
+// MeanBySensorFromBucket is MeanBySensor after resolving the bucket.
+func (s *Stub) MeanBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (map[string]float64, error) {
+	if _, err := s.ResolveBucket(bucketName); err != nil {
+		return nil, err
+	}
+	return s.MeanBySensor(ctx, measurement, machineName, start, stop)
+}
+
+// MinMaxBySensor returns each sensor's extremes within [start, stop).
+func (s *Stub) MinMaxBySensor(ctx context.Context, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error) {
+	mins, maxs = map[string]float64{}, map[string]float64{}
+	err = s.bySensor(machineName, start, stop, func(sensor string, value float64) {
+		if lo, ok := mins[sensor]; !ok || value < lo {
+			mins[sensor] = value
+		}
+		if hi, ok := maxs[sensor]; !ok || value > hi {
+			maxs[sensor] = value
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return mins, maxs, nil
+}
+
+// MinMaxBySensorFromBucket is MinMaxBySensor after resolving the bucket.
+func (s *Stub) MinMaxBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error) {
+	if _, err := s.ResolveBucket(bucketName); err != nil {
+		return nil, nil, err
+	}
+	return s.MinMaxBySensor(ctx, measurement, machineName, start, stop)
+}
+
+// StatusChanges returns the points where a sensor's Status differs from its
+// previous reading within [start, stop).
+func (s *Stub) StatusChanges(ctx context.Context, measurement, machineName string, start, stop time.Time) ([]influxdb.StatusChange, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].SensorName < readings[j].SensorName })
+	var changes []influxdb.StatusChange
+	last := map[string]string{}
+	for _, r := range readings {
+		if previous, ok := last[r.SensorName]; ok && previous == r.Status {
+			continue
+		}
+		last[r.SensorName] = r.Status
+		changes = append(changes, influxdb.StatusChange{SensorName: r.SensorName, Status: r.Status, Time: r.Time})
+	}
+	return changes, nil
+}
+
+// MachineUtilization counts a machine's readings by Status within [start, stop).
+func (s *Stub) MachineUtilization(ctx context.Context, measurement, machineName string, start, stop time.Time) (influxdb.Utilization, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return influxdb.Utilization{}, err
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return influxdb.Utilization{}, err
+	}
+	u := influxdb.Utilization{Counts: map[string]int64{}}
+	for _, r := range readings {
+		u.Counts[r.Status]++
+		u.Total++
+		switch r.Status {
+		case influxdb.StatusRunning:
+			u.Running++
+		case influxdb.StatusDown:
+			u.Down++
+		}
+	}
+	return u, nil
+}
+
+// SensorGaps finds each sensor's spans without readings longer than threshold
+// within [start, stop), with stop clipped to Now, as the client does.
+func (s *Stub) SensorGaps(ctx context.Context, measurement, machineName string, start, stop time.Time, threshold time.Duration) ([]influxdb.SensorGaps, error) {
+	filters, err := machineFilter(machineName)
+	if err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		return nil, fmt.Errorf("threshold must be positive")
+	}
+	readings, err := s.selectReadings(filters, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	end := stop
+	if now := s.now(); end.IsZero() || end.After(now) {
+		end = now
+	}
+	bySensor := map[string]*influxdb.SensorGaps{}
+	var names []string
+	for _, r := range readings {
+		entry, ok := bySensor[r.SensorName]
+		if !ok {
+			entry = &influxdb.SensorGaps{SensorName: r.SensorName, First: r.Time}
+			if lead := (influxdb.Gap{Start: start, End: r.Time}); lead.Duration() > threshold {
+				entry.Gaps = append(entry.Gaps, lead)
+			}
+			bySensor[r.SensorName] = entry
+			names = append(names, r.SensorName)
+		} else if gap := (influxdb.Gap{Start: entry.Last, End: r.Time}); gap.Duration() > threshold {
+			entry.Gaps = append(entry.Gaps, gap)
+		}
+		entry.Last = r.Time
+	}
+	sort.Strings(names)
+	result := make([]influxdb.SensorGaps, 0, len(names))
+	for _, name := range names {
+		entry := bySensor[name]
+		if trail := (influxdb.Gap{Start: entry.Last, End: end}); trail.Duration() > threshold {
+			entry.Gaps = append(entry.Gaps, trail)
+		}
+		result = append(result, *entry)
+	}
+	return result, nil
+}
+
+// QueryRaw records flux and returns FluxRaw.
+func (s *Stub) QueryRaw(ctx context.Context, flux string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Queries = append(s.Queries, flux)
+	if s.Err != nil {
+		return "", s.Err
+	}
+	return s.FluxRaw, nil
+}
+
+// QueryRecords records flux and returns up to limit of FluxRecords.
+func (s *Stub) QueryRecords(ctx context.Context, flux string, limit int) ([]map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Queries = append(s.Queries, flux)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	records := s.FluxRecords
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return append([]map[string]any(nil), records...), nil
+}
+
+// CountPoints counts readings within [start, stop).
+func (s *Stub) CountPoints(ctx context.Context, measurement string, start, stop time.Time) (int64, error) {
+	readings, err := s.selectReadings(nil, start, stop)
+	return int64(len(readings)), err
+}
+
+// CountMachinePoints counts a machine's readings within [start, stop).
+func (s *Stub) CountMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) (int64, error) {
+	readings, err := s.selectReadings(map[string]string{"machine_name": machineName}, start, stop)
+	return int64(len(readings)), err
+}
+
+// DeleteMeasurement drops readings within [start, stop].
+func (s *Stub) DeleteMeasurement(ctx context.Context, measurement string, start, stop time.Time) error {
+	return s.deleteWhere(func(r influxdb.SensorReading) bool {
+		return !r.Time.Before(start) && !r.Time.After(stop)
+	})
+}
+
+// DeleteMachinePoints drops a machine's readings within [start, stop].
+func (s *Stub) DeleteMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) error {
+	return s.deleteWhere(func(r influxdb.SensorReading) bool {
+		return r.MachineName == machineName && !r.Time.Before(start) && !r.Time.After(stop)
+	})
+}
+
+func (s *Stub) deleteWhere(match func(influxdb.SensorReading) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	kept := s.Readings[:0]
+	for _, r := range s.Readings {
+		if !match(r) {
+			kept = append(kept, r)
+		}
+	}
+	s.Readings = kept
+	return nil
+}
@@ -0,0 +1,167 @@
+package influxdb
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	api "github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// connection is one underlying client together with the configuration it was
+// dialled with. inflight counts the calls still using it, so a replaced
+// connection is only closed once they finish.
+type connection struct {
+	cfg      Config
+	client   influxdb2.Client
+	inflight sync.WaitGroup
+}
+
+// acquire returns the current connection and a release func the caller must
+// invoke once it no longer needs it.
+func (c *Client) acquire() (*connection, func()) {
+	c.mu.RLock()
+	conn := c.conn
+	conn.inflight.Add(1)
+	c.mu.RUnlock()
+	return conn, conn.inflight.Done
+}
+
+// Reconnect dials cfg, validating it like New, and swaps it in for the current
+// connection. Calls already running finish against the old connection, which
+// is closed in the background once they do. On error the current connection is
+// kept.
+func (c *Client) Reconnect(ctx context.Context, cfg Config) error {
+	client, err := dial(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	next := &connection{cfg: cfg, client: client}
+	if c.batching.Load() {
+		client.WriteAPIBlocking(cfg.Org, cfg.Bucket).EnableBatching()
+	}
+
+	c.mu.Lock()
+	old := c.conn
+	c.conn = next
+	c.mu.Unlock()
+	c.connected.Store(true)
+
+	go func() {
+		old.inflight.Wait()
+		if c.batching.Load() {
+			_ = old.client.WriteAPIBlocking(old.cfg.Org, old.cfg.Bucket).Flush(context.Background())
+		}
+		old.client.Close()
+	}()
+	return nil
+}
+
+// NewUnverified returns a client for cfg without contacting the server, for
+// starting while InfluxDB is unreachable. Calls fail until the server is up, and
+// Connected reports false until a Reconnect succeeds; Redial retries one.
+func NewUnverified(cfg Config) *Client {
+	return &Client{conn: &connection{cfg: cfg, client: influxdb2.NewClient(cfg.URL, cfg.Token)}}
+}
+
+// Connected reports whether the current connection passed the checks New
+// makes. It is false for a client from NewUnverified until Reconnect succeeds.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// Redial retries Reconnect with the current configuration every interval until
+// it succeeds or ctx is cancelled. It returns at once when the client is
+// already connected, and is meant to run in its own goroutine.
+func (c *Client) Redial(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	for attempt := 1; !c.Connected(); attempt++ {
+		err := c.Reconnect(ctx, c.Config())
+		if err == nil {
+			logger.Info("influx connection established", "attempts", attempt)
+			return
+		}
+		if attempt == 1 {
+			logger.Warn("influx still unreachable, retrying in background", "error", err, "retry", interval.String())
+		} else {
+			logger.Debug("influx still unreachable", "attempt", attempt, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// queryResult releases its connection when closed.
+type queryResult struct {
+	*api.QueryTableResult
+	release func()
+}
+
+func (r *queryResult) Close() error {
+	err := r.QueryTableResult.Close()
+	r.release()
+	return err
+}
+
+// query runs flux on the current connection, holding it until the result is
+// closed.
+func (c *Client) query(ctx context.Context, flux string) (*queryResult, error) {
+	conn, release := c.acquire()
+	result, err := conn.client.QueryAPI(conn.cfg.Org).Query(ctx, flux)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &queryResult{QueryTableResult: result, release: release}, nil
+}
+
+// QueryRaw runs flux and returns the annotated CSV response.
+func (c *Client) QueryRaw(ctx context.Context, flux string) (string, error) {
+	conn, release := c.acquire()
+	defer release()
+	return conn.client.QueryAPI(conn.cfg.Org).QueryRaw(ctx, flux, nil)
+}
+
+// writeAPI resolves the current connection on every call, so long-lived
+// writers such as the simulator follow Reconnect.
+type writeAPI struct {
+	c *Client
+}
+
+func (w writeAPI) current() (api.WriteAPIBlocking, func()) {
+	conn, release := w.c.acquire()
+	return conn.client.WriteAPIBlocking(conn.cfg.Org, conn.cfg.Bucket), release
+}
+
+func (w writeAPI) WriteRecord(ctx context.Context, line ...string) error {
+	writer, release := w.current()
+	defer release()
+	return writer.WriteRecord(ctx, line...)
+}
+
+func (w writeAPI) WritePoint(ctx context.Context, point ...*write.Point) error {
+	writer, release := w.current()
+	defer release()
+	return writer.WritePoint(ctx, point...)
+}
+
+// EnableBatching turns on batching for the current and all later connections.
+func (w writeAPI) EnableBatching() {
+	w.c.batching.Store(true)
+	writer, release := w.current()
+	defer release()
+	writer.EnableBatching()
+}
+
+func (w writeAPI) Flush(ctx context.Context) error {
+	writer, release := w.current()
+	defer release()
+	return writer.Flush(ctx)
+}
+
+var _ api.WriteAPIBlocking = writeAPI{}
@@ -0,0 +1,37 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+)
+
+// QueryRecords runs flux through the typed query API and returns each record's
+// columns, minus the constant "result" column. A positive limit stops reading
+// after that many records.
+func (c *Client) QueryRecords(ctx context.Context, flux string, limit int) ([]map[string]any, error) {
+	result, err := c.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	records := []map[string]any{}
+	for result.Next() {
+		values := result.Record().Values()
+		row := make(map[string]any, len(values))
+		for key, value := range values {
+			if key == "result" {
+				continue
+			}
+			row[key] = value
+		}
+		records = append(records, row)
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return records, nil
+}
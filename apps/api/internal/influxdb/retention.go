@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CountMachinePoints returns how many points a machine wrote to measurement
+// within [start, stop).
+func (c *Client) CountMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) (int64, error) {
+	if measurement == "" {
+		return 0, fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return 0, fmt.Errorf("machine name is required")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTag("machine_name", machineName).
+		Group().
+		Count()
+
+	result, err := c.query(ctx, flux.String())
+	if err != nil {
+		return 0, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	var total int64
+	for result.Next() {
+		if n, ok := result.Record().Value().(int64); ok {
+			total += n
+		}
+	}
+	if err := result.Err(); err != nil {
+		return total, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return total, nil
+}
+
+// DeleteMachinePoints removes a machine's points in measurement within
+// [start, stop]. Other machines' data in the window is left untouched.
+func (c *Client) DeleteMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) error {
+	if measurement == "" {
+		return fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return fmt.Errorf("machine name is required")
+	}
+	predicate := fmt.Sprintf("_measurement=%s AND machine_name=%s", deletePredicateLiteral(measurement), deletePredicateLiteral(machineName))
+	conn, release := c.acquire()
+	defer release()
+	if err := conn.client.DeleteAPI().DeleteWithName(ctx, conn.cfg.Org, conn.cfg.Bucket, start, stop, predicate); err != nil {
+		return fmt.Errorf("delete influx points: %w", classifyError(err))
+	}
+	return nil
+}
+
+// CountPoints returns how many points all machines wrote to measurement within
+// [start, stop).
+func (c *Client) CountPoints(ctx context.Context, measurement string, start, stop time.Time) (int64, error) {
+	if measurement == "" {
+		return 0, fmt.Errorf("measurement is required")
+	}
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		Group().
+		Count()
+
+	result, err := c.query(ctx, flux.String())
+	if err != nil {
+		return 0, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	var total int64
+	for result.Next() {
+		if n, ok := result.Record().Value().(int64); ok {
+			total += n
+		}
+	}
+	if err := result.Err(); err != nil {
+		return total, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return total, nil
+}
+
+// DeleteMeasurement removes every point in measurement within [start, stop],
+// across all machines.
+func (c *Client) DeleteMeasurement(ctx context.Context, measurement string, start, stop time.Time) error {
+	if measurement == "" {
+		return fmt.Errorf("measurement is required")
+	}
+	predicate := fmt.Sprintf("_measurement=%s", deletePredicateLiteral(measurement))
+	conn, release := c.acquire()
+	defer release()
+	if err := conn.client.DeleteAPI().DeleteWithName(ctx, conn.cfg.Org, conn.cfg.Bucket, start, stop, predicate); err != nil {
+		return fmt.Errorf("delete influx points: %w", classifyError(err))
+	}
+	return nil
+}
+
+func deletePredicateLiteral(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return "\"" + s + "\""
+}
@@ -0,0 +1,48 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// minSampleWindow is the narrowest window SampledReadings averages over; Flux
+// durations below a second would be truncated to zero.
+const minSampleWindow = time.Second
+
+// SampleWindow returns the window SampledReadings uses to split [start, stop)
+// into buckets, never narrower than one second.
+func SampleWindow(start, stop time.Time, buckets int) time.Duration {
+	if buckets <= 0 {
+		buckets = 1
+	}
+	every := (stop.Sub(start) / time.Duration(buckets)).Truncate(time.Second)
+	if every < minSampleWindow {
+		return minSampleWindow
+	}
+	return every
+}
+
+// SampledReadings averages each sensor's readings within [start, stop) into at
+// most buckets evenly spaced points, oldest first within each series, so a
+// chart gets a fixed number of points whatever the range. Filters match tag
+// values such as machine_name and sensor_name.
+func (c *Client) SampledReadings(ctx context.Context, measurement string, start, stop time.Time, filters map[string]string, buckets int) ([]SensorReading, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	if !start.Before(stop) {
+		return nil, fmt.Errorf("start must be before stop")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("value").
+		FilterTags(filters).
+		Group("_measurement", "_field", "machine_name", "sensor_name").
+		AggregateWindow(SampleWindow(start, stop, buckets), "mean").
+		Sort("_time", false)
+
+	return c.querySensorReadings(ctx, flux.String(), 0)
+}
@@ -0,0 +1,134 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatusChange marks the time a sensor entered a status.
+type StatusChange struct {
+	SensorName string
+	Status     string
+	Time       time.Time
+}
+
+// StatusChanges returns, per sensor of a machine, each point within
+// [start, stop) where the written "status" field differs from the sensor's
+// previous status. Changes are in time order within each sensor.
+func (c *Client) StatusChanges(ctx context.Context, measurement, machineName string, start, stop time.Time) ([]StatusChange, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("status").
+		FilterTag("machine_name", machineName).
+		Group("sensor_name").
+		Sort("_time", false).
+		Keep("_time", "_value", "sensor_name")
+
+	result, err := c.query(ctx, flux.String())
+	if err != nil {
+		return nil, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	var (
+		changes []StatusChange
+		last    = map[string]string{}
+	)
+	for result.Next() {
+		record := result.Record()
+		sensor := stringify(record.ValueByKey("sensor_name"))
+		status := stringify(record.Value())
+		if previous, ok := last[sensor]; ok && previous == status {
+			continue
+		}
+		last[sensor] = status
+		changes = append(changes, StatusChange{SensorName: sensor, Status: status, Time: record.Time()})
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return changes, nil
+}
+
+// Statuses the simulator writes while a sensor is running or fully down; the
+// ramps in between are "starting" and "shutting_down".
+const (
+	StatusRunning = "running"
+	StatusDown    = "down"
+)
+
+// Utilization counts a machine's status samples over a time range.
+type Utilization struct {
+	// Counts holds the number of samples per status across all sensors.
+	Counts  map[string]int64
+	Total   int64
+	Running int64
+	Down    int64
+}
+
+// Percent returns n as a percentage of Total, or zero when there are no samples.
+func (u Utilization) Percent(n int64) float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(n) / float64(u.Total) * 100
+}
+
+// MachineUtilization counts the written "status" samples of every sensor on a
+// machine within [start, stop), by status. Counting happens in Influx, so long
+// ranges do not transfer every point.
+func (c *Client) MachineUtilization(ctx context.Context, measurement, machineName string, start, stop time.Time) (Utilization, error) {
+	if measurement == "" {
+		return Utilization{}, fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return Utilization{}, fmt.Errorf("machine name is required")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterField("status").
+		FilterTag("machine_name", machineName).
+		Duplicate("_value", "status").
+		Group("status").
+		Count()
+
+	result, err := c.query(ctx, flux.String())
+	if err != nil {
+		return Utilization{}, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	u := Utilization{Counts: map[string]int64{}}
+	for result.Next() {
+		record := result.Record()
+		count, ok := record.Value().(int64)
+		if !ok {
+			continue
+		}
+		status := stringify(record.ValueByKey("status"))
+		u.Counts[status] += count
+		u.Total += count
+		switch status {
+		case StatusRunning:
+			u.Running += count
+		case StatusDown:
+			u.Down += count
+		}
+	}
+	if err := result.Err(); err != nil {
+		return Utilization{}, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return u, nil
+}
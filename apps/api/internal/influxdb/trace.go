@@ -0,0 +1,56 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MachineTrace returns every reading of a machine's sensors within [start,
+// stop), oldest first, with the status written alongside each value. A
+// positive limit returns only the oldest limit readings.
+func (c *Client) MachineTrace(ctx context.Context, measurement, machineName string, start, stop time.Time, limit int) ([]SensorReading, error) {
+	if measurement == "" {
+		return nil, fmt.Errorf("measurement is required")
+	}
+	if strings.TrimSpace(machineName) == "" {
+		return nil, fmt.Errorf("machine name is required")
+	}
+
+	flux := newFluxQuery(c.Config().Bucket).
+		Range(start, stop).
+		FilterMeasurement(measurement).
+		FilterFields("value", "status").
+		FilterTag("machine_name", machineName).
+		PivotFields().
+		Group().
+		Sort("_time", false).
+		Limit(limit)
+
+	result, err := c.query(ctx, flux.String())
+	if err != nil {
+		return nil, fmt.Errorf("query influx: %w", classifyError(err))
+	}
+	defer result.Close()
+
+	var readings []SensorReading
+	for result.Next() {
+		record := result.Record()
+		value, ok := toFloat(record.ValueByKey("value"))
+		if !ok {
+			continue
+		}
+		readings = append(readings, SensorReading{
+			Time:        record.Time(),
+			MachineName: stringify(record.ValueByKey("machine_name")),
+			SensorName:  stringify(record.ValueByKey("sensor_name")),
+			Status:      stringify(record.ValueByKey("status")),
+			Value:       value,
+		})
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("iterate influx result: %w", err)
+	}
+	return readings, nil
+}
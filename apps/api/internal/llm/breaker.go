@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrLLMUnavailable is returned without contacting the provider while the circuit
+// breaker is open after repeated provider failures.
+var ErrLLMUnavailable = errors.New("llm provider unavailable")
+
+// Breaker states reported by BreakerStatus.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus describes the circuit breaker guarding provider calls.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Threshold           int        `json:"threshold"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+}
+
+// breaker opens after threshold consecutive provider failures. Once cooldown has
+// passed it lets a single trial call through: success closes it, failure
+// re-opens it for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports ErrLLMUnavailable while open, or while a half-open trial call is
+// already in flight.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.trial {
+		return ErrLLMUnavailable
+	}
+	b.trial = true
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up; the call says nothing about the provider.
+		return
+	}
+	if !isProviderFailure(err) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: BreakerClosed, ConsecutiveFailures: b.failures, Threshold: b.threshold}
+	if b.openedAt.IsZero() {
+		return st
+	}
+	openedAt := b.openedAt
+	retryAt := openedAt.Add(b.cooldown)
+	st.OpenedAt, st.RetryAt = &openedAt, &retryAt
+	st.State = BreakerOpen
+	if b.trial || !time.Now().Before(retryAt) {
+		st.State = BreakerHalfOpen
+	}
+	return st
+}
+
+// isProviderFailure reports whether err signals the provider being down or
+// overloaded, as opposed to, say, a rejected prompt.
+func isProviderFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return isRetryable(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// BreakerStatus reports the state of the circuit breaker guarding provider calls.
+func (c *Client) BreakerStatus() BreakerStatus {
+	return c.breaker.status()
+}
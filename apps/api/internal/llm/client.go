@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/logging"
 )
 
 const (
@@ -24,14 +28,31 @@ var (
 
 // Config captures the parameters required to instantiate the LLM client.
 type Config struct {
-	APIKey      string
-	Model       string
+	APIKey string
+	// Models is the fallback chain, primary model first. GenerateText moves to
+	// the next model when one stays rate limited or unavailable after its
+	// retries. Empty uses defaultModel.
+	Models      []string
 	Temperature float32
 	TopP        *float32
 	TopK        *int32
+	// MaxOutputTokens caps the length of each response; nil leaves the model default.
+	MaxOutputTokens *int32
+	MaxRetries      int
+	// BreakerThreshold consecutive provider failures open the circuit breaker
+	// for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Safety relaxes Gemini's safety filters; SafetyDefault keeps them as they are.
+	Safety SafetyPreset
 }
 
 // FromEnv builds a Config from well-known environment variables. GEMINI_API_KEY or LLM_API_KEY is required.
+// GEMINI_MODELS lists the model chain as "model-a,model-b"; without it GEMINI_MODEL names a single model.
+// GEMINI_MAX_OUTPUT_TOKENS caps response length. LLM_MAX_RETRIES (default 3) bounds retries of rate-limited or unavailable requests.
+// LLM_BREAKER_THRESHOLD (default 5) and LLM_BREAKER_COOLDOWN (default 30s) tune the
+// circuit breaker. LLM_SAFETY selects a SafetyPreset (default, block_few or
+// block_none); unlike the tuning values, an invalid preset is an error.
 func FromEnv() (Config, error) {
 	apiKey := strings.TrimSpace(os.Getenv("GEMINI_API_KEY"))
 	if apiKey == "" {
@@ -41,13 +62,26 @@ func FromEnv() (Config, error) {
 		return Config{}, ErrMissingAPIKey
 	}
 
+	safety, err := ParseSafetyPreset(os.Getenv("LLM_SAFETY"))
+	if err != nil {
+		return Config{}, fmt.Errorf("LLM_SAFETY: %w", err)
+	}
+
 	cfg := Config{
 		APIKey:      apiKey,
-		Model:       strings.TrimSpace(os.Getenv("GEMINI_MODEL")),
+		Models:      parseModels(os.Getenv("GEMINI_MODELS")),
 		Temperature: defaultTemperature,
+		MaxRetries:  defaultMaxRetries,
+
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+		Safety:           safety,
+	}
+	if len(cfg.Models) == 0 {
+		cfg.Models = parseModels(os.Getenv("GEMINI_MODEL"))
 	}
-	if cfg.Model == "" {
-		cfg.Model = defaultModel
+	if len(cfg.Models) == 0 {
+		cfg.Models = []string{defaultModel}
 	}
 
 	if tempStr := strings.TrimSpace(os.Getenv("GEMINI_TEMPERATURE")); tempStr != "" {
@@ -68,16 +102,56 @@ func FromEnv() (Config, error) {
 		}
 	}
 
+	if maxTokensStr := strings.TrimSpace(os.Getenv("GEMINI_MAX_OUTPUT_TOKENS")); maxTokensStr != "" {
+		if val, err := strconv.ParseInt(maxTokensStr, 10, 32); err == nil && val > 0 {
+			i32 := int32(val)
+			cfg.MaxOutputTokens = &i32
+		}
+	}
+
+	if retriesStr := strings.TrimSpace(os.Getenv("LLM_MAX_RETRIES")); retriesStr != "" {
+		if val, err := strconv.Atoi(retriesStr); err == nil && val >= 0 {
+			cfg.MaxRetries = val
+		}
+	}
+
+	if thresholdStr := strings.TrimSpace(os.Getenv("LLM_BREAKER_THRESHOLD")); thresholdStr != "" {
+		if val, err := strconv.Atoi(thresholdStr); err == nil && val > 0 {
+			cfg.BreakerThreshold = val
+		}
+	}
+	if cooldownStr := strings.TrimSpace(os.Getenv("LLM_BREAKER_COOLDOWN")); cooldownStr != "" {
+		if val, err := time.ParseDuration(cooldownStr); err == nil && val > 0 {
+			cfg.BreakerCooldown = val
+		}
+	}
+
 	return cfg, nil
 }
 
+// parseModels splits a comma-separated model list, dropping blanks and repeats.
+func parseModels(raw string) []string {
+	var models []string
+	for _, model := range strings.Split(raw, ",") {
+		model = strings.TrimSpace(model)
+		if model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
 // Client wraps the Gemini SDK to provide higher-level helpers for the chatbot workflow.
 type Client struct {
 	client      *genai.Client
-	modelName   string
+	models      []string
 	temperature float32
 	topP        *float32
 	topK        *int32
+	maxTokens   *int32
+	maxRetries  int
+	breaker     *breaker
+	safety      SafetyPreset
 }
 
 // New instantiates a Client using the provided configuration.
@@ -87,12 +161,20 @@ func New(ctx context.Context, cfg Config) (*Client, error) {
 		return nil, fmt.Errorf("create generative ai client: %w", err)
 	}
 
+	models := cfg.Models
+	if len(models) == 0 {
+		models = []string{defaultModel}
+	}
 	return &Client{
 		client:      genClient,
-		modelName:   cfg.Model,
+		models:      append([]string(nil), models...),
 		temperature: cfg.Temperature,
 		topP:        cfg.TopP,
 		topK:        cfg.TopK,
+		maxTokens:   cfg.MaxOutputTokens,
+		maxRetries:  cfg.MaxRetries,
+		breaker:     newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		safety:      cfg.Safety,
 	}, nil
 }
 
@@ -101,17 +183,30 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
-// GenerateText executes a single request-response interaction with the configured model.
-func (c *Client) GenerateText(ctx context.Context, systemPrompt string, userParts ...string) (string, error) {
-	if len(userParts) == 0 {
-		return "", fmt.Errorf("user prompt is required")
-	}
+// Usage reports the tokens consumed by a request.
+type Usage struct {
+	PromptTokens     int32 `json:"promptTokens"`
+	CandidatesTokens int32 `json:"candidatesTokens"`
+	TotalTokens      int32 `json:"totalTokens"`
+}
 
-	model := c.client.GenerativeModel(c.modelName)
-	c.applyGenerationConfig(model)
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CandidatesTokens: u.CandidatesTokens + other.CandidatesTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
 
-	if systemPrompt != "" {
-		model.SystemInstruction = &genai.Content{Role: "system", Parts: []genai.Part{genai.Text(systemPrompt)}}
+// GenerateText executes a single request-response interaction and reports the
+// tokens it used. Usage is zero when the provider omits it. Models are tried in
+// their configured order: a model that is still rate limited or unavailable
+// after its retries hands the request to the next one, while any other error is
+// returned as is.
+func (c *Client) GenerateText(ctx context.Context, systemPrompt string, userParts ...string) (string, Usage, error) {
+	if len(userParts) == 0 {
+		return "", Usage{}, fmt.Errorf("user prompt is required")
 	}
 
 	parts := make([]genai.Part, 0, len(userParts))
@@ -123,15 +218,62 @@ func (c *Client) GenerateText(ctx context.Context, systemPrompt string, userPart
 		parts = append(parts, genai.Text(text))
 	}
 	if len(parts) == 0 {
-		return "", fmt.Errorf("user prompt is empty")
+		return "", Usage{}, fmt.Errorf("user prompt is empty")
 	}
 
-	resp, err := model.GenerateContent(ctx, parts...)
+	if err := c.breaker.allow(); err != nil {
+		return "", Usage{}, err
+	}
+	logger := logging.FromContext(ctx)
+	var (
+		resp *genai.GenerateContentResponse
+		err  error
+	)
+	for i, name := range c.models {
+		model := c.client.GenerativeModel(name)
+		c.applyGenerationConfig(model)
+		if systemPrompt != "" {
+			model.SystemInstruction = &genai.Content{Role: "system", Parts: []genai.Part{genai.Text(systemPrompt)}}
+		}
+		err = c.withRetry(ctx, func() error {
+			var callErr error
+			resp, callErr = model.GenerateContent(ctx, parts...)
+			return callErr
+		})
+		if err == nil {
+			if i > 0 {
+				logger.Info("llm request answered by fallback model", "model", name, "primary", c.models[0])
+			} else {
+				logger.Debug("llm request answered", "model", name)
+			}
+			break
+		}
+		if i == len(c.models)-1 || !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+		logger.Warn("llm model unavailable, falling back", "model", name, "next", c.models[i+1], "error", err)
+	}
+	c.breaker.record(err)
 	if err != nil {
-		return "", fmt.Errorf("generate content: %w", err)
+		if blocked := blockedFromSDK(err); blocked != nil {
+			return "", Usage{}, blocked
+		}
+		return "", Usage{}, fmt.Errorf("generate content: %w", err)
 	}
 
-	return extractText(resp)
+	text, err := extractText(resp)
+	return text, usageFrom(resp), err
+}
+
+func usageFrom(resp *genai.GenerateContentResponse) Usage {
+	if resp == nil || resp.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+		CandidatesTokens: resp.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+	}
 }
 
 func (c *Client) applyGenerationConfig(model *genai.GenerativeModel) {
@@ -142,28 +284,48 @@ func (c *Client) applyGenerationConfig(model *genai.GenerativeModel) {
 	if c.topK != nil {
 		model.GenerationConfig.SetTopK(*c.topK)
 	}
+	if c.maxTokens != nil {
+		model.GenerationConfig.SetMaxOutputTokens(*c.maxTokens)
+	}
+	model.SafetySettings = c.safety.settings()
 }
 
 func extractText(resp *genai.GenerateContentResponse) (string, error) {
 	if resp == nil {
-		return "", fmt.Errorf("empty LLM response")
+		return "", ErrLLMEmpty
 	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != genai.BlockReasonUnspecified {
+		return "", &BlockedError{Source: "prompt", Reason: reasonName(fb.BlockReason.String(), "BlockReason"), Categories: flaggedCategories(fb.SafetyRatings)}
+	}
+
+	var blocked *BlockedError
 	for _, cand := range resp.Candidates {
-		if cand == nil || cand.Content == nil {
+		if cand == nil {
 			continue
 		}
-		var sb strings.Builder
-		for _, part := range cand.Content.Parts {
-			switch v := part.(type) {
-			case genai.Text:
-				sb.WriteString(string(v))
-			case *genai.Text:
-				sb.WriteString(string(*v))
+		if cand.Content != nil {
+			var sb strings.Builder
+			for _, part := range cand.Content.Parts {
+				switch v := part.(type) {
+				case genai.Text:
+					sb.WriteString(string(v))
+				case *genai.Text:
+					sb.WriteString(string(*v))
+				}
+			}
+			if sb.Len() > 0 {
+				return sb.String(), nil
 			}
 		}
-		if sb.Len() > 0 {
-			return sb.String(), nil
+		switch cand.FinishReason {
+		case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+			if blocked == nil {
+				blocked = &BlockedError{Source: "response", Reason: reasonName(cand.FinishReason.String(), "FinishReason"), Categories: flaggedCategories(cand.SafetyRatings)}
+			}
 		}
 	}
-	return "", fmt.Errorf("no text candidates in LLM response")
+	if blocked != nil {
+		return "", blocked
+	}
+	return "", ErrLLMEmpty
 }
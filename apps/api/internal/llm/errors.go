@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var (
+	// ErrLLMBlocked matches any BlockedError.
+	ErrLLMBlocked = errors.New("llm response blocked")
+	// ErrLLMEmpty is returned when the model answered without any text.
+	ErrLLMEmpty = errors.New("llm returned no text")
+)
+
+// BlockedError reports that Gemini refused the prompt or withheld its response.
+type BlockedError struct {
+	// Source is "prompt" or "response".
+	Source string
+	// Reason is the SDK block or finish reason, e.g. "safety".
+	Reason string
+	// Categories lists the harm categories Gemini flagged, e.g.
+	// "dangerous_content"; empty when it gave no ratings.
+	Categories []string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("llm %s blocked: %s", e.Source, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrLLMBlocked) true for every BlockedError.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrLLMBlocked
+}
+
+// blockedFromSDK converts the SDK's blocked error, returning nil for other errors.
+func blockedFromSDK(err error) *BlockedError {
+	var sdkErr *genai.BlockedError
+	if !errors.As(err, &sdkErr) {
+		return nil
+	}
+	if sdkErr.PromptFeedback != nil {
+		return &BlockedError{Source: "prompt", Reason: reasonName(sdkErr.PromptFeedback.BlockReason.String(), "BlockReason"),
+			Categories: flaggedCategories(sdkErr.PromptFeedback.SafetyRatings)}
+	}
+	if sdkErr.Candidate != nil {
+		return &BlockedError{Source: "response", Reason: reasonName(sdkErr.Candidate.FinishReason.String(), "FinishReason"),
+			Categories: flaggedCategories(sdkErr.Candidate.SafetyRatings)}
+	}
+	return &BlockedError{Source: "response", Reason: "unspecified"}
+}
+
+// reasonName turns "FinishReasonSafety" into "safety".
+func reasonName(name, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(name, prefix))
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var dangerousHigh = []*genai.SafetyRating{
+	{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityNegligible},
+	{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh},
+}
+
+func TestBlockedFromSDK(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *BlockedError
+	}{
+		{
+			name: "prompt blocked",
+			err:  &genai.BlockedError{PromptFeedback: &genai.PromptFeedback{BlockReason: genai.BlockReasonSafety, SafetyRatings: dangerousHigh}},
+			want: &BlockedError{Source: "prompt", Reason: "safety", Categories: []string{"dangerous_content"}},
+		},
+		{
+			name: "safety finish",
+			err:  &genai.BlockedError{Candidate: &genai.Candidate{FinishReason: genai.FinishReasonSafety, SafetyRatings: dangerousHigh}},
+			want: &BlockedError{Source: "response", Reason: "safety", Categories: []string{"dangerous_content"}},
+		},
+		{
+			name: "recitation finish",
+			err:  &genai.BlockedError{Candidate: &genai.Candidate{FinishReason: genai.FinishReasonRecitation}},
+			want: &BlockedError{Source: "response", Reason: "recitation"},
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("generate content: %w", &genai.BlockedError{PromptFeedback: &genai.PromptFeedback{BlockReason: genai.BlockReasonOther}}),
+			want: &BlockedError{Source: "prompt", Reason: "other"},
+		},
+		{
+			name: "no details",
+			err:  &genai.BlockedError{},
+			want: &BlockedError{Source: "response", Reason: "unspecified"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("googleapi: Error 503: overloaded"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := blockedFromSDK(tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("blockedFromSDK = %+v, want %+v", got, tt.want)
+			}
+			if got != nil && !errors.Is(got, ErrLLMBlocked) {
+				t.Error("errors.Is(ErrLLMBlocked) = false")
+			}
+		})
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	text := func(parts ...genai.Part) *genai.Content { return &genai.Content{Parts: parts} }
+	tests := []struct {
+		name    string
+		resp    *genai.GenerateContentResponse
+		want    string
+		wantErr error
+		blocked *BlockedError
+	}{
+		{
+			name:    "nil response",
+			wantErr: ErrLLMEmpty,
+		},
+		{
+			name: "text",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: text(genai.Text("from(bucket: "), genai.Text(`"sensors")`)), FinishReason: genai.FinishReasonStop},
+			}},
+			want: `from(bucket: "sensors")`,
+		},
+		{
+			name:    "prompt blocked",
+			resp:    &genai.GenerateContentResponse{PromptFeedback: &genai.PromptFeedback{BlockReason: genai.BlockReasonSafety, SafetyRatings: dangerousHigh}},
+			blocked: &BlockedError{Source: "prompt", Reason: "safety", Categories: []string{"dangerous_content"}},
+		},
+		{
+			name: "safety finish",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{FinishReason: genai.FinishReasonSafety, SafetyRatings: dangerousHigh},
+			}},
+			blocked: &BlockedError{Source: "response", Reason: "safety", Categories: []string{"dangerous_content"}},
+		},
+		{
+			name: "recitation finish",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: text(), FinishReason: genai.FinishReasonRecitation},
+			}},
+			blocked: &BlockedError{Source: "response", Reason: "recitation"},
+		},
+		{
+			name: "later candidate answers",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{FinishReason: genai.FinishReasonSafety},
+				{Content: text(genai.Text("ok")), FinishReason: genai.FinishReasonStop},
+			}},
+			want: "ok",
+		},
+		{
+			name: "empty stop",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: text(), FinishReason: genai.FinishReasonStop},
+			}},
+			wantErr: ErrLLMEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractText(tt.resp)
+			if tt.blocked != nil {
+				var blocked *BlockedError
+				if !errors.As(err, &blocked) || !reflect.DeepEqual(blocked, tt.blocked) {
+					t.Fatalf("extractText error = %#v, want %+v", err, tt.blocked)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extractText error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("extractText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/logging"
+)
+
+const (
+	defaultMaxRetries = 3
+	retryBaseDelay    = 500 * time.Millisecond
+	retryMaxDelay     = 8 * time.Second
+)
+
+// withRetry runs call until it succeeds, fails with a non-retryable error, the
+// retry budget is spent, or the next backoff would overrun ctx's deadline.
+func (c *Client) withRetry(ctx context.Context, call func() error) error {
+	logger := logging.FromContext(ctx)
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= c.maxRetries || !isRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := backoffDelay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		logger.Debug("retrying llm request", "attempt", attempt+1, "maxRetries", c.maxRetries, "delay", delay.String(), "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay doubles the base delay per attempt, capped at retryMaxDelay, and
+// applies full jitter.
+func backoffDelay(attempt int) time.Duration {
+	ceiling := retryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > retryMaxDelay {
+		ceiling = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + time.Millisecond
+}
+
+// isRetryable reports whether err is a rate limit or transient availability failure.
+func isRetryable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.ResourceExhausted, codes.Unavailable, codes.Aborted:
+			return true
+		}
+	}
+	return false
+}
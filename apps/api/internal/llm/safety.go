@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// SafetyPreset is a coarse setting for Gemini's safety filters, applied to
+// every harm category alike.
+type SafetyPreset string
+
+const (
+	// SafetyDefault leaves Gemini's own thresholds in place.
+	SafetyDefault SafetyPreset = "default"
+	// SafetyBlockFew blocks only content rated highly likely to be harmful.
+	SafetyBlockFew SafetyPreset = "block_few"
+	// SafetyBlockNone disables blocking; ratings are still reported.
+	SafetyBlockNone SafetyPreset = "block_none"
+)
+
+// ErrInvalidSafetyPreset is returned for LLM_SAFETY values other than the presets.
+var ErrInvalidSafetyPreset = errors.New("safety preset must be default, block_few or block_none")
+
+// safetyCategories are the harm categories Gemini models filter on.
+var safetyCategories = []genai.HarmCategory{
+	genai.HarmCategoryHarassment,
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategorySexuallyExplicit,
+	genai.HarmCategoryDangerousContent,
+}
+
+// ParseSafetyPreset reads a preset name case-insensitively; empty is SafetyDefault.
+func ParseSafetyPreset(raw string) (SafetyPreset, error) {
+	switch preset := SafetyPreset(strings.ToLower(strings.TrimSpace(raw))); preset {
+	case "":
+		return SafetyDefault, nil
+	case SafetyDefault, SafetyBlockFew, SafetyBlockNone:
+		return preset, nil
+	default:
+		return "", fmt.Errorf("%w, got %q", ErrInvalidSafetyPreset, raw)
+	}
+}
+
+// settings maps the preset to per-category thresholds; SafetyDefault sends none.
+func (p SafetyPreset) settings() []*genai.SafetySetting {
+	var threshold genai.HarmBlockThreshold
+	switch p {
+	case SafetyBlockFew:
+		threshold = genai.HarmBlockOnlyHigh
+	case SafetyBlockNone:
+		threshold = genai.HarmBlockNone
+	default:
+		return nil
+	}
+	settings := make([]*genai.SafetySetting, len(safetyCategories))
+	for i, category := range safetyCategories {
+		settings[i] = &genai.SafetySetting{Category: category, Threshold: threshold}
+	}
+	return settings
+}
+
+// flaggedCategories names the categories rated as blocking, or at least
+// medium probability, e.g. "dangerous_content".
+func flaggedCategories(ratings []*genai.SafetyRating) []string {
+	var names []string
+	for _, rating := range ratings {
+		if rating == nil || (!rating.Blocked && rating.Probability < genai.HarmProbabilityMedium) {
+			continue
+		}
+		names = append(names, categoryName(rating.Category))
+	}
+	return names
+}
+
+// categoryName turns HarmCategoryDangerousContent into "dangerous_content".
+func categoryName(category genai.HarmCategory) string {
+	name := strings.TrimPrefix(category.String(), "HarmCategory")
+	var sb strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(r)
+	}
+	return strings.ToLower(sb.String())
+}
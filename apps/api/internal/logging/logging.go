@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	levelEnvKey  = "LOG_LEVEL"
+	formatEnvKey = "LOG_FORMAT"
+
+	// FormatJSON emits one JSON object per record and is the default.
+	FormatJSON = "json"
+	// FormatText emits logfmt-style records for local development.
+	FormatText = "text"
+)
+
+// Config controls how the application logger is constructed.
+type Config struct {
+	Level  slog.Level
+	Format string
+}
+
+// FromEnv reads LOG_LEVEL (debug, info, warn, error; default info) and
+// LOG_FORMAT (json or text; default json).
+func FromEnv() Config {
+	return Config{
+		Level:  ParseLevel(os.Getenv(levelEnvKey)),
+		Format: parseFormat(os.Getenv(formatEnvKey)),
+	}
+}
+
+// ParseLevel converts a textual level into a slog.Level, defaulting to info.
+func ParseLevel(raw string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func parseFormat(raw string) string {
+	if strings.EqualFold(strings.TrimSpace(raw), FormatText) {
+		return FormatText
+	}
+	return FormatJSON
+}
+
+// New builds a logger writing to w using the configured level and format.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	if cfg.Format == FormatText {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// OrDefault returns logger when non-nil, otherwise the process-wide default.
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, falling back to the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return slog.Default()
+}
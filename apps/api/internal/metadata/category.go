@@ -0,0 +1,63 @@
+package metadata
+
+import (
+	"context"
+	"strings"
+)
+
+// machineCategoryPrefixes maps machine name prefixes to the category inferred
+// for seeded machines, checked in order.
+var machineCategoryPrefixes = []struct {
+	prefix   string
+	category string
+}{
+	{"Furnace", "furnace"},
+	{"CT", "cooling"},
+	{"Casting", "casting"},
+}
+
+// MachineCategoryCount is a distinct machine category and how many machines
+// carry it. Machines without a category are counted under "".
+type MachineCategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// InferMachineCategory derives a category from a machine name prefix, e.g.
+// Furnace-01 is "furnace" and CT-02 is "cooling". Unknown names yield "".
+func InferMachineCategory(machineName string) string {
+	name := strings.TrimSpace(machineName)
+	for _, p := range machineCategoryPrefixes {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(p.prefix)) {
+			return p.category
+		}
+	}
+	return ""
+}
+
+// normalizeCategory trims and lowercases a category so filters match
+// regardless of how it was entered.
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// ListMachineCategories returns the distinct machine categories with their
+// machine counts, ordered by category.
+func (r *Repository) ListMachineCategories(ctx context.Context) ([]MachineCategoryCount, error) {
+	const query = `SELECT COALESCE(category, ''), COUNT(*) FROM machines GROUP BY COALESCE(category, '') ORDER BY 1`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []MachineCategoryCount{}
+	for rows.Next() {
+		var c MachineCategoryCount
+		if err := rows.Scan(&c.Category, &c.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
@@ -0,0 +1,93 @@
+package metadata
+
+import (
+	"context"
+	"time"
+)
+
+// ListCompletedLotsBefore returns completed lots, including soft-deleted ones,
+// whose completed_at precedes cutoff, oldest first.
+func (r *Repository) ListCompletedLotsBefore(ctx context.Context, cutoff time.Time) ([]Lot, error) {
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE status = ? AND completed_at < ? ORDER BY completed_at`
+	rows, err := r.db.QueryContext(ctx, query, LotStatusCompleted, cutoff.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []Lot{}
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// DeleteCompletedLotsBefore permanently removes the lots ListCompletedLotsBefore
+// would return and reports how many were deleted.
+func (r *Repository) DeleteCompletedLotsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	const stmt = `DELETE FROM lots WHERE status = ? AND completed_at < ?`
+	res, err := r.db.ExecContext(ctx, stmt, LotStatusCompleted, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+// RetainedLotOverlaps reports whether a lot that survives a purge at cutoff ran
+// on machineName at any point within [start, stop]. Such windows must keep
+// their time-series data.
+func (r *Repository) RetainedLotOverlaps(ctx context.Context, machineName string, start, stop, cutoff time.Time) (bool, error) {
+	const query = `SELECT COUNT(*) FROM lots
+		WHERE machine_name = ?
+			AND NOT (status = ? AND completed_at < ?)
+			AND started_at <= ?
+			AND (completed_at IS NULL OR completed_at >= ?)`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, machineName, LotStatusCompleted, cutoff.UTC(), stop.UTC(), start.UTC()).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ResetLots deletes every lot, including soft-deleted ones, and unless
+// keepMachines is set every machine, in one transaction. It reports how many
+// rows of each were removed.
+func (r *Repository) ResetLots(ctx context.Context, keepMachines bool) (lots, machines int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM lots`)
+	if err != nil {
+		return 0, 0, err
+	}
+	deletedLots, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	var deletedMachines int64
+	if !keepMachines {
+		res, err := tx.ExecContext(ctx, `DELETE FROM machines`)
+		if err != nil {
+			return 0, 0, err
+		}
+		if deletedMachines, err = res.RowsAffected(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return int(deletedLots), int(deletedMachines), nil
+}
@@ -0,0 +1,28 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConclusionCategory classifies a lot's outcome alongside the free-text conclusion.
+type ConclusionCategory string
+
+const (
+	ConclusionPass   ConclusionCategory = "pass"
+	ConclusionFail   ConclusionCategory = "fail"
+	ConclusionRework ConclusionCategory = "rework"
+	ConclusionNone   ConclusionCategory = "none"
+)
+
+// ParseConclusionCategory normalises raw and rejects unknown categories with
+// ErrInvalidProductData.
+func ParseConclusionCategory(raw string) (ConclusionCategory, error) {
+	category := ConclusionCategory(strings.ToLower(strings.TrimSpace(raw)))
+	switch category {
+	case ConclusionPass, ConclusionFail, ConclusionRework, ConclusionNone:
+		return category, nil
+	}
+	return "", fmt.Errorf("%w: conclusionCategory must be one of %q, %q, %q or %q",
+		ErrInvalidProductData, ConclusionPass, ConclusionFail, ConclusionRework, ConclusionNone)
+}
@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// defaultFinalizeTimeout bounds how long a finalizer may hold a lot's row lock,
+// so a slow InfluxDB cannot block product upserts on the lot.
+const defaultFinalizeTimeout = 10 * time.Second
+
+// LotFinalizer evaluates a lot while FinalizeLot holds its row lock and returns
+// the summary to complete it with, or nil to leave the lot untouched.
+type LotFinalizer func(ctx context.Context, lot Lot) (*LotSummary, error)
+
+// FinalizeResult reports what FinalizeLot did with a lot.
+type FinalizeResult int
+
+const (
+	// FinalizeSkipped means the lot was left as it was.
+	FinalizeSkipped FinalizeResult = iota
+	// FinalizeCompleted means the lot moved from processing to completed.
+	FinalizeCompleted
+	// FinalizeUpgraded means the lot was already completed and its stored
+	// summary was replaced with a richer one.
+	FinalizeUpgraded
+)
+
+// FinalizeLot runs the evaluate-then-complete sequence for a lot under a
+// SELECT ... FOR UPDATE row lock, so concurrent callers (the coordinator's
+// cycle hook and the completion service) are serialised per lot: the first to
+// take the lock decides, and later callers see the status it committed.
+//
+// finalize is called for processing lots, and for completed lots whose stored
+// summary has no sensor snapshots, since the coordinator completes lots with a
+// bare summary. A completed lot's summary is only replaced when the new one
+// covers more sensors, so whichever path finishes first, the richer summary is
+// kept and the lot is completed exactly once. Missing lots yield ErrLotNotFound.
+//
+// The lock is held while finalize runs, so finalize gets a context that expires
+// after the repository's finalize timeout; an expired finalize leaves the lot
+// untouched and returns its error.
+func (r *Repository) FinalizeLot(ctx context.Context, lotID int64, finalize LotFinalizer) (FinalizeResult, *LotSummary, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return FinalizeSkipped, nil, err
+	}
+	defer tx.Rollback()
+
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE id = ? AND deleted_at IS NULL FOR UPDATE`
+	lot, err := scanLot(tx.QueryRowContext(ctx, query, lotID))
+	if err != nil {
+		return FinalizeSkipped, nil, mapLotError(err)
+	}
+
+	switch lot.Status {
+	case LotStatusProcessing:
+	case LotStatusCompleted:
+		current, err := lot.Summary()
+		if err != nil || (current != nil && len(current.Sensors) > 0) {
+			return FinalizeSkipped, nil, nil
+		}
+	default:
+		return FinalizeSkipped, nil, nil
+	}
+
+	timeout := r.finalizeTimeout
+	if timeout <= 0 {
+		timeout = defaultFinalizeTimeout
+	}
+	finalizeCtx, cancel := context.WithTimeout(ctx, timeout)
+	summary, err := finalize(finalizeCtx, lot)
+	cancel()
+	if err != nil || summary == nil {
+		return FinalizeSkipped, nil, err
+	}
+	if lot.Status == LotStatusCompleted && len(summary.Sensors) == 0 {
+		return FinalizeSkipped, nil, nil
+	}
+
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return FinalizeSkipped, nil, fmt.Errorf("marshal lot summary: %w", err)
+	}
+
+	result := FinalizeCompleted
+	if lot.Status == LotStatusCompleted {
+		// Keep the original completion time; only the summary improves.
+		const stmt = `UPDATE lots SET summary_json = ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, stmt, string(payload), lotID); err != nil {
+			return FinalizeSkipped, nil, err
+		}
+		result = FinalizeUpgraded
+	} else {
+		const stmt = `UPDATE lots SET status = ?, completed_at = ?, summary_json = ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, stmt, LotStatusCompleted, summary.CompletedAt.UTC(), string(payload), lotID); err != nil {
+			return FinalizeSkipped, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return FinalizeSkipped, nil, err
+	}
+	return result, summary, nil
+}
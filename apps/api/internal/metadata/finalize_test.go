@@ -0,0 +1,321 @@
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/mysql/mysqltest"
+)
+
+// lotTable holds lots rows for FinalizeLot the way InnoDB would: SELECT ...
+// FOR UPDATE takes the row's lock until the transaction ends, and updates only
+// become visible on commit.
+type lotTable struct {
+	mu      sync.Mutex
+	rows    map[int64][]driver.Value
+	locks   map[int64]*sync.Mutex
+	held    map[int64][]*sync.Mutex // row locks by connection
+	pending map[int64][]func()      // uncommitted updates by connection
+}
+
+// lotRow is a lots row in lotColumns order.
+func lotRow(id int64, status LotStatus, completedAt, summary driver.Value) []driver.Value {
+	started := time.Date(2024, 6, 15, 8, 0, 0, 0, time.UTC)
+	return []driver.Value{
+		id, fmt.Sprintf("LOT-%d", id), "Oven-01", string(status), started, completedAt, started,
+		summary, nil, nil, nil, int64(0), int64(0), nil, false, string(ConclusionNone),
+	}
+}
+
+func newLotTable(rows ...[]driver.Value) *lotTable {
+	t := &lotTable{
+		rows:    map[int64][]driver.Value{},
+		locks:   map[int64]*sync.Mutex{},
+		held:    map[int64][]*sync.Mutex{},
+		pending: map[int64][]func(){},
+	}
+	for _, row := range rows {
+		id := row[0].(int64)
+		t.rows[id] = row
+		t.locks[id] = &sync.Mutex{}
+	}
+	return t
+}
+
+func (t *lotTable) server() *mysqltest.Server {
+	columns := strings.Split(lotColumns, ", ")
+	endTx := func(commit bool) func(conn int64) error {
+		return func(conn int64) error {
+			t.mu.Lock()
+			if commit {
+				for _, apply := range t.pending[conn] {
+					apply()
+				}
+			}
+			delete(t.pending, conn)
+			held := t.held[conn]
+			delete(t.held, conn)
+			t.mu.Unlock()
+			for _, lock := range held {
+				lock.Unlock()
+			}
+			return nil
+		}
+	}
+	selectRow := func(id int64) *mysqltest.Rows {
+		out := &mysqltest.Rows{Columns: columns}
+		if row, ok := t.rows[id]; ok {
+			out.Values = append(out.Values, append([]driver.Value(nil), row...))
+		}
+		return out
+	}
+	return &mysqltest.Server{
+		Query: func(c mysqltest.Call) (*mysqltest.Rows, error) {
+			switch {
+			case strings.HasSuffix(c.Query, "FROM lots WHERE id = ? AND deleted_at IS NULL FOR UPDATE"):
+				id := c.Args[0].(int64)
+				t.mu.Lock()
+				lock := t.locks[id]
+				t.mu.Unlock()
+				if lock != nil {
+					lock.Lock()
+				}
+				t.mu.Lock()
+				defer t.mu.Unlock()
+				if lock != nil {
+					t.held[c.Conn] = append(t.held[c.Conn], lock)
+				}
+				return selectRow(id), nil
+			case strings.HasSuffix(c.Query, "FROM lots WHERE lot_number = ? AND deleted_at IS NULL"):
+				t.mu.Lock()
+				defer t.mu.Unlock()
+				for id, row := range t.rows {
+					if row[1] == c.Args[0] {
+						return selectRow(id), nil
+					}
+				}
+				return &mysqltest.Rows{Columns: columns}, nil
+			}
+			return nil, fmt.Errorf("unexpected query: %s", c.Query)
+		},
+		Exec: func(c mysqltest.Call) (mysqltest.Result, error) {
+			var (
+				id  int64
+				set map[int]driver.Value
+			)
+			switch c.Query {
+			case "UPDATE lots SET status = ?, completed_at = ?, summary_json = ? WHERE id = ?":
+				id, set = c.Args[3].(int64), map[int]driver.Value{3: c.Args[0], 5: c.Args[1], 7: c.Args[2]}
+			case "UPDATE lots SET summary_json = ? WHERE id = ?":
+				id, set = c.Args[1].(int64), map[int]driver.Value{7: c.Args[0]}
+			default:
+				return mysqltest.Result{}, fmt.Errorf("unexpected statement: %s", c.Query)
+			}
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.pending[c.Conn] = append(t.pending[c.Conn], func() {
+				for column, value := range set {
+					t.rows[id][column] = value
+				}
+			})
+			return mysqltest.Result{RowsAffected: 1}, nil
+		},
+		Commit:   endTx(true),
+		Rollback: endTx(false),
+	}
+}
+
+func TestFinalizeLotSerialisesCallers(t *testing.T) {
+	ctx := context.Background()
+	completedAt := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	bareSummary := LotSummary{CompletedAt: completedAt, MachineName: "Oven-01"}
+	richSummary := LotSummary{
+		CompletedAt: completedAt.Add(time.Second),
+		MachineName: "Oven-01",
+		Sensors:     []SensorSnapshot{{SensorName: "Temperature", LatestStatus: "down", ObservedCount: 3}},
+	}
+
+	for round := 0; round < 20; round++ {
+		srv := newLotTable(lotRow(1, LotStatusProcessing, nil, nil)).server()
+		db := srv.DB()
+		repo := NewRepository(db)
+
+		var inFlight, overlaps, sawProcessing atomic.Int32
+		finalizer := func(summary LotSummary) LotFinalizer {
+			return func(ctx context.Context, lot Lot) (*LotSummary, error) {
+				if inFlight.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				defer inFlight.Add(-1)
+				if lot.Status == LotStatusProcessing {
+					sawProcessing.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+				return &summary, nil
+			}
+		}
+
+		// Half the callers finalize like the coordinator, half like the
+		// completion service.
+		results := make([]FinalizeResult, 8)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				finalize := finalizer(bareSummary)
+				if i%2 == 1 {
+					finalize = finalizer(richSummary)
+				}
+				result, _, err := repo.FinalizeLot(ctx, 1, finalize)
+				if err != nil {
+					t.Errorf("FinalizeLot: %v", err)
+				}
+				results[i] = result
+			}()
+		}
+		wg.Wait()
+
+		if n := overlaps.Load(); n > 0 {
+			t.Fatalf("round %d: %d finalizers ran while another held the lot", round, n)
+		}
+		if n := sawProcessing.Load(); n != 1 {
+			t.Fatalf("round %d: %d finalizers saw the lot still processing, want 1", round, n)
+		}
+		counts := map[FinalizeResult]int{}
+		for _, result := range results {
+			counts[result]++
+		}
+		if counts[FinalizeCompleted] != 1 || counts[FinalizeUpgraded] > 1 {
+			t.Fatalf("round %d: results %v, want one completion and at most one upgrade", round, results)
+		}
+
+		lot, err := repo.GetLotByNumber(ctx, "LOT-1")
+		if err != nil {
+			t.Fatalf("GetLotByNumber: %v", err)
+		}
+		summary, err := lot.Summary()
+		if err != nil || summary == nil {
+			t.Fatalf("round %d: stored summary = %v, %v", round, summary, err)
+		}
+		if lot.Status != LotStatusCompleted || len(summary.Sensors) != 1 {
+			t.Fatalf("round %d: lot %s with %d sensors, want completed with the richer summary", round, lot.Status, len(summary.Sensors))
+		}
+		db.Close()
+	}
+}
+
+func TestFinalizeLotMissing(t *testing.T) {
+	db := newLotTable().server().DB()
+	defer db.Close()
+	called := false
+	_, _, err := NewRepository(db).FinalizeLot(context.Background(), 7, func(context.Context, Lot) (*LotSummary, error) {
+		called = true
+		return nil, nil
+	})
+	if !errors.Is(err, ErrLotNotFound) {
+		t.Fatalf("FinalizeLot error = %v, want ErrLotNotFound", err)
+	}
+	if called {
+		t.Error("finalize ran for a missing lot")
+	}
+}
+
+func TestFinalizeLotKeepsRicherSummary(t *testing.T) {
+	ctx := context.Background()
+	bareAt := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	bare := LotSummary{CompletedAt: bareAt, MachineName: "Oven-01"}
+	rich := LotSummary{
+		CompletedAt: bareAt.Add(30 * time.Second),
+		MachineName: "Oven-01",
+		Sensors: []SensorSnapshot{
+			{SensorName: "Pressure", LatestStatus: "down", LatestValue: 0.1, ObservedCount: 3},
+			{SensorName: "Temperature", LatestStatus: "down", LatestValue: 0.5, ObservedCount: 3},
+		},
+	}
+	tests := []struct {
+		name          string
+		first, second LotSummary
+		wantSecond    FinalizeResult
+		wantSensors   int
+	}{
+		{"coordinator then completion service", bare, rich, FinalizeUpgraded, 2},
+		{"completion service then coordinator", rich, bare, FinalizeSkipped, 2},
+		{"coordinator twice", bare, bare, FinalizeSkipped, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newLotTable(lotRow(1, LotStatusProcessing, nil, nil)).server().DB()
+			defer db.Close()
+			repo := NewRepository(db)
+			finalizer := func(summary LotSummary) LotFinalizer {
+				return func(context.Context, Lot) (*LotSummary, error) { return &summary, nil }
+			}
+
+			result, _, err := repo.FinalizeLot(ctx, 1, finalizer(tt.first))
+			if err != nil || result != FinalizeCompleted {
+				t.Fatalf("first FinalizeLot = %v, %v, want FinalizeCompleted", result, err)
+			}
+			result, stored, err := repo.FinalizeLot(ctx, 1, finalizer(tt.second))
+			if err != nil || result != tt.wantSecond {
+				t.Fatalf("second FinalizeLot = %v, %v, want %v", result, err, tt.wantSecond)
+			}
+			if result == FinalizeUpgraded && (stored == nil || len(stored.Sensors) != tt.wantSensors) {
+				t.Errorf("upgrade returned summary %+v", stored)
+			}
+
+			lot, err := repo.GetLotByNumber(ctx, "LOT-1")
+			if err != nil {
+				t.Fatalf("GetLotByNumber: %v", err)
+			}
+			summary, err := lot.Summary()
+			if err != nil || summary == nil {
+				t.Fatalf("stored summary = %v, %v", summary, err)
+			}
+			if len(summary.Sensors) != tt.wantSensors {
+				t.Errorf("stored summary has %d sensors, want %d", len(summary.Sensors), tt.wantSensors)
+			}
+			// An upgrade only replaces the summary; the lot keeps the time it
+			// was first completed at.
+			if !lot.CompletedAt.Valid || !lot.CompletedAt.Time.Equal(tt.first.CompletedAt) {
+				t.Errorf("completed at %v, want %v", lot.CompletedAt, tt.first.CompletedAt)
+			}
+		})
+	}
+}
+
+func TestFinalizeLotTimesOutSlowFinalizer(t *testing.T) {
+	ctx := context.Background()
+	db := newLotTable(lotRow(1, LotStatusProcessing, nil, nil)).server().DB()
+	defer db.Close()
+	repo := NewRepository(db)
+	repo.finalizeTimeout = 20 * time.Millisecond
+
+	started := time.Now()
+	result, _, err := repo.FinalizeLot(ctx, 1, func(ctx context.Context, lot Lot) (*LotSummary, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || result != FinalizeSkipped {
+		t.Fatalf("FinalizeLot = %v, %v, want skipped with DeadlineExceeded", result, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("slow finalizer held the lot for %s", elapsed)
+	}
+
+	// The row lock was released, so the next caller completes the lot.
+	summary := LotSummary{CompletedAt: time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC), MachineName: "Oven-01"}
+	result, _, err = repo.FinalizeLot(ctx, 1, func(context.Context, Lot) (*LotSummary, error) {
+		return &summary, nil
+	})
+	if err != nil || result != FinalizeCompleted {
+		t.Fatalf("FinalizeLot after timeout = %v, %v, want completed", result, err)
+	}
+}
@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyReused indicates an idempotency key was replayed with a
+// different request body than the one it was first used with.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// IdempotentResponse is the stored outcome of a request carrying an idempotency key.
+type IdempotentResponse struct {
+	StatusCode  int
+	Body        []byte
+	RequestHash string
+	CreatedAt   time.Time
+}
+
+// LookupIdempotentResponse returns the response stored for key within scope if
+// it is younger than ttl. A stored response whose request hash differs from
+// requestHash yields ErrIdempotencyKeyReused.
+func (r *Repository) LookupIdempotentResponse(ctx context.Context, scope, key, requestHash string, ttl time.Duration) (IdempotentResponse, bool, error) {
+	const query = `SELECT status_code, response_body, request_hash, created_at FROM idempotency_keys WHERE scope = ? AND idem_key = ? AND created_at >= ?`
+	var resp IdempotentResponse
+	err := r.db.QueryRowContext(ctx, query, scope, key, time.Now().Add(-ttl).UTC()).Scan(&resp.StatusCode, &resp.Body, &resp.RequestHash, &resp.CreatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return IdempotentResponse{}, false, nil
+	case err != nil:
+		return IdempotentResponse{}, false, err
+	}
+	if resp.RequestHash != requestHash {
+		return IdempotentResponse{}, false, ErrIdempotencyKeyReused
+	}
+	return resp, true, nil
+}
+
+// SaveIdempotentResponse stores resp for key within scope, replacing an expired
+// entry with the same key, and drops entries older than ttl.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, scope, key string, resp IdempotentResponse, ttl time.Duration) error {
+	now := time.Now().UTC()
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, now.Add(-ttl)); err != nil {
+		return err
+	}
+	const stmt = `INSERT INTO idempotency_keys (scope, idem_key, request_hash, status_code, response_body, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE request_hash = VALUES(request_hash), status_code = VALUES(status_code),
+			response_body = VALUES(response_body), created_at = VALUES(created_at)`
+	_, err := r.db.ExecContext(ctx, stmt, scope, key, resp.RequestHash, resp.StatusCode, resp.Body, now)
+	return err
+}
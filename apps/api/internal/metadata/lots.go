@@ -36,8 +36,10 @@ type Lot struct {
 	GoodProduct     *int            `json:"goodProduct,omitempty"`
 	DefectProduct   *int            `json:"defectProduct,omitempty"`
 	Conclusion      *string         `json:"conclusion,omitempty"`
-	SummaryJSON     json.RawMessage `json:"summary,omitempty"`
-	IsConclusion    bool            `json:"isConclusion"`
+	// ConclusionCategory is the structured outcome; ConclusionNone when unset.
+	ConclusionCategory ConclusionCategory `json:"conclusionCategory"`
+	SummaryJSON        json.RawMessage    `json:"summary,omitempty"`
+	IsConclusion       bool               `json:"isConclusion"`
 }
 
 // CreateLotInput captures the values required to register a lot.
@@ -56,7 +58,9 @@ type ProductInput struct {
 	GoodProduct     *int
 	DefectProduct   *int
 	Conclusion      *string
-	IsConclusion    *bool
+	// ConclusionCategory leaves the stored category unchanged when nil.
+	ConclusionCategory *ConclusionCategory
+	IsConclusion       *bool
 }
 
 // LotSummary stores aggregated sensor context when a lot completes.
@@ -67,6 +71,9 @@ type LotSummary struct {
 	GoodProduct   int              `json:"goodProduct,omitempty"`
 	DefectProduct int              `json:"defectProduct,omitempty"`
 	Conclusion    string           `json:"conclusion,omitempty"`
+	// RangesCheckedAt is set when the backfill found no readings to take sensor
+	// ranges from, so the lot is not queried again.
+	RangesCheckedAt *time.Time `json:"rangesCheckedAt,omitempty"`
 }
 
 // SensorSnapshot summarises the latest readings per sensor.
@@ -76,20 +83,25 @@ type SensorSnapshot struct {
 	LatestValue   float64 `json:"latestValue"`
 	AverageDown   float64 `json:"averageDown"`
 	ObservedCount int     `json:"observedCount"`
+	// MinValue and MaxValue span the lot's processing window; nil until computed.
+	MinValue *float64 `json:"minValue,omitempty"`
+	MaxValue *float64 `json:"maxValue,omitempty"`
 }
 
 // ProductData represents the product summary returned to API consumers.
 type ProductData struct {
-	Lot             string             `json:"lot"`
-	Status          LotStatus          `json:"status"`
-	ActiveMachineID string             `json:"activeMachineId"`
-	Averages        map[string]float64 `json:"averages"`
-	OperationHour   float64            `json:"operationHour"`
-	GoodProduct     int                `json:"goodProduct"`
-	DefectProduct   int                `json:"defectProduct"`
-	Conclusion      string             `json:"conclusion,omitempty"`
-	IsConclusion    bool               `json:"isConclusion"`
-	UpdatedAt       time.Time          `json:"updatedAt"`
+	Lot                string             `json:"lot"`
+	Status             LotStatus          `json:"status"`
+	ActiveMachineID    string             `json:"activeMachineId"`
+	Averages           map[string]float64 `json:"averages"`
+	AveragesDelta      map[string]float64 `json:"averagesDelta"`
+	OperationHour      float64            `json:"operationHour"`
+	GoodProduct        int                `json:"goodProduct"`
+	DefectProduct      int                `json:"defectProduct"`
+	Conclusion         string             `json:"conclusion,omitempty"`
+	ConclusionCategory ConclusionCategory `json:"conclusionCategory"`
+	IsConclusion       bool               `json:"isConclusion"`
+	UpdatedAt          time.Time          `json:"updatedAt"`
 }
 
 // ErrLotExists indicates the provided lot number already exists.
@@ -101,30 +113,47 @@ var ErrLotNumberRequired = errors.New("lot number is required")
 // ErrLotNotFound indicates a lookup failed to locate the requested lot.
 var ErrLotNotFound = errors.New("lot not found")
 
-func normalizeMachineName(lotNumber, machineName string) string {
-	trimmed := strings.TrimSpace(machineName)
-	if trimmed != "" {
-		return trimmed
+// ErrLotDeleted indicates the lot number belongs to a soft-deleted lot that must be restored instead.
+var ErrLotDeleted = errors.New("lot was deleted; restore it instead")
+
+// ErrLotNotCompleted is returned when an operation requires a completed lot.
+var ErrLotNotCompleted = errors.New("lot is not completed")
+
+// lotColumns lists the columns read by scanLot, in scan order.
+const lotColumns = `id, lot_number, machine_name, status, started_at, completed_at, updated_at, summary_json, active_machine_id, averages_json, operation_hour, good_product, defect_product, conclusion, is_conclusion, conclusion_category`
+
+// lotMachineName returns the normalized machine name of a new lot, falling back
+// to its lot number when none is given.
+func lotMachineName(lotNumber, machineName string) string {
+	if normalized := NormalizeMachineName(machineName); normalized != "" {
+		return normalized
 	}
-	fallback := strings.TrimSpace(lotNumber)
+	fallback := NormalizeMachineName(lotNumber)
 	if fallback != "" {
 		return fallback
 	}
 	return "auto-machine"
 }
 
-// CreateLot inserts a new lot marked as processing.
+// CreateLot inserts a new lot marked as processing. A machine name matching a
+// registered machine takes that machine's spelling.
 func (r *Repository) CreateLot(ctx context.Context, input CreateLotInput) (Lot, error) {
 	lotNumber := strings.TrimSpace(input.LotNumber)
 	if lotNumber == "" {
 		return Lot{}, ErrLotNumberRequired
 	}
-	machineName := normalizeMachineName(lotNumber, input.MachineName)
+	machineName, err := registeredMachineName(ctx, r.db, lotMachineName(lotNumber, input.MachineName))
+	if err != nil {
+		return Lot{}, err
+	}
 
 	const stmt = `INSERT INTO lots (lot_number, machine_name, status) VALUES (?, ?, ?)`
 	res, err := r.db.ExecContext(ctx, stmt, lotNumber, machineName, LotStatusProcessing)
 	if err != nil {
 		if isDuplicateEntry(err) {
+			if r.isLotSoftDeleted(ctx, lotNumber) {
+				return Lot{}, ErrLotDeleted
+			}
 			return Lot{}, ErrLotExists
 		}
 		return Lot{}, err
@@ -144,14 +173,16 @@ func (r *Repository) UpsertLotProduct(ctx context.Context, input ProductInput) (
 	if lotNumber == "" {
 		return ProductData{}, ErrLotNumberRequired
 	}
+	if err := ValidateProductInput(input); err != nil {
+		return ProductData{}, err
+	}
 
 	lot, err := r.GetLotByNumber(ctx, lotNumber)
 	switch {
 	case err == nil:
 		// existing lot found
 	case errors.Is(err, ErrLotNotFound):
-		machine := normalizeMachineName(lotNumber, input.MachineName)
-		lot, err = r.CreateLot(ctx, CreateLotInput{LotNumber: lotNumber, MachineName: machine})
+		lot, err = r.CreateLot(ctx, CreateLotInput{LotNumber: lotNumber, MachineName: input.MachineName})
 		if err != nil {
 			return ProductData{}, err
 		}
@@ -159,24 +190,96 @@ func (r *Repository) UpsertLotProduct(ctx context.Context, input ProductInput) (
 		return ProductData{}, err
 	}
 
+	if err := updateLotProduct(ctx, r.db, lot.ID, input); err != nil {
+		return ProductData{}, err
+	}
+	return r.productDataForLot(ctx, lot.ID)
+}
+
+// CreateLotWithProduct inserts a lot and its product metadata in one transaction, so
+// a conflict or invalid product data leaves no lot behind. product.LotNumber and
+// product.MachineName are ignored in favour of lot.
+func (r *Repository) CreateLotWithProduct(ctx context.Context, lot CreateLotInput, product ProductInput) (ProductData, error) {
+	lotNumber := strings.TrimSpace(lot.LotNumber)
+	if lotNumber == "" {
+		return ProductData{}, ErrLotNumberRequired
+	}
+	if err := ValidateProductInput(product); err != nil {
+		return ProductData{}, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductData{}, err
+	}
+	defer tx.Rollback()
+
+	machineName, err := registeredMachineName(ctx, tx, lotMachineName(lotNumber, lot.MachineName))
+	if err != nil {
+		return ProductData{}, err
+	}
+
+	const stmt = `INSERT INTO lots (lot_number, machine_name, status) VALUES (?, ?, ?)`
+	res, err := tx.ExecContext(ctx, stmt, lotNumber, machineName, LotStatusProcessing)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			tx.Rollback()
+			if r.isLotSoftDeleted(ctx, lotNumber) {
+				return ProductData{}, ErrLotDeleted
+			}
+			return ProductData{}, ErrLotExists
+		}
+		return ProductData{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ProductData{}, err
+	}
+	if err := updateLotProduct(ctx, tx, id, product); err != nil {
+		return ProductData{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ProductData{}, err
+	}
+
+	return r.productDataForLot(ctx, id)
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func updateLotProduct(ctx context.Context, db execer, lotID int64, input ProductInput) error {
 	activeMachine := toNullString(input.ActiveMachineID)
 	averages := toNullRawMessage(input.Averages)
 	opHour := toNullString(input.OperationHour)
 	good := toNullInt(input.GoodProduct)
 	defect := toNullInt(input.DefectProduct)
 	conclusion := toNullString(input.Conclusion)
+	var category sql.NullString
+	if input.ConclusionCategory != nil {
+		category = sql.NullString{String: string(*input.ConclusionCategory), Valid: true}
+	}
 
-	const stmt = `UPDATE lots SET active_machine_id = ?, averages_json = ?, operation_hour = ?, good_product = ?, defect_product = ?, conclusion = ?, is_conclusion = COALESCE(?, is_conclusion), updated_at = NOW() WHERE id = ?`
+	const stmt = `UPDATE lots SET active_machine_id = ?, averages_json = ?, operation_hour = ?, good_product = ?, defect_product = ?, conclusion = ?, is_conclusion = COALESCE(?, is_conclusion), conclusion_category = COALESCE(?, conclusion_category), updated_at = NOW() WHERE id = ?`
 	isConclusion := toNullBool(input.IsConclusion)
-	if _, err := r.db.ExecContext(ctx, stmt, activeMachine, averages, opHour, good, defect, conclusion, isConclusion, lot.ID); err != nil {
+	_, err := db.ExecContext(ctx, stmt, activeMachine, averages, opHour, good, defect, conclusion, isConclusion, category, lotID)
+	return err
+}
+
+// productDataForLot reloads a lot and renders it with its trend reference.
+func (r *Repository) productDataForLot(ctx context.Context, lotID int64) (ProductData, error) {
+	updated, err := r.GetLotByID(ctx, lotID)
+	if err != nil {
 		return ProductData{}, err
 	}
-
-	updated, err := r.GetLotByID(ctx, lot.ID)
+	now := time.Now().UTC()
+	previous, err := r.previousLotFor(ctx, updated, now)
 	if err != nil {
 		return ProductData{}, err
 	}
-	product, err := lotToProductData(updated, time.Now().UTC())
+	product, err := ProductDataFromLot(updated, previous, now)
 	if err != nil {
 		return ProductData{}, err
 	}
@@ -185,7 +288,7 @@ func (r *Repository) UpsertLotProduct(ctx context.Context, input ProductInput) (
 
 // GetLotByID retrieves a single lot record by its identifier.
 func (r *Repository) GetLotByID(ctx context.Context, id int64) (Lot, error) {
-	const query = `SELECT id, lot_number, machine_name, status, started_at, completed_at, updated_at, summary_json, active_machine_id, averages_json, operation_hour, good_product, defect_product, conclusion, is_conclusion FROM lots WHERE id = ?`
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE id = ? AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, id)
 	lot, err := scanLot(row)
 	if err != nil {
@@ -196,7 +299,7 @@ func (r *Repository) GetLotByID(ctx context.Context, id int64) (Lot, error) {
 
 // GetLotByNumber fetches a lot using its public identifier.
 func (r *Repository) GetLotByNumber(ctx context.Context, lotNumber string) (Lot, error) {
-	const query = `SELECT id, lot_number, machine_name, status, started_at, completed_at, updated_at, summary_json, active_machine_id, averages_json, operation_hour, good_product, defect_product, conclusion, is_conclusion FROM lots WHERE lot_number = ?`
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE lot_number = ? AND deleted_at IS NULL`
 	row := r.db.QueryRowContext(ctx, query, lotNumber)
 	lot, err := scanLot(row)
 	if err != nil {
@@ -205,16 +308,62 @@ func (r *Repository) GetLotByNumber(ctx context.Context, lotNumber string) (Lot,
 	return lot, nil
 }
 
-// ListLots returns all lots ordered by start time desc.
-func (r *Repository) ListLots(ctx context.Context) ([]Lot, error) {
-	const query = `SELECT id, lot_number, machine_name, status, started_at, completed_at, updated_at, summary_json, active_machine_id, averages_json, operation_hour, good_product, defect_product, conclusion, is_conclusion FROM lots ORDER BY started_at DESC`
-	rows, err := r.db.QueryContext(ctx, query)
+// ListLotsOptions narrows and pages lot listings. Zero values apply no filter.
+type ListLotsOptions struct {
+	Status             LotStatus
+	ConclusionCategory ConclusionCategory
+	Limit              int
+	Offset             int
+}
+
+// ListLots returns lots ordered by start time desc.
+func (r *Repository) ListLots(ctx context.Context, opts ListLotsOptions) ([]Lot, error) {
+	return r.listLots(ctx, "", opts)
+}
+
+// ListLotsByMachine returns the lots of a single machine, matched
+// case-insensitively on the normalized machine name, ordered by start time desc.
+func (r *Repository) ListLotsByMachine(ctx context.Context, machineName string, opts ListLotsOptions) ([]Lot, error) {
+	machineName = NormalizeMachineName(machineName)
+	if machineName == "" {
+		return nil, ErrMachineNameRequired
+	}
+	return r.listLots(ctx, machineName, opts)
+}
+
+func (r *Repository) listLots(ctx context.Context, machineName string, opts ListLotsOptions) ([]Lot, error) {
+	query := `SELECT ` + lotColumns + ` FROM lots WHERE deleted_at IS NULL`
+	var args []any
+	if machineName != "" {
+		query += ` AND machine_name = ?`
+		args = append(args, machineName)
+	}
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.ConclusionCategory != "" {
+		query += ` AND conclusion_category = ?`
+		args = append(args, opts.ConclusionCategory)
+	}
+	query += ` ORDER BY started_at DESC`
+	switch {
+	case opts.Limit > 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, opts.Limit, max(opts.Offset, 0))
+	case opts.Offset > 0:
+		// MySQL has no OFFSET without LIMIT; use the documented maximum.
+		query += ` LIMIT 18446744073709551615 OFFSET ?`
+		args = append(args, opts.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var lots []Lot
+	lots := []Lot{}
 	for rows.Next() {
 		lot, err := scanLot(rows)
 		if err != nil {
@@ -227,7 +376,7 @@ func (r *Repository) ListLots(ctx context.Context) ([]Lot, error) {
 
 // ListActiveLots returns lots that are not yet completed.
 func (r *Repository) ListActiveLots(ctx context.Context) ([]Lot, error) {
-	const query = `SELECT id, lot_number, machine_name, status, started_at, completed_at, updated_at, summary_json, active_machine_id, averages_json, operation_hour, good_product, defect_product, conclusion, is_conclusion FROM lots WHERE status = ? ORDER BY started_at`
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE status = ? AND deleted_at IS NULL ORDER BY started_at`
 	rows, err := r.db.QueryContext(ctx, query, LotStatusProcessing)
 	if err != nil {
 		return nil, err
@@ -245,9 +394,30 @@ func (r *Repository) ListActiveLots(ctx context.Context) ([]Lot, error) {
 	return lots, rows.Err()
 }
 
+// ListLotsCompletedBetween returns completed lots whose completed_at falls in
+// [start, stop), oldest first. The range is served by idx_lots_completed_at.
+func (r *Repository) ListLotsCompletedBetween(ctx context.Context, start, stop time.Time) ([]Lot, error) {
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE status = ? AND deleted_at IS NULL AND completed_at >= ? AND completed_at < ? ORDER BY completed_at`
+	rows, err := r.db.QueryContext(ctx, query, LotStatusCompleted, start.UTC(), stop.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []Lot{}
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
 // HasActiveLots reports whether any lots are currently in processing state.
 func (r *Repository) HasActiveLots(ctx context.Context) (bool, error) {
-	const query = `SELECT 1 FROM lots WHERE status = ? LIMIT 1`
+	const query = `SELECT 1 FROM lots WHERE status = ? AND deleted_at IS NULL LIMIT 1`
 	var flag int
 	err := r.db.QueryRowContext(ctx, query, LotStatusProcessing).Scan(&flag)
 	switch {
@@ -260,15 +430,27 @@ func (r *Repository) HasActiveLots(ctx context.Context) (bool, error) {
 	}
 }
 
-// MarkLotCompleted updates a lot as completed and stores the summary payload.
-func (r *Repository) MarkLotCompleted(ctx context.Context, lotID int64, summary LotSummary) error {
+// CountActiveLots returns the number of lots currently in processing state. The
+// status equality predicate is served by idx_lots_status.
+func (r *Repository) CountActiveLots(ctx context.Context) (int, error) {
+	const query = `SELECT COUNT(*) FROM lots WHERE status = ? AND deleted_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, LotStatusProcessing).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ReplaceLotSummary overwrites a completed lot's stored summary. It returns
+// ErrLotNotCompleted when the lot is missing or still processing.
+func (r *Repository) ReplaceLotSummary(ctx context.Context, lotID int64, summary LotSummary) error {
 	payload, err := json.Marshal(summary)
 	if err != nil {
 		return fmt.Errorf("marshal lot summary: %w", err)
 	}
 
-	const stmt = `UPDATE lots SET status = ?, completed_at = ?, summary_json = ? WHERE id = ? AND status = ?`
-	res, err := r.db.ExecContext(ctx, stmt, LotStatusCompleted, summary.CompletedAt.UTC(), string(payload), lotID, LotStatusProcessing)
+	const stmt = `UPDATE lots SET summary_json = ? WHERE id = ? AND status = ?`
+	res, err := r.db.ExecContext(ctx, stmt, string(payload), lotID, LotStatusCompleted)
 	if err != nil {
 		return err
 	}
@@ -277,7 +459,7 @@ func (r *Repository) MarkLotCompleted(ctx context.Context, lotID int64, summary
 		return err
 	}
 	if rows == 0 {
-		return sql.ErrNoRows
+		return ErrLotNotCompleted
 	}
 	return nil
 }
@@ -294,38 +476,43 @@ func (l Lot) Summary() (*LotSummary, error) {
 	return &summary, nil
 }
 
-// ListProductData returns lot records transformed to product-centric payloads.
-func (r *Repository) ListProductData(ctx context.Context) ([]ProductData, error) {
-	lots, err := r.ListLots(ctx)
+// GetProductData returns the product payload for a single lot.
+func (r *Repository) GetProductData(ctx context.Context, lotNumber string) (ProductData, error) {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return ProductData{}, ErrLotNumberRequired
+	}
+	lot, err := r.GetLotByNumber(ctx, lotNumber)
 	if err != nil {
-		return nil, err
+		return ProductData{}, err
 	}
-
-	products := make([]ProductData, 0, len(lots))
 	now := time.Now().UTC()
-	for _, lot := range lots {
-		product, err := lotToProductData(lot, now)
-		if err != nil {
-			return nil, err
-		}
-		products = append(products, product)
+	previous, err := r.previousLotFor(ctx, lot, now)
+	if err != nil {
+		return ProductData{}, err
 	}
-
-	return products, nil
+	return ProductDataFromLot(lot, previous, now)
 }
 
 // BackfillCandidate represents a lot row needing computed fields.
 type BackfillCandidate struct {
-	ID          int64
-	LotNumber   string
-	MachineName string
-	StartedAt   time.Time
-	CompletedAt sql.NullTime
-}
-
-// ListCompletedLotsMissingData returns completed lots where averages or operation_hour are missing.
+	ID            int64
+	LotNumber     string
+	MachineName   string
+	StartedAt     time.Time
+	CompletedAt   sql.NullTime
+	OperationHour *string
+	Averages      json.RawMessage
+	// MissingRanges is true when the summary lacks per-sensor min/max values.
+	MissingRanges bool
+}
+
+// ListCompletedLotsMissingData returns completed lots where averages, operation_hour or
+// per-sensor min/max values are missing. Lots whose ranges were checked and found
+// no readings do not count as missing them.
 func (r *Repository) ListCompletedLotsMissingData(ctx context.Context) ([]BackfillCandidate, error) {
-	const query = `SELECT id, lot_number, machine_name, started_at, completed_at FROM lots WHERE status = ? AND (averages_json IS NULL OR operation_hour IS NULL)`
+	const missingRanges = `JSON_CONTAINS_PATH(COALESCE(summary_json, JSON_OBJECT()), 'one', '$.sensors[*].minValue', '$.rangesCheckedAt') = 0`
+	const query = `SELECT id, lot_number, machine_name, started_at, completed_at, operation_hour, averages_json, ` + missingRanges + ` FROM lots WHERE status = ? AND deleted_at IS NULL AND (averages_json IS NULL OR operation_hour IS NULL OR ` + missingRanges + `)`
 	rows, err := r.db.QueryContext(ctx, query, LotStatusCompleted)
 	if err != nil {
 		return nil, err
@@ -334,10 +521,23 @@ func (r *Repository) ListCompletedLotsMissingData(ctx context.Context) ([]Backfi
 
 	var list []BackfillCandidate
 	for rows.Next() {
-		var b BackfillCandidate
-		if err := rows.Scan(&b.ID, &b.LotNumber, &b.MachineName, &b.StartedAt, &b.CompletedAt); err != nil {
+		var (
+			b        BackfillCandidate
+			opHour   sql.NullString
+			averages sql.NullString
+		)
+		if err := rows.Scan(&b.ID, &b.LotNumber, &b.MachineName, &b.StartedAt, &b.CompletedAt, &opHour, &averages, &b.MissingRanges); err != nil {
 			return nil, err
 		}
+		if opHour.Valid {
+			value := strings.TrimSpace(opHour.String)
+			if value != "" {
+				b.OperationHour = &value
+			}
+		}
+		if averages.Valid {
+			b.Averages = json.RawMessage(averages.String)
+		}
 		list = append(list, b)
 	}
 	return list, rows.Err()
@@ -363,23 +563,25 @@ func (r *Repository) UpdateLotComputedFields(ctx context.Context, id int64, opHo
 	return err
 }
 
-func lotToProductData(lot Lot, fallbackNow time.Time) (ProductData, error) {
+// ProductDataFromLot builds the product payload of lot. previous is the lot
+// before it on the same machine, for averagesDelta, and fallbackNow stands in
+// for missing timestamps.
+func ProductDataFromLot(lot Lot, previous *Lot, fallbackNow time.Time) (ProductData, error) {
 	summary, err := lot.Summary()
 	if err != nil {
 		return ProductData{}, fmt.Errorf("parse summary for lot %s: %w", lot.LotNumber, err)
 	}
 
-	averages, err := decodeLotAverages(lot.Averages)
+	averages, err := resolveAverages(lot, summary)
 	if err != nil {
-		return ProductData{}, fmt.Errorf("parse averages for lot %s: %w", lot.LotNumber, err)
+		return ProductData{}, err
 	}
-	if len(averages) == 0 && summary != nil {
-		for _, sensor := range summary.Sensors {
-			name := strings.TrimSpace(sensor.SensorName)
-			if name == "" {
-				continue
-			}
-			averages[strings.ToLower(name)] = sensor.LatestValue
+
+	delta := map[string]float64{}
+	if previous != nil {
+		delta, err = averagesDelta(averages, *previous)
+		if err != nil {
+			return ProductData{}, err
 		}
 	}
 
@@ -424,19 +626,44 @@ func lotToProductData(lot Lot, fallbackNow time.Time) (ProductData, error) {
 	}
 
 	return ProductData{
-		Lot:             lot.LotNumber,
-		Status:          lot.Status,
-		ActiveMachineID: activeMachineID,
-		Averages:        averages,
-		OperationHour:   operationHour,
-		GoodProduct:     goodProduct,
-		DefectProduct:   defectProduct,
-		Conclusion:      conclusion,
-		IsConclusion:    lot.IsConclusion,
-		UpdatedAt:       updatedAt,
+		Lot:                lot.LotNumber,
+		Status:             lot.Status,
+		ActiveMachineID:    activeMachineID,
+		Averages:           averages,
+		AveragesDelta:      delta,
+		OperationHour:      operationHour,
+		GoodProduct:        goodProduct,
+		DefectProduct:      defectProduct,
+		Conclusion:         conclusion,
+		ConclusionCategory: lot.ConclusionCategory,
+		IsConclusion:       lot.IsConclusion,
+		UpdatedAt:          updatedAt,
 	}, nil
 }
 
+// AverageValues decodes the lot's stored averages; it is empty when none are stored.
+func (l Lot) AverageValues() (map[string]float64, error) {
+	return decodeLotAverages(l.Averages)
+}
+
+// resolveAverages returns stored averages, falling back to the summary's latest values.
+func resolveAverages(lot Lot, summary *LotSummary) (map[string]float64, error) {
+	averages, err := decodeLotAverages(lot.Averages)
+	if err != nil {
+		return nil, fmt.Errorf("parse averages for lot %s: %w", lot.LotNumber, err)
+	}
+	if len(averages) == 0 && summary != nil {
+		for _, sensor := range summary.Sensors {
+			name := strings.TrimSpace(sensor.SensorName)
+			if name == "" {
+				continue
+			}
+			averages[strings.ToLower(name)] = sensor.LatestValue
+		}
+	}
+	return averages, nil
+}
+
 func decodeLotAverages(raw json.RawMessage) (map[string]float64, error) {
 	if len(raw) == 0 {
 		return map[string]float64{}, nil
@@ -536,6 +763,7 @@ func scanLot(scanner rowScanner) (Lot, error) {
 		defectProduct sql.NullInt64
 		conclusion    sql.NullString
 		isConclusion  sql.NullBool
+		category      sql.NullString
 	)
 	if err := scanner.Scan(
 		&lot.ID,
@@ -553,6 +781,7 @@ func scanLot(scanner rowScanner) (Lot, error) {
 		&defectProduct,
 		&conclusion,
 		&isConclusion,
+		&category,
 	); err != nil {
 		return Lot{}, err
 	}
@@ -592,6 +821,10 @@ func scanLot(scanner rowScanner) (Lot, error) {
 	if isConclusion.Valid {
 		lot.IsConclusion = isConclusion.Bool
 	}
+	lot.ConclusionCategory = ConclusionNone
+	if category.Valid && category.String != "" {
+		lot.ConclusionCategory = ConclusionCategory(category.String)
+	}
 	return lot, nil
 }
 
@@ -627,16 +860,48 @@ func toNullBool(value *bool) sql.NullBool {
 	return sql.NullBool{Bool: *value, Valid: true}
 }
 
-// DeleteLotByNumber removes a lot (and its product data) by lot number.
+// DeleteLotByNumber soft-deletes a lot by lot number so it can later be restored.
 // Returns ErrLotNumberRequired when lotNumber is empty and ErrLotNotFound
-// when no matching row exists.
+// when no matching live row exists.
 func (r *Repository) DeleteLotByNumber(ctx context.Context, lotNumber string) error {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return ErrLotNumberRequired
+	}
+	const stmt = `UPDATE lots SET deleted_at = NOW() WHERE lot_number = ? AND deleted_at IS NULL`
+	return execAffectingLot(ctx, r.db, stmt, lotNumber)
+}
+
+// PurgeLotByNumber permanently removes a lot (and its product data), including
+// soft-deleted rows.
+func (r *Repository) PurgeLotByNumber(ctx context.Context, lotNumber string) error {
 	lotNumber = strings.TrimSpace(lotNumber)
 	if lotNumber == "" {
 		return ErrLotNumberRequired
 	}
 	const stmt = `DELETE FROM lots WHERE lot_number = ?`
-	res, err := r.db.ExecContext(ctx, stmt, lotNumber)
+	return execAffectingLot(ctx, r.db, stmt, lotNumber)
+}
+
+// RestoreLot clears the soft-delete marker of a lot. Returns ErrLotNotFound when
+// no soft-deleted lot with that number exists.
+func (r *Repository) RestoreLot(ctx context.Context, lotNumber string) error {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return ErrLotNumberRequired
+	}
+	const stmt = `UPDATE lots SET deleted_at = NULL WHERE lot_number = ? AND deleted_at IS NOT NULL`
+	return execAffectingLot(ctx, r.db, stmt, lotNumber)
+}
+
+func (r *Repository) isLotSoftDeleted(ctx context.Context, lotNumber string) bool {
+	const query = `SELECT 1 FROM lots WHERE lot_number = ? AND deleted_at IS NOT NULL`
+	var flag int
+	return r.db.QueryRowContext(ctx, query, lotNumber).Scan(&flag) == nil
+}
+
+func execAffectingLot(ctx context.Context, db *sql.DB, stmt string, args ...any) error {
+	res, err := db.ExecContext(ctx, stmt, args...)
 	if err != nil {
 		return err
 	}
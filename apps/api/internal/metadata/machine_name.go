@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Machine names keep the case they were registered with but match
+// case-insensitively: "Furnace-01" and " furnace-01 " name the same machine.
+// The canonical form of a name is the spelling stored in the machines table,
+// after NormalizeMachineName. Lots take that spelling when they are created, so
+// the name they carry matches the machine_name tag the simulator writes to
+// InfluxDB, where tag comparisons are case-sensitive. MySQL matches names through
+// a case-insensitive collation on the machine_name columns.
+
+// NormalizeMachineName trims name and collapses inner runs of whitespace to a
+// single space, leaving its case as given.
+func NormalizeMachineName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SameMachine reports whether a and b name the same machine.
+func SameMachine(a, b string) bool {
+	return strings.EqualFold(NormalizeMachineName(a), NormalizeMachineName(b))
+}
+
+// rowQuerier is satisfied by *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// registeredMachineName returns the stored spelling of the machine matching the
+// normalized name, or the normalized name when no machine is registered under it.
+func registeredMachineName(ctx context.Context, q rowQuerier, name string) (string, error) {
+	name = NormalizeMachineName(name)
+	var stored string
+	err := q.QueryRowContext(ctx, `SELECT machine_name FROM machines WHERE machine_name = ?`, name).Scan(&stored)
+	switch {
+	case err == nil:
+		return stored, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return name, nil
+	default:
+		return "", err
+	}
+}
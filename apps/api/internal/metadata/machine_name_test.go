@@ -0,0 +1,146 @@
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/mysql/mysqltest"
+)
+
+func TestNormalizeMachineName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Furnace-01", "Furnace-01"},
+		{"  Furnace-01 ", "Furnace-01"},
+		{"Paint  Line\t2", "Paint Line 2"},
+		{"furnace-01", "furnace-01"},
+		{" \t ", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeMachineName(tt.in); got != tt.want {
+			t.Errorf("NormalizeMachineName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSameMachine(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Furnace-01", "Furnace-01", true},
+		{"Furnace-01", "furnace-01", true},
+		{"Furnace-01", " FURNACE-01 ", true},
+		{"Paint Line 2", "paint  line 2", true},
+		{"Furnace-01", "Furnace-02", false},
+		{"Furnace-01", "Furnace01", false},
+		{"", "  ", true},
+	}
+	for _, tt := range tests {
+		if got := SameMachine(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameMachine(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// registeredServer answers machine name lookups from registered, matching
+// case-insensitively as the machine_name collation does, and records lots
+// inserted through it.
+func registeredServer(registered ...string) *mysqltest.Server {
+	var inserted []driver.Value
+	columns := strings.Split(lotColumns, ", ")
+	created := time.Date(2024, 6, 15, 8, 0, 0, 0, time.UTC)
+	return &mysqltest.Server{
+		Query: func(c mysqltest.Call) (*mysqltest.Rows, error) {
+			switch {
+			case c.Query == "SELECT machine_name FROM machines WHERE machine_name = ?":
+				out := &mysqltest.Rows{Columns: []string{"machine_name"}}
+				for _, name := range registered {
+					if strings.EqualFold(name, c.Args[0].(string)) {
+						out.Values = append(out.Values, []driver.Value{name})
+					}
+				}
+				return out, nil
+			case strings.HasSuffix(c.Query, "FROM lots WHERE id = ? AND deleted_at IS NULL"):
+				row := []driver.Value{
+					int64(1), inserted[0], inserted[1], inserted[2], created, nil, created,
+					nil, nil, nil, nil, int64(0), int64(0), nil, false, string(ConclusionNone),
+				}
+				return &mysqltest.Rows{Columns: columns, Values: [][]driver.Value{row}}, nil
+			}
+			return nil, fmt.Errorf("unexpected query: %s", c.Query)
+		},
+		Exec: func(c mysqltest.Call) (mysqltest.Result, error) {
+			if c.Query != "INSERT INTO lots (lot_number, machine_name, status) VALUES (?, ?, ?)" {
+				return mysqltest.Result{}, fmt.Errorf("unexpected statement: %s", c.Query)
+			}
+			inserted = c.Args
+			return mysqltest.Result{LastInsertID: 1, RowsAffected: 1}, nil
+		},
+	}
+}
+
+func TestRegisteredMachineName(t *testing.T) {
+	tests := []struct {
+		name       string
+		registered []string
+		in         string
+		want       string
+	}{
+		{"registered spelling", []string{"Furnace-01"}, "Furnace-01", "Furnace-01"},
+		{"case mismatch", []string{"Furnace-01"}, "furnace-01", "Furnace-01"},
+		{"surrounding spaces", []string{"Furnace-01"}, "  FURNACE-01 ", "Furnace-01"},
+		{"inner spaces", []string{"Paint Line 2"}, "paint   line 2", "Paint Line 2"},
+		{"unregistered", []string{"Furnace-01"}, " oven-01 ", "oven-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := registeredServer(tt.registered...).DB()
+			defer db.Close()
+			got, err := registeredMachineName(context.Background(), db, tt.in)
+			if err != nil {
+				t.Fatalf("registeredMachineName: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("registeredMachineName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateLotTakesRegisteredSpelling(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine string
+		want    string
+	}{
+		{"case mismatch", "furnace-01", "Furnace-01"},
+		{"surrounding spaces", " FURNACE-01 ", "Furnace-01"},
+		{"unregistered", "Oven-02", "Oven-02"},
+		{"no machine", "", "LOT-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := registeredServer("Furnace-01")
+			db := srv.DB()
+			defer db.Close()
+			lot, err := NewRepository(db).CreateLot(context.Background(), CreateLotInput{LotNumber: "LOT-1", MachineName: tt.machine})
+			if err != nil {
+				t.Fatalf("CreateLot: %v", err)
+			}
+			if lot.MachineName != tt.want {
+				t.Errorf("lot machine = %q, want %q", lot.MachineName, tt.want)
+			}
+			for _, c := range srv.Calls() {
+				if strings.HasPrefix(c.Query, "INSERT INTO lots") && c.Args[1] != tt.want {
+					t.Errorf("inserted machine = %v, want %q", c.Args[1], tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,825 @@
+// Package metadatatest provides an in-memory stand-in for metadata.Repository,
+// so handlers can be tested without MySQL.
+package metadatatest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+// Store keeps lots, machines and idempotent responses in memory. It mirrors
+// the Repository's validation and sentinel errors; SQL-specific behaviour such
+// as connection errors is only simulated through PingErr. The zero value is not
+// usable; call New.
+type Store struct {
+	mu       sync.Mutex
+	nextID   int64
+	lots     map[int64]*storedLot
+	machines []metadata.Machine
+	idem     map[string]metadata.IdempotentResponse
+	// finalizing serialises FinalizeLot calls in place of the row lock.
+	finalizing sync.Mutex
+
+	// PingErr is returned by Ping when set.
+	PingErr error
+	// Now supplies timestamps; it defaults to time.Now.
+	Now func() time.Time
+}
+
+type storedLot struct {
+	lot     metadata.Lot
+	deleted bool
+}
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{
+		lots: map[int64]*storedLot{},
+		idem: map[string]metadata.IdempotentResponse{},
+		Now:  time.Now,
+	}
+}
+
+// PutLot stores lot as-is, assigning an ID when it has none, and returns it.
+// Tests use it to seed lots in states the API cannot reach directly.
+func (s *Store) PutLot(lot metadata.Lot) metadata.Lot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lot.ID == 0 {
+		s.nextID++
+		lot.ID = s.nextID
+	} else if lot.ID > s.nextID {
+		s.nextID = lot.ID
+	}
+	s.lots[lot.ID] = &storedLot{lot: lot}
+	return lot
+}
+
+func (s *Store) now() time.Time {
+	return s.Now().UTC()
+}
+
+// Ping returns PingErr.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.PingErr
+}
+
+func (s *Store) findLocked(lotNumber string, deleted bool) *storedLot {
+	for _, stored := range s.lots {
+		if stored.lot.LotNumber == lotNumber && stored.deleted == deleted {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (s *Store) createLotLocked(lotNumber, machineName string) (*storedLot, error) {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return nil, metadata.ErrLotNumberRequired
+	}
+	if s.findLocked(lotNumber, true) != nil {
+		return nil, metadata.ErrLotDeleted
+	}
+	if s.findLocked(lotNumber, false) != nil {
+		return nil, metadata.ErrLotExists
+	}
+	machineName = metadata.NormalizeMachineName(machineName)
+	if machineName == "" {
+		machineName = lotNumber
+	}
+	if m, ok := s.machineLocked(machineName); ok {
+		machineName = m.MachineName
+	}
+	now := s.now()
+	s.nextID++
+	stored := &storedLot{lot: metadata.Lot{
+		ID:          s.nextID,
+		LotNumber:   lotNumber,
+		MachineName: machineName,
+		Status:      metadata.LotStatusProcessing,
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}}
+	s.lots[stored.lot.ID] = stored
+	return stored, nil
+}
+
+// CreateLot adds a processing lot.
+func (s *Store) CreateLot(ctx context.Context, input metadata.CreateLotInput) (metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.createLotLocked(input.LotNumber, input.MachineName)
+	if err != nil {
+		return metadata.Lot{}, err
+	}
+	return stored.lot, nil
+}
+
+// CreateLotWithProduct adds a processing lot with product data, or nothing on error.
+func (s *Store) CreateLotWithProduct(ctx context.Context, lot metadata.CreateLotInput, product metadata.ProductInput) (metadata.ProductData, error) {
+	if err := metadata.ValidateProductInput(product); err != nil {
+		return metadata.ProductData{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.createLotLocked(lot.LotNumber, lot.MachineName)
+	if err != nil {
+		return metadata.ProductData{}, err
+	}
+	s.applyProduct(stored, product)
+	return s.productDataLocked(stored.lot)
+}
+
+// UpsertLotProduct stores product data for a lot, creating the lot when needed.
+func (s *Store) UpsertLotProduct(ctx context.Context, input metadata.ProductInput) (metadata.ProductData, error) {
+	lotNumber := strings.TrimSpace(input.LotNumber)
+	if lotNumber == "" {
+		return metadata.ProductData{}, metadata.ErrLotNumberRequired
+	}
+	if err := metadata.ValidateProductInput(input); err != nil {
+		return metadata.ProductData{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findLocked(lotNumber, false)
+	if stored == nil {
+		var err error
+		if stored, err = s.createLotLocked(lotNumber, input.MachineName); err != nil {
+			return metadata.ProductData{}, err
+		}
+	}
+	s.applyProduct(stored, input)
+	return s.productDataLocked(stored.lot)
+}
+
+func (s *Store) applyProduct(stored *storedLot, input metadata.ProductInput) {
+	lot := &stored.lot
+	lot.ActiveMachineID = input.ActiveMachineID
+	lot.Averages = input.Averages
+	lot.OperationHour = input.OperationHour
+	lot.GoodProduct = input.GoodProduct
+	lot.DefectProduct = input.DefectProduct
+	lot.Conclusion = input.Conclusion
+	if input.IsConclusion != nil {
+		lot.IsConclusion = *input.IsConclusion
+	}
+	if input.ConclusionCategory != nil {
+		lot.ConclusionCategory = *input.ConclusionCategory
+	}
+	lot.UpdatedAt = s.now()
+}
+
+// GetLotByNumber returns a live lot.
+func (s *Store) GetLotByNumber(ctx context.Context, lotNumber string) (metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findLocked(strings.TrimSpace(lotNumber), false)
+	if stored == nil {
+		return metadata.Lot{}, metadata.ErrLotNotFound
+	}
+	return stored.lot, nil
+}
+
+// liveLotsLocked returns live lots matching filter, newest start first.
+func (s *Store) liveLotsLocked(filter func(metadata.Lot) bool) []metadata.Lot {
+	lots := []metadata.Lot{}
+	for _, stored := range s.lots {
+		if !stored.deleted && filter(stored.lot) {
+			lots = append(lots, stored.lot)
+		}
+	}
+	sort.Slice(lots, func(i, j int) bool {
+		if !lots[i].StartedAt.Equal(lots[j].StartedAt) {
+			return lots[i].StartedAt.After(lots[j].StartedAt)
+		}
+		return lots[i].ID > lots[j].ID
+	})
+	return lots
+}
+
+func matchesOptions(lot metadata.Lot, opts metadata.ListLotsOptions) bool {
+	if opts.Status != "" && lot.Status != opts.Status {
+		return false
+	}
+	return opts.ConclusionCategory == "" || lot.ConclusionCategory == opts.ConclusionCategory
+}
+
+func page[T any](items []T, opts metadata.ListLotsOptions) []T {
+	offset := max(opts.Offset, 0)
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if opts.Limit > 0 && opts.Limit < len(items) {
+		items = items[:opts.Limit]
+	}
+	return items
+}
+
+// ListLots returns live lots, newest start first.
+func (s *Store) ListLots(ctx context.Context, opts metadata.ListLotsOptions) ([]metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool { return matchesOptions(lot, opts) })
+	return page(lots, opts), nil
+}
+
+// ListLotsByMachine returns one machine's live lots, newest start first.
+func (s *Store) ListLotsByMachine(ctx context.Context, machineName string, opts metadata.ListLotsOptions) ([]metadata.Lot, error) {
+	machineName = metadata.NormalizeMachineName(machineName)
+	if machineName == "" {
+		return nil, metadata.ErrMachineNameRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool {
+		return metadata.SameMachine(lot.MachineName, machineName) && matchesOptions(lot, opts)
+	})
+	return page(lots, opts), nil
+}
+
+// ListLotsCompletedBetween returns live lots completed within [start, stop),
+// oldest completion first.
+func (s *Store) ListLotsCompletedBetween(ctx context.Context, start, stop time.Time) ([]metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool {
+		return lot.Status == metadata.LotStatusCompleted && lot.CompletedAt.Valid &&
+			!lot.CompletedAt.Time.Before(start) && lot.CompletedAt.Time.Before(stop)
+	})
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].CompletedAt.Time.Before(lots[j].CompletedAt.Time) })
+	return lots, nil
+}
+
+// CountActiveLots counts live processing lots.
+func (s *Store) CountActiveLots(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.liveLotsLocked(func(lot metadata.Lot) bool { return lot.Status == metadata.LotStatusProcessing })), nil
+}
+
+// ListActiveLots returns live processing lots, earliest start first.
+func (s *Store) ListActiveLots(ctx context.Context) ([]metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool { return lot.Status == metadata.LotStatusProcessing })
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].StartedAt.Before(lots[j].StartedAt) })
+	return lots, nil
+}
+
+func (s *Store) liveByIDLocked(id int64) *storedLot {
+	stored, ok := s.lots[id]
+	if !ok || stored.deleted {
+		return nil
+	}
+	return stored
+}
+
+// ReplaceLotSummary overwrites a completed lot's summary.
+func (s *Store) ReplaceLotSummary(ctx context.Context, lotID int64, summary metadata.LotSummary) error {
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("marshal lot summary: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.lots[lotID]
+	if !ok || stored.lot.Status != metadata.LotStatusCompleted {
+		return metadata.ErrLotNotCompleted
+	}
+	stored.lot.SummaryJSON = payload
+	return nil
+}
+
+// UpdateLotComputedFields stores computed averages and operation hours.
+func (s *Store) UpdateLotComputedFields(ctx context.Context, id int64, opHour *string, averagesJSON *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.lots[id]
+	if !ok {
+		return nil
+	}
+	if averagesJSON != nil {
+		stored.lot.Averages = json.RawMessage(*averagesJSON)
+	} else {
+		stored.lot.Averages = nil
+	}
+	stored.lot.OperationHour = opHour
+	return nil
+}
+
+// UpdateLotSensorRanges merges per-sensor min/max values into a lot's summary.
+func (s *Store) UpdateLotSensorRanges(ctx context.Context, id int64, ranges map[string]metadata.SensorRange) error {
+	return s.rewriteSummary(id, func(summary *metadata.LotSummary) {
+		metadata.ApplySensorRanges(summary, ranges)
+	})
+}
+
+// MarkLotRangesChecked records that no readings were found for a lot's ranges.
+func (s *Store) MarkLotRangesChecked(ctx context.Context, id int64) error {
+	return s.rewriteSummary(id, func(summary *metadata.LotSummary) {
+		metadata.MarkRangesChecked(summary, s.now())
+	})
+}
+
+func (s *Store) rewriteSummary(id int64, change func(*metadata.LotSummary)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.liveByIDLocked(id)
+	if stored == nil {
+		return metadata.ErrLotNotFound
+	}
+	summary, err := stored.lot.Summary()
+	if err != nil {
+		return err
+	}
+	if summary == nil {
+		summary = &metadata.LotSummary{MachineName: stored.lot.MachineName}
+		if stored.lot.CompletedAt.Valid {
+			summary.CompletedAt = stored.lot.CompletedAt.Time
+		}
+	}
+	change(summary)
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	stored.lot.SummaryJSON = payload
+	return nil
+}
+
+// ListCompletedLotsMissingData returns completed lots lacking averages,
+// operation hours or sensor ranges.
+func (s *Store) ListCompletedLotsMissingData(ctx context.Context) ([]metadata.BackfillCandidate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var candidates []metadata.BackfillCandidate
+	for _, lot := range s.liveLotsLocked(func(lot metadata.Lot) bool { return lot.Status == metadata.LotStatusCompleted }) {
+		missingRanges := true
+		if summary, err := lot.Summary(); err == nil && summary != nil {
+			missingRanges = summary.RangesCheckedAt == nil
+			for _, sensor := range summary.Sensors {
+				if sensor.MinValue != nil {
+					missingRanges = false
+					break
+				}
+			}
+		}
+		if len(lot.Averages) > 0 && lot.OperationHour != nil && !missingRanges {
+			continue
+		}
+		candidates = append(candidates, metadata.BackfillCandidate{
+			ID:            lot.ID,
+			LotNumber:     lot.LotNumber,
+			MachineName:   lot.MachineName,
+			StartedAt:     lot.StartedAt,
+			CompletedAt:   lot.CompletedAt,
+			OperationHour: lot.OperationHour,
+			Averages:      lot.Averages,
+			MissingRanges: missingRanges,
+		})
+	}
+	return candidates, nil
+}
+
+// DeleteLotByNumber soft-deletes a live lot.
+func (s *Store) DeleteLotByNumber(ctx context.Context, lotNumber string) error {
+	return s.setDeleted(lotNumber, false, true)
+}
+
+// RestoreLot undoes a soft delete.
+func (s *Store) RestoreLot(ctx context.Context, lotNumber string) error {
+	return s.setDeleted(lotNumber, true, false)
+}
+
+func (s *Store) setDeleted(lotNumber string, from, to bool) error {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return metadata.ErrLotNumberRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findLocked(lotNumber, from)
+	if stored == nil {
+		return metadata.ErrLotNotFound
+	}
+	stored.deleted = to
+	return nil
+}
+
+// PurgeLotByNumber removes a lot, live or soft-deleted.
+func (s *Store) PurgeLotByNumber(ctx context.Context, lotNumber string) error {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return metadata.ErrLotNumberRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, stored := range s.lots {
+		if stored.lot.LotNumber == lotNumber {
+			delete(s.lots, id)
+			return nil
+		}
+	}
+	return metadata.ErrLotNotFound
+}
+
+func completedBefore(lot metadata.Lot, cutoff time.Time) bool {
+	return lot.Status == metadata.LotStatusCompleted && lot.CompletedAt.Valid && lot.CompletedAt.Time.Before(cutoff)
+}
+
+// ListCompletedLotsBefore returns lots, including soft-deleted ones, completed
+// before cutoff, oldest first.
+func (s *Store) ListCompletedLotsBefore(ctx context.Context, cutoff time.Time) ([]metadata.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := []metadata.Lot{}
+	for _, stored := range s.lots {
+		if completedBefore(stored.lot, cutoff) {
+			lots = append(lots, stored.lot)
+		}
+	}
+	sort.Slice(lots, func(i, j int) bool { return lots[i].CompletedAt.Time.Before(lots[j].CompletedAt.Time) })
+	return lots, nil
+}
+
+// DeleteCompletedLotsBefore removes the lots ListCompletedLotsBefore returns.
+func (s *Store) DeleteCompletedLotsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for id, stored := range s.lots {
+		if completedBefore(stored.lot, cutoff) {
+			delete(s.lots, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// RetainedLotOverlaps reports whether a lot surviving a purge at cutoff ran on
+// machineName within [start, stop].
+func (s *Store) RetainedLotOverlaps(ctx context.Context, machineName string, start, stop, cutoff time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stored := range s.lots {
+		lot := stored.lot
+		if !metadata.SameMachine(lot.MachineName, machineName) || completedBefore(lot, cutoff) || lot.StartedAt.After(stop) {
+			continue
+		}
+		if !lot.CompletedAt.Valid || !lot.CompletedAt.Time.Before(start) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ResetLots removes every lot and, unless keepMachines is set, every machine.
+func (s *Store) ResetLots(ctx context.Context, keepMachines bool) (lots, machines int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots = len(s.lots)
+	s.lots = map[int64]*storedLot{}
+	if !keepMachines {
+		machines = len(s.machines)
+		s.machines = nil
+	}
+	return lots, machines, nil
+}
+
+// previousLocked is the latest live completed lot on lot's machine that
+// finished before lot, or now while lot is processing.
+func (s *Store) previousLocked(lot metadata.Lot) *metadata.Lot {
+	reference := s.now()
+	if lot.CompletedAt.Valid {
+		reference = lot.CompletedAt.Time
+	}
+	var previous *metadata.Lot
+	for _, stored := range s.lots {
+		candidate := stored.lot
+		if stored.deleted || !metadata.SameMachine(candidate.MachineName, lot.MachineName) || candidate.Status != metadata.LotStatusCompleted ||
+			!candidate.CompletedAt.Valid || !candidate.CompletedAt.Time.Before(reference) {
+			continue
+		}
+		if previous == nil || candidate.CompletedAt.Time.After(previous.CompletedAt.Time) {
+			previous = &candidate
+		}
+	}
+	return previous
+}
+
+func (s *Store) productDataLocked(lot metadata.Lot) (metadata.ProductData, error) {
+	return metadata.ProductDataFromLot(lot, s.previousLocked(lot), s.now())
+}
+
+// GetProductData renders a live lot as product data.
+func (s *Store) GetProductData(ctx context.Context, lotNumber string) (metadata.ProductData, error) {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return metadata.ProductData{}, metadata.ErrLotNumberRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findLocked(lotNumber, false)
+	if stored == nil {
+		return metadata.ProductData{}, metadata.ErrLotNotFound
+	}
+	return s.productDataLocked(stored.lot)
+}
+
+// ListProductData renders a page of live lots as product data, newest first.
+func (s *Store) ListProductData(ctx context.Context, opts metadata.ListLotsOptions) (metadata.ProductPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool { return matchesOptions(lot, opts) })
+	result := metadata.ProductPage{Total: len(lots), Products: []metadata.ProductData{}}
+	for _, lot := range page(lots, opts) {
+		product, err := s.productDataLocked(lot)
+		if err != nil {
+			return metadata.ProductPage{}, err
+		}
+		result.Products = append(result.Products, product)
+	}
+	return result, nil
+}
+
+// AggregateYield sums product counts per machine over live lots.
+func (s *Store) AggregateYield(ctx context.Context, filter metadata.YieldFilter) (metadata.YieldSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := metadata.NormalizeMachineName(filter.MachineName)
+	lots := s.liveLotsLocked(func(lot metadata.Lot) bool {
+		return (name == "" || metadata.SameMachine(lot.MachineName, name)) &&
+			(filter.From.IsZero() || !lot.StartedAt.Before(filter.From)) &&
+			(filter.To.IsZero() || lot.StartedAt.Before(filter.To))
+	})
+
+	byMachine := map[string]*metadata.MachineYield{}
+	for _, lot := range lots {
+		product, err := s.productDataLocked(lot)
+		if err != nil {
+			return metadata.YieldSummary{}, err
+		}
+		key := strings.ToLower(lot.MachineName)
+		m, ok := byMachine[key]
+		if !ok {
+			m = &metadata.MachineYield{MachineName: lot.MachineName}
+			byMachine[key] = m
+		}
+		m.Lots++
+		m.GoodProduct += int64(product.GoodProduct)
+		m.DefectProduct += int64(product.DefectProduct)
+	}
+
+	summary := metadata.YieldSummary{Machines: []metadata.MachineYield{}}
+	for _, m := range byMachine {
+		m.TotalProduct = m.GoodProduct + m.DefectProduct
+		m.YieldPercent = yieldPercent(m.GoodProduct, m.TotalProduct)
+		summary.Machines = append(summary.Machines, *m)
+		summary.Lots += m.Lots
+		summary.GoodProduct += m.GoodProduct
+		summary.DefectProduct += m.DefectProduct
+	}
+	sort.Slice(summary.Machines, func(i, j int) bool { return summary.Machines[i].MachineName < summary.Machines[j].MachineName })
+	summary.TotalProduct = summary.GoodProduct + summary.DefectProduct
+	summary.YieldPercent = yieldPercent(summary.GoodProduct, summary.TotalProduct)
+	return summary, nil
+}
+
+func yieldPercent(good, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(good)/float64(total)*10000) / 100
+}
+
+func (s *Store) machineLocked(name string) (metadata.Machine, bool) {
+	for _, m := range s.machines {
+		if metadata.SameMachine(m.MachineName, name) {
+			return m, true
+		}
+	}
+	return metadata.Machine{}, false
+}
+
+func (s *Store) addMachineLocked(input metadata.CreateMachineInput) metadata.Machine {
+	s.nextID++
+	m := metadata.Machine{
+		ID:          s.nextID,
+		MachineName: metadata.NormalizeMachineName(input.MachineName),
+		Location:    input.Location,
+		Category:    strings.ToLower(strings.TrimSpace(input.Category)),
+		CreatedAt:   s.now(),
+	}
+	s.machines = append(s.machines, m)
+	return m
+}
+
+// CreateMachine adds a machine.
+func (s *Store) CreateMachine(ctx context.Context, input metadata.CreateMachineInput) (metadata.Machine, error) {
+	name := metadata.NormalizeMachineName(input.MachineName)
+	if name == "" {
+		return metadata.Machine{}, metadata.ErrMachineNameRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.machineLocked(name); exists {
+		return metadata.Machine{}, metadata.ErrMachineExists
+	}
+	return s.addMachineLocked(input), nil
+}
+
+// CreateMachinesBatch adds machines all-or-nothing, skipping existing ones
+// when skipExisting is set.
+func (s *Store) CreateMachinesBatch(ctx context.Context, inputs []metadata.CreateMachineInput, skipExisting bool) ([]metadata.Machine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]struct{}, len(inputs))
+	pending := make([]metadata.CreateMachineInput, 0, len(inputs))
+	for i, input := range inputs {
+		name := metadata.NormalizeMachineName(input.MachineName)
+		if name == "" {
+			return nil, fmt.Errorf("%w: machines[%d]", metadata.ErrMachineNameRequired, i)
+		}
+		key := strings.ToLower(name)
+		_, repeated := seen[key]
+		_, exists := s.machineLocked(name)
+		if repeated || exists {
+			if skipExisting {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s", metadata.ErrMachineExists, name)
+		}
+		seen[key] = struct{}{}
+		pending = append(pending, input)
+	}
+	created := make([]metadata.Machine, 0, len(pending))
+	for _, input := range pending {
+		created = append(created, s.addMachineLocked(input))
+	}
+	return created, nil
+}
+
+// GetMachineByName returns a machine by name, matched case-insensitively.
+func (s *Store) GetMachineByName(ctx context.Context, name string) (metadata.Machine, error) {
+	name = metadata.NormalizeMachineName(name)
+	if name == "" {
+		return metadata.Machine{}, metadata.ErrMachineNameRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.machineLocked(name)
+	if !ok {
+		return metadata.Machine{}, metadata.ErrMachineNotFound
+	}
+	return m, nil
+}
+
+// ListMachines returns machines newest first, optionally of one category.
+func (s *Store) ListMachines(ctx context.Context, category string) ([]metadata.Machine, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var machines []metadata.Machine
+	for i := len(s.machines) - 1; i >= 0; i-- {
+		if category == "" || s.machines[i].Category == category {
+			machines = append(machines, s.machines[i])
+		}
+	}
+	return machines, nil
+}
+
+// ListMachineCategories counts machines per category, ordered by category.
+func (s *Store) ListMachineCategories(ctx context.Context) ([]metadata.MachineCategoryCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int{}
+	for _, m := range s.machines {
+		counts[m.Category]++
+	}
+	categories := make([]metadata.MachineCategoryCount, 0, len(counts))
+	for category, count := range counts {
+		categories = append(categories, metadata.MachineCategoryCount{Category: category, Count: count})
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Category < categories[j].Category })
+	return categories, nil
+}
+
+func idemKey(scope, key string) string {
+	return scope + "\x00" + key
+}
+
+// LookupIdempotentResponse returns a stored response younger than ttl.
+func (s *Store) LookupIdempotentResponse(ctx context.Context, scope, key, requestHash string, ttl time.Duration) (metadata.IdempotentResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, ok := s.idem[idemKey(scope, key)]
+	if !ok || resp.CreatedAt.Before(s.now().Add(-ttl)) {
+		return metadata.IdempotentResponse{}, false, nil
+	}
+	if resp.RequestHash != requestHash {
+		return metadata.IdempotentResponse{}, false, metadata.ErrIdempotencyKeyReused
+	}
+	return resp, true, nil
+}
+
+// SaveIdempotentResponse stores resp unless a live response for key exists.
+func (s *Store) SaveIdempotentResponse(ctx context.Context, scope, key string, resp metadata.IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := idemKey(scope, key)
+	if existing, ok := s.idem[k]; ok && !existing.CreatedAt.Before(s.now().Add(-ttl)) {
+		return nil
+	}
+	if resp.CreatedAt.IsZero() {
+		resp.CreatedAt = s.now()
+	}
+	s.idem[k] = resp
+	return nil
+}
+
+// CompleteLot marks a processing lot completed at the given time with summary,
+// like the completion service does.
+func (s *Store) CompleteLot(lotNumber string, completedAt time.Time, summary metadata.LotSummary) error {
+	if completedAt.IsZero() {
+		return errors.New("metadatatest: completedAt is required")
+	}
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findLocked(lotNumber, false)
+	if stored == nil || stored.lot.Status != metadata.LotStatusProcessing {
+		return metadata.ErrLotNotFound
+	}
+	stored.lot.Status = metadata.LotStatusCompleted
+	stored.lot.CompletedAt = sql.NullTime{Time: completedAt.UTC(), Valid: true}
+	stored.lot.SummaryJSON = payload
+	return nil
+}
+
+// FinalizeLot completes a processing lot, or replaces a completed lot's bare
+// summary, with the summary finalize returns, following the Repository's rules.
+// Calls are serialised store-wide rather than per lot, and finalize runs
+// without the store's lock held.
+func (s *Store) FinalizeLot(ctx context.Context, lotID int64, finalize metadata.LotFinalizer) (metadata.FinalizeResult, *metadata.LotSummary, error) {
+	s.finalizing.Lock()
+	defer s.finalizing.Unlock()
+
+	s.mu.Lock()
+	stored := s.liveByIDLocked(lotID)
+	var lot metadata.Lot
+	if stored != nil {
+		lot = stored.lot
+	}
+	s.mu.Unlock()
+	if stored == nil {
+		return metadata.FinalizeSkipped, nil, metadata.ErrLotNotFound
+	}
+
+	switch lot.Status {
+	case metadata.LotStatusProcessing:
+	case metadata.LotStatusCompleted:
+		current, err := lot.Summary()
+		if err != nil || (current != nil && len(current.Sensors) > 0) {
+			return metadata.FinalizeSkipped, nil, nil
+		}
+	default:
+		return metadata.FinalizeSkipped, nil, nil
+	}
+
+	summary, err := finalize(ctx, lot)
+	if err != nil || summary == nil {
+		return metadata.FinalizeSkipped, nil, err
+	}
+	if lot.Status == metadata.LotStatusCompleted && len(summary.Sensors) == 0 {
+		return metadata.FinalizeSkipped, nil, nil
+	}
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return metadata.FinalizeSkipped, nil, fmt.Errorf("marshal lot summary: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored.lot.SummaryJSON = payload
+	if lot.Status == metadata.LotStatusCompleted {
+		return metadata.FinalizeUpgraded, summary, nil
+	}
+	stored.lot.Status = metadata.LotStatusCompleted
+	stored.lot.CompletedAt = sql.NullTime{Time: summary.CompletedAt.UTC(), Valid: true}
+	return metadata.FinalizeCompleted, summary, nil
+}
@@ -0,0 +1,352 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDuplicateMachineNames is returned by EnsureSchema when machine rows share a
+// name, or names differing only in case or surrounding spaces, that a migration
+// is about to make unique. The error lists the rows; the migration does not
+// pick one to keep, so rename or delete the extra rows and restart.
+var ErrDuplicateMachineNames = errors.New("duplicate machine names")
+
+// migration is a single, ordered schema change. Each version is applied at most once.
+type migration struct {
+	version int
+	name    string
+	apply   func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations lists schema changes in the order they must be applied. Append new
+// entries with the next version number; never edit or reorder applied ones.
+var migrations = []migration{
+	{version: 1, name: "create machines table", apply: createMachinesTable},
+	{version: 2, name: "create lots table", apply: createLotsTable},
+	{version: 3, name: "add product columns to legacy lots table", apply: addLegacyLotColumns},
+	{version: 4, name: "add lots soft-delete column", apply: addLotsDeletedAt},
+	{version: 5, name: "add unique machine name index", apply: addUniqueMachineName},
+	{version: 6, name: "create idempotency keys table", apply: createIdempotencyKeysTable},
+	{version: 7, name: "add lots conclusion category column", apply: addLotsConclusionCategory},
+	{version: 8, name: "add lots completed_at index", apply: addLotsCompletedAtIndex},
+	{version: 9, name: "add machines category column", apply: addMachinesCategory},
+	{version: 10, name: "match machine names case-insensitively", apply: caseInsensitiveMachineNames},
+}
+
+func (r *Repository) migrate(ctx context.Context) error {
+	const ddl = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := r.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("read applied migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if err := r.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("apply schema migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) appliedMigrations(ctx context.Context) (map[int]struct{}, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]struct{})
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = struct{}{}
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs a migration and records its version in one transaction.
+// MySQL commits DDL implicitly, so migrations must be safe to re-run if the
+// version insert itself fails.
+func (r *Repository) applyMigration(ctx context.Context, m migration) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.apply(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createMachinesTable(ctx context.Context, tx *sql.Tx) error {
+	const ddl = `CREATE TABLE IF NOT EXISTS machines (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		machine_name VARCHAR(255) NOT NULL,
+		location VARCHAR(255) NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	_, err := tx.ExecContext(ctx, ddl)
+	return err
+}
+
+func createLotsTable(ctx context.Context, tx *sql.Tx) error {
+	const ddl = `CREATE TABLE IF NOT EXISTS lots (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		lot_number VARCHAR(255) NOT NULL UNIQUE,
+		machine_name VARCHAR(255) NOT NULL,
+		active_machine_id VARCHAR(255) NULL,
+		status VARCHAR(32) NOT NULL DEFAULT 'processing',
+		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		completed_at TIMESTAMP NULL,
+		summary_json JSON NULL,
+		averages_json JSON NULL,
+		operation_hour VARCHAR(32) NULL,
+		good_product INT NULL,
+		defect_product INT NULL,
+		conclusion TEXT NULL,
+		is_conclusion BOOLEAN NOT NULL DEFAULT FALSE,
+		INDEX idx_lots_status (status),
+		INDEX idx_lots_machine (machine_name)
+	)`
+	_, err := tx.ExecContext(ctx, ddl)
+	return err
+}
+
+// addLegacyLotColumns brings lots tables created before the product columns
+// existed up to date. Columns already present are left untouched.
+func addLegacyLotColumns(ctx context.Context, tx *sql.Tx) error {
+	columns := []struct {
+		name string
+		ddl  string
+	}{
+		{"active_machine_id", `ALTER TABLE lots ADD COLUMN active_machine_id VARCHAR(255) NULL AFTER machine_name`},
+		{"updated_at", `ALTER TABLE lots ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER started_at`},
+		{"averages_json", `ALTER TABLE lots ADD COLUMN averages_json JSON NULL AFTER summary_json`},
+		{"operation_hour", `ALTER TABLE lots ADD COLUMN operation_hour VARCHAR(32) NULL AFTER averages_json`},
+		{"good_product", `ALTER TABLE lots ADD COLUMN good_product INT NULL AFTER operation_hour`},
+		{"defect_product", `ALTER TABLE lots ADD COLUMN defect_product INT NULL AFTER good_product`},
+		{"conclusion", `ALTER TABLE lots ADD COLUMN conclusion TEXT NULL AFTER defect_product`},
+		{"is_conclusion", `ALTER TABLE lots ADD COLUMN is_conclusion BOOLEAN NOT NULL DEFAULT FALSE AFTER conclusion`},
+	}
+	for _, col := range columns {
+		exists, err := columnExists(ctx, tx, "lots", col.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, col.ddl); err != nil {
+			return fmt.Errorf("add column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+func addLotsDeletedAt(ctx context.Context, tx *sql.Tx) error {
+	exists, err := columnExists(ctx, tx, "lots", "deleted_at")
+	if err != nil || exists {
+		return err
+	}
+	stmts := []string{
+		`ALTER TABLE lots ADD COLUMN deleted_at TIMESTAMP NULL AFTER completed_at`,
+		`CREATE INDEX idx_lots_deleted_at ON lots (deleted_at)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addUniqueMachineName puts a unique index on machine_name. It fails with
+// ErrDuplicateMachineNames while machine rows share a name.
+func addUniqueMachineName(ctx context.Context, tx *sql.Tx) error {
+	exists, err := indexExists(ctx, tx, "machines", "uq_machines_machine_name")
+	if err != nil || exists {
+		return err
+	}
+	if err := checkDuplicateMachines(ctx, tx, "machine_name"); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE UNIQUE INDEX uq_machines_machine_name ON machines (machine_name)`)
+	return err
+}
+
+func createIdempotencyKeysTable(ctx context.Context, tx *sql.Tx) error {
+	const ddl = `CREATE TABLE IF NOT EXISTS idempotency_keys (
+		scope VARCHAR(64) NOT NULL,
+		idem_key VARCHAR(255) NOT NULL,
+		request_hash CHAR(64) NOT NULL,
+		status_code INT NOT NULL,
+		response_body MEDIUMBLOB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (scope, idem_key),
+		INDEX idx_idempotency_keys_created_at (created_at)
+	)`
+	_, err := tx.ExecContext(ctx, ddl)
+	return err
+}
+
+// addLotsConclusionCategory adds the structured outcome column; existing rows
+// take the "none" default.
+func addLotsConclusionCategory(ctx context.Context, tx *sql.Tx) error {
+	exists, err := columnExists(ctx, tx, "lots", "conclusion_category")
+	if err != nil || exists {
+		return err
+	}
+	stmts := []string{
+		`ALTER TABLE lots ADD COLUMN conclusion_category ENUM('pass', 'fail', 'rework', 'none') NOT NULL DEFAULT 'none' AFTER is_conclusion`,
+		`CREATE INDEX idx_lots_conclusion_category ON lots (conclusion_category)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLotsCompletedAtIndex supports completion date range queries.
+func addLotsCompletedAtIndex(ctx context.Context, tx *sql.Tx) error {
+	exists, err := indexExists(ctx, tx, "lots", "idx_lots_completed_at")
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE INDEX idx_lots_completed_at ON lots (completed_at)`)
+	return err
+}
+
+// addMachinesCategory adds the machine category column and fills it for
+// existing machines whose names have a known prefix.
+func addMachinesCategory(ctx context.Context, tx *sql.Tx) error {
+	exists, err := columnExists(ctx, tx, "machines", "category")
+	if err != nil || exists {
+		return err
+	}
+	stmts := []string{
+		`ALTER TABLE machines ADD COLUMN category VARCHAR(64) NULL AFTER location`,
+		`CREATE INDEX idx_machines_category ON machines (category)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	for _, p := range machineCategoryPrefixes {
+		if _, err := tx.ExecContext(ctx, `UPDATE machines SET category = ? WHERE category IS NULL AND machine_name LIKE ?`, p.category, p.prefix+"%"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// machineNameCollation makes machine_name comparisons, joins and the unique
+// index case-insensitive.
+const machineNameCollation = "utf8mb4_unicode_ci"
+
+// caseInsensitiveMachineNames moves both machine_name columns to
+// machineNameCollation and rewrites lots to the spelling of the machine they
+// belong to. It fails with ErrDuplicateMachineNames, changing nothing, while
+// machines have trimmed names the new collation treats as equal, since the
+// unique index would reject them.
+func caseInsensitiveMachineNames(ctx context.Context, tx *sql.Tx) error {
+	collation, err := columnCollation(ctx, tx, "machines", "machine_name")
+	if err != nil || collation == machineNameCollation {
+		return err
+	}
+	if err := checkDuplicateMachines(ctx, tx, `CONVERT(TRIM(machine_name) USING utf8mb4) COLLATE `+machineNameCollation); err != nil {
+		return err
+	}
+	stmts := []string{
+		`UPDATE machines SET machine_name = TRIM(machine_name)`,
+		`ALTER TABLE machines MODIFY machine_name VARCHAR(255) CHARACTER SET utf8mb4 COLLATE ` + machineNameCollation + ` NOT NULL`,
+		`UPDATE lots SET machine_name = TRIM(machine_name)`,
+		`ALTER TABLE lots MODIFY machine_name VARCHAR(255) CHARACTER SET utf8mb4 COLLATE ` + machineNameCollation + ` NOT NULL`,
+		`UPDATE lots l JOIN machines m ON l.machine_name = m.machine_name SET l.machine_name = m.machine_name`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDuplicateMachines fails with ErrDuplicateMachineNames when machine rows
+// share a value of key, an SQL expression over machine_name. Each group is
+// listed by row ID and stored name, oldest first.
+func checkDuplicateMachines(ctx context.Context, tx *sql.Tx, key string) error {
+	query := `SELECT GROUP_CONCAT(CONCAT('id ', id, ' ', QUOTE(machine_name)) ORDER BY id SEPARATOR ', ') FROM machines GROUP BY ` + key + ` HAVING COUNT(*) > 1 ORDER BY MIN(id)`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return err
+		}
+		groups = append(groups, "["+group+"]")
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(groups) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateMachineNames, strings.Join(groups, " "))
+	}
+	return nil
+}
+
+func indexExists(ctx context.Context, tx *sql.Tx, table, index string) (bool, error) {
+	const query = `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+	var count int
+	if err := tx.QueryRowContext(ctx, query, table, index).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func columnExists(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	const query = `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	var count int
+	if err := tx.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func columnCollation(ctx context.Context, tx *sql.Tx, table, column string) (string, error) {
+	const query = `SELECT COALESCE(COLLATION_NAME, '') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	var collation string
+	if err := tx.QueryRowContext(ctx, query, table, column).Scan(&collation); err != nil {
+		return "", err
+	}
+	return collation, nil
+}
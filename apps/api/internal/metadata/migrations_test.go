@@ -0,0 +1,204 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/mysql/mysqltest"
+)
+
+type machineRow struct {
+	id   int64
+	name string
+}
+
+// migrationServer answers the information_schema lookups and duplicate checks
+// the machine migrations run against machines, grouping names the way MySQL
+// would evaluate the GROUP BY expression in the query. The case-insensitive
+// collation is approximated by trimming spaces and folding case.
+func migrationServer(machines []machineRow, collation string) *mysqltest.Server {
+	return &mysqltest.Server{
+		Query: func(c mysqltest.Call) (*mysqltest.Rows, error) {
+			switch {
+			case strings.Contains(c.Query, "information_schema.STATISTICS"):
+				return &mysqltest.Rows{Columns: []string{"COUNT(*)"}, Values: [][]driver.Value{{int64(0)}}}, nil
+			case strings.Contains(c.Query, "COLLATION_NAME"):
+				return &mysqltest.Rows{Columns: []string{"collation"}, Values: [][]driver.Value{{collation}}}, nil
+			case strings.Contains(c.Query, "GROUP_CONCAT"):
+				key := func(name string) string { return name }
+				if strings.Contains(c.Query, "GROUP BY CONVERT(TRIM(machine_name) USING utf8mb4) COLLATE "+machineNameCollation+" ") {
+					key = func(name string) string { return strings.ToLower(strings.Trim(name, " ")) }
+				} else if !strings.Contains(c.Query, "GROUP BY machine_name ") {
+					return nil, fmt.Errorf("unexpected grouping: %s", c.Query)
+				}
+				return duplicateGroups(machines, key), nil
+			}
+			return nil, fmt.Errorf("unexpected query: %s", c.Query)
+		},
+	}
+}
+
+func duplicateGroups(machines []machineRow, key func(string) string) *mysqltest.Rows {
+	var order []string
+	groups := map[string][]string{}
+	for _, m := range machines {
+		k := key(m.name)
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], fmt.Sprintf("id %d '%s'", m.id, m.name))
+	}
+	out := &mysqltest.Rows{Columns: []string{"rows"}}
+	for _, k := range order {
+		if len(groups[k]) > 1 {
+			out.Values = append(out.Values, []driver.Value{strings.Join(groups[k], ", ")})
+		}
+	}
+	return out
+}
+
+func runMigration(t *testing.T, srv *mysqltest.Server, apply func(context.Context, *sql.Tx) error) error {
+	t.Helper()
+	db := srv.DB()
+	defer db.Close()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer tx.Rollback()
+	return apply(ctx, tx)
+}
+
+func execCalls(srv *mysqltest.Server) []string {
+	var stmts []string
+	for _, c := range srv.Calls() {
+		if !strings.HasPrefix(c.Query, "SELECT") {
+			stmts = append(stmts, c.Query)
+		}
+	}
+	return stmts
+}
+
+func TestAddUniqueMachineName(t *testing.T) {
+	tests := []struct {
+		name     string
+		machines []machineRow
+		wantErr  string
+	}{
+		{
+			name:     "distinct names",
+			machines: []machineRow{{1, "Press-01"}, {2, "Oven-01"}},
+		},
+		{
+			name:     "repeated name",
+			machines: []machineRow{{1, "Press-01"}, {2, "Oven-01"}, {4, "Press-01"}},
+			wantErr:  "[id 1 'Press-01', id 4 'Press-01']",
+		},
+		{
+			name:     "two repeated names",
+			machines: []machineRow{{1, "Press-01"}, {2, "Oven-01"}, {3, "Oven-01"}, {4, "Press-01"}},
+			wantErr:  "[id 1 'Press-01', id 4 'Press-01'] [id 2 'Oven-01', id 3 'Oven-01']",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := migrationServer(tt.machines, "")
+			err := runMigration(t, srv, addUniqueMachineName)
+			stmts := execCalls(srv)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("addUniqueMachineName: %v", err)
+				}
+				if len(stmts) != 1 || !strings.HasPrefix(stmts[0], "CREATE UNIQUE INDEX uq_machines_machine_name") {
+					t.Fatalf("statements = %q, want only the unique index", stmts)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDuplicateMachineNames) {
+				t.Fatalf("err = %v, want ErrDuplicateMachineNames", err)
+			}
+			if !strings.HasSuffix(err.Error(), ": "+tt.wantErr) {
+				t.Errorf("err = %q, want it to list %s", err, tt.wantErr)
+			}
+			if len(stmts) != 0 {
+				t.Errorf("statements run despite duplicates: %q", stmts)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitiveMachineNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		machines  []machineRow
+		collation string
+		wantErr   string
+		wantStmts int
+	}{
+		{
+			name:      "distinct names",
+			machines:  []machineRow{{1, "Furnace-01"}, {2, "Oven-01"}},
+			collation: "utf8mb4_bin",
+			wantStmts: 5,
+		},
+		{
+			name:      "already case-insensitive",
+			machines:  []machineRow{{1, "Furnace-01"}, {2, "furnace-01"}},
+			collation: machineNameCollation,
+		},
+		{
+			name:      "case mismatch",
+			machines:  []machineRow{{1, "Furnace-01"}, {2, "Oven-01"}, {3, "furnace-01"}},
+			collation: "utf8mb4_bin",
+			wantErr:   "[id 1 'Furnace-01', id 3 'furnace-01']",
+		},
+		{
+			name:      "surrounding spaces",
+			machines:  []machineRow{{2, " Furnace-01"}, {5, "Furnace-01 "}},
+			collation: "utf8mb4_bin",
+			wantErr:   "[id 2 ' Furnace-01', id 5 'Furnace-01 ']",
+		},
+		{
+			name:      "several groups",
+			machines:  []machineRow{{1, "OVEN-01"}, {2, "Press-01"}, {3, "oven-01"}, {4, "press-01 "}, {6, "Oven-01"}},
+			collation: "latin1_swedish_ci",
+			wantErr:   "[id 1 'OVEN-01', id 3 'oven-01', id 6 'Oven-01'] [id 2 'Press-01', id 4 'press-01 ']",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := migrationServer(tt.machines, tt.collation)
+			err := runMigration(t, srv, caseInsensitiveMachineNames)
+			stmts := execCalls(srv)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("caseInsensitiveMachineNames: %v", err)
+				}
+				if len(stmts) != tt.wantStmts {
+					t.Fatalf("ran %d statements, want %d: %q", len(stmts), tt.wantStmts, stmts)
+				}
+				for _, stmt := range stmts {
+					if strings.HasPrefix(stmt, "DELETE") {
+						t.Errorf("migration deleted rows: %s", stmt)
+					}
+				}
+				return
+			}
+			if !errors.Is(err, ErrDuplicateMachineNames) {
+				t.Fatalf("err = %v, want ErrDuplicateMachineNames", err)
+			}
+			if !strings.HasSuffix(err.Error(), ": "+tt.wantErr) {
+				t.Errorf("err = %q, want it to list %s", err, tt.wantErr)
+			}
+			if len(stmts) != 0 {
+				t.Errorf("statements run despite duplicates: %q", stmts)
+			}
+		})
+	}
+}
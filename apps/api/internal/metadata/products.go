@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+)
+
+// productLotColumns matches lotColumns, except that a missing operation_hour is
+// computed in SQL from the lot's start and completion (or the supplied now).
+const productLotColumns = `l.id, l.lot_number, l.machine_name, l.status, l.started_at, l.completed_at, l.updated_at, l.summary_json, l.active_machine_id, l.averages_json, ` +
+	`COALESCE(NULLIF(TRIM(l.operation_hour), ''), CAST(ROUND(GREATEST(TIMESTAMPDIFF(SECOND, l.started_at, COALESCE(l.completed_at, ?)), 0) / 3600, 1) AS CHAR)), ` +
+	`l.good_product, l.defect_product, l.conclusion, l.is_conclusion, l.conclusion_category`
+
+// previousLotIDColumn selects the id of the lot each row is compared against for
+// averagesDelta, mirroring GetPreviousCompletedLot.
+const previousLotIDColumn = `(SELECT p.id FROM lots p WHERE p.machine_name = l.machine_name AND p.status = ? AND p.deleted_at IS NULL AND p.completed_at < COALESCE(l.completed_at, ?) ORDER BY p.completed_at DESC LIMIT 1)`
+
+// ProductPage is one page of product payloads plus the total matching lots.
+type ProductPage struct {
+	Products []ProductData `json:"products"`
+	Total    int           `json:"total"`
+}
+
+// ListProductData returns lot records transformed to product-centric payloads,
+// newest first. Operation hours and previous-lot references are resolved in SQL so
+// only the requested page is transformed in Go.
+func (r *Repository) ListProductData(ctx context.Context, opts ListLotsOptions) (ProductPage, error) {
+	now := time.Now().UTC()
+
+	where := ` WHERE l.deleted_at IS NULL`
+	var filterArgs []any
+	if opts.Status != "" {
+		where += ` AND l.status = ?`
+		filterArgs = append(filterArgs, opts.Status)
+	}
+	if opts.ConclusionCategory != "" {
+		where += ` AND l.conclusion_category = ?`
+		filterArgs = append(filterArgs, opts.ConclusionCategory)
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM lots l`+where, filterArgs...).Scan(&total); err != nil {
+		return ProductPage{}, err
+	}
+
+	query := `SELECT ` + productLotColumns + `, ` + previousLotIDColumn + ` FROM lots l` + where + ` ORDER BY l.started_at DESC`
+	args := append([]any{now, LotStatusCompleted, now}, filterArgs...)
+	switch {
+	case opts.Limit > 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, opts.Limit, max(opts.Offset, 0))
+	case opts.Offset > 0:
+		query += ` LIMIT 18446744073709551615 OFFSET ?`
+		args = append(args, opts.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	defer rows.Close()
+
+	var (
+		lots        []Lot
+		previousIDs []*int64
+	)
+	for rows.Next() {
+		var previousID *int64
+		lot, err := scanLot(previousIDScanner{rows, &previousID})
+		if err != nil {
+			return ProductPage{}, err
+		}
+		lots = append(lots, lot)
+		previousIDs = append(previousIDs, previousID)
+	}
+	if err := rows.Err(); err != nil {
+		return ProductPage{}, err
+	}
+
+	// Previous lots are often on the page itself; only the others are queried.
+	previous := make(map[int64]Lot, len(lots))
+	for _, lot := range lots {
+		previous[lot.ID] = lot
+	}
+	var missing []*int64
+	for _, id := range previousIDs {
+		if id != nil {
+			if _, ok := previous[*id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+	}
+	fetched, err := r.lotsByID(ctx, missing)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	maps.Copy(previous, fetched)
+
+	products := make([]ProductData, 0, len(lots))
+	for i, lot := range lots {
+		var prev *Lot
+		if id := previousIDs[i]; id != nil {
+			if p, ok := previous[*id]; ok {
+				prev = &p
+			}
+		}
+		product, err := ProductDataFromLot(lot, prev, now)
+		if err != nil {
+			return ProductPage{}, err
+		}
+		products = append(products, product)
+	}
+	return ProductPage{Products: products, Total: total}, nil
+}
+
+// previousIDScanner appends the previous-lot id column to scanLot's destinations.
+type previousIDScanner struct {
+	rowScanner
+	previousID **int64
+}
+
+func (s previousIDScanner) Scan(dest ...any) error {
+	return s.rowScanner.Scan(append(dest, s.previousID)...)
+}
+
+// lotsByID loads the distinct, non-nil ids in one query.
+func (r *Repository) lotsByID(ctx context.Context, ids []*int64) (map[int64]Lot, error) {
+	seen := map[int64]struct{}{}
+	var args []any
+	for _, id := range ids {
+		if id == nil {
+			continue
+		}
+		if _, ok := seen[*id]; ok {
+			continue
+		}
+		seen[*id] = struct{}{}
+		args = append(args, *id)
+	}
+	lots := make(map[int64]Lot, len(args))
+	if len(args) == 0 {
+		return lots, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	rows, err := r.db.QueryContext(ctx, `SELECT `+lotColumns+` FROM lots WHERE id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots[lot.ID] = lot
+	}
+	return lots, rows.Err()
+}
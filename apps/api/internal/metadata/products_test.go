@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/mysql/mysqltest"
+)
+
+// productRow is one lots row in lotColumns order, plus the previous-lot id the
+// product query computes.
+type productRow struct {
+	values     []driver.Value
+	previousID driver.Value
+}
+
+// productRows builds n lots spread over ten machines, newest first. Every fifth
+// lot is still processing; completed lots carry a summary and averages.
+func productRows(n int) []productRow {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastCompleted := map[string]int64{}
+	rows := make([]productRow, n)
+	for i := 0; i < n; i++ {
+		id := int64(i + 1)
+		machine := fmt.Sprintf("Machine-%02d", i%10)
+		started := base.Add(time.Duration(i) * 30 * time.Minute)
+		var completed, summary, averages driver.Value
+		status := string(LotStatusProcessing)
+		if i%5 != 0 {
+			status = string(LotStatusCompleted)
+			completedAt := started.Add(25 * time.Minute)
+			completed = completedAt
+			summary = fmt.Sprintf(`{"completedAt":%q,"machineName":%q,"sensors":[`+
+				`{"sensorName":"Temperature","latestStatus":"down","latestValue":%d,"averageDown":0.1,"observedCount":5},`+
+				`{"sensorName":"Pressure","latestStatus":"down","latestValue":%d,"averageDown":0.2,"observedCount":5}]}`,
+				completedAt.Format(time.RFC3339), machine, 100+i%7, 3+i%3)
+			averages = fmt.Sprintf(`{"Temperature":%d.5,"Pressure":%d.25}`, 100+i%7, 3+i%3)
+		}
+		var previous driver.Value
+		if prev, ok := lastCompleted[machine]; ok {
+			previous = prev
+		}
+		if status == string(LotStatusCompleted) {
+			lastCompleted[machine] = id
+		}
+		rows[n-1-i] = productRow{
+			values: []driver.Value{
+				id, fmt.Sprintf("LOT-%05d", i), machine, status, started, completed, started,
+				summary, nil, averages, "0.4", int64(90 + i%10), int64(i % 10), nil, false, string(ConclusionNone),
+			},
+			previousID: previous,
+		}
+	}
+	return rows
+}
+
+// productServer serves the queries ListProductData runs over rows.
+func productServer(rows []productRow) *mysqltest.Server {
+	byID := make(map[int64][]driver.Value, len(rows))
+	for _, r := range rows {
+		byID[r.values[0].(int64)] = r.values
+	}
+	lotCols := strings.Split(lotColumns, ", ")
+	return &mysqltest.Server{
+		Query: func(c mysqltest.Call) (*mysqltest.Rows, error) {
+			switch {
+			case strings.HasPrefix(c.Query, "SELECT COUNT(*) FROM lots"):
+				return &mysqltest.Rows{Columns: []string{"COUNT(*)"}, Values: [][]driver.Value{{int64(len(rows))}}}, nil
+			case strings.Contains(c.Query, "ORDER BY l.started_at DESC"):
+				page := rows
+				if strings.HasSuffix(c.Query, "LIMIT ? OFFSET ?") {
+					limit, offset := int(c.Args[len(c.Args)-2].(int64)), int(c.Args[len(c.Args)-1].(int64))
+					page = page[min(offset, len(page)):min(offset+limit, len(page))]
+				}
+				out := &mysqltest.Rows{Columns: append(append([]string(nil), lotCols...), "previous_id")}
+				for _, r := range page {
+					out.Values = append(out.Values, append(append([]driver.Value(nil), r.values...), r.previousID))
+				}
+				return out, nil
+			case strings.Contains(c.Query, "WHERE id IN ("):
+				out := &mysqltest.Rows{Columns: lotCols}
+				for _, arg := range c.Args {
+					if values, ok := byID[arg.(int64)]; ok {
+						out.Values = append(out.Values, values)
+					}
+				}
+				return out, nil
+			}
+			return nil, fmt.Errorf("unexpected query: %s", c.Query)
+		},
+	}
+}
+
+func TestListProductDataPage(t *testing.T) {
+	rows := productRows(40)
+	srv := productServer(rows)
+	db := srv.DB()
+	defer db.Close()
+	repo := NewRepository(db)
+
+	page, err := repo.ListProductData(context.Background(), ListLotsOptions{Limit: 10, Offset: 5})
+	if err != nil {
+		t.Fatalf("ListProductData: %v", err)
+	}
+	if page.Total != 40 {
+		t.Errorf("Total = %d, want 40", page.Total)
+	}
+	if len(page.Products) != 10 {
+		t.Fatalf("got %d products, want 10", len(page.Products))
+	}
+	first := page.Products[0]
+	if want := rows[5].values[1]; first.Lot != want {
+		t.Errorf("first product = %s, want %s", first.Lot, want)
+	}
+	if len(first.AveragesDelta) == 0 {
+		t.Errorf("product %s has no averages delta against its previous lot", first.Lot)
+	}
+	var queries int
+	for _, c := range srv.Calls() {
+		if strings.HasPrefix(c.Query, "SELECT") {
+			queries++
+		}
+	}
+	if queries != 3 {
+		t.Errorf("ran %d queries, want 3 (count, page, previous lots)", queries)
+	}
+}
+
+// BenchmarkListProductData lists 5000 lots through the fake driver, so it
+// measures the API-side cost of scanning and transforming rows; time spent in
+// MySQL evaluating the queries is not included.
+func BenchmarkListProductData(b *testing.B) {
+	rows := productRows(5000)
+	for _, bc := range []struct {
+		name string
+		opts ListLotsOptions
+	}{
+		{"all", ListLotsOptions{}},
+		{"page50", ListLotsOptions{Limit: 50}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db := productServer(rows).DB()
+			defer db.Close()
+			repo := NewRepository(db)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.ListProductData(ctx, bc.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
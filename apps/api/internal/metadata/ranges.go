@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// SensorRange is the lowest and highest value a sensor reported during a lot.
+type SensorRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// BuildSensorRanges pairs per-sensor minimums and maximums. A sensor present in only
+// one map (e.g. a single data point) uses that value for both bounds, and swapped
+// bounds are reordered so Min <= Max always holds.
+func BuildSensorRanges(mins, maxs map[string]float64) map[string]SensorRange {
+	ranges := make(map[string]SensorRange, len(mins))
+	for name, low := range mins {
+		high, ok := maxs[name]
+		if !ok {
+			high = low
+		}
+		ranges[name] = orderedRange(low, high)
+	}
+	for name, high := range maxs {
+		if _, ok := ranges[name]; !ok {
+			ranges[name] = SensorRange{Min: high, Max: high}
+		}
+	}
+	return ranges
+}
+
+func orderedRange(low, high float64) SensorRange {
+	if low > high {
+		low, high = high, low
+	}
+	return SensorRange{Min: low, Max: high}
+}
+
+// ApplySensorRanges stores ranges on the matching sensor snapshots, appending
+// snapshots for sensors the summary does not list yet.
+func ApplySensorRanges(summary *LotSummary, ranges map[string]SensorRange) {
+	if summary == nil {
+		return
+	}
+	seen := make(map[string]struct{}, len(summary.Sensors))
+	for i := range summary.Sensors {
+		snapshot := &summary.Sensors[i]
+		seen[snapshot.SensorName] = struct{}{}
+		if r, ok := ranges[snapshot.SensorName]; ok {
+			snapshot.MinValue, snapshot.MaxValue = &r.Min, &r.Max
+		}
+	}
+
+	names := make([]string, 0, len(ranges))
+	for name := range ranges {
+		if _, ok := seen[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := ranges[name]
+		summary.Sensors = append(summary.Sensors, SensorSnapshot{SensorName: name, MinValue: &r.Min, MaxValue: &r.Max})
+	}
+}
+
+// MarkRangesChecked records on summary that its sensor ranges were looked up at
+// at and no readings were found, listing no sensors rather than none known.
+func MarkRangesChecked(summary *LotSummary, at time.Time) {
+	if summary == nil {
+		return
+	}
+	if summary.Sensors == nil {
+		summary.Sensors = []SensorSnapshot{}
+	}
+	at = at.UTC()
+	summary.RangesCheckedAt = &at
+}
+
+// UpdateLotSensorRanges merges per-sensor min/max values into a lot's stored summary.
+func (r *Repository) UpdateLotSensorRanges(ctx context.Context, id int64, ranges map[string]SensorRange) error {
+	return r.rewriteLotSummary(ctx, id, func(summary *LotSummary) {
+		ApplySensorRanges(summary, ranges)
+	})
+}
+
+// MarkLotRangesChecked records on a lot's stored summary that no readings were
+// found to compute its sensor ranges from, so the backfill stops listing it.
+func (r *Repository) MarkLotRangesChecked(ctx context.Context, id int64) error {
+	return r.rewriteLotSummary(ctx, id, func(summary *LotSummary) {
+		MarkRangesChecked(summary, time.Now())
+	})
+}
+
+// rewriteLotSummary applies change to a lot's stored summary, starting from a
+// bare one when the lot has none, and stores the result.
+func (r *Repository) rewriteLotSummary(ctx context.Context, id int64, change func(*LotSummary)) error {
+	lot, err := r.GetLotByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	summary, err := lot.Summary()
+	if err != nil {
+		return err
+	}
+	if summary == nil {
+		summary = &LotSummary{MachineName: lot.MachineName}
+		if lot.CompletedAt.Valid {
+			summary.CompletedAt = lot.CompletedAt.Time
+		}
+	}
+	change(summary)
+
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	const stmt = `UPDATE lots SET summary_json = ?, updated_at = NOW() WHERE id = ?`
+	_, err = r.db.ExecContext(ctx, stmt, string(payload), id)
+	return err
+}
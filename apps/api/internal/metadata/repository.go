@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 )
@@ -11,9 +12,20 @@ import (
 // ErrMachineNameRequired is returned when an insert lacks a name.
 var ErrMachineNameRequired = errors.New("machine name is required")
 
+// ErrMachineExists is returned when a machine with the same name is already registered.
+var ErrMachineExists = errors.New("machine already exists")
+
+// ErrMachineNotFound is returned when no machine matches a lookup.
+var ErrMachineNotFound = errors.New("machine not found")
+
+const machineColumns = `id, machine_name, location, category, created_at`
+
 // Repository persists non time-series metadata in MySQL.
 type Repository struct {
 	db *sql.DB
+	// finalizeTimeout bounds each FinalizeLot finalizer; zero uses
+	// defaultFinalizeTimeout.
+	finalizeTimeout time.Duration
 }
 
 // Machine represents a physical asset associated with the simulator.
@@ -21,13 +33,16 @@ type Machine struct {
 	ID          int64     `json:"id"`
 	MachineName string    `json:"machineName"`
 	Location    string    `json:"location"`
+	Category    string    `json:"category"`
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
-// CreateMachineInput is used for inserting a new machine row.
+// CreateMachineInput is used for inserting a new machine row. MachineName is
+// stored normalized and Category lowercased.
 type CreateMachineInput struct {
 	MachineName string
 	Location    string
+	Category    string
 }
 
 // NewRepository constructs a Repository with the provided sql.DB pool.
@@ -35,68 +50,9 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// EnsureSchema creates the required tables if they are missing.
+// EnsureSchema applies any pending schema migrations.
 func (r *Repository) EnsureSchema(ctx context.Context) error {
-	if err := r.ensureMachinesTable(ctx); err != nil {
-		return err
-	}
-	return r.ensureLotsTable(ctx)
-}
-
-func (r *Repository) ensureMachinesTable(ctx context.Context) error {
-	const ddl = `CREATE TABLE IF NOT EXISTS machines (
-		id BIGINT AUTO_INCREMENT PRIMARY KEY,
-		machine_name VARCHAR(255) NOT NULL,
-		location VARCHAR(255) NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`
-	_, err := r.db.ExecContext(ctx, ddl)
-	return err
-}
-
-func (r *Repository) ensureLotsTable(ctx context.Context) error {
-	const ddl = `CREATE TABLE IF NOT EXISTS lots (
-		id BIGINT AUTO_INCREMENT PRIMARY KEY,
-		lot_number VARCHAR(255) NOT NULL UNIQUE,
-		machine_name VARCHAR(255) NOT NULL,
-		active_machine_id VARCHAR(255) NULL,
-		status VARCHAR(32) NOT NULL DEFAULT 'processing',
-		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-		completed_at TIMESTAMP NULL,
-		summary_json JSON NULL,
-		averages_json JSON NULL,
-		operation_hour VARCHAR(32) NULL,
-		good_product INT NULL,
-		defect_product INT NULL,
-		conclusion TEXT NULL,
-		is_conclusion BOOLEAN NOT NULL DEFAULT FALSE,
-		INDEX idx_lots_status (status),
-		INDEX idx_lots_machine (machine_name)
-	)`
-	if _, err := r.db.ExecContext(ctx, ddl); err != nil {
-		return err
-	}
-
-	alterStatements := []string{
-		`ALTER TABLE lots ADD COLUMN active_machine_id VARCHAR(255) NULL AFTER machine_name`,
-		`ALTER TABLE lots ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER started_at`,
-		`ALTER TABLE lots ADD COLUMN averages_json JSON NULL AFTER summary_json`,
-		`ALTER TABLE lots ADD COLUMN operation_hour VARCHAR(32) NULL AFTER averages_json`,
-		`ALTER TABLE lots ADD COLUMN good_product INT NULL AFTER operation_hour`,
-		`ALTER TABLE lots ADD COLUMN defect_product INT NULL AFTER good_product`,
-		`ALTER TABLE lots ADD COLUMN conclusion TEXT NULL AFTER defect_product`,
-		`ALTER TABLE lots ADD COLUMN is_conclusion BOOLEAN NOT NULL DEFAULT FALSE AFTER conclusion`,
-	}
-	for _, stmt := range alterStatements {
-		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
-			if isDuplicateColumnError(err) {
-				continue
-			}
-			return err
-		}
-	}
-	return nil
+	return r.migrate(ctx)
 }
 
 // Ping checks MySQL connectivity using the provided context.
@@ -104,10 +60,17 @@ func (r *Repository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-// ListMachines returns all machines ordered by creation time descending.
-func (r *Repository) ListMachines(ctx context.Context) ([]Machine, error) {
-	const query = `SELECT id, machine_name, location, created_at FROM machines ORDER BY created_at DESC`
-	rows, err := r.db.QueryContext(ctx, query)
+// ListMachines returns machines ordered by creation time descending. A
+// non-empty category restricts the list to that category.
+func (r *Repository) ListMachines(ctx context.Context, category string) ([]Machine, error) {
+	query := `SELECT ` + machineColumns + ` FROM machines`
+	var args []any
+	if category = normalizeCategory(category); category != "" {
+		query += ` WHERE category = ?`
+		args = append(args, category)
+	}
+	query += ` ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +78,8 @@ func (r *Repository) ListMachines(ctx context.Context) ([]Machine, error) {
 
 	var machines []Machine
 	for rows.Next() {
-		var m Machine
-		if err := rows.Scan(&m.ID, &m.MachineName, &m.Location, &m.CreatedAt); err != nil {
+		m, err := scanMachine(rows)
+		if err != nil {
 			return nil, err
 		}
 		machines = append(machines, m)
@@ -129,13 +92,17 @@ func (r *Repository) ListMachines(ctx context.Context) ([]Machine, error) {
 
 // CreateMachine inserts a machine row and returns the stored record.
 func (r *Repository) CreateMachine(ctx context.Context, input CreateMachineInput) (Machine, error) {
-	if strings.TrimSpace(input.MachineName) == "" {
+	name := NormalizeMachineName(input.MachineName)
+	if name == "" {
 		return Machine{}, ErrMachineNameRequired
 	}
 
-	const stmt = `INSERT INTO machines (machine_name, location) VALUES (?, ?)`
-	res, err := r.db.ExecContext(ctx, stmt, input.MachineName, nullableString(input.Location))
+	const stmt = `INSERT INTO machines (machine_name, location, category) VALUES (?, ?, ?)`
+	res, err := r.db.ExecContext(ctx, stmt, name, nullableString(input.Location), nullableString(normalizeCategory(input.Category)))
 	if err != nil {
+		if isDuplicateEntry(err) {
+			return Machine{}, ErrMachineExists
+		}
 		return Machine{}, err
 	}
 
@@ -144,11 +111,102 @@ func (r *Repository) CreateMachine(ctx context.Context, input CreateMachineInput
 		return Machine{}, err
 	}
 
-	const query = `SELECT id, machine_name, location, created_at FROM machines WHERE id = ?`
-	var m Machine
-	if err := r.db.QueryRowContext(ctx, query, id).Scan(&m.ID, &m.MachineName, &m.Location, &m.CreatedAt); err != nil {
+	const query = `SELECT ` + machineColumns + ` FROM machines WHERE id = ?`
+	return scanMachine(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateMachinesBatch inserts machines in one transaction and returns the rows
+// created, in input order. Every name is checked before the transaction starts.
+// Names compare case-insensitively after normalization. A name that already
+// exists, or repeats within inputs, fails the whole batch
+// with ErrMachineExists unless skipExisting is set, in which case it is left out
+// of the result.
+func (r *Repository) CreateMachinesBatch(ctx context.Context, inputs []CreateMachineInput, skipExisting bool) ([]Machine, error) {
+	seen := make(map[string]struct{}, len(inputs))
+	pending := make([]CreateMachineInput, 0, len(inputs))
+	for i, input := range inputs {
+		name := NormalizeMachineName(input.MachineName)
+		if name == "" {
+			return nil, fmt.Errorf("%w: machines[%d]", ErrMachineNameRequired, i)
+		}
+		key := strings.ToLower(name)
+		if _, ok := seen[key]; ok {
+			if skipExisting {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s is listed twice", ErrMachineExists, name)
+		}
+		seen[key] = struct{}{}
+		pending = append(pending, CreateMachineInput{MachineName: name, Location: input.Location, Category: input.Category})
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	const (
+		stmt  = `INSERT INTO machines (machine_name, location, category) VALUES (?, ?, ?)`
+		query = `SELECT ` + machineColumns + ` FROM machines WHERE id = ?`
+	)
+	created := make([]Machine, 0, len(pending))
+	for _, input := range pending {
+		res, err := tx.ExecContext(ctx, stmt, input.MachineName, nullableString(input.Location), nullableString(normalizeCategory(input.Category)))
+		if err != nil {
+			if isDuplicateEntry(err) {
+				if skipExisting {
+					continue
+				}
+				return nil, fmt.Errorf("%w: %s", ErrMachineExists, input.MachineName)
+			}
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		m, err := scanMachine(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, m)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetMachineByName fetches a machine by its unique name, matched
+// case-insensitively after normalization. Returns ErrMachineNameRequired for an
+// empty name and ErrMachineNotFound when no machine matches.
+func (r *Repository) GetMachineByName(ctx context.Context, name string) (Machine, error) {
+	name = NormalizeMachineName(name)
+	if name == "" {
+		return Machine{}, ErrMachineNameRequired
+	}
+	const query = `SELECT ` + machineColumns + ` FROM machines WHERE machine_name = ?`
+	m, err := scanMachine(r.db.QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Machine{}, ErrMachineNotFound
+	}
+	return m, err
+}
+
+// scanMachine reads machineColumns. A NULL location or category becomes an
+// empty string.
+func scanMachine(scanner rowScanner) (Machine, error) {
+	var (
+		m        Machine
+		location sql.NullString
+		category sql.NullString
+	)
+	if err := scanner.Scan(&m.ID, &m.MachineName, &location, &category, &m.CreatedAt); err != nil {
 		return Machine{}, err
 	}
+	m.Location = location.String
+	m.Category = category.String
 	return m, nil
 }
 
@@ -158,10 +216,3 @@ func nullableString(val string) interface{} {
 	}
 	return val
 }
-
-func isDuplicateColumnError(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.Contains(strings.ToLower(err.Error()), "duplicate column name")
-}
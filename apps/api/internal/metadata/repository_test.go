@@ -0,0 +1,110 @@
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/mysql/mysqltest"
+)
+
+// machinesServer keeps the machines table in memory, storing INSERT arguments
+// as given so a nil location stays NULL.
+func machinesServer() *mysqltest.Server {
+	var (
+		mu   sync.Mutex
+		rows [][]driver.Value
+	)
+	columns := strings.Split(machineColumns, ", ")
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	return &mysqltest.Server{
+		Exec: func(c mysqltest.Call) (mysqltest.Result, error) {
+			if !strings.HasPrefix(c.Query, "INSERT INTO machines (machine_name, location, category)") {
+				return mysqltest.Result{}, fmt.Errorf("unexpected statement: %s", c.Query)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			id := int64(len(rows) + 1)
+			rows = append(rows, []driver.Value{id, c.Args[0], c.Args[1], c.Args[2], created.Add(time.Duration(id) * time.Minute)})
+			return mysqltest.Result{LastInsertID: id, RowsAffected: 1}, nil
+		},
+		Query: func(c mysqltest.Call) (*mysqltest.Rows, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			out := &mysqltest.Rows{Columns: columns}
+			switch {
+			case strings.HasSuffix(c.Query, "FROM machines WHERE id = ?"):
+				for _, row := range rows {
+					if row[0] == c.Args[0] {
+						out.Values = append(out.Values, row)
+					}
+				}
+			case strings.HasSuffix(c.Query, "FROM machines WHERE machine_name = ?"):
+				for _, row := range rows {
+					if strings.EqualFold(row[1].(string), c.Args[0].(string)) {
+						out.Values = append(out.Values, row)
+					}
+				}
+			case strings.HasSuffix(c.Query, "FROM machines ORDER BY created_at DESC"):
+				for i := len(rows) - 1; i >= 0; i-- {
+					out.Values = append(out.Values, rows[i])
+				}
+			default:
+				return nil, fmt.Errorf("unexpected query: %s", c.Query)
+			}
+			return out, nil
+		},
+	}
+}
+
+func TestMachineWithoutLocation(t *testing.T) {
+	srv := machinesServer()
+	db := srv.DB()
+	defer db.Close()
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	created, err := repo.CreateMachine(ctx, CreateMachineInput{MachineName: "Oven-01", Location: "  "})
+	if err != nil {
+		t.Fatalf("CreateMachine without location: %v", err)
+	}
+	if created.Location != "" || created.Category != "" {
+		t.Errorf("created machine = %+v, want empty location and category", created)
+	}
+	if _, err := repo.CreateMachine(ctx, CreateMachineInput{MachineName: "Press-01", Location: "Hall B"}); err != nil {
+		t.Fatalf("CreateMachine with location: %v", err)
+	}
+	if args := srv.Calls()[0].Args; args[1] != nil {
+		t.Errorf("inserted location = %#v, want NULL", args[1])
+	}
+
+	machines, err := repo.ListMachines(ctx, "")
+	if err != nil {
+		t.Fatalf("ListMachines: %v", err)
+	}
+	if len(machines) != 2 {
+		t.Fatalf("got %d machines, want 2", len(machines))
+	}
+	byName := map[string]Machine{}
+	for _, m := range machines {
+		byName[m.MachineName] = m
+	}
+	if m := byName["Oven-01"]; m.ID != created.ID || m.Location != "" {
+		t.Errorf("listed Oven-01 = %+v, want id %d with no location", m, created.ID)
+	}
+	if m := byName["Press-01"]; m.Location != "Hall B" {
+		t.Errorf("listed Press-01 location = %q, want %q", m.Location, "Hall B")
+	}
+
+	got, err := repo.GetMachineByName(ctx, "oven-01")
+	if err != nil {
+		t.Fatalf("GetMachineByName: %v", err)
+	}
+	if got.Location != "" {
+		t.Errorf("GetMachineByName location = %q, want empty", got.Location)
+	}
+}
@@ -0,0 +1,26 @@
+package metadata
+
+import "context"
+
+// SeedMachines inserts a machine row for every name not already present and
+// reports how many were added. New rows take the category inferred from their
+// name. It is safe to run repeatedly; the unique index on machine_name turns
+// existing names, in any case, into no-ops.
+func (r *Repository) SeedMachines(ctx context.Context, machineNames []string) (int, error) {
+	const stmt = `INSERT INTO machines (machine_name, category) VALUES (?, ?) ON DUPLICATE KEY UPDATE machine_name = machine_name`
+	seeded := 0
+	for _, name := range machineNames {
+		name = NormalizeMachineName(name)
+		if name == "" {
+			continue
+		}
+		res, err := r.db.ExecContext(ctx, stmt, name, nullableString(InferMachineCategory(name)))
+		if err != nil {
+			return seeded, err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 1 {
+			seeded++
+		}
+	}
+	return seeded, nil
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// GetPreviousCompletedLot returns the most recent completed lot on machineName that
+// finished strictly before the given time. Returns ErrLotNotFound when none exists.
+func (r *Repository) GetPreviousCompletedLot(ctx context.Context, machineName string, before time.Time) (Lot, error) {
+	const query = `SELECT ` + lotColumns + ` FROM lots WHERE machine_name = ? AND status = ? AND deleted_at IS NULL AND completed_at < ? ORDER BY completed_at DESC LIMIT 1`
+	row := r.db.QueryRowContext(ctx, query, machineName, LotStatusCompleted, before.UTC())
+	lot, err := scanLot(row)
+	if err != nil {
+		return Lot{}, mapLotError(err)
+	}
+	return lot, nil
+}
+
+// previousLotFor looks up the lot preceding lot for trend comparison, or nil when none exists.
+func (r *Repository) previousLotFor(ctx context.Context, lot Lot, now time.Time) (*Lot, error) {
+	previous, err := r.GetPreviousCompletedLot(ctx, lot.MachineName, trendReference(lot, now))
+	switch {
+	case err == nil:
+		return &previous, nil
+	case errors.Is(err, ErrLotNotFound):
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// trendReference is the point in time a lot is compared from: its completion time,
+// or now while it is still processing.
+func trendReference(lot Lot, now time.Time) time.Time {
+	if lot.CompletedAt.Valid {
+		return lot.CompletedAt.Time
+	}
+	return now
+}
+
+// averagesDelta returns current minus previous for every sensor present in both.
+func averagesDelta(current map[string]float64, previous Lot) (map[string]float64, error) {
+	summary, err := previous.Summary()
+	if err != nil {
+		return nil, err
+	}
+	prior, err := resolveAverages(previous, summary)
+	if err != nil {
+		return nil, err
+	}
+	delta := make(map[string]float64, len(current))
+	for name, value := range current {
+		if before, ok := prior[name]; ok {
+			delta[name] = value - before
+		}
+	}
+	return delta, nil
+}
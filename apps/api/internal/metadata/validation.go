@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidProductData indicates manual product values are out of range or malformed.
+var ErrInvalidProductData = errors.New("invalid product data")
+
+// ValidateProductInput rejects values that would corrupt yield and averages
+// calculations downstream.
+func ValidateProductInput(input ProductInput) error {
+	if input.GoodProduct != nil && *input.GoodProduct < 0 {
+		return fmt.Errorf("%w: goodProduct must be non-negative", ErrInvalidProductData)
+	}
+	if input.DefectProduct != nil && *input.DefectProduct < 0 {
+		return fmt.Errorf("%w: defectProduct must be non-negative", ErrInvalidProductData)
+	}
+	if input.OperationHour != nil {
+		if value := strings.TrimSpace(*input.OperationHour); value != "" {
+			hours, err := parseDecimal(value)
+			if err != nil || hours < 0 {
+				return fmt.Errorf("%w: operationHour must be a non-negative number", ErrInvalidProductData)
+			}
+		}
+	}
+	if input.ConclusionCategory != nil {
+		if _, err := ParseConclusionCategory(string(*input.ConclusionCategory)); err != nil {
+			return err
+		}
+	}
+	if err := validateAverages(input.Averages); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProductData, err)
+	}
+	return nil
+}
+
+// validateAverages requires averages to be a JSON object of finite numbers.
+// Numeric strings are accepted, matching decodeLotAverages.
+func validateAverages(raw json.RawMessage) error {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var payload map[string]any
+	if err := decoder.Decode(&payload); err != nil {
+		return errors.New("averages must be an object of sensor values")
+	}
+
+	for key, value := range payload {
+		var (
+			f   float64
+			err error
+		)
+		switch typed := value.(type) {
+		case json.Number:
+			f, err = typed.Float64()
+		case string:
+			f, err = parseDecimal(typed)
+		default:
+			err = errors.New("not a number")
+		}
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("averages[%q] must be a finite number", key)
+		}
+	}
+	return nil
+}
+
+// parseDecimal parses a finite float, accepting a comma as the decimal separator.
+func parseDecimal(raw string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(raw), ",", "."), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("value must be finite")
+	}
+	return f, nil
+}
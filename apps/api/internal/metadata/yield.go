@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+)
+
+// YieldFilter narrows AggregateYield. Zero values disable a filter; From and To
+// bound the lot start time (From inclusive, To exclusive).
+type YieldFilter struct {
+	MachineName string
+	From        time.Time
+	To          time.Time
+}
+
+// MachineYield holds product totals for a single machine.
+type MachineYield struct {
+	MachineName   string  `json:"machineName"`
+	Lots          int64   `json:"lots"`
+	GoodProduct   int64   `json:"goodProduct"`
+	DefectProduct int64   `json:"defectProduct"`
+	TotalProduct  int64   `json:"totalProduct"`
+	YieldPercent  float64 `json:"yieldPercent"`
+}
+
+// YieldSummary aggregates good/defect products across lots.
+type YieldSummary struct {
+	Lots          int64          `json:"lots"`
+	GoodProduct   int64          `json:"goodProduct"`
+	DefectProduct int64          `json:"defectProduct"`
+	TotalProduct  int64          `json:"totalProduct"`
+	YieldPercent  float64        `json:"yieldPercent"`
+	Machines      []MachineYield `json:"machines"`
+}
+
+// AggregateYield sums good and defect products per machine in SQL. Product counts
+// fall back to the completion summary when the columns are unset, matching ProductData.
+func (r *Repository) AggregateYield(ctx context.Context, filter YieldFilter) (YieldSummary, error) {
+	var (
+		conditions = []string{"deleted_at IS NULL"}
+		args       []any
+	)
+	if name := NormalizeMachineName(filter.MachineName); name != "" {
+		conditions = append(conditions, "machine_name = ?")
+		args = append(args, name)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "started_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `SELECT machine_name, COUNT(*),
+		COALESCE(SUM(COALESCE(good_product, CAST(JSON_EXTRACT(summary_json, '$.goodProduct') AS SIGNED), 0)), 0),
+		COALESCE(SUM(COALESCE(defect_product, CAST(JSON_EXTRACT(summary_json, '$.defectProduct') AS SIGNED), 0)), 0)
+		FROM lots WHERE ` + strings.Join(conditions, " AND ") + ` GROUP BY machine_name ORDER BY machine_name`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return YieldSummary{}, err
+	}
+	defer rows.Close()
+
+	summary := YieldSummary{Machines: []MachineYield{}}
+	for rows.Next() {
+		var m MachineYield
+		if err := rows.Scan(&m.MachineName, &m.Lots, &m.GoodProduct, &m.DefectProduct); err != nil {
+			return YieldSummary{}, err
+		}
+		m.TotalProduct = m.GoodProduct + m.DefectProduct
+		m.YieldPercent = yieldPercent(m.GoodProduct, m.TotalProduct)
+		summary.Machines = append(summary.Machines, m)
+
+		summary.Lots += m.Lots
+		summary.GoodProduct += m.GoodProduct
+		summary.DefectProduct += m.DefectProduct
+	}
+	if err := rows.Err(); err != nil {
+		return YieldSummary{}, err
+	}
+	summary.TotalProduct = summary.GoodProduct + summary.DefectProduct
+	summary.YieldPercent = yieldPercent(summary.GoodProduct, summary.TotalProduct)
+	return summary, nil
+}
+
+// yieldPercent returns good/total as a percentage rounded to two decimals, or 0 when total is 0.
+func yieldPercent(good, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(good)/float64(total)*10000) / 100
+}
@@ -7,7 +7,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"strconv"
@@ -192,7 +192,7 @@ func parseInt(raw string, fallback int) int {
 	}
 	v, err := strconv.Atoi(raw)
 	if err != nil {
-		log.Printf("invalid int value %q: %v, using %d", raw, err, fallback)
+		slog.Warn("invalid int value, using fallback", "value", raw, "error", err, "fallback", fallback)
 		return fallback
 	}
 	return v
@@ -204,7 +204,7 @@ func parseDuration(raw string, fallback time.Duration) time.Duration {
 	}
 	dur, err := time.ParseDuration(raw)
 	if err != nil {
-		log.Printf("invalid duration value %q: %v, using %s", raw, err, fallback)
+		slog.Warn("invalid duration value, using fallback", "value", raw, "error", err, "fallback", fallback.String())
 		return fallback
 	}
 	return dur
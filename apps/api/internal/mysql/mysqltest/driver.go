@@ -0,0 +1,243 @@
+// Package mysqltest provides a database/sql driver whose statements are
+// answered by Go functions, so repository code can be tested without MySQL.
+// It does not parse SQL: tests match on the statement text and return the rows
+// or result MySQL would.
+package mysqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Call is one statement run against a Server.
+type Call struct {
+	// Conn identifies the connection the statement ran on. Statements inside a
+	// transaction share their transaction's connection.
+	Conn int64
+	// Query is the statement text with runs of whitespace collapsed to a single
+	// space, so multi-line statements can be matched with strings.Contains.
+	Query string
+	Args  []driver.Value
+}
+
+// Rows is the result of a query. Values holds one slice per row, in Columns
+// order, using driver.Value types: int64, float64, bool, []byte, string,
+// time.Time or nil.
+type Rows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// Result is the outcome of a statement run with Exec.
+type Result struct {
+	LastInsertID int64
+	RowsAffected int64
+}
+
+// Server answers statements for the *sql.DB returned by DB. Nil functions
+// answer with an empty result. The functions may be called concurrently, one
+// call per connection at a time.
+type Server struct {
+	// Query answers statements run with QueryContext and QueryRowContext. A
+	// nil *Rows yields no rows and no columns.
+	Query func(Call) (*Rows, error)
+	// Exec answers statements run with ExecContext.
+	Exec func(Call) (Result, error)
+	// Begin, Commit and Rollback observe transactions on a connection.
+	Begin    func(conn int64) error
+	Commit   func(conn int64) error
+	Rollback func(conn int64) error
+
+	nextConn atomic.Int64
+	mu       sync.Mutex
+	calls    []Call
+}
+
+// DB returns a pool whose connections are served by s. Close it when done.
+func (s *Server) DB() *sql.DB {
+	return sql.OpenDB(connector{s})
+}
+
+// Calls returns the statements run so far, in order.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+func (s *Server) record(conn int64, query string, named []driver.NamedValue) Call {
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		args[i] = nv.Value
+	}
+	call := Call{Conn: conn, Query: strings.Join(strings.Fields(query), " "), Args: args}
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+	return call
+}
+
+type connector struct {
+	server *Server
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{server: c.server, id: c.server.nextConn.Add(1)}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("mysqltest: open connections through Server.DB")
+}
+
+type conn struct {
+	server *Server
+	id     int64
+}
+
+var (
+	_ driver.QueryerContext = (*conn)(nil)
+	_ driver.ExecerContext  = (*conn)(nil)
+	_ driver.ConnBeginTx    = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.server.Begin != nil {
+		if err := c.server.Begin(c.id); err != nil {
+			return nil, err
+		}
+	}
+	return tx{c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	call := c.server.record(c.id, query, args)
+	if c.server.Query == nil {
+		return &rows{}, nil
+	}
+	result, err := c.server.Query(call)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return &rows{}, nil
+	}
+	return &rows{columns: result.Columns, values: result.Values}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	call := c.server.record(c.id, query, args)
+	if c.server.Exec == nil {
+		return execResult{}, nil
+	}
+	result, err := c.server.Exec(call)
+	if err != nil {
+		return nil, err
+	}
+	return execResult{result}, nil
+}
+
+type execResult struct {
+	result Result
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return r.result.LastInsertID, nil
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.result.RowsAffected, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t tx) Commit() error {
+	if t.conn.server.Commit != nil {
+		return t.conn.server.Commit(t.conn.id)
+	}
+	return nil
+}
+
+func (t tx) Rollback() error {
+	if t.conn.server.Rollback != nil {
+		return t.conn.server.Rollback(t.conn.id)
+	}
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return out
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
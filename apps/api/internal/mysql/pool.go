@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrInvalidPoolLimits indicates pool limits that are negative or allow more idle
+// than open connections.
+var ErrInvalidPoolLimits = errors.New("invalid connection pool limits")
+
+// PoolStats is a JSON-friendly view of sql.DBStats plus the configured limits.
+type PoolStats struct {
+	MaxOpenConns      int    `json:"maxOpenConns"`
+	MaxIdleConns      int    `json:"maxIdleConns"`
+	OpenConnections   int    `json:"openConnections"`
+	InUse             int    `json:"inUse"`
+	Idle              int    `json:"idle"`
+	WaitCount         int64  `json:"waitCount"`
+	WaitDuration      string `json:"waitDuration"`
+	MaxIdleClosed     int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64  `json:"maxLifetimeClosed"`
+}
+
+// Pool tracks the limits applied to a *sql.DB so they can be reported and
+// adjusted at runtime; database/sql does not expose the idle limit.
+type Pool struct {
+	db *sql.DB
+
+	mu           sync.Mutex
+	maxOpenConns int
+	maxIdleConns int
+}
+
+// NewPool wraps db, whose limits were set from cfg by New.
+func NewPool(db *sql.DB, cfg Config) *Pool {
+	return &Pool{db: db, maxOpenConns: cfg.MaxOpenConns, maxIdleConns: cfg.MaxIdleConns}
+}
+
+// Stats returns the current pool statistics.
+func (p *Pool) Stats() PoolStats {
+	stats := p.db.Stats()
+	p.mu.Lock()
+	maxIdle := p.maxIdleConns
+	p.mu.Unlock()
+	return PoolStats{
+		MaxOpenConns:      stats.MaxOpenConnections,
+		MaxIdleConns:      maxIdle,
+		OpenConnections:   stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDuration:      stats.WaitDuration.String(),
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+	}
+}
+
+// SetLimits applies new open and idle connection limits; nil leaves a limit
+// unchanged. A max open of 0 means unlimited, as in database/sql.
+func (p *Pool) SetLimits(maxOpen, maxIdle *int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	open, idle := p.maxOpenConns, p.maxIdleConns
+	if maxOpen != nil {
+		open = *maxOpen
+	}
+	if maxIdle != nil {
+		idle = *maxIdle
+	}
+	if open < 0 || idle < 0 || (open > 0 && idle > open) {
+		return ErrInvalidPoolLimits
+	}
+	p.db.SetMaxOpenConns(open)
+	p.db.SetMaxIdleConns(idle)
+	p.maxOpenConns, p.maxIdleConns = open, idle
+	return nil
+}
+
+// Monitor logs a warning every interval in which callers had to wait for a
+// connection, until ctx is cancelled.
+func (p *Pool) Monitor(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if interval <= 0 {
+		return
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := p.db.Stats()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := p.db.Stats()
+				if waits := current.WaitCount - last.WaitCount; waits > 0 {
+					logger.Warn("mysql connection pool exhausted",
+						"waits", waits,
+						"waitDuration", (current.WaitDuration - last.WaitDuration).String(),
+						"inUse", current.InUse,
+						"maxOpenConns", current.MaxOpenConnections,
+					)
+				}
+				last = current
+			}
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+const (
+	webhookURLEnvKey           = "LOT_EVENT_WEBHOOK_URL"
+	defectAlertThresholdEnvKey = "DEFECT_ALERT_THRESHOLD"
+	defaultWebhookTimeout      = 5 * time.Second
+)
+
+// Event types published for lots.
+const (
+	EventLotCompleted = "lot_completed"
+	EventAlert        = "alert"
+)
+
+// AlertDefectRate identifies alerts raised for a defect ratio above the threshold.
+const AlertDefectRate = "defect_rate"
+
+// Event describes something that happened to a lot.
+type Event struct {
+	Type          string    `json:"type"`
+	Alert         string    `json:"alert,omitempty"`
+	LotNumber     string    `json:"lotNumber"`
+	MachineName   string    `json:"machineName"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	GoodProduct   *int      `json:"goodProduct,omitempty"`
+	DefectProduct *int      `json:"defectProduct,omitempty"`
+	DefectRatio   *float64  `json:"defectRatio,omitempty"`
+	Threshold     *float64  `json:"threshold,omitempty"`
+}
+
+// Notifier delivers lot events to downstream systems.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier writes events to a structured logger.
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+// Notify logs the event at info level.
+func (n LogNotifier) Notify(_ context.Context, event Event) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("lot event", "type", event.Type, "alert", event.Alert, "lot", event.LotNumber, "machine", event.MachineName)
+	return nil
+}
+
+// WebhookNotifier POSTs events as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify sends the event and fails on a non-2xx response.
+func (n WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode lot event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build lot event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post lot event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post lot event: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// FromEnv returns a WebhookNotifier when LOT_EVENT_WEBHOOK_URL is set and a
+// LogNotifier otherwise.
+func FromEnv(logger *slog.Logger) Notifier {
+	if url := strings.TrimSpace(os.Getenv(webhookURLEnvKey)); url != "" {
+		return WebhookNotifier{URL: url}
+	}
+	return LogNotifier{Logger: logger}
+}
+
+// DefectAlertThresholdFromEnv reads DEFECT_ALERT_THRESHOLD, the defect ratio
+// (defect / (good + defect)) above which completed lots raise an alert. Unset or
+// invalid values disable the alert and return 0.
+func DefectAlertThresholdFromEnv() float64 {
+	raw := strings.TrimSpace(os.Getenv(defectAlertThresholdEnvKey))
+	if raw == "" {
+		return 0
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold <= 0 || threshold >= 1 {
+		slog.Warn("invalid defect alert threshold, alerts disabled", "key", defectAlertThresholdEnvKey, "value", raw)
+		return 0
+	}
+	return threshold
+}
+
+// DefectRatio returns defect / (good + defect). ok is false when either count is
+// missing or both are zero.
+func DefectRatio(good, defect *int) (ratio float64, ok bool) {
+	if good == nil || defect == nil {
+		return 0, false
+	}
+	total := *good + *defect
+	if total <= 0 {
+		return 0, false
+	}
+	return float64(*defect) / float64(total), true
+}
+
+// LotCompleted publishes a completion event for lot and, when defectThreshold is
+// positive and the lot's stored defect ratio exceeds it, an alert event. Lots
+// without product counts never raise an alert. Delivery runs in the background
+// so a slow receiver does not hold up completion; failures are logged.
+func LotCompleted(ctx context.Context, n Notifier, logger *slog.Logger, lot metadata.Lot, completedAt time.Time, defectThreshold float64) {
+	if n == nil {
+		return
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	events := []Event{{
+		Type:          EventLotCompleted,
+		LotNumber:     lot.LotNumber,
+		MachineName:   lot.MachineName,
+		OccurredAt:    completedAt,
+		GoodProduct:   lot.GoodProduct,
+		DefectProduct: lot.DefectProduct,
+	}}
+	if ratio, ok := DefectRatio(lot.GoodProduct, lot.DefectProduct); ok && defectThreshold > 0 && ratio > defectThreshold {
+		alert := events[0]
+		alert.Type = EventAlert
+		alert.Alert = AlertDefectRate
+		alert.DefectRatio = &ratio
+		alert.Threshold = &defectThreshold
+		events = append(events, alert)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		for _, event := range events {
+			if err := n.Notify(ctx, event); err != nil {
+				logger.Warn("lot event notification failed", "type", event.Type, "lot", event.LotNumber, "error", err)
+			}
+		}
+	}()
+}
@@ -2,48 +2,112 @@ package processing
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/notify"
 )
 
+// The defaults assume the simulator's 1s write interval. The lookback must span
+// at least defaultSamplesPerSensor writes and should exceed the poll interval so
+// consecutive passes see overlapping windows; a lot whose sensors go down is
+// then completed within roughly one interval of its samples arriving.
 const (
-	defaultCompletionInterval   = 25 * time.Millisecond // Match simulator interval (was 1 minute)
-	defaultCompletionLookback   = 30 * time.Second // Increased lookback window
+	defaultCompletionInterval   = 5 * time.Second
+	minCompletionInterval       = time.Second
+	defaultCompletionLookback   = 30 * time.Second
 	defaultSamplesPerSensor     = 3
 	defaultZeroThreshold        = 5.0
 	defaultMeasurementForStatus = "sensor_data"
+	defaultMaxCheckBackoff      = time.Minute
+	minRunDurationEnvKey        = "LOT_COMPLETION_MIN_RUN"
+	recoverThresholdEnvKey      = "LOT_COMPLETION_RECOVER_THRESHOLD"
+	counterSensorsEnvKey        = "LOT_COUNTER_SENSORS"
+	defectCounterSensorsEnvKey  = "LOT_DEFECT_COUNTER_SENSORS"
+	completionIntervalEnvKey    = "COMPLETION_INTERVAL"
 )
 
 // CompletionService watches sensor readings and marks lots complete when machines stay down.
 type CompletionService struct {
-	influx              *influxdb.Client
-	repo                *metadata.Repository
+	influx              ReadingsSource
+	repo                LotStore
 	interval            time.Duration
 	lookback            time.Duration
 	samplesRequired     int
 	zeroThreshold       float64
+	recoverThreshold    float64
 	measurement         string
+	idleValues          map[string]float64
+	expectedSensors     map[string][]string
+	counterSensors      map[string]productCounters
+	minRunDuration      time.Duration
+	logger              *slog.Logger
+	notifier            notify.Notifier
+	defectThreshold     float64
+	maxCheckBackoff     time.Duration
+	cursor              *lotCursor
+	downStates          *downTracker
+
+	mu        sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+	lastCycle CompletionCycle
+}
+
+// CompletionCycle reports the outcome of one checkLots pass. Deferred counts
+// active lots skipped because their backoff had not yet elapsed.
+type CompletionCycle struct {
+	Evaluated  int
+	Deferred   int
+	Completed  int
+	FinishedAt time.Time
 }
 
 // CompletionOption customises the detector.
 type CompletionOption func(*CompletionService)
 
-// WithInterval overrides the poll interval.
+// WithInterval overrides the poll interval. Values below one second are raised
+// to that floor, since every pass queries Influx for each due lot.
 func WithInterval(d time.Duration) CompletionOption {
 	return func(s *CompletionService) {
-		if d > 0 {
-			s.interval = d
+		if d <= 0 {
+			return
 		}
+		s.interval = max(d, minCompletionInterval)
 	}
 }
 
+// IntervalFromEnv reads COMPLETION_INTERVAL, e.g. "10s". Unset or invalid
+// values fall back to the default; values below the floor are raised to it.
+func IntervalFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv(completionIntervalEnvKey))
+	if raw == "" {
+		return defaultCompletionInterval
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid lot completion interval, using default", "key", completionIntervalEnvKey, "value", raw, "default", defaultCompletionInterval.String())
+		return defaultCompletionInterval
+	}
+	if d < minCompletionInterval {
+		slog.Warn("lot completion interval below floor, using floor", "key", completionIntervalEnvKey, "value", raw, "floor", minCompletionInterval.String())
+		return minCompletionInterval
+	}
+	return d
+}
+
 // WithLookback changes the query lookback window.
 func WithLookback(d time.Duration) CompletionOption {
 	return func(s *CompletionService) {
@@ -71,6 +135,170 @@ func WithZeroThreshold(threshold float64) CompletionOption {
 	}
 }
 
+// WithRecoverThreshold adds hysteresis to the down check: a sensor goes down
+// when it reads at most the zero threshold, but once down only counts as
+// recovered when it rises above threshold, so values hovering around the zero
+// threshold do not make a lot flap between done and not. A threshold below the
+// zero threshold, including the default, disables hysteresis.
+func WithRecoverThreshold(threshold float64) CompletionOption {
+	return func(s *CompletionService) {
+		if threshold >= 0 {
+			s.recoverThreshold = threshold
+		}
+	}
+}
+
+// RecoverThresholdFromEnv reads LOT_COMPLETION_RECOVER_THRESHOLD, the value a
+// down sensor must exceed to recover. Unset or invalid values disable
+// hysteresis.
+func RecoverThresholdFromEnv() float64 {
+	raw := strings.TrimSpace(os.Getenv(recoverThresholdEnvKey))
+	if raw == "" {
+		return 0
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold < 0 || math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+		slog.Warn("invalid lot completion recover threshold, hysteresis disabled", "key", recoverThresholdEnvKey, "value", raw)
+		return 0
+	}
+	return threshold
+}
+
+// WithIdleValues registers per-sensor idle values keyed by SensorKey. A sensor with
+// an idle value is considered down when it reads at most idle + zero threshold,
+// and recovers above idle + recover threshold.
+func WithIdleValues(values map[string]float64) CompletionOption {
+	return func(s *CompletionService) {
+		if len(values) > 0 {
+			s.idleValues = values
+		}
+	}
+}
+
+// WithExpectedSensors lists the sensors machine must report before its lots can
+// complete. An expected sensor with no readings in the lookback counts as not
+// down, so a sensor that went silent cannot let the others complete the lot
+// early. Repeated calls for the same machine replace its list.
+func WithExpectedSensors(machine string, names []string) CompletionOption {
+	return func(s *CompletionService) {
+		if machine == "" {
+			return
+		}
+		if s.expectedSensors == nil {
+			s.expectedSensors = make(map[string][]string)
+		}
+		s.expectedSensors[machine] = append([]string(nil), names...)
+	}
+}
+
+// productCounters names a machine's cumulative counter sensors. Either may be
+// empty.
+type productCounters struct {
+	good, defect string
+}
+
+// WithCounterSensor derives the good product count of machine's lots from
+// sensor, a cumulative counter of units produced: a lot's count is the
+// counter's rise over the lot, its maximum minus its minimum between the lot's
+// start and completion, rounded to a whole unit. The count is stored as the
+// completion summary's goodProduct, which product data falls back to until
+// good_product is set by hand. A counter that resets during a lot undercounts
+// it. Machines without a counter sensor complete with no counts, as before.
+// Counter sensors are left out of the sensor-down check.
+func WithCounterSensor(machine, sensor string) CompletionOption {
+	return func(s *CompletionService) {
+		s.setCounter(machine, func(c *productCounters) { c.good = sensor })
+	}
+}
+
+// WithDefectCounterSensor is WithCounterSensor for the defect count, stored as
+// the summary's defectProduct. Without one, defects are left unset.
+func WithDefectCounterSensor(machine, sensor string) CompletionOption {
+	return func(s *CompletionService) {
+		s.setCounter(machine, func(c *productCounters) { c.defect = sensor })
+	}
+}
+
+func (s *CompletionService) setCounter(machine string, set func(*productCounters)) {
+	if machine == "" {
+		return
+	}
+	if s.counterSensors == nil {
+		s.counterSensors = make(map[string]productCounters)
+	}
+	counters := s.counterSensors[machine]
+	set(&counters)
+	s.counterSensors[machine] = counters
+}
+
+// CounterSensorsFromEnv reads LOT_COUNTER_SENSORS and
+// LOT_DEFECT_COUNTER_SENSORS, each a list of "machine:sensor" pairs such as
+// "CT-01:UnitCount,CT-02:UnitCount", into WithCounterSensor and
+// WithDefectCounterSensor options. Malformed pairs are logged and skipped.
+func CounterSensorsFromEnv() []CompletionOption {
+	var opts []CompletionOption
+	for key, option := range map[string]func(machine, sensor string) CompletionOption{
+		counterSensorsEnvKey:       WithCounterSensor,
+		defectCounterSensorsEnvKey: WithDefectCounterSensor,
+	} {
+		for _, pair := range strings.Split(os.Getenv(key), ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			machine, sensor, ok := strings.Cut(pair, ":")
+			machine, sensor = strings.TrimSpace(machine), strings.TrimSpace(sensor)
+			if !ok || machine == "" || sensor == "" {
+				slog.Warn("invalid lot counter sensor, skipping", "key", key, "value", pair)
+				continue
+			}
+			opts = append(opts, option(machine, sensor))
+		}
+	}
+	return opts
+}
+
+// SensorKey builds the lookup key used by WithIdleValues.
+func SensorKey(machineName, sensorName string) string {
+	return machineName + "/" + sensorName
+}
+
+// WithMinRunDuration keeps lots from completing until d has elapsed since they
+// started, so low readings during the startup ramp are not taken as done.
+func WithMinRunDuration(d time.Duration) CompletionOption {
+	return func(s *CompletionService) {
+		if d > 0 {
+			s.minRunDuration = d
+		}
+	}
+}
+
+// MinRunDurationFromEnv reads LOT_COMPLETION_MIN_RUN, e.g. "5m". Unset or
+// invalid values disable the grace period.
+func MinRunDurationFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv(minRunDurationEnvKey))
+	if raw == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		slog.Warn("invalid lot completion min run duration, grace period disabled", "key", minRunDurationEnvKey, "value", raw)
+		return 0
+	}
+	return d
+}
+
+// WithMaxCheckBackoff caps how long a lot whose sensors keep running may go
+// between evaluations. Each running result doubles the lot's delay, starting
+// at the poll interval.
+func WithMaxCheckBackoff(d time.Duration) CompletionOption {
+	return func(s *CompletionService) {
+		if d > 0 {
+			s.maxCheckBackoff = d
+		}
+	}
+}
+
 // WithMeasurement allows overriding the measurement name queried in Influx.
 func WithMeasurement(name string) CompletionOption {
 	return func(s *CompletionService) {
@@ -80,8 +308,26 @@ func WithMeasurement(name string) CompletionOption {
 	}
 }
 
+// WithLogger sets the structured logger used by the detector.
+func WithLogger(logger *slog.Logger) CompletionOption {
+	return func(s *CompletionService) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+// WithNotifier publishes lot completion events to n. When defectThreshold is
+// positive, lots completing with a higher defect ratio also raise an alert event.
+func WithNotifier(n notify.Notifier, defectThreshold float64) CompletionOption {
+	return func(s *CompletionService) {
+		s.notifier = n
+		s.defectThreshold = defectThreshold
+	}
+}
+
 // NewCompletionService constructs a detector with sensible defaults.
-func NewCompletionService(client *influxdb.Client, repo *metadata.Repository, opts ...CompletionOption) *CompletionService {
+func NewCompletionService(client ReadingsSource, repo LotStore, opts ...CompletionOption) *CompletionService {
 	svc := &CompletionService{
 		influx:          client,
 		repo:            repo,
@@ -90,142 +336,393 @@ func NewCompletionService(client *influxdb.Client, repo *metadata.Repository, op
 		samplesRequired: defaultSamplesPerSensor,
 		zeroThreshold:   defaultZeroThreshold,
 		measurement:     defaultMeasurementForStatus,
+		maxCheckBackoff: defaultMaxCheckBackoff,
+		cursor:          newLotCursor(),
+		downStates:      newDownTracker(),
+		logger:          slog.Default(),
 	}
 	for _, opt := range opts {
 		opt(svc)
 	}
+	svc.recoverThreshold = max(svc.recoverThreshold, svc.zeroThreshold)
 	return svc
 }
 
-// Start begins the background polling loop.
+// Start begins the background polling loop. Cancelling ctx stops the loop
+// between passes; use Stop to also wait for an in-progress pass to finish.
 func (s *CompletionService) Start(ctx context.Context) {
 	if s.influx == nil || s.repo == nil {
 		return
 	}
 
+	s.mu.Lock()
+	if s.done != nil {
+		s.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.stop, s.done = stop, done
+	s.mu.Unlock()
+
+	// Passes run on a context detached from ctx so a shutdown does not abort a
+	// lot halfway through being marked complete.
+	passCtx := context.WithoutCancel(ctx)
+
 	ticker := time.NewTicker(s.interval)
 	go func() {
+		defer close(done)
 		defer ticker.Stop()
-		log.Printf("lot completion service running; interval=%s lookback=%s", s.interval, s.lookback)
+		s.logger.Info("lot completion service running", "interval", s.interval.String(), "lookback", s.lookback.String(), "minRunDuration", s.minRunDuration.String(), "maxCheckBackoff", s.maxCheckBackoff.String())
 		for {
 			select {
 			case <-ctx.Done():
-				log.Println("lot completion service stopped")
+				s.logger.Info("lot completion service stopped")
+				return
+			case <-stop:
 				return
 			case <-ticker.C:
-				s.checkLots(ctx)
+				cycle := s.checkLots(passCtx)
+				s.mu.Lock()
+				s.lastCycle = cycle
+				s.mu.Unlock()
 			}
 		}
 	}()
 }
 
-func (s *CompletionService) checkLots(ctx context.Context) {
-	log.Printf("[DEBUG] checkLots: starting lot completion check cycle...")
+// Stop ends the polling loop and waits for an in-progress pass to finish, or
+// for ctx to expire. It logs how many lots the last completed pass evaluated.
+func (s *CompletionService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	if stop != nil {
+		close(stop)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("lot completion service stop timed out with a pass in progress", "error", ctx.Err())
+		return ctx.Err()
+	}
+
+	cycle := s.LastCycle()
+	s.logger.Info("lot completion service shutdown summary",
+		"lastCycleEvaluated", cycle.Evaluated,
+		"lastCycleDeferred", cycle.Deferred,
+		"lastCycleCompleted", cycle.Completed,
+		"lastCycleAt", cycle.FinishedAt,
+	)
+	return nil
+}
+
+// LastCycle returns the outcome of the most recent completed pass.
+func (s *CompletionService) LastCycle() CompletionCycle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastCycle
+}
+
+func (s *CompletionService) checkLots(ctx context.Context) CompletionCycle {
+	s.logger.Debug("lot completion check cycle starting")
+	cycle := CompletionCycle{}
 	lots, err := s.repo.ListActiveLots(ctx)
 	if err != nil {
-		log.Printf("[DEBUG] checkLots: list active lots failed: %v", err)
-		return
+		s.logger.Error("lot completion list active lots failed", "error", err)
+		cycle.FinishedAt = time.Now()
+		return cycle
 	}
 	if len(lots) == 0 {
-		log.Printf("[DEBUG] checkLots: no active lots found, skipping check")
-		return
+		s.logger.Debug("lot completion found no active lots")
+		cycle.FinishedAt = time.Now()
+		return cycle
 	}
 
-	log.Printf("[DEBUG] checkLots: found %d active lot(s) to check", len(lots))
-
-	for i, lot := range lots {
-		log.Printf("[DEBUG] checkLots: [%d/%d] checking lot=%s machine=%s status=%s for sensor-down", 
-			i+1, len(lots), lot.LotNumber, lot.MachineName, lot.Status)
+	active := make(map[int64]struct{}, len(lots))
+	for _, lot := range lots {
+		active[lot.ID] = struct{}{}
+	}
+	s.cursor.prune(active)
+	s.downStates.prune(active)
 
-		// Fallback to original sensor-down based completion
-		summary, done, evalErr := s.evaluateLot(ctx, lot)
-		if evalErr != nil {
-			log.Printf("[DEBUG] checkLots: lot=%s evaluateLot error: %v", lot.LotNumber, evalErr)
-			continue
-		}
-		if !done || summary == nil {
-			log.Printf("[DEBUG] checkLots: lot=%s sensor-down check returned done=%v (not ready for completion)", lot.LotNumber, done)
+	now := time.Now()
+	for _, lot := range lots {
+		if !s.cursor.due(lot.ID, now) {
+			cycle.Deferred++
 			continue
 		}
+		s.logger.Debug("lot completion checking lot for sensor-down",
+			"lot", lot.LotNumber, "machine", lot.MachineName, "status", lot.Status)
 
-		log.Printf("[DEBUG] checkLots: lot=%s all sensors DOWN, marking as completed via sensor-down logic", lot.LotNumber)
-		if err := s.repo.MarkLotCompleted(ctx, lot.ID, *summary); err != nil {
-			if !errorsIsNoRows(err) {
-				log.Printf("[DEBUG] checkLots: lot=%s MarkLotCompleted (sensor-down) error: %v", lot.LotNumber, err)
+		cycle.Evaluated++
+		// Evaluate under the lot's row lock so a concurrent coordinator
+		// completion either waits for this decision or is upgraded by it.
+		progress := lotRunning
+		var states map[string]sensorDownState
+		result, summary, err := s.repo.FinalizeLot(ctx, lot.ID, func(ctx context.Context, locked metadata.Lot) (*metadata.LotSummary, error) {
+			eval, err := s.evaluateLot(ctx, locked)
+			progress, states = eval.progress, eval.states
+			if err != nil || !eval.Done {
+				return nil, err
 			}
+			return eval.Summary, nil
+		})
+		if err == nil && states != nil {
+			s.downStates.set(lot.ID, states)
+		}
+		if err != nil {
+			if !errors.Is(err, metadata.ErrLotNotFound) {
+				s.logger.Error("lot completion finalize failed", "lot", lot.LotNumber, "error", err)
+			}
+			continue
+		}
+		switch {
+		case result == metadata.FinalizeUpgraded:
+			s.logger.Info("lot completion replaced bare summary", "lot", lot.LotNumber, "machine", lot.MachineName)
+			continue
+		case result == metadata.FinalizeCompleted:
+		case progress == lotRunning:
+			s.cursor.backOff(lot.ID, now, s.interval, s.maxCheckBackoff)
+			continue
+		default:
+			s.logger.Debug("lot completion not ready, sensors trending down", "lot", lot.LotNumber)
+			s.cursor.reset(lot.ID)
 			continue
 		}
-		log.Printf("✅ lot completion: lot %s marked complete via sensor-down (machine=%s)", lot.LotNumber, lot.MachineName)
+
+		cycle.Completed++
+		s.logger.Info("lot marked complete via sensor-down", "lot", lot.LotNumber, "machine", lot.MachineName)
+		notify.LotCompleted(ctx, s.notifier, s.logger, lot, summary.CompletedAt, s.defectThreshold)
 	}
-	log.Printf("[DEBUG] checkLots: cycle completed, processed %d lot(s)", len(lots))
+	s.logger.Debug("lot completion check cycle completed", "evaluated", cycle.Evaluated, "deferred", cycle.Deferred)
+	cycle.FinishedAt = time.Now()
+	return cycle
 }
 
-func (s *CompletionService) evaluateLot(ctx context.Context, lot metadata.Lot) (*metadata.LotSummary, bool, error) {
+// evaluateLot reports whether lot's sensors have stayed down, with the summary
+// to complete it with once they all have. A lot still inside its grace period
+// counts as running.
+func (s *CompletionService) evaluateLot(ctx context.Context, lot metadata.Lot) (LotEvaluation, error) {
+	eval := LotEvaluation{
+		LotNumber:   lot.LotNumber,
+		MachineName: lot.MachineName,
+		EvaluatedAt: time.Now().UTC(),
+		Progress:    lotRunning.String(),
+	}
+	if s.minRunDuration > 0 {
+		if elapsed := time.Since(lot.StartedAt); elapsed < s.minRunDuration {
+			s.logger.Debug("lot completion within grace period", "lot", lot.LotNumber, "elapsed", elapsed.Round(time.Second).String(), "minRunDuration", s.minRunDuration.String())
+			eval.Reason = fmt.Sprintf("within the %s grace period after the lot started", s.minRunDuration)
+			return eval, nil
+		}
+	}
 	limit := s.samplesRequired * 8
 	if limit < s.samplesRequired {
 		limit = s.samplesRequired
 	}
 	readings, err := s.influx.RecentSensorReadingsByMachine(ctx, s.measurement, lot.MachineName, s.lookback, limit)
 	if err != nil {
-		return nil, false, err
+		return eval, err
+	}
+	windows := sensorWindows(readings, s.samplesRequired)
+	if len(windows) == 0 {
+		eval.Reason = fmt.Sprintf("no readings within the last %s", s.lookback)
+		return eval, nil
 	}
-	if len(readings) == 0 {
-		return nil, false, nil
+	eval.MissingSensors = s.missingSensors(lot.MachineName, windows)
+	if len(eval.MissingSensors) > 0 {
+		s.logger.Debug("lot completion waiting for expected sensors", "lot", lot.LotNumber, "missing", eval.MissingSensors)
 	}
 
-	sensorWindows := make(map[string][]influxdb.SensorReading)
-	for _, reading := range readings {
-		window := sensorWindows[reading.SensorName]
-		if len(window) >= s.samplesRequired {
+	// Counters only ever rise, so they take no part in deciding whether the
+	// machine has stopped.
+	counters := s.counterSensors[lot.MachineName]
+	previous := s.downStates.get(lot.ID)
+	eval.states = make(map[string]sensorDownState, len(windows))
+	anyDown := false
+	for _, name := range slices.Sorted(maps.Keys(windows)) {
+		samples := windows[name]
+		threshold, recovery := s.downThreshold(samples[0]), s.recoverLevel(samples[0])
+		state := previous[name].advance(samples, s.samplesRequired, threshold, recovery)
+		eval.states[name] = state
+		sensor := SensorEvaluation{
+			SensorName:       name,
+			LatestStatus:     samples[0].Status,
+			LatestValue:      samples[0].Value,
+			LatestAt:         samples[0].Time,
+			Threshold:        threshold,
+			RecoverThreshold: recovery,
+			Samples:          len(samples),
+			DownSamples:      state.count,
+			Down:             state.down && state.count >= s.samplesRequired,
+			Counter:          name == counters.good || name == counters.defect,
+		}
+		eval.Sensors = append(eval.Sensors, sensor)
+		if sensor.Counter {
 			continue
 		}
-		sensorWindows[reading.SensorName] = append(window, reading)
+		if state.down {
+			anyDown = true
+		}
+		if !sensor.Down {
+			eval.NotDown = append(eval.NotDown, name)
+		}
 	}
-	if len(sensorWindows) == 0 {
-		return nil, false, nil
+	if len(eval.MissingSensors) > 0 || len(eval.NotDown) > 0 {
+		if anyDown {
+			eval.progress = lotWindingDown
+		}
+		eval.Progress = eval.progress.String()
+		eval.Reason = notDoneReason(eval, s.samplesRequired)
+		return eval, nil
 	}
 
+	summary := buildLotSummary(lot, readings[0].Time, windows)
+	mins, maxs, err := applyLotRanges(ctx, s.influx, s.measurement, lot, &summary)
+	if err != nil {
+		s.logger.Warn("lot completion sensor min/max query failed", "lot", lot.LotNumber, "error", err)
+	} else {
+		s.applyProductCounts(lot, &summary, mins, maxs)
+	}
+	eval.progress = lotDone
+	eval.Progress = lotDone.String()
+	eval.Done = true
+	eval.Summary = &summary
+	return eval, nil
+}
+
+// SummarizeLot rebuilds a completed lot's summary from Influx history between its
+// start and completion, using up to samplesPerSensor of each sensor's final
+// readings for the snapshots.
+func SummarizeLot(ctx context.Context, client ReadingsSource, measurement string, lot metadata.Lot, samplesPerSensor int) (metadata.LotSummary, error) {
+	if !lot.CompletedAt.Valid {
+		return metadata.LotSummary{}, errors.New("lot has no completion time")
+	}
+	if samplesPerSensor <= 0 {
+		samplesPerSensor = defaultSamplesPerSensor
+	}
+	completedAt := lot.CompletedAt.Time
+	readings, err := client.SensorReadingsBetween(ctx, measurement, lot.MachineName, lot.StartedAt, completedAt.Add(time.Nanosecond), samplesPerSensor)
+	if err != nil {
+		return metadata.LotSummary{}, err
+	}
+	summary := buildLotSummary(lot, completedAt, sensorWindows(readings, samplesPerSensor))
+	if _, _, err := applyLotRanges(ctx, client, measurement, lot, &summary); err != nil {
+		return metadata.LotSummary{}, err
+	}
+	return summary, nil
+}
+
+// missingSensors returns the expected sensors of machine absent from windows.
+func (s *CompletionService) missingSensors(machine string, windows map[string][]influxdb.SensorReading) []string {
+	var missing []string
+	for _, name := range s.expectedSensors[machine] {
+		if _, ok := windows[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// sensorWindows groups readings by sensor, keeping at most samples readings per
+// sensor in their original (newest first) order.
+func sensorWindows(readings []influxdb.SensorReading, samples int) map[string][]influxdb.SensorReading {
+	windows := make(map[string][]influxdb.SensorReading)
+	for _, reading := range readings {
+		window := windows[reading.SensorName]
+		if len(window) >= samples {
+			continue
+		}
+		windows[reading.SensorName] = append(window, reading)
+	}
+	return windows
+}
+
+// buildLotSummary snapshots each sensor window, ordered by sensor name.
+func buildLotSummary(lot metadata.Lot, completedAt time.Time, windows map[string][]influxdb.SensorReading) metadata.LotSummary {
 	summary := metadata.LotSummary{
-		CompletedAt: readings[0].Time,
+		CompletedAt: completedAt,
 		MachineName: lot.MachineName,
 	}
-	sensorNames := make([]string, 0, len(sensorWindows))
-	for name := range sensorWindows {
+	sensorNames := make([]string, 0, len(windows))
+	for name := range windows {
 		sensorNames = append(sensorNames, name)
 	}
 	sort.Strings(sensorNames)
 
-	allDown := true
 	for _, name := range sensorNames {
-		samples := sensorWindows[name]
-		if len(samples) < s.samplesRequired {
-			allDown = false
-		}
-		avg := averageValue(samples)
+		samples := windows[name]
 		latest := samples[0]
-		if !isDownSample(latest, s.zeroThreshold) {
-			allDown = false
-		}
-		for _, sample := range samples[1:] {
-			if !isDownSample(sample, s.zeroThreshold) {
-				allDown = false
-				break
-			}
-		}
 		summary.Sensors = append(summary.Sensors, metadata.SensorSnapshot{
 			SensorName:    name,
 			LatestStatus:  latest.Status,
 			LatestValue:   latest.Value,
-			AverageDown:   avg,
+			AverageDown:   averageValue(samples),
 			ObservedCount: len(samples),
 		})
 	}
+	return summary
+}
+
+// applyLotRanges adds each sensor's min/max over the lot's processing window
+// and returns them by sensor name.
+func applyLotRanges(ctx context.Context, client ReadingsSource, measurement string, lot metadata.Lot, summary *metadata.LotSummary) (mins, maxs map[string]float64, err error) {
+	// Range stop is exclusive; extend it so the final reading is included.
+	mins, maxs, err = client.MinMaxBySensor(ctx, measurement, lot.MachineName, lot.StartedAt, summary.CompletedAt.Add(time.Nanosecond))
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata.ApplySensorRanges(summary, metadata.BuildSensorRanges(mins, maxs))
+	return mins, maxs, nil
+}
+
+// applyProductCounts sets summary's product counts from the rise of lot's
+// machine counters, as described on WithCounterSensor. A counter with no
+// readings over the lot leaves its count unset.
+func (s *CompletionService) applyProductCounts(lot metadata.Lot, summary *metadata.LotSummary, mins, maxs map[string]float64) {
+	counters, ok := s.counterSensors[lot.MachineName]
+	if !ok {
+		return
+	}
+	rise := func(sensor string) (int, bool) {
+		lo, okLo := mins[sensor]
+		hi, okHi := maxs[sensor]
+		if sensor == "" || !okLo || !okHi {
+			return 0, false
+		}
+		return int(math.Round(hi - lo)), true
+	}
+	if count, ok := rise(counters.good); ok {
+		summary.GoodProduct = count
+	} else if counters.good != "" {
+		s.logger.Warn("lot counter sensor has no readings", "lot", lot.LotNumber, "sensor", counters.good)
+	}
+	if count, ok := rise(counters.defect); ok {
+		summary.DefectProduct = count
+	} else if counters.defect != "" {
+		s.logger.Warn("lot defect counter sensor has no readings", "lot", lot.LotNumber, "sensor", counters.defect)
+	}
+}
 
-	if !allDown {
-		return nil, false, nil
+func (s *CompletionService) downThreshold(sample influxdb.SensorReading) float64 {
+	if idle, ok := s.idleValues[SensorKey(sample.MachineName, sample.SensorName)]; ok {
+		return idle + s.zeroThreshold
 	}
+	return s.zeroThreshold
+}
 
-	return &summary, true, nil
+// recoverLevel is the value a down sensor must exceed to recover.
+func (s *CompletionService) recoverLevel(sample influxdb.SensorReading) float64 {
+	if idle, ok := s.idleValues[SensorKey(sample.MachineName, sample.SensorName)]; ok {
+		return idle + s.recoverThreshold
+	}
+	return s.recoverThreshold
 }
 
 func averageValue(samples []influxdb.SensorReading) float64 {
@@ -245,7 +742,3 @@ func isDownSample(sample influxdb.SensorReading, threshold float64) bool {
 	}
 	return sample.Value <= threshold
 }
-
-func errorsIsNoRows(err error) bool {
-	return errors.Is(err, sql.ErrNoRows)
-}
@@ -0,0 +1,223 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+)
+
+var (
+	_ LotStore       = (*metadatatest.Store)(nil)
+	_ ReadingsSource = (*influxtest.Stub)(nil)
+)
+
+// sensorReadings returns n readings of one sensor a second apart, the newest
+// at last.
+func sensorReadings(machine, sensor, status string, value float64, last time.Time, n int) []influxdb.SensorReading {
+	readings := make([]influxdb.SensorReading, n)
+	for i := range readings {
+		readings[i] = influxdb.SensorReading{
+			Time:        last.Add(-time.Duration(i) * time.Second),
+			MachineName: machine,
+			SensorName:  sensor,
+			Status:      status,
+			Value:       value,
+		}
+	}
+	return readings
+}
+
+func TestCheckLotsCompletesDownLot(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		readings [][]influxdb.SensorReading
+		want     metadata.LotStatus
+	}{
+		{
+			name: "every sensor down",
+			readings: [][]influxdb.SensorReading{
+				sensorReadings("Oven-01", "Temperature", "down", 0.5, now, 4),
+				sensorReadings("Oven-01", "Pressure", "down", 0.1, now, 4),
+			},
+			want: metadata.LotStatusCompleted,
+		},
+		{
+			name: "one sensor still running",
+			readings: [][]influxdb.SensorReading{
+				sensorReadings("Oven-01", "Temperature", "running", 180, now, 4),
+				sensorReadings("Oven-01", "Pressure", "down", 0.1, now, 4),
+			},
+			want: metadata.LotStatusProcessing,
+		},
+		{
+			// Low values alone do not complete a lot; the status must say down.
+			name: "low values without status",
+			readings: [][]influxdb.SensorReading{
+				sensorReadings("Oven-01", "Temperature", "", 0.5, now, 4),
+				sensorReadings("Oven-01", "Pressure", "", 0.1, now, 4),
+			},
+			want: metadata.LotStatusProcessing,
+		},
+		{
+			name: "too few down samples",
+			readings: [][]influxdb.SensorReading{
+				sensorReadings("Oven-01", "Temperature", "down", 0.5, now, 2),
+				sensorReadings("Oven-01", "Pressure", "down", 0.1, now, 2),
+			},
+			want: metadata.LotStatusProcessing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := influxtest.New()
+			stub.Now = func() time.Time { return now }
+			for _, readings := range tt.readings {
+				stub.Add(readings...)
+			}
+			store := metadatatest.New()
+			lot := store.PutLot(metadata.Lot{
+				LotNumber:   "LOT-1",
+				MachineName: "Oven-01",
+				Status:      metadata.LotStatusProcessing,
+				StartedAt:   now.Add(-10 * time.Minute),
+			})
+			svc := NewCompletionService(stub, store)
+
+			cycle := svc.checkLots(context.Background())
+			got, err := store.GetLotByNumber(context.Background(), lot.LotNumber)
+			if err != nil {
+				t.Fatalf("GetLotByNumber: %v", err)
+			}
+			if got.Status != tt.want {
+				t.Fatalf("lot status = %s, want %s", got.Status, tt.want)
+			}
+			if tt.want != metadata.LotStatusCompleted {
+				if cycle.Completed != 0 {
+					t.Errorf("cycle completed %d lots, want 0", cycle.Completed)
+				}
+				return
+			}
+			if cycle.Completed != 1 {
+				t.Errorf("cycle completed %d lots, want 1", cycle.Completed)
+			}
+			if !got.CompletedAt.Valid || !got.CompletedAt.Time.Equal(now) {
+				t.Errorf("completed at %v, want %v", got.CompletedAt, now)
+			}
+			summary, err := got.Summary()
+			if err != nil || summary == nil {
+				t.Fatalf("lot summary = %v, %v", summary, err)
+			}
+			if len(summary.Sensors) != 2 {
+				t.Fatalf("summary has %d sensors, want 2", len(summary.Sensors))
+			}
+			for _, sensor := range summary.Sensors {
+				if sensor.LatestStatus != "down" {
+					t.Errorf("sensor %s latest status = %q, want down", sensor.SensorName, sensor.LatestStatus)
+				}
+			}
+		})
+	}
+}
+
+// writeTick adds one simulator tick for Oven-01: temperature and pressure with
+// status, and the product counter, which keeps running.
+func writeTick(stub *influxtest.Stub, at time.Time, status string, temperature, pressure, count float64) {
+	stub.Add(
+		influxdb.SensorReading{Time: at, MachineName: "Oven-01", SensorName: "Temperature", Status: status, Value: temperature},
+		influxdb.SensorReading{Time: at, MachineName: "Oven-01", SensorName: "Pressure", Status: status, Value: pressure},
+		influxdb.SensorReading{Time: at, MachineName: "Oven-01", SensorName: "Count", Status: "running", Value: count},
+	)
+}
+
+func TestCompletionServiceAcrossPasses(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	now := start
+	stub := influxtest.New()
+	stub.Now = func() time.Time { return now }
+	store := metadatatest.New()
+	lot := store.PutLot(metadata.Lot{LotNumber: "LOT-1", MachineName: "Oven-01", Status: metadata.LotStatusProcessing, StartedAt: start})
+	svc := NewCompletionService(stub, store, WithCounterSensor("Oven-01", "Count"))
+
+	// The machine runs for a minute, producing 60 units.
+	count := 10.0
+	for i := 0; i < 60; i++ {
+		now = now.Add(time.Second)
+		writeTick(stub, now, "running", 180, 3.2, count)
+		count++
+	}
+	eval, err := svc.EvaluateLot(ctx, lot)
+	if err != nil {
+		t.Fatalf("EvaluateLot: %v", err)
+	}
+	if eval.Done || eval.Progress != "running" {
+		t.Fatalf("running machine evaluated as %s (done %v)", eval.Progress, eval.Done)
+	}
+	for _, sensor := range eval.Sensors {
+		if sensor.SensorName == "Count" && !sensor.Counter {
+			t.Error("the counter sensor took part in the down check")
+		}
+	}
+
+	// It shuts down: two down ticks are not yet enough.
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Second)
+		writeTick(stub, now, "down", 0.4, 0.1, count)
+	}
+	if cycle := svc.checkLots(ctx); cycle.Completed != 0 {
+		t.Fatal("lot completed after two down samples")
+	}
+	if eval, _ := svc.EvaluateLot(ctx, lot); eval.Progress != "winding_down" {
+		t.Fatalf("progress after two down ticks = %s, want winding_down", eval.Progress)
+	}
+
+	// A third completes it, with the counter's rise as the good product count.
+	now = now.Add(time.Second)
+	writeTick(stub, now, "down", 0.4, 0.1, count)
+	if cycle := svc.checkLots(ctx); cycle.Completed != 1 {
+		t.Fatalf("cycle completed %d lots, want 1", cycle.Completed)
+	}
+	got, err := store.GetLotByNumber(ctx, lot.LotNumber)
+	if err != nil {
+		t.Fatalf("GetLotByNumber: %v", err)
+	}
+	summary, err := got.Summary()
+	if err != nil || summary == nil {
+		t.Fatalf("lot summary = %v, %v", summary, err)
+	}
+	if got.Status != metadata.LotStatusCompleted || !got.CompletedAt.Time.Equal(now) {
+		t.Errorf("lot %s at %v, want completed at %v", got.Status, got.CompletedAt.Time, now)
+	}
+	if summary.GoodProduct != 60 {
+		t.Errorf("good product = %d, want 60", summary.GoodProduct)
+	}
+
+	// Completed lots are no longer polled.
+	if cycle := svc.checkLots(ctx); cycle.Evaluated != 0 {
+		t.Errorf("evaluated %d lots after completion, want 0", cycle.Evaluated)
+	}
+}
+
+func TestCompletionServiceInfluxError(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	stub := influxtest.New(sensorReadings("Oven-01", "Temperature", "down", 0.5, now, 4)...)
+	stub.Now = func() time.Time { return now }
+	stub.Err = errors.New("influx unavailable")
+	store := metadatatest.New()
+	store.PutLot(metadata.Lot{LotNumber: "LOT-1", MachineName: "Oven-01", Status: metadata.LotStatusProcessing, StartedAt: now.Add(-time.Hour)})
+
+	cycle := NewCompletionService(stub, store).checkLots(context.Background())
+	if cycle.Evaluated != 1 || cycle.Completed != 0 {
+		t.Errorf("cycle = %+v, want one lot evaluated and none completed", cycle)
+	}
+	if lot, _ := store.GetLotByNumber(context.Background(), "LOT-1"); lot.Status != metadata.LotStatusProcessing {
+		t.Errorf("lot status = %s after a failed query, want processing", lot.Status)
+	}
+}
@@ -0,0 +1,78 @@
+package processing
+
+import "time"
+
+// lotProgress is what one evaluation learned about a lot.
+type lotProgress int
+
+const (
+	// lotRunning means no sensor reported down.
+	lotRunning lotProgress = iota
+	// lotWindingDown means some sensors reported down but not all long enough.
+	lotWindingDown
+	// lotDone means every sensor stayed down and the lot can be completed.
+	lotDone
+)
+
+// String names the progress as LotEvaluation reports it.
+func (p lotProgress) String() string {
+	switch p {
+	case lotWindingDown:
+		return "winding_down"
+	case lotDone:
+		return "done"
+	default:
+		return "running"
+	}
+}
+
+// lotCheck records when a lot is next due and the delay used to schedule it.
+type lotCheck struct {
+	next    time.Time
+	backoff time.Duration
+}
+
+// lotCursor is the in-memory schedule of per-lot evaluations, keyed by lot ID.
+// Lots that keep running are checked at doubling intervals up to a cap; lots
+// that start winding down are checked every pass. It is only touched by the
+// polling goroutine.
+type lotCursor struct {
+	checks map[int64]lotCheck
+}
+
+func newLotCursor() *lotCursor {
+	return &lotCursor{checks: make(map[int64]lotCheck)}
+}
+
+// due reports whether lotID should be evaluated at now. Unknown lots are due.
+func (c *lotCursor) due(lotID int64, now time.Time) bool {
+	check, ok := c.checks[lotID]
+	return !ok || !now.Before(check.next)
+}
+
+// backOff schedules lotID after double its previous delay, starting at base
+// and capped at max.
+func (c *lotCursor) backOff(lotID int64, now time.Time, base, max time.Duration) {
+	delay := base
+	if check, ok := c.checks[lotID]; ok && check.backoff > 0 {
+		delay = check.backoff * 2
+	}
+	if delay > max {
+		delay = max
+	}
+	c.checks[lotID] = lotCheck{next: now.Add(delay), backoff: delay}
+}
+
+// reset makes lotID due on the next pass.
+func (c *lotCursor) reset(lotID int64) {
+	delete(c.checks, lotID)
+}
+
+// prune drops lots that are no longer active.
+func (c *lotCursor) prune(active map[int64]struct{}) {
+	for id := range c.checks {
+		if _, ok := active[id]; !ok {
+			delete(c.checks, id)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package processing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+// LotEvaluation explains one sensor-down evaluation of a lot: what each sensor
+// last reported and, when the lot is not done, why.
+type LotEvaluation struct {
+	LotNumber   string    `json:"lotNumber"`
+	MachineName string    `json:"machineName"`
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+	// Done reports whether every sensor stayed down, so a pass would complete
+	// the lot with Summary.
+	Done bool `json:"done"`
+	// Progress is "running", "winding_down" once some sensor reports down, or
+	// "done".
+	Progress string `json:"progress"`
+	// Reason says why the lot is not done; empty when it is.
+	Reason string `json:"reason,omitempty"`
+	// MissingSensors lists expected sensors with no readings in the lookback.
+	MissingSensors []string `json:"missingSensors,omitempty"`
+	// NotDown lists the sensors that failed the down check.
+	NotDown []string             `json:"notDownSensors,omitempty"`
+	Sensors []SensorEvaluation   `json:"sensors"`
+	Summary *metadata.LotSummary `json:"summary,omitempty"`
+
+	progress lotProgress
+	// states is each sensor's hysteresis state after this evaluation.
+	states map[string]sensorDownState
+}
+
+// SensorEvaluation is one sensor's recent window as the down check saw it. A
+// sensor goes down on a sample reporting status down at or below Threshold and
+// stays down until a sample rises above RecoverThreshold or stops reporting
+// down. It passes the check once it has been down for the required number of
+// consecutive samples, counted in DownSamples.
+type SensorEvaluation struct {
+	SensorName       string    `json:"sensorName"`
+	LatestStatus     string    `json:"latestStatus"`
+	LatestValue      float64   `json:"latestValue"`
+	LatestAt         time.Time `json:"latestAt"`
+	Threshold        float64   `json:"threshold"`
+	RecoverThreshold float64   `json:"recoverThreshold"`
+	Samples          int       `json:"samples"`
+	DownSamples      int       `json:"downSamples"`
+	Down             bool      `json:"down"`
+	// Counter marks a product counter, which takes no part in the down check.
+	Counter bool `json:"counter,omitempty"`
+}
+
+// EvaluateLot runs the sensor-down check for lot once, as a completion pass
+// would, without completing it or affecting the pass schedule or the sensors'
+// hysteresis state.
+func (s *CompletionService) EvaluateLot(ctx context.Context, lot metadata.Lot) (LotEvaluation, error) {
+	eval, err := s.evaluateLot(ctx, lot)
+	if eval.Sensors == nil {
+		eval.Sensors = []SensorEvaluation{}
+	}
+	return eval, err
+}
+
+// notDoneReason summarises the missing sensors and failed down checks of eval.
+func notDoneReason(eval LotEvaluation, samplesRequired int) string {
+	var reasons []string
+	if len(eval.MissingSensors) > 0 {
+		reasons = append(reasons, "no recent readings from "+strings.Join(eval.MissingSensors, ", "))
+	}
+	if len(eval.NotDown) > 0 {
+		reasons = append(reasons, fmt.Sprintf("not down for %d consecutive samples: %s", samplesRequired, strings.Join(eval.NotDown, ", ")))
+	}
+	return strings.Join(reasons, "; ")
+}
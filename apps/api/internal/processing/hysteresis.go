@@ -0,0 +1,82 @@
+package processing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+)
+
+// sensorDownState is a sensor's hysteresis state after the newest sample
+// applied to it: whether it is down, for how many consecutive samples, and the
+// time of that sample.
+type sensorDownState struct {
+	down  bool
+	count int
+	at    time.Time
+}
+
+// advance applies the samples of window (newest first) that are newer than
+// state. A sample below trigger takes the sensor down; a down sensor only
+// recovers on a sample above recovery or one not reporting status down. window
+// holds at most limit samples; when it is full and every sample is new, older
+// ones may have gone unseen, so the down count restarts from window.
+func (state sensorDownState) advance(window []influxdb.SensorReading, limit int, trigger, recovery float64) sensorDownState {
+	if len(window) >= limit && len(window) > 0 && window[len(window)-1].Time.After(state.at) {
+		state.count = 0
+	}
+	for i := len(window) - 1; i >= 0; i-- {
+		sample := window[i]
+		if !sample.Time.After(state.at) {
+			continue
+		}
+		down := isDownSample(sample, trigger) || (state.down && isDownSample(sample, recovery))
+		switch {
+		case !down:
+			state.count = 0
+		case state.down:
+			state.count++
+		default:
+			state.count = 1
+		}
+		state.down = down
+		state.at = sample.Time
+	}
+	return state
+}
+
+// downTracker keeps each active lot's per-sensor hysteresis state between
+// passes. The polling goroutine updates it; on-demand evaluations only read it.
+type downTracker struct {
+	mu   sync.Mutex
+	lots map[int64]map[string]sensorDownState
+}
+
+func newDownTracker() *downTracker {
+	return &downTracker{lots: make(map[int64]map[string]sensorDownState)}
+}
+
+// get returns the states recorded for lotID; callers must not modify them.
+func (t *downTracker) get(lotID int64) map[string]sensorDownState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lots[lotID]
+}
+
+// set replaces the states recorded for lotID.
+func (t *downTracker) set(lotID int64, states map[string]sensorDownState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lots[lotID] = states
+}
+
+// prune drops lots that are no longer active.
+func (t *downTracker) prune(active map[int64]struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range t.lots {
+		if _, ok := active[id]; !ok {
+			delete(t.lots, id)
+		}
+	}
+}
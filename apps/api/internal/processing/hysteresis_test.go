@@ -0,0 +1,114 @@
+package processing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+)
+
+// sample is one reading, at seconds after the test's base time.
+type sample struct {
+	at     int
+	status string
+	value  float64
+}
+
+func TestSensorDownStateAdvance(t *testing.T) {
+	const (
+		trigger  = 1.0
+		recovery = 5.0
+		limit    = 3
+	)
+	base := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+
+	tests := []struct {
+		name    string
+		state   sensorDownState
+		samples []sample // oldest first
+		want    sensorDownState
+	}{
+		{
+			name:    "trigger below threshold",
+			samples: []sample{{1, "down", 0.5}, {2, "DOWN", 1.0}},
+			want:    sensorDownState{down: true, count: 2, at: at(2)},
+		},
+		{
+			name:    "between thresholds does not trigger",
+			samples: []sample{{1, "down", 3}, {2, "down", 4}},
+			want:    sensorDownState{at: at(2)},
+		},
+		{
+			name:    "down status is required",
+			samples: []sample{{1, "running", 0.5}},
+			want:    sensorDownState{at: at(1)},
+		},
+		{
+			name:    "hold between thresholds",
+			state:   sensorDownState{down: true, count: 2, at: at(0)},
+			samples: []sample{{1, "down", 3}, {2, "down", recovery}},
+			want:    sensorDownState{down: true, count: 4, at: at(2)},
+		},
+		{
+			name:    "recover above threshold",
+			state:   sensorDownState{down: true, count: 2, at: at(0)},
+			samples: []sample{{1, "down", 3}, {2, "down", 5.1}},
+			want:    sensorDownState{count: 0, at: at(2)},
+		},
+		{
+			name:    "recover on status",
+			state:   sensorDownState{down: true, count: 2, at: at(0)},
+			samples: []sample{{1, "running", 0.5}},
+			want:    sensorDownState{at: at(1)},
+		},
+		{
+			name:    "trigger again after recovering",
+			samples: []sample{{1, "down", 0.5}, {2, "down", 6}, {3, "down", 3}, {4, "down", 0.5}},
+			want:    sensorDownState{down: true, count: 1, at: at(4)},
+		},
+		{
+			name:    "seen samples are skipped",
+			state:   sensorDownState{down: true, count: 2, at: at(2)},
+			samples: []sample{{1, "running", 180}, {2, "down", 0.5}},
+			want:    sensorDownState{down: true, count: 2, at: at(2)},
+		},
+		{
+			// Samples between at(0) and the window may have been missed, so
+			// the count restarts from the window.
+			name:    "full window of new samples resets the count",
+			state:   sensorDownState{down: true, count: 5, at: at(0)},
+			samples: []sample{{10, "down", 0.5}, {11, "down", 0.5}, {12, "down", 0.5}},
+			want:    sensorDownState{down: true, count: 3, at: at(12)},
+		},
+		{
+			name:    "full window overlapping the last pass keeps the count",
+			state:   sensorDownState{down: true, count: 5, at: at(10)},
+			samples: []sample{{10, "down", 0.5}, {11, "down", 0.5}, {12, "down", 0.5}},
+			want:    sensorDownState{down: true, count: 7, at: at(12)},
+		},
+		{
+			name:  "empty window",
+			state: sensorDownState{down: true, count: 5, at: at(10)},
+			want:  sensorDownState{down: true, count: 5, at: at(10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := make([]influxdb.SensorReading, len(tt.samples))
+			for i, s := range tt.samples {
+				window[len(window)-1-i] = influxdb.SensorReading{
+					Time:        at(s.at),
+					MachineName: "Oven-01",
+					SensorName:  "Temperature",
+					Status:      s.status,
+					Value:       s.value,
+				}
+			}
+			got := tt.state.advance(window, limit, trigger, recovery)
+			if got.down != tt.want.down || got.count != tt.want.count || !got.at.Equal(tt.want.at) {
+				t.Errorf("advance = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
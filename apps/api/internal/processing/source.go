@@ -0,0 +1,30 @@
+package processing
+
+import (
+	"context"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+// ReadingsSource is the sensor history the completion service and SummarizeLot
+// read. *influxdb.Client implements it; influxtest.Stub serves canned readings
+// in tests.
+type ReadingsSource interface {
+	RecentSensorReadingsByMachine(ctx context.Context, measurement, machineName string, lookback time.Duration, limit int) ([]influxdb.SensorReading, error)
+	SensorReadingsBetween(ctx context.Context, measurement, machineName string, start, stop time.Time, perSensor int) ([]influxdb.SensorReading, error)
+	MinMaxBySensor(ctx context.Context, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error)
+}
+
+var _ ReadingsSource = (*influxdb.Client)(nil)
+
+// LotStore is the lot storage the completion service polls and completes lots
+// in. *metadata.Repository implements it; metadatatest.Store keeps lots in
+// memory in tests.
+type LotStore interface {
+	ListActiveLots(ctx context.Context) ([]metadata.Lot, error)
+	FinalizeLot(ctx context.Context, lotID int64, finalize metadata.LotFinalizer) (metadata.FinalizeResult, *metadata.LotSummary, error)
+}
+
+var _ LotStore = (*metadata.Repository)(nil)
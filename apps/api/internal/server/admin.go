@@ -0,0 +1,36 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const adminTokenEnvKey = "ADMIN_TOKEN"
+
+// AdminTokenFromEnv returns the bearer token guarding admin endpoints. Admin
+// endpoints are disabled when ADMIN_TOKEN is unset.
+func AdminTokenFromEnv() string {
+	return strings.TrimSpace(os.Getenv(adminTokenEnvKey))
+}
+
+// requireAdmin rejects requests lacking "Authorization: Bearer <token>".
+func requireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			respondError(c, http.StatusForbidden, codeAdminDisabled, "admin endpoints are disabled")
+			c.Abort()
+			return
+		}
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			respondError(c, http.StatusUnauthorized, codeUnauthorized, "admin token required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/logging"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+const (
+	defaultBackfillWorkers = 4
+	maxBackfillWorkers     = 32
+)
+
+// backfillPreview describes the change a backfill would apply to a single lot.
+type backfillPreview struct {
+	LotNumber             string             `json:"lotNumber"`
+	CurrentOperationHour  *string            `json:"currentOperationHour"`
+	ProposedOperationHour string             `json:"proposedOperationHour"`
+	CurrentAverages       json.RawMessage    `json:"currentAverages"`
+	ProposedAverages      map[string]float64 `json:"proposedAverages"`
+	// ProposedRanges is only set for lots whose summary lacks per-sensor min/max.
+	ProposedRanges map[string]metadata.SensorRange `json:"proposedRanges,omitempty"`
+}
+
+// HandleLotBackfill computes operation hours and per-sensor averages for completed lots
+// missing them. With ?dryRun=true the proposed values are returned without being stored.
+// ?bucket= reads from a bucket named in INFLUX_BUCKETS instead of the default.
+// interrupted is set when the request ended before every candidate was processed.
+func HandleLotBackfill(c *gin.Context, deps Dependencies) {
+	logger := requestLogger(c)
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+
+	dryRun, err := queryBool(c, "dryRun", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	workers, err := queryInt(c, "workers", defaultBackfillWorkers, 1, maxBackfillWorkers)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	measurement := c.DefaultQuery("measurement", "sensor_data")
+
+	bucket := c.Query("bucket")
+	if _, err := deps.Influx.ResolveBucket(bucket); err != nil {
+		respondErrorDetails(c, http.StatusBadRequest, codeUnknownBucket, err.Error(), gin.H{"buckets": deps.Influx.BucketNames()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	candidates, err := deps.Metadata.ListCompletedLotsMissingData(ctx)
+	if err != nil {
+		logger.Error("list backfill candidates failed", "error", err)
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to list backfill candidates")
+		return
+	}
+
+	updated, previews := runBackfill(ctx, deps, bucket, measurement, candidates, workers, dryRun)
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		logger.Warn("lot backfill interrupted", "error", ctx.Err(), "updated", len(updated))
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dryRun": true, "lots": previews, "interrupted": interrupted})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated, "interrupted": interrupted})
+}
+
+// runBackfill processes candidates with a bounded pool of workers. The returned slices keep
+// the candidate order so the result matches a sequential run. Dispatch stops once ctx is done.
+func runBackfill(ctx context.Context, deps Dependencies, bucket, measurement string, candidates []metadata.BackfillCandidate, workers int, dryRun bool) ([]string, []backfillPreview) {
+	logger := logging.FromContext(ctx)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		previews = make([]*backfillPreview, len(candidates))
+		written  = make([]bool, len(candidates))
+		jobs     = make(chan int)
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			for idx := range jobs {
+				cand := candidates[idx]
+				preview, ok := computeBackfill(workerCtx, deps, bucket, measurement, cand)
+				if !ok {
+					continue
+				}
+				if dryRun {
+					mu.Lock()
+					previews[idx] = &preview
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				wrote, err := storeBackfill(workerCtx, deps, cand, preview)
+				written[idx] = wrote
+				mu.Unlock()
+				if err != nil {
+					logger.Error("update lot computed failed", "lot", cand.LotNumber, "error", err)
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for idx := range candidates {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- idx:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	updated := []string{}
+	collected := []backfillPreview{}
+	for idx, cand := range candidates {
+		if written[idx] {
+			updated = append(updated, cand.LotNumber)
+		}
+		if previews[idx] != nil {
+			collected = append(collected, *previews[idx])
+		}
+	}
+	return updated, collected
+}
+
+// storeBackfill writes the proposed values and reports whether any were stored.
+// Operation hour and averages are only rewritten when one of them was missing;
+// lots listed solely for missing min/max keep their stored values. A lot without
+// readings to take ranges from is only marked as checked, so later runs skip it.
+func storeBackfill(ctx context.Context, deps Dependencies, cand metadata.BackfillCandidate, preview backfillPreview) (bool, error) {
+	wrote := false
+	if cand.OperationHour == nil || cand.Averages == nil {
+		// marshal averages to JSON
+		avgJSON, _ := json.Marshal(preview.ProposedAverages)
+		avgStr := string(avgJSON)
+		opStr := preview.ProposedOperationHour
+		if err := deps.Metadata.UpdateLotComputedFields(ctx, cand.ID, &opStr, &avgStr); err != nil {
+			return false, err
+		}
+		wrote = true
+	}
+	switch {
+	case preview.ProposedRanges == nil:
+	case len(preview.ProposedRanges) == 0:
+		if err := deps.Metadata.MarkLotRangesChecked(ctx, cand.ID); err != nil {
+			return wrote, err
+		}
+	default:
+		if err := deps.Metadata.UpdateLotSensorRanges(ctx, cand.ID, preview.ProposedRanges); err != nil {
+			return wrote, err
+		}
+		wrote = true
+	}
+	return wrote, nil
+}
+
+// computeBackfill runs the Flux aggregation for a candidate and returns the proposed values.
+// The boolean result is false when the candidate should be skipped.
+func computeBackfill(ctx context.Context, deps Dependencies, bucket, measurement string, cand metadata.BackfillCandidate) (backfillPreview, bool) {
+	logger := logging.FromContext(ctx)
+	if !cand.CompletedAt.Valid {
+		return backfillPreview{}, false
+	}
+	start := cand.StartedAt.UTC()
+	end := cand.CompletedAt.Time.UTC()
+	if end.Before(start) {
+		// skip invalid ranges
+		return backfillPreview{}, false
+	}
+
+	// compute operation hours (one decimal place)
+	hours := end.Sub(start).Hours()
+	hours = math.Round(hours*10) / 10
+	opStr := fmt.Sprintf("%.1f", hours)
+
+	averages, err := deps.Influx.MeanBySensorFromBucket(ctx, bucket, measurement, cand.MachineName, start, end)
+	if err != nil {
+		if averages == nil {
+			logger.Error("influx query failed", "lot", cand.LotNumber, "error", err)
+			return backfillPreview{}, false
+		}
+		logger.Error("iterate influx result failed", "lot", cand.LotNumber, "error", err)
+	}
+
+	preview := backfillPreview{
+		LotNumber:             cand.LotNumber,
+		CurrentOperationHour:  cand.OperationHour,
+		ProposedOperationHour: opStr,
+		CurrentAverages:       cand.Averages,
+		ProposedAverages:      averages,
+	}
+	if cand.MissingRanges {
+		mins, maxs, err := deps.Influx.MinMaxBySensorFromBucket(ctx, bucket, measurement, cand.MachineName, start, end)
+		if err != nil {
+			logger.Error("influx min/max query failed", "lot", cand.LotNumber, "error", err)
+		} else {
+			preview.ProposedRanges = metadata.BuildSensorRanges(mins, maxs)
+		}
+	}
+	return preview, true
+}
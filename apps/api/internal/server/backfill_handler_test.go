@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+)
+
+// backfillFixture seeds completed lots missing their computed fields, every
+// other one with a stored operation hour, and the readings they span.
+func backfillFixture(t *testing.T) (Dependencies, *metadatatest.Store, []metadata.BackfillCandidate) {
+	t.Helper()
+	store := metadatatest.New()
+	stub := influxtest.New()
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 24; i++ {
+		machine := fmt.Sprintf("Machine-%02d", i%3)
+		start := base.Add(time.Duration(i) * 2 * time.Hour)
+		end := start.Add(time.Duration(30+i) * time.Minute)
+		lot := metadata.Lot{
+			LotNumber:   fmt.Sprintf("LOT-%03d", i),
+			MachineName: machine,
+			Status:      metadata.LotStatusCompleted,
+			StartedAt:   start,
+			CompletedAt: sql.NullTime{Time: end, Valid: true},
+			UpdatedAt:   end,
+		}
+		if i%2 == 0 {
+			hour := "0.5"
+			lot.OperationHour = &hour
+		}
+		store.PutLot(lot)
+		for m := 0; m < 30; m += 5 {
+			at := start.Add(time.Duration(m) * time.Minute)
+			stub.Add(
+				influxdb.SensorReading{Time: at, MachineName: machine, SensorName: "Temperature", Status: "running", Value: float64(100 + i + m)},
+				influxdb.SensorReading{Time: at, MachineName: machine, SensorName: "Pressure", Status: "running", Value: float64(2*i) - float64(m)/10},
+			)
+		}
+	}
+	candidates, err := store.ListCompletedLotsMissingData(context.Background())
+	if err != nil {
+		t.Fatalf("ListCompletedLotsMissingData: %v", err)
+	}
+	if len(candidates) != 24 {
+		t.Fatalf("got %d candidates, want 24", len(candidates))
+	}
+	return Dependencies{Metadata: store, Influx: stub}, store, candidates
+}
+
+func TestRunBackfillParallelMatchesSequential(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run", func(t *testing.T) {
+		deps, _, candidates := backfillFixture(t)
+		seqUpdated, seqPreviews := runBackfill(ctx, deps, "", "sensor_data", candidates, 1, true)
+		parUpdated, parPreviews := runBackfill(ctx, deps, "", "sensor_data", candidates, 8, true)
+		if len(seqUpdated) != 0 || len(parUpdated) != 0 {
+			t.Fatalf("dry run updated lots: sequential %v, parallel %v", seqUpdated, parUpdated)
+		}
+		if len(seqPreviews) != len(candidates) {
+			t.Fatalf("got %d previews, want %d", len(seqPreviews), len(candidates))
+		}
+		if !reflect.DeepEqual(seqPreviews, parPreviews) {
+			t.Fatalf("parallel previews differ from sequential:\nsequential %+v\nparallel   %+v", seqPreviews, parPreviews)
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		seqDeps, seqStore, seqCandidates := backfillFixture(t)
+		parDeps, parStore, parCandidates := backfillFixture(t)
+		seqUpdated, _ := runBackfill(ctx, seqDeps, "", "sensor_data", seqCandidates, 1, false)
+		parUpdated, _ := runBackfill(ctx, parDeps, "", "sensor_data", parCandidates, 8, false)
+		if !reflect.DeepEqual(seqUpdated, parUpdated) {
+			t.Fatalf("updated lots differ:\nsequential %v\nparallel   %v", seqUpdated, parUpdated)
+		}
+		if len(seqUpdated) != len(seqCandidates) {
+			t.Fatalf("got %d updated lots, want %d", len(seqUpdated), len(seqCandidates))
+		}
+		for _, lotNumber := range seqUpdated {
+			seqLot, err := seqStore.GetLotByNumber(ctx, lotNumber)
+			if err != nil {
+				t.Fatalf("GetLotByNumber(%s): %v", lotNumber, err)
+			}
+			parLot, err := parStore.GetLotByNumber(ctx, lotNumber)
+			if err != nil {
+				t.Fatalf("GetLotByNumber(%s): %v", lotNumber, err)
+			}
+			if !reflect.DeepEqual(seqLot, parLot) {
+				t.Errorf("lot %s differs:\nsequential %+v\nparallel   %+v", lotNumber, seqLot, parLot)
+			}
+			if seqLot.OperationHour == nil || len(seqLot.Averages) == 0 {
+				t.Errorf("lot %s was not backfilled: %+v", lotNumber, seqLot)
+			}
+		}
+		remaining, err := parStore.ListCompletedLotsMissingData(ctx)
+		if err != nil {
+			t.Fatalf("ListCompletedLotsMissingData: %v", err)
+		}
+		if len(remaining) != 0 {
+			t.Errorf("%d lots still missing data after backfill", len(remaining))
+		}
+	})
+}
+
+func TestLotBackfillReportsInterruption(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps, _, candidates := backfillFixture(t)
+	router := NewRouter(deps)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/api/lots/backfill", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancelled backfill status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body struct {
+		Updated     []string `json:"updated"`
+		Interrupted bool     `json:"interrupted"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+	if !body.Interrupted || len(body.Updated) == len(candidates) {
+		t.Errorf("cancelled backfill = %+v, want interrupted before every lot", body)
+	}
+
+	status, full := serveJSON(t, router, http.MethodPost, "/api/lots/backfill", "")
+	if status != http.StatusOK || full["interrupted"] != false {
+		t.Errorf("backfill = %d %v, want 200 and not interrupted", status, full)
+	}
+	if updated, _ := full["updated"].([]any); len(updated)+len(body.Updated) != len(candidates) {
+		t.Errorf("backfills updated %d and %d lots, want %d in total", len(body.Updated), len(updated), len(candidates))
+	}
+}
+
+func TestRunBackfillMarksLotsWithoutReadings(t *testing.T) {
+	ctx := context.Background()
+	store := metadatatest.New()
+	start := time.Date(2023, 1, 10, 8, 0, 0, 0, time.UTC)
+	hour, averages := "1.0", json.RawMessage(`{"Temperature":180}`)
+	store.PutLot(metadata.Lot{
+		LotNumber:     "LOT-OLD",
+		MachineName:   "Machine-00",
+		Status:        metadata.LotStatusCompleted,
+		StartedAt:     start,
+		CompletedAt:   sql.NullTime{Time: start.Add(time.Hour), Valid: true},
+		OperationHour: &hour,
+		Averages:      averages,
+	})
+	deps := Dependencies{Metadata: store, Influx: influxtest.New()}
+
+	candidates, err := store.ListCompletedLotsMissingData(ctx)
+	if err != nil {
+		t.Fatalf("ListCompletedLotsMissingData: %v", err)
+	}
+	if len(candidates) != 1 || !candidates[0].MissingRanges {
+		t.Fatalf("candidates = %+v, want LOT-OLD missing ranges", candidates)
+	}
+	if updated, _ := runBackfill(ctx, deps, "", "sensor_data", candidates, 1, false); len(updated) != 0 {
+		t.Errorf("updated = %v, want no lots without readings", updated)
+	}
+
+	lot, err := store.GetLotByNumber(ctx, "LOT-OLD")
+	if err != nil {
+		t.Fatalf("GetLotByNumber: %v", err)
+	}
+	summary, err := lot.Summary()
+	if err != nil || summary == nil || summary.RangesCheckedAt == nil || summary.Sensors == nil || len(summary.Sensors) != 0 {
+		t.Fatalf("summary = %+v (err %v), want an empty sensor list marked as checked", summary, err)
+	}
+	remaining, err := store.ListCompletedLotsMissingData(ctx)
+	if err != nil {
+		t.Fatalf("ListCompletedLotsMissingData: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("remaining candidates = %+v, want none after the check", remaining)
+	}
+}
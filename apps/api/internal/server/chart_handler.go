@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+const (
+	defaultChartPoints = 200
+	// maxChartPoints bounds the points per series; larger requests are clamped.
+	maxChartPoints = 1000
+	// defaultChartRange is how far back the chart reaches when start is omitted.
+	defaultChartRange = 24 * time.Hour
+)
+
+// HandleChart returns readings averaged into evenly spaced points over
+// [start, stop), at most points per sensor, for plotting long ranges without
+// transferring every raw reading. stop defaults to now and start to 24 hours
+// before stop; machine and sensor narrow the series.
+func HandleChart(c *gin.Context, deps Dependencies) {
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+
+	start, stop, err := queryTimeRange(c, defaultChartRange)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	points, err := queryInt(c, "points", defaultChartPoints, 1, maxChartPoints)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	precision, err := queryPrecision(c, displayPrecision)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	measurement := c.DefaultQuery("measurement", simulation.MeasurementName())
+	filters := map[string]string{}
+	if machine := canonicalMachine(c.Request.Context(), deps, c.Query("machine")); machine != "" {
+		filters["machine_name"] = machine
+	}
+	if sensor := strings.TrimSpace(c.Query("sensor")); sensor != "" {
+		filters["sensor_name"] = sensor
+	}
+
+	readings, err := deps.Influx.SampledReadings(c.Request.Context(), measurement, start, stop, filters, points)
+	if err != nil {
+		requestLogger(c).Error("sampled sensor readings failed", "error", err)
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to query sampled sensor readings")
+		return
+	}
+
+	units := sensorUnits(deps)
+	payloads := make([]readingPayload, 0, len(readings))
+	for _, reading := range readings {
+		payloads = append(payloads, newReadingPayload(reading, units, precision))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"measurement": measurement,
+		"start":       start.UTC().Format(time.RFC3339),
+		"stop":        stop.UTC().Format(time.RFC3339),
+		"points":      points,
+		"every":       influx.SampleWindow(start, stop, points).String(),
+		"readings":    payloads,
+	})
+}
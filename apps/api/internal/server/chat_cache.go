@@ -0,0 +1,139 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	chatCacheSizeEnvKey  = "CHAT_CACHE_SIZE"
+	chatCacheTTLEnvKey   = "CHAT_CACHE_TTL"
+	defaultChatCacheSize = 128
+	defaultChatCacheTTL  = 10 * time.Minute
+)
+
+// ChatCache is an in-memory LRU of generated Flux queries and answers keyed by
+// normalized question and schema prompt. It is safe for concurrent use.
+type ChatCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+type chatCacheEntry struct {
+	key       string
+	fluxQuery string
+	answer    string
+	expiresAt time.Time
+}
+
+// ChatCacheStats reports cache effectiveness counters.
+type ChatCacheStats struct {
+	Hit    bool  `json:"hit"`
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// NewChatCache builds a cache holding up to capacity entries for ttl each.
+func NewChatCache(capacity int, ttl time.Duration) *ChatCache {
+	if capacity <= 0 {
+		capacity = defaultChatCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultChatCacheTTL
+	}
+	return &ChatCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// ChatCacheFromEnv reads CHAT_CACHE_SIZE (default 128, 0 disables caching) and
+// CHAT_CACHE_TTL (default 10m). A nil cache is returned when caching is disabled.
+func ChatCacheFromEnv() *ChatCache {
+	size := defaultChatCacheSize
+	if raw := strings.TrimSpace(os.Getenv(chatCacheSizeEnvKey)); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			slog.Warn("invalid chat cache size, using default", "key", chatCacheSizeEnvKey, "value", raw, "default", defaultChatCacheSize)
+		} else {
+			size = parsed
+		}
+	}
+	if size == 0 {
+		return nil
+	}
+	ttl := defaultChatCacheTTL
+	if raw := strings.TrimSpace(os.Getenv(chatCacheTTLEnvKey)); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			slog.Warn("invalid chat cache ttl, using default", "key", chatCacheTTLEnvKey, "value", raw, "default", defaultChatCacheTTL.String())
+		} else {
+			ttl = parsed
+		}
+	}
+	return NewChatCache(size, ttl)
+}
+
+// chatCacheKey hashes the normalized question together with the answer language and
+// schema prompt so schema changes invalidate earlier answers.
+func chatCacheKey(question, language, schemaPrompt string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(question)), " ")
+	sum := sha256.Sum256([]byte(schemaPrompt + "\x00" + language + "\x00" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ChatCache) get(key string) (chatCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return chatCacheEntry{}, false
+	}
+	entry := elem.Value.(chatCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		return chatCacheEntry{}, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry, true
+}
+
+func (c *ChatCache) put(key, fluxQuery, answer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := chatCacheEntry{key: key, fluxQuery: fluxQuery, answer: answer, expiresAt: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(chatCacheEntry).key)
+	}
+}
+
+func (c *ChatCache) stats(hit bool) ChatCacheStats {
+	return ChatCacheStats{Hit: hit, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	chatFormatCSV        = "csv"
+	chatFormatStructured = "structured"
+
+	// maxStructuredRecords bounds the rows decoded for a structured response.
+	maxStructuredRecords = 10000
+)
+
+// leadingRecordColumns are rendered first, in this order, when structured rows
+// are turned back into a table for the analysis prompt.
+var leadingRecordColumns = []string{"_time", "machine_name", "sensor_name", "_value"}
+
+// parseChatFormat reads ?format=, defaulting to raw CSV.
+func parseChatFormat(c *gin.Context) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format {
+	case "", chatFormatCSV:
+		return chatFormatCSV, nil
+	case chatFormatStructured:
+		return chatFormatStructured, nil
+	default:
+		return "", fmt.Errorf("format must be %q or %q", chatFormatCSV, chatFormatStructured)
+	}
+}
+
+// runChatFlux executes fluxQuery and returns the response data in the requested
+// format along with a CSV rendering for the analysis prompt.
+func runChatFlux(ctx context.Context, deps Dependencies, fluxQuery, format string) (data any, promptCSV string, err error) {
+	if format != chatFormatStructured {
+		raw, err := deps.Influx.QueryRaw(ctx, fluxQuery)
+		if err != nil {
+			return nil, "", err
+		}
+		return raw, raw, nil
+	}
+	records, err := deps.Influx.QueryRecords(ctx, fluxQuery, maxStructuredRecords)
+	if err != nil {
+		return nil, "", err
+	}
+	return records, renderRecordsCSV(records), nil
+}
+
+// renderRecordsCSV renders records as a plain CSV table, dropping the window
+// and schema columns that repeat on every row.
+func renderRecordsCSV(records []map[string]any) string {
+	if len(records) == 0 {
+		return ""
+	}
+	omitted := map[string]bool{"_start": true, "_stop": true, "_measurement": true, "_field": true, "table": true}
+	present := map[string]bool{}
+	for _, record := range records {
+		for key := range record {
+			if !omitted[key] {
+				present[key] = true
+			}
+		}
+	}
+	columns := make([]string, 0, len(present))
+	for _, key := range leadingRecordColumns {
+		if present[key] {
+			columns = append(columns, key)
+			delete(present, key)
+		}
+	}
+	rest := make([]string, 0, len(present))
+	for key := range present {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	columns = append(columns, rest...)
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	_ = w.Write(columns)
+	row := make([]string, len(columns))
+	for _, record := range records {
+		for i, column := range columns {
+			row[i] = formatRecordValue(record[column])
+		}
+		_ = w.Write(row)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+func formatRecordValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
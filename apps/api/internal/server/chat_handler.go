@@ -2,99 +2,239 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/llm"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
 )
 
-const (
-	fluxSystemPromptHeader = "Anda adalah asisten AI yang ahli dalam menyusun query Flux untuk InfluxDB. Gunakan informasi skema berikut untuk menerjemahkan pertanyaan pengguna ke query Flux yang valid. Kembalikan HANYA kode Flux tanpa penjelasan atau pembungkus markdown."
-	analysisSystemPrompt   = "Anda adalah analis data manufaktur. Gunakan data CSV yang diberikan untuk menjawab pertanyaan secara ringkas dan akurat. Jika data kosong, jelaskan bahwa data tidak tersedia."
-)
+// fluxRetryInstruction asks the model to repair a query InfluxDB failed to
+// run; it is sent after the question with the query and the error.
+const fluxRetryInstruction = "Query Flux berikut gagal dijalankan oleh InfluxDB:\n\n%s\n\nPesan error dari InfluxDB: %s\n\nPerbaiki query tersebut agar tetap menjawab pertanyaan di atas. Kembalikan HANYA kode Flux yang sudah diperbaiki."
+
+const fluxSystemPromptHeader = "Anda adalah asisten AI yang ahli dalam menyusun query Flux untuk InfluxDB. Gunakan informasi skema berikut untuk menerjemahkan pertanyaan pengguna ke query Flux yang valid. Kembalikan HANYA kode Flux tanpa penjelasan atau pembungkus markdown."
 
 type chatQueryRequest struct {
 	Question string `json:"question"`
+	// Language selects the answer language ("id" or "en"); defaults to "id".
+	Language string `json:"language"`
 }
 
 // HandleChatQuery orchestrates the text-to-Flux-to-answer workflow described in the LLM integration design.
 func HandleChatQuery(c *gin.Context, deps Dependencies) {
+	logger := requestLogger(c)
 	if deps.LLM == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LLM client not configured"})
+		respondError(c, http.StatusServiceUnavailable, codeLLMUnavail, "LLM client not configured")
 		return
 	}
 	if deps.Influx == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "InfluxDB client not configured"})
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "InfluxDB client not configured")
 		return
 	}
 
 	var req chatQueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
 		return
 	}
 
 	question := strings.TrimSpace(req.Question)
 	if question == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "question is required"})
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "question is required")
+		return
+	}
+
+	format, err := parseChatFormat(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 		return
 	}
 
+	language, lang, ok := resolveChatLanguage(req.Language)
+	if !ok {
+		logger.Warn("unsupported chat language, using default", "language", req.Language, "default", defaultChatLanguage)
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 45*time.Second)
 	defer cancel()
 
 	cfg := deps.Influx.Config()
-	fluxSystemPrompt := buildFluxSystemPrompt(cfg.Bucket, simulation.MeasurementName())
+	fluxSystemPrompt := buildFluxSystemPrompt(cfg.Bucket, simulation.MeasurementName(), question, deps.ChatPromptMaxSensors)
 
-	fluxQueryRaw, err := deps.LLM.GenerateText(ctx, fluxSystemPrompt, question)
-	if err != nil {
-		log.Printf("llm flux generation failed: %v", err)
-		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to generate Flux query"})
-		return
+	useCache := deps.ChatCache != nil
+	if raw := c.Query("nocache"); raw != "" {
+		nocache, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "nocache must be a boolean")
+			return
+		}
+		useCache = useCache && !nocache
 	}
 
-	fluxQuery := normalizeFluxQuery(fluxQueryRaw)
-	if fluxQuery == "" {
-		log.Printf("llm returned empty flux query; raw=%q", fluxQueryRaw)
-		c.JSON(http.StatusBadGateway, gin.H{"error": "LLM produced an empty Flux query"})
+	cacheKey := chatCacheKey(question, language, fluxSystemPrompt)
+	if useCache {
+		if entry, ok := deps.ChatCache.get(cacheKey); ok {
+			// Re-run the cached query so the returned data is fresh.
+			data, _, err := runChatFlux(ctx, deps, entry.fluxQuery, format)
+			if err == nil {
+				c.JSON(http.StatusOK, gin.H{
+					"answer":    entry.answer,
+					"fluxQuery": entry.fluxQuery,
+					"data":      data,
+					"format":    format,
+					"language":  language,
+					"cache":     deps.ChatCache.stats(true),
+				})
+				return
+			}
+			logger.Warn("cached flux query execution failed, regenerating", "error", err, "query", entry.fluxQuery)
+		}
+	}
+
+	fluxQuery, usage, ok := generateChatFlux(ctx, c, deps, fluxSystemPrompt, nil, question)
+	if !ok {
 		return
 	}
 
-	rawResult, err := deps.Influx.QueryAPI().QueryRaw(ctx, fluxQuery, nil)
+	data, promptCSV, err := runChatFlux(ctx, deps, fluxQuery, format)
+	// One corrective round trip: the model sees InfluxDB's error and rewrites
+	// its query. A single retry keeps the request within its timeout.
+	attempts := []string{fluxQuery}
+	if err != nil && influx.IsQueryError(err) && ctx.Err() == nil {
+		logger.Warn("flux query execution failed, asking llm to fix it", "error", err, "query", fluxQuery)
+		retryParts := []string{question, fmt.Sprintf(fluxRetryInstruction, fluxQuery, err.Error())}
+		retried, retryUsage, ok := generateChatFlux(ctx, c, deps, fluxSystemPrompt, attempts, retryParts...)
+		usage = usage.Add(retryUsage)
+		if !ok {
+			return
+		}
+		fluxQuery = retried
+		attempts = append(attempts, fluxQuery)
+		data, promptCSV, err = runChatFlux(ctx, deps, fluxQuery, format)
+	}
 	if err != nil {
-		log.Printf("flux query execution failed: %v; query=%s", err, fluxQuery)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "flux query execution failed", "fluxQuery": fluxQuery})
+		logger.Error("flux query execution failed", "error", err, "query", fluxQuery, "attempts", len(attempts))
+		respondErrorDetails(c, http.StatusBadRequest, codeFluxQueryFailed, "flux query execution failed",
+			gin.H{"fluxQuery": fluxQuery, "fluxQueries": attempts, "reason": err.Error()})
 		return
 	}
 
-	analysisPrompt := buildAnalysisPrompt(question, rawResult)
+	promptData := summarizeCSVForPrompt(promptCSV, deps.ChatPromptMaxRows)
+	if promptData.truncated() {
+		logger.Info("truncated flux result for analysis prompt", "rows", promptData.TotalRows, "kept", promptData.KeptRows)
+	}
+	analysisPrompt := buildAnalysisPrompt(lang, question, promptData.Text)
 
-	answer, err := deps.LLM.GenerateText(ctx, analysisSystemPrompt, analysisPrompt)
+	answer, analysisUsage, err := deps.LLM.GenerateText(ctx, lang.analysisSystemPrompt, analysisPrompt)
+	usage = usage.Add(analysisUsage)
 	if err != nil {
-		log.Printf("llm analysis failed: %v", err)
-		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to interpret query result", "fluxQuery": fluxQuery, "data": rawResult})
+		logger.Error("llm analysis failed", "error", err)
+		writeLLMError(c, err, "failed to interpret query result", gin.H{"fluxQuery": fluxQuery, "data": data})
 		return
 	}
+	answer = strings.TrimSpace(answer)
+	logger.Info("chat llm usage", "promptTokens", usage.PromptTokens, "candidatesTokens", usage.CandidatesTokens, "totalTokens", usage.TotalTokens)
+	if promptData.truncated() {
+		answer += "\n\n" + fmt.Sprintf(lang.truncatedNotice, promptData.KeptRows, promptData.TotalRows)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"answer":    strings.TrimSpace(answer),
+	response := gin.H{
+		"answer":    answer,
 		"fluxQuery": fluxQuery,
-		"data":      rawResult,
-	})
+		"data":      data,
+		"format":    format,
+		"language":  language,
+		"usage":     usage,
+	}
+	if deps.ChatCache != nil {
+		deps.ChatCache.put(cacheKey, fluxQuery, answer)
+		response["cache"] = deps.ChatCache.stats(false)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-func buildFluxSystemPrompt(bucket, measurement string) string {
+// generateChatFlux asks the model for a Flux query and checks it is usable,
+// answering the request itself and reporting false when it is not. Queries
+// already attempted are included in error details as fluxQueries.
+func generateChatFlux(ctx context.Context, c *gin.Context, deps Dependencies, systemPrompt string, attempts []string, userParts ...string) (string, llm.Usage, bool) {
+	logger := requestLogger(c)
+	var details gin.H
+	if len(attempts) > 0 {
+		details = gin.H{"fluxQueries": attempts}
+	}
+
+	fluxQueryRaw, usage, err := deps.LLM.GenerateText(ctx, systemPrompt, userParts...)
+	if err != nil {
+		logger.Error("llm flux generation failed", "error", err)
+		writeLLMError(c, err, "failed to generate Flux query", details)
+		return "", usage, false
+	}
+
+	fluxQuery := normalizeFluxQuery(fluxQueryRaw)
+	if fluxQuery == "" {
+		logger.Warn("llm returned empty flux query", "raw", fluxQueryRaw)
+		respondErrorDetails(c, http.StatusBadGateway, codeLLMInvalidQuery, "LLM produced an empty Flux query", details)
+		return "", usage, false
+	}
+	if err := validateFluxQuery(fluxQuery, time.Now()); err != nil {
+		logger.Warn("llm flux query rejected", "error", err, "query", fluxQuery)
+		if details == nil {
+			details = gin.H{}
+		}
+		details["reason"] = err.Error()
+		details["fluxQuery"] = fluxQuery
+		respondErrorDetails(c, http.StatusBadGateway, codeLLMInvalidQuery, "LLM produced a disallowed Flux query", details)
+		return "", usage, false
+	}
+	return fluxQuery, usage, true
+}
+
+// writeLLMError answers 422 when the model blocked the request, explaining why, 503
+// while the circuit breaker is open, and 502 with message otherwise. details
+// are included in every case.
+func writeLLMError(c *gin.Context, err error, message string, details gin.H) {
+	var blocked *llm.BlockedError
+	if errors.As(err, &blocked) {
+		if details == nil {
+			details = gin.H{}
+		}
+		details["reason"] = blocked.Reason
+		if len(blocked.Categories) > 0 {
+			details["categories"] = blocked.Categories
+		}
+		// Logged so operators can spot false positives and relax LLM_SAFETY.
+		requestLogger(c).Warn("llm request blocked", "source", blocked.Source, "reason", blocked.Reason, "categories", blocked.Categories)
+		respondErrorDetails(c, http.StatusUnprocessableEntity, codeLLMBlocked,
+			fmt.Sprintf("the AI model declined to answer (%s %s); try rephrasing the question", blocked.Source, blocked.Reason), details)
+		return
+	}
+	if errors.Is(err, llm.ErrLLMUnavailable) {
+		respondErrorDetails(c, http.StatusServiceUnavailable, codeLLMUnavail, "the AI model is temporarily unavailable; try again shortly", details)
+		return
+	}
+	if errors.Is(err, llm.ErrLLMEmpty) {
+		message = "the AI model returned an empty response"
+	}
+	respondErrorDetails(c, http.StatusBadGateway, codeLLMError, message, details)
+}
+
+// buildFluxSystemPrompt describes the schema and up to maxSensors of the
+// available sensors, chosen for question as describeAvailableSensors does.
+func buildFluxSystemPrompt(bucket, measurement, question string, maxSensors int) string {
 	var sb strings.Builder
 	sb.WriteString(fluxSystemPromptHeader)
 	sb.WriteString("\n\n")
-	sb.WriteString(fmt.Sprintf("Skema:\n- Bucket: %s\n- Measurement: %s\n- Field numerik: \"value\"\n- Tag: \"machine_name\", \"sensor_name\"\n", bucket, measurement))
-	ss := describeAvailableSensors()
+	sb.WriteString(fmt.Sprintf("Skema:\n- Bucket: %s\n- Measurement: %s (kecuali sensor yang mencantumkan measurement sendiri)\n- Field numerik: \"value\"\n- Tag: \"machine_name\", \"sensor_name\"\n", bucket, measurement))
+	ss := describeAvailableSensors(question, maxSensors)
 	if ss != "" {
 		sb.WriteString("\nSensor yang tersedia:\n")
 		sb.WriteString(ss)
@@ -104,13 +244,28 @@ func buildFluxSystemPrompt(bucket, measurement string) string {
 	return sb.String()
 }
 
-func describeAvailableSensors() string {
+// promptSensor is one machine/sensor pair listed in the Flux system prompt.
+type promptSensor struct {
+	machine, sensor string
+	entry           string
+	// score ranks how directly the question names the pair; rank is the
+	// pair's position among its machine's sensors.
+	score, rank int
+}
+
+// describeAvailableSensors lists the distinct machine/sensor pairs, one per
+// line. With a positive maxSensors and more pairs than that, it keeps the pairs
+// whose sensor the question names, then those whose machine it names, and fills
+// the rest a sensor per machine in turn, so every machine stays represented
+// before any gets a second sensor. A closing note tells the model how many were
+// left out.
+func describeAvailableSensors(question string, maxSensors int) string {
 	sensors := simulation.DefaultSensors()
 	if len(sensors) == 0 {
 		return ""
 	}
 	seen := make(map[string]struct{}, len(sensors))
-	entries := make([]string, 0, len(sensors))
+	pairs := make([]promptSensor, 0, len(sensors))
 	for _, sensor := range sensors {
 		if sensor == nil {
 			continue
@@ -120,15 +275,72 @@ func describeAvailableSensors() string {
 			continue
 		}
 		seen[key] = struct{}{}
-		entries = append(entries, fmt.Sprintf("- machine_name=\"%s\", sensor_name=\"%s\"", sensor.MachineName, sensor.SensorName))
+		entry := fmt.Sprintf("- machine_name=\"%s\", sensor_name=\"%s\"", sensor.MachineName, sensor.SensorName)
+		if sensor.Unit != "" {
+			entry += fmt.Sprintf(", satuan=%s", sensor.Unit)
+		}
+		if sensor.Measurement != "" && sensor.Measurement != simulation.MeasurementName() {
+			entry += fmt.Sprintf(", measurement=%s", sensor.Measurement)
+		}
+		pairs = append(pairs, promptSensor{machine: sensor.MachineName, sensor: sensor.SensorName, entry: entry})
 	}
-	if len(entries) == 0 {
+	if len(pairs) == 0 {
 		return ""
 	}
+
+	omitted := 0
+	if maxSensors > 0 && len(pairs) > maxSensors {
+		rankPromptSensors(pairs, question)
+		omitted = len(pairs) - maxSensors
+		pairs = pairs[:maxSensors]
+	}
+	entries := make([]string, len(pairs))
+	for i, pair := range pairs {
+		entries[i] = pair.entry
+	}
 	sort.Strings(entries)
+	if omitted > 0 {
+		entries = append(entries, fmt.Sprintf("(%d sensor lain juga tersedia tetapi tidak dicantumkan; gunakan nama machine_name dan sensor_name persis seperti yang disebut pengguna.)", omitted))
+	}
 	return strings.Join(entries, "\n")
 }
 
+// rankPromptSensors orders pairs by how directly question names them, then
+// round-robin across machines, then by name.
+func rankPromptSensors(pairs []promptSensor, question string) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].machine != pairs[j].machine {
+			return pairs[i].machine < pairs[j].machine
+		}
+		return pairs[i].sensor < pairs[j].sensor
+	})
+	question = promptSearchText(question)
+	perMachine := map[string]int{}
+	for i := range pairs {
+		pair := &pairs[i]
+		pair.rank = perMachine[pair.machine]
+		perMachine[pair.machine]++
+		if strings.Contains(question, promptSearchText(pair.sensor)) {
+			pair.score += 2
+		}
+		if strings.Contains(question, promptSearchText(pair.machine)) {
+			pair.score++
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].score != pairs[j].score {
+			return pairs[i].score > pairs[j].score
+		}
+		return pairs[i].rank < pairs[j].rank
+	})
+}
+
+// promptSearchText lowercases s and turns underscores and hyphens into spaces,
+// so "suhu_oven" in a sensor name matches "suhu oven" in a question.
+func promptSearchText(s string) string {
+	return strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(s))
+}
+
 func normalizeFluxQuery(raw string) string {
 	trimmed := strings.TrimSpace(raw)
 	if !strings.HasPrefix(trimmed, "```") {
@@ -142,10 +354,10 @@ func normalizeFluxQuery(raw string) string {
 	return strings.TrimSpace(trimmed)
 }
 
-func buildAnalysisPrompt(question, rawData string) string {
+func buildAnalysisPrompt(lang chatLanguage, question, rawData string) string {
 	cleanData := strings.TrimSpace(rawData)
 	if cleanData == "" {
-		cleanData = "(data kosong)"
+		cleanData = lang.emptyData
 	}
-	return fmt.Sprintf("Data hasil query (format CSV):\n%s\n\nPertanyaan pengguna: %s\nBerikan jawaban yang jelas dan ringkas berdasarkan data di atas.", cleanData, question)
+	return fmt.Sprintf(lang.analysisTemplate, cleanData, question)
 }
@@ -0,0 +1,43 @@
+package server
+
+import "strings"
+
+// defaultChatLanguage is used when a request omits or names an unsupported language.
+const defaultChatLanguage = "id"
+
+// chatLanguage holds the analysis prompts for one answer language. Flux generation
+// is language-neutral and shares a single prompt.
+type chatLanguage struct {
+	analysisSystemPrompt string
+	analysisTemplate     string // data, question
+	emptyData            string
+	truncatedNotice      string // kept rows, total rows
+}
+
+var chatLanguages = map[string]chatLanguage{
+	"id": {
+		analysisSystemPrompt: "Anda adalah analis data manufaktur. Gunakan data CSV yang diberikan untuk menjawab pertanyaan secara ringkas dan akurat. Jika data kosong, jelaskan bahwa data tidak tersedia. Jawab dalam Bahasa Indonesia.",
+		analysisTemplate:     "Data hasil query (format CSV):\n%s\n\nPertanyaan pengguna: %s\nBerikan jawaban yang jelas dan ringkas berdasarkan data di atas.",
+		emptyData:            "(data kosong)",
+		truncatedNotice:      "Catatan: analisis hanya menggunakan %d dari %d baris data hasil query beserta ringkasan agregatnya.",
+	},
+	"en": {
+		analysisSystemPrompt: "You are a manufacturing data analyst. Use the provided CSV data to answer the question concisely and accurately. If the data is empty, explain that no data is available. Answer in English.",
+		analysisTemplate:     "Query result data (CSV format):\n%s\n\nUser question: %s\nGive a clear and concise answer based on the data above.",
+		emptyData:            "(no data)",
+		truncatedNotice:      "Note: the analysis used only %d of %d query result rows plus their aggregate summary.",
+	},
+}
+
+// resolveChatLanguage normalizes code and returns its prompts. ok is false when a
+// non-empty code is unsupported and the default language was substituted.
+func resolveChatLanguage(code string) (string, chatLanguage, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(code))
+	if normalized == "" {
+		return defaultChatLanguage, chatLanguages[defaultChatLanguage], true
+	}
+	if lang, found := chatLanguages[normalized]; found {
+		return normalized, lang, true
+	}
+	return defaultChatLanguage, chatLanguages[defaultChatLanguage], false
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	chatPromptMaxRowsEnvKey     = "CHAT_PROMPT_MAX_ROWS"
+	defaultChatPromptMaxRows    = 200
+	chatPromptMaxSensorsEnvKey  = "CHAT_PROMPT_MAX_SENSORS"
+	defaultChatPromptMaxSensors = 15
+)
+
+// ChatPromptMaxRowsFromEnv reads CHAT_PROMPT_MAX_ROWS, the number of CSV data rows
+// passed to the analysis prompt (default 200). Zero disables truncation.
+func ChatPromptMaxRowsFromEnv() int {
+	raw := strings.TrimSpace(os.Getenv(chatPromptMaxRowsEnvKey))
+	if raw == "" {
+		return defaultChatPromptMaxRows
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		slog.Warn("invalid chat prompt row limit, using default", "key", chatPromptMaxRowsEnvKey, "value", raw, "default", defaultChatPromptMaxRows)
+		return defaultChatPromptMaxRows
+	}
+	return parsed
+}
+
+// ChatPromptMaxSensorsFromEnv reads CHAT_PROMPT_MAX_SENSORS, the number of
+// machine/sensor pairs listed in the Flux system prompt (default 15). Zero
+// lists every sensor.
+func ChatPromptMaxSensorsFromEnv() int {
+	raw := strings.TrimSpace(os.Getenv(chatPromptMaxSensorsEnvKey))
+	if raw == "" {
+		return defaultChatPromptMaxSensors
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		slog.Warn("invalid chat prompt sensor limit, using default", "key", chatPromptMaxSensorsEnvKey, "value", raw, "default", defaultChatPromptMaxSensors)
+		return defaultChatPromptMaxSensors
+	}
+	return parsed
+}
+
+// csvPromptSummary describes how summarizeCSVForPrompt shortened a result.
+type csvPromptSummary struct {
+	Text      string
+	TotalRows int
+	KeptRows  int
+}
+
+func (s csvPromptSummary) truncated() bool {
+	return s.KeptRows < s.TotalRows
+}
+
+// csvTable is one table of an annotated Flux CSV result.
+type csvTable struct {
+	preamble []string // annotation rows followed by the header row
+	columns  []string
+	rows     []string
+}
+
+// summarizeCSVForPrompt keeps the first and last rows of an annotated Flux CSV
+// result so that at most maxRows data rows remain, and appends per-series
+// aggregates computed over every row. Results within the limit, or a
+// non-positive maxRows, are returned unchanged.
+func summarizeCSVForPrompt(raw string, maxRows int) csvPromptSummary {
+	tables := parseCSVTables(raw)
+	total := 0
+	for _, table := range tables {
+		total += len(table.rows)
+	}
+	if maxRows <= 0 || total <= maxRows {
+		return csvPromptSummary{Text: raw, TotalRows: total, KeptRows: total}
+	}
+
+	head := (maxRows + 1) / 2
+	tailStart := total - (maxRows - head)
+
+	var sb strings.Builder
+	index := 0
+	omitted := false
+	for _, table := range tables {
+		wroteHeader := false
+		for _, row := range table.rows {
+			keep := index < head || index >= tailStart
+			index++
+			if !keep {
+				if !omitted {
+					fmt.Fprintf(&sb, "# ... %d rows omitted ...\n", tailStart-head)
+					omitted = true
+				}
+				continue
+			}
+			if !wroteHeader {
+				if sb.Len() > 0 {
+					sb.WriteString("\n")
+				}
+				for _, line := range table.preamble {
+					sb.WriteString(line)
+					sb.WriteString("\n")
+				}
+				wroteHeader = true
+			}
+			sb.WriteString(row)
+			sb.WriteString("\n")
+		}
+	}
+
+	if aggregates := csvSeriesAggregates(tables); aggregates != "" {
+		fmt.Fprintf(&sb, "\n# aggregates over all %d rows:\n%s", total, aggregates)
+	}
+	return csvPromptSummary{Text: sb.String(), TotalRows: total, KeptRows: maxRows}
+}
+
+func parseCSVTables(raw string) []csvTable {
+	var tables []csvTable
+	var current *csvTable
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			tables = append(tables, csvTable{})
+			current = &tables[len(tables)-1]
+		}
+		switch {
+		case strings.HasPrefix(line, "#"):
+			current.preamble = append(current.preamble, line)
+		case current.columns == nil:
+			current.preamble = append(current.preamble, line)
+			current.columns = splitCSVLine(line)
+		default:
+			current.rows = append(current.rows, line)
+		}
+	}
+	return tables
+}
+
+func splitCSVLine(line string) []string {
+	record, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return strings.Split(line, ",")
+	}
+	return record
+}
+
+type seriesAggregate struct {
+	count     int
+	sum       float64
+	min       float64
+	max       float64
+	firstTime string
+	lastTime  string
+}
+
+// csvSeriesAggregates computes count/min/max/mean of _value per machine and
+// sensor, falling back to the Flux table id when those tags are absent.
+func csvSeriesAggregates(tables []csvTable) string {
+	series := map[string]*seriesAggregate{}
+	for _, table := range tables {
+		valueCol := columnIndex(table.columns, "_value")
+		if valueCol < 0 {
+			continue
+		}
+		timeCol := columnIndex(table.columns, "_time")
+		keyCols := make([]int, 0, 2)
+		keyNames := make([]string, 0, 2)
+		for _, name := range []string{"machine_name", "sensor_name"} {
+			if idx := columnIndex(table.columns, name); idx >= 0 {
+				keyCols = append(keyCols, idx)
+				keyNames = append(keyNames, name)
+			}
+		}
+		if len(keyCols) == 0 {
+			if idx := columnIndex(table.columns, "table"); idx >= 0 {
+				keyCols = append(keyCols, idx)
+				keyNames = append(keyNames, "table")
+			}
+		}
+
+		for _, row := range table.rows {
+			record := splitCSVLine(row)
+			if valueCol >= len(record) {
+				continue
+			}
+			value, err := strconv.ParseFloat(record[valueCol], 64)
+			if err != nil {
+				continue
+			}
+			parts := make([]string, 0, len(keyCols))
+			for i, col := range keyCols {
+				if col < len(record) {
+					parts = append(parts, keyNames[i]+"="+record[col])
+				}
+			}
+			key := strings.Join(parts, " ")
+			agg, ok := series[key]
+			if !ok {
+				agg = &seriesAggregate{min: math.Inf(1), max: math.Inf(-1)}
+				series[key] = agg
+			}
+			agg.count++
+			agg.sum += value
+			agg.min = math.Min(agg.min, value)
+			agg.max = math.Max(agg.max, value)
+			if timeCol >= 0 && timeCol < len(record) {
+				if agg.firstTime == "" {
+					agg.firstTime = record[timeCol]
+				}
+				agg.lastTime = record[timeCol]
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		agg := series[key]
+		sb.WriteString("# ")
+		if key != "" {
+			sb.WriteString(key)
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "count=%d min=%g max=%g mean=%g", agg.count, agg.min, agg.max, agg.sum/float64(agg.count))
+		if agg.firstTime != "" {
+			fmt.Fprintf(&sb, " from=%s to=%s", agg.firstTime, agg.lastTime)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, column := range columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+// parseRetention parses a retention period such as "30d", "12h" or "90m".
+func parseRetention(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// HandleLotCleanup purges completed lots older than ?olderThan= (e.g. 30d). With
+// ?influx=true each purged lot's points are also deleted from Influx, except for
+// windows shared with a lot that is kept. ?dryRun=true reports what would be
+// removed without deleting anything.
+func HandleLotCleanup(c *gin.Context, deps Dependencies) {
+	logger := requestLogger(c)
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+
+	olderThan, err := parseRetention(c.Query("olderThan"))
+	if err != nil || olderThan <= 0 {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "olderThan must be a positive duration such as 30d or 12h")
+		return
+	}
+	dryRun, err := queryBool(c, "dryRun", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	purgeInflux, err := queryBool(c, "influx", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if purgeInflux && deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+
+	ctx := c.Request.Context()
+	cutoff := time.Now().Add(-olderThan)
+	lots, err := deps.Metadata.ListCompletedLotsBefore(ctx, cutoff)
+	if err != nil {
+		logger.Error("list lots for cleanup failed", "error", err)
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to list lots")
+		return
+	}
+
+	lotNumbers := make([]string, len(lots))
+	for i, lot := range lots {
+		lotNumbers[i] = lot.LotNumber
+	}
+	response := gin.H{"dryRun": dryRun, "cutoff": cutoff.UTC(), "lotNumbers": lotNumbers}
+
+	// Influx data goes first so a failure leaves the lots in place for a retry.
+	if purgeInflux {
+		measurement := simulation.MeasurementName()
+		var points int64
+		var kept []string
+		for _, lot := range lots {
+			start, stop := lot.StartedAt, lot.CompletedAt.Time
+			shared, err := deps.Metadata.RetainedLotOverlaps(ctx, lot.MachineName, start, stop, cutoff)
+			if err != nil {
+				logger.Error("check lot overlap failed", "lot", lot.LotNumber, "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to check retained lots")
+				return
+			}
+			if shared {
+				kept = append(kept, lot.LotNumber)
+				continue
+			}
+			// Count's range stop is exclusive, delete's inclusive.
+			count, err := deps.Influx.CountMachinePoints(ctx, measurement, lot.MachineName, start, stop.Add(time.Nanosecond))
+			if err != nil {
+				logger.Error("count lot points failed", "lot", lot.LotNumber, "error", err)
+				respondError(c, http.StatusBadGateway, codeInfluxError, "failed to count influx points")
+				return
+			}
+			if !dryRun && count > 0 {
+				if err := deps.Influx.DeleteMachinePoints(ctx, measurement, lot.MachineName, start, stop); err != nil {
+					logger.Error("delete lot points failed", "lot", lot.LotNumber, "error", err)
+					respondErrorDetails(c, http.StatusBadGateway, codeInfluxError, "failed to delete influx points", gin.H{"points": points})
+					return
+				}
+			}
+			points += count
+		}
+		response["points"] = points
+		response["influxKept"] = kept
+	}
+
+	deleted := len(lots)
+	if !dryRun {
+		deleted, err = deps.Metadata.DeleteCompletedLotsBefore(ctx, cutoff)
+		if err != nil {
+			logger.Error("delete old lots failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to delete lots")
+			return
+		}
+		logger.Info("old lots purged", "lots", deleted, "cutoff", cutoff, "points", response["points"])
+	}
+	response["lots"] = deleted
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+)
+
+const corsOriginsEnvKey = "CORS_ALLOWED_ORIGINS"
+
+// DefaultCORSOrigins are allowed when CORS_ALLOWED_ORIGINS is unset.
+var DefaultCORSOrigins = []string{
+	"http://localhost:3000",
+	"https://localhost:3000",
+	"https://minerva-ericsson.vercel.app",
+}
+
+// CORSOriginsFromEnv reads the comma-separated CORS_ALLOWED_ORIGINS list, falling back
+// to DefaultCORSOrigins. Entries must be "*" or http(s) origins without a path; a
+// "*" may also appear as a subdomain wildcard such as https://*.example.com.
+func CORSOriginsFromEnv() ([]string, error) {
+	raw := strings.TrimSpace(os.Getenv(corsOriginsEnvKey))
+	if raw == "" {
+		return append([]string(nil), DefaultCORSOrigins...), nil
+	}
+
+	var origins []string
+	for _, entry := range strings.Split(raw, ",") {
+		origin := strings.TrimSuffix(strings.TrimSpace(entry), "/")
+		if origin == "" {
+			continue
+		}
+		if err := validateCORSOrigin(origin); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", corsOriginsEnvKey, origin, err)
+		}
+		origins = append(origins, origin)
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("%s is set but contains no origins", corsOriginsEnvKey)
+	}
+	return origins, nil
+}
+
+func validateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("origin must not contain a path, query, or fragment")
+	}
+	return nil
+}
+
+// newCORSConfig builds the middleware config. A bare "*" allows every origin and
+// therefore disables credentials, which browsers reject alongside a wildcard.
+func newCORSConfig(origins []string) cors.Config {
+	if len(origins) == 0 {
+		origins = DefaultCORSOrigins
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:        []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:        []string{"Origin", "Content-Type", "Accept", "Authorization", requestIDHeader, idempotencyKeyHeader},
+		ExposeHeaders:       []string{requestIDHeader, idempotencyReplayedHeader, totalCountHeader, truncatedHeader},
+		AllowCredentials:    true,
+		MaxAge:              12 * time.Hour,
+		AllowPrivateNetwork: true,
+		AllowWildcard:       true,
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			corsConfig.AllowAllOrigins = true
+			corsConfig.AllowCredentials = false
+			return corsConfig
+		}
+	}
+	corsConfig.AllowOrigins = origins
+	return corsConfig
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+const (
+	// csvFlushRows is how many rows are buffered before a CSV response is
+	// flushed to the client.
+	csvFlushRows = 500
+	// totalCountHeader carries the unpaged row count of a CSV page, which has
+	// no envelope to hold it.
+	totalCountHeader = "X-Total-Count"
+)
+
+// wantsCSV reports whether the client asked for CSV, via ?format=csv|json or,
+// when format is absent, an Accept header preferring text/csv. JSON is the
+// default.
+func wantsCSV(c *gin.Context) (bool, error) {
+	switch format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format {
+	case "csv":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+	default:
+		return false, fmt.Errorf("format must be csv or json")
+	}
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return true, nil
+		case "application/json", "*/*":
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// streamCSV writes header and then row(i) for i in [0, n) as a CSV attachment,
+// flushing every csvFlushRows rows so large exports are not held in a buffer.
+func streamCSV(c *gin.Context, filename string, header []string, n int, row func(i int) []string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(header); err != nil {
+		requestLogger(c).Warn("write csv header failed", "error", err)
+		return
+	}
+	for i := 0; i < n; i++ {
+		if err := w.Write(row(i)); err != nil {
+			requestLogger(c).Warn("write csv row failed", "row", i, "error", err)
+			return
+		}
+		if (i+1)%csvFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		requestLogger(c).Warn("flush csv failed", "error", err)
+	}
+}
+
+// averageColumns returns the union of the averages' keys, sorted, so every row
+// of an export has the same columns.
+func averageColumns(averages []map[string]float64) []string {
+	seen := map[string]struct{}{}
+	for _, values := range averages {
+		for key := range values {
+			seen[key] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendAverages appends one cell per column, empty where the row has no value.
+func appendAverages(record []string, columns []string, values map[string]float64) []string {
+	for _, key := range columns {
+		if v, ok := values[key]; ok {
+			record = append(record, formatCSVFloat(v))
+		} else {
+			record = append(record, "")
+		}
+	}
+	return record
+}
+
+func averageHeaders(columns []string) []string {
+	headers := make([]string, len(columns))
+	for i, key := range columns {
+		headers[i] = "avg_" + key
+	}
+	return headers
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// writeLotsCSV exports lots with their averages flattened into avg_ columns.
+func writeLotsCSV(c *gin.Context, lots []metadata.Lot) {
+	averages := make([]map[string]float64, len(lots))
+	for i, lot := range lots {
+		values, err := lot.AverageValues()
+		if err != nil {
+			requestLogger(c).Warn("decode lot averages failed", "lot", lot.LotNumber, "error", err)
+		}
+		averages[i] = values
+	}
+	columns := averageColumns(averages)
+
+	header := []string{"id", "lotNumber", "machineName", "status", "startedAt", "completedAt", "updatedAt",
+		"activeMachineId", "operationHour", "goodProduct", "defectProduct", "conclusionCategory", "conclusion"}
+	header = append(header, averageHeaders(columns)...)
+
+	streamCSV(c, "lots.csv", header, len(lots), func(i int) []string {
+		lot := lots[i]
+		completedAt := ""
+		if lot.CompletedAt.Valid {
+			completedAt = formatCSVTime(lot.CompletedAt.Time)
+		}
+		record := []string{
+			strconv.FormatInt(lot.ID, 10),
+			lot.LotNumber,
+			lot.MachineName,
+			string(lot.Status),
+			formatCSVTime(lot.StartedAt),
+			completedAt,
+			formatCSVTime(lot.UpdatedAt),
+			optionalString(lot.ActiveMachineID),
+			optionalString(lot.OperationHour),
+			optionalInt(lot.GoodProduct),
+			optionalInt(lot.DefectProduct),
+			string(lot.ConclusionCategory),
+			optionalString(lot.Conclusion),
+		}
+		return appendAverages(record, columns, averages[i])
+	})
+}
+
+// writeProductsCSV exports a page of product rows with their averages
+// flattened into avg_ columns; the unpaged total is sent as X-Total-Count.
+func writeProductsCSV(c *gin.Context, page metadata.ProductPage) {
+	products := page.Products
+	c.Header(totalCountHeader, strconv.Itoa(page.Total))
+	averages := make([]map[string]float64, len(products))
+	for i, product := range products {
+		averages[i] = product.Averages
+	}
+	columns := averageColumns(averages)
+
+	header := []string{"lot", "status", "activeMachineId", "operationHour", "goodProduct", "defectProduct",
+		"conclusionCategory", "conclusion", "updatedAt"}
+	header = append(header, averageHeaders(columns)...)
+
+	streamCSV(c, "products.csv", header, len(products), func(i int) []string {
+		product := products[i]
+		record := []string{
+			product.Lot,
+			string(product.Status),
+			product.ActiveMachineID,
+			formatCSVFloat(product.OperationHour),
+			strconv.Itoa(product.GoodProduct),
+			strconv.Itoa(product.DefectProduct),
+			string(product.ConclusionCategory),
+			product.Conclusion,
+			formatCSVTime(product.UpdatedAt),
+		}
+		return appendAverages(record, columns, averages[i])
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import "github.com/gin-gonic/gin"
+
+// Error codes are part of the API contract: clients branch on them, so existing
+// codes must not be renamed.
+const (
+	codeInvalidPayload     = "INVALID_PAYLOAD"
+	codeInvalidRequest     = "INVALID_REQUEST"
+	codeUnknownBucket      = "UNKNOWN_BUCKET"
+	codeUnauthorized       = "UNAUTHORIZED"
+	codeAdminDisabled      = "ADMIN_DISABLED"
+	codeLotNotFound        = "LOT_NOT_FOUND"
+	codeMachineNotFound    = "MACHINE_NOT_FOUND"
+	codeLotExists          = "LOT_EXISTS"
+	codeMachineExists      = "MACHINE_EXISTS"
+	codeSensorExists       = "SENSOR_EXISTS"
+	codeLotNotCompleted    = "LOT_NOT_COMPLETED"
+	codeLotDeleted         = "LOT_DELETED"
+	codeIdempotencyReuse   = "IDEMPOTENCY_KEY_REUSED"
+	codeMetadataUnavail    = "METADATA_UNAVAILABLE"
+	codeInfluxUnavail      = "INFLUX_UNAVAILABLE"
+	codeSimulatorUnavail   = "SIMULATOR_UNAVAILABLE"
+	codeCoordinatorUnavail = "COORDINATOR_UNAVAILABLE"
+	codeCompletionUnavail  = "COMPLETION_UNAVAILABLE"
+	codeLLMUnavail         = "LLM_UNAVAILABLE"
+	codeMySQLPoolUnavail   = "MYSQL_POOL_UNAVAILABLE"
+	codeMySQLUnhealthy     = "MYSQL_UNHEALTHY"
+	codeInfluxUnhealthy    = "INFLUX_UNHEALTHY"
+	codeInfluxError        = "INFLUX_ERROR"
+	codeFluxQueryFailed    = "FLUX_QUERY_FAILED"
+	codeQueryTimeout       = "QUERY_TIMEOUT"
+	codeRequestTimeout     = "REQUEST_TIMEOUT"
+	codeLLMError           = "LLM_ERROR"
+	codeLLMBlocked         = "LLM_BLOCKED"
+	codeLLMInvalidQuery    = "LLM_INVALID_QUERY"
+	codeInternal           = "INTERNAL_ERROR"
+	codeLookbackClamped    = "LOOKBACK_CLAMPED"
+)
+
+// apiError is the body of the "error" member of every error response, and the
+// payload of error and warning events on the reading streams.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details gin.H  `json:"details,omitempty"`
+}
+
+// respondError writes {"error": {"code": ..., "message": ...}} with status,
+// adding the request ID when one is set.
+func respondError(c *gin.Context, status int, code, message string) {
+	respondErrorDetails(c, status, code, message, nil)
+}
+
+// respondErrorDetails is respondError with extra context, such as the
+// offending Flux query, under error.details.
+func respondErrorDetails(c *gin.Context, status int, code, message string, details gin.H) {
+	body := gin.H{"error": apiError{Code: code, Message: message, Details: details}}
+	if id := RequestID(c.Request.Context()); id != "" {
+		body["requestId"] = id
+	}
+	c.JSON(status, body)
+}
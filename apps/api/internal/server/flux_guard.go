@@ -0,0 +1,226 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFluxRange is the furthest back a guarded Flux query may start.
+const maxFluxRange = 30 * 24 * time.Hour
+
+// errFluxRejected marks Flux queries refused by validateFluxQuery.
+var errFluxRejected = errors.New("flux query rejected")
+
+// fluxImports lists the packages a guarded query may import. They only
+// transform values; packages that reach other hosts, files or databases, such
+// as http, http/requests, csv, sql and experimental, are left out.
+var fluxImports = map[string]bool{
+	"date":                       true,
+	"math":                       true,
+	"regexp":                     true,
+	"strings":                    true,
+	"timezone":                   true,
+	"influxdata/influxdb/schema": true,
+}
+
+var (
+	// fluxWriteCalls matches the built-in functions that write data. Any other
+	// writer needs an import outside fluxImports.
+	fluxWriteCalls = regexp.MustCompile(`(?i)(\bto|\bwideTo)\s*\(`)
+	fluxImport     = regexp.MustCompile(`\bimport\b`)
+	fluxImportStmt = regexp.MustCompile(`^import(?:\s+\w+)?\s*("(?:[^"\\]|\\.)*")`)
+	// fluxFromCall matches unqualified from() calls; csv.from and the like need
+	// an import, which fluxImports already restricts.
+	fluxFromCall   = regexp.MustCompile(`(?:^|[^.\w])from\s*\(`)
+	fluxArgName    = regexp.MustCompile(`(\w+)\s*:`)
+	fluxRangeCall  = regexp.MustCompile(`\brange\s*\(`)
+	fluxRangeStart = regexp.MustCompile(`\bstart\s*:\s*(time\s*\(\s*v\s*:\s*"[^"]*"\s*\)|[^,)]+)`)
+	fluxTimeArg    = regexp.MustCompile(`^time\s*\(\s*v\s*:\s*"([^"]*)"\s*\)$`)
+	fluxDuration   = regexp.MustCompile(`(\d+)(mo|ms|us|µs|ns|y|w|d|h|m|s)`)
+)
+
+// validateFluxQuery rejects Flux that could mutate data, reach outside the
+// configured InfluxDB or read without a bounded time range. Only packages in
+// fluxImports may be imported, from() may only name a bucket, and every range()
+// must start no earlier than maxFluxRange before now, given as a relative
+// duration or an RFC3339 time.
+func validateFluxQuery(query string, now time.Time) error {
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("%w: query is empty", errFluxRejected)
+	}
+	code, err := fluxCode(query)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errFluxRejected, err)
+	}
+	if match := fluxWriteCalls.FindString(code); match != "" {
+		return fmt.Errorf("%w: %s is not allowed", errFluxRejected, strings.TrimSpace(strings.TrimSuffix(match, "(")))
+	}
+	for _, loc := range fluxImport.FindAllStringIndex(code, -1) {
+		m := fluxImportStmt.FindStringSubmatch(query[loc[0]:])
+		if m == nil {
+			return fmt.Errorf("%w: malformed import", errFluxRejected)
+		}
+		path, err := strconv.Unquote(m[1])
+		if err != nil || !fluxImports[path] {
+			return fmt.Errorf("%w: import %s is not allowed", errFluxRejected, m[1])
+		}
+	}
+	froms, err := fluxCallArgs(code, fluxFromCall)
+	if err != nil {
+		return err
+	}
+	for _, call := range froms {
+		args := fluxArgName.FindAllStringSubmatch(code[call[0]:call[1]], -1)
+		if len(args) != 1 || (args[0][1] != "bucket" && args[0][1] != "bucketID") {
+			return fmt.Errorf("%w: from() may only set bucket", errFluxRejected)
+		}
+	}
+
+	ranges, err := fluxCallArgs(code, fluxRangeCall)
+	if err != nil {
+		return err
+	}
+	if len(ranges) == 0 {
+		return fmt.Errorf("%w: a range(start: ...) is required", errFluxRejected)
+	}
+	earliest := now.Add(-maxFluxRange)
+	for _, call := range ranges {
+		arg := fluxRangeStart.FindStringSubmatchIndex(code[call[0]:call[1]])
+		if arg == nil {
+			return fmt.Errorf("%w: range() must set start", errFluxRejected)
+		}
+		raw := query[call[0]+arg[2] : call[0]+arg[3]]
+		start, err := parseFluxStart(strings.TrimSpace(raw), now)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errFluxRejected, err)
+		}
+		if start.Before(earliest) {
+			return fmt.Errorf("%w: range start must be within the last %d days", errFluxRejected, int(maxFluxRange.Hours()/24))
+		}
+	}
+	return nil
+}
+
+// fluxCode returns query with comments and the contents of string and regular
+// expression literals blanked out, so the checks only match code. Byte offsets
+// are kept, letting a match be read back from query. String interpolation is
+// refused because it would put code inside a string.
+func fluxCode(query string) (string, error) {
+	code := []byte(query)
+	var (
+		quote byte // '"' or '/' while inside a literal
+		prev  byte // last code byte outside literals and spaces
+	)
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case quote != 0 && c == '\\':
+			code[i] = ' '
+			if i+1 < len(code) {
+				i++
+				code[i] = ' '
+			}
+		case quote != 0 && c == quote:
+			quote = 0
+			prev = c
+		case quote != 0:
+			if quote == '"' && c == '$' && i+1 < len(code) && code[i+1] == '{' {
+				return "", errors.New("string interpolation is not allowed")
+			}
+			code[i] = ' '
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			for ; i < len(code) && code[i] != '\n'; i++ {
+				code[i] = ' '
+			}
+		case c == '"':
+			quote = c
+		case c == '/' && strings.IndexByte("~:(,[=", prev) >= 0:
+			// A slash right after one of these starts a regular expression
+			// rather than a division.
+			quote = c
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			prev = c
+		}
+	}
+	if quote != 0 {
+		return "", errors.New("unterminated literal")
+	}
+	return string(code), nil
+}
+
+// fluxCallArgs returns the byte span of the arguments of every call in code
+// matched by call, a pattern ending at the opening parenthesis.
+func fluxCallArgs(code string, call *regexp.Regexp) ([][2]int, error) {
+	var spans [][2]int
+	for _, loc := range call.FindAllStringIndex(code, -1) {
+		depth := 1
+		end := loc[1]
+		for ; end < len(code) && depth > 0; end++ {
+			switch code[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("%w: unbalanced parentheses", errFluxRejected)
+		}
+		spans = append(spans, [2]int{loc[1], end - 1})
+	}
+	return spans, nil
+}
+
+// parseFluxStart resolves a range start given as a negative duration literal,
+// an RFC3339 literal or time(v: "...").
+func parseFluxStart(raw string, now time.Time) (time.Time, error) {
+	if m := fluxTimeArg.FindStringSubmatch(raw); m != nil {
+		raw = m[1]
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, nil
+	}
+	if rest, ok := strings.CutPrefix(raw, "-"); ok {
+		if d, err := parseFluxDuration(rest); err == nil {
+			return now.Add(-d), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("range start %q must be a negative duration or an RFC3339 time", raw)
+}
+
+// parseFluxDuration parses Flux duration literals such as 1h30m or 7d. Months
+// and years count as 30 and 365 days.
+func parseFluxDuration(raw string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"ns": time.Nanosecond, "us": time.Microsecond, "µs": time.Microsecond, "ms": time.Millisecond,
+		"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+		"mo": 30 * 24 * time.Hour, "y": 365 * 24 * time.Hour,
+	}
+	parts := fluxDuration.FindAllStringSubmatchIndex(raw, -1)
+	if len(parts) == 0 {
+		return 0, errors.New("invalid duration")
+	}
+	var (
+		total time.Duration
+		next  int
+	)
+	for _, p := range parts {
+		if p[0] != next {
+			return 0, errors.New("invalid duration")
+		}
+		n, err := strconv.ParseInt(raw[p[2]:p[3]], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += time.Duration(n) * units[raw[p[4]:p[5]]]
+		next = p[1]
+	}
+	if next != len(raw) {
+		return 0, errors.New("invalid duration")
+	}
+	return total, nil
+}
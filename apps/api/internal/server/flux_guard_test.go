@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateFluxQuery(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	const base = `from(bucket: "sensor_data") |> range(start: -1h)`
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string // substring of the rejection; empty when allowed
+	}{
+		{name: "plain read", query: base + ` |> filter(fn: (r) => r._measurement == "sensor_data")`},
+		{name: "bucket id", query: `from(bucketID: "0123456789abcdef") |> range(start: -2d)`},
+		{name: "rfc3339 start", query: `from(bucket: "sensor_data") |> range(start: time(v: "2024-06-01T00:00:00Z"), stop: now())`},
+		{name: "allowed imports", query: "import \"strings\"\nimport \"influxdata/influxdb/schema\"\n" + base + ` |> map(fn: (r) => ({r with machine_name: strings.toUpper(v: r.machine_name)})) |> schema.fieldsAsCols()`},
+		{name: "regex and division", query: base + ` |> filter(fn: (r) => r.machine_name =~ /^Press-"\d+$/) |> map(fn: (r) => ({r with _value: r._value / 2.0}))`},
+		{name: "keywords inside strings and comments", query: "// import \"http\" and to(bucket: x)\n" + base + ` |> filter(fn: (r) => r.note == "import \"csv\" to(" or r.host == "from(bucket: x, host: y)")`},
+
+		{name: "http/requests", query: "import \"http/requests\"\n" + base + "\nrequests.post(url: \"http://example.com\", body: bytes(v: \"x\"))", wantErr: `import "http/requests" is not allowed`},
+		{name: "aliased import", query: "import r \"http/requests\"\n" + base, wantErr: `import "http/requests" is not allowed`},
+		{name: "experimental/http", query: "import \"experimental/http\"\n" + base + "\nhttp.get(url: \"http://169.254.169.254/\")", wantErr: `import "experimental/http" is not allowed`},
+		{name: "csv.from", query: "import \"csv\"\ncsv.from(url: \"http://169.254.169.254/latest/meta-data\") |> range(start: -1h)", wantErr: `import "csv" is not allowed`},
+		{name: "sql", query: "import \"sql\"\n" + base, wantErr: `import "sql" is not allowed`},
+		{name: "experimental", query: "import \"experimental\"\n" + base, wantErr: `import "experimental" is not allowed`},
+		{name: "from another host", query: `from(bucket: "sensor_data", host: "http://169.254.169.254") |> range(start: -1h)`, wantErr: "from() may only set bucket"},
+		{name: "from with a token", query: `from(bucket: "sensor_data", org: "other", token: "secret") |> range(start: -1h)`, wantErr: "from() may only set bucket"},
+		{name: "from with nested calls", query: `from(host: strings.trimSpace(v: strings.toLower(v: "H")), bucket: "b") |> range(start: -1h)`, wantErr: "from() may only set bucket"},
+		{name: "from inside interpolation", query: `x = "${from(bucket: "b", host: "h")}"` + "\n" + base, wantErr: "string interpolation is not allowed"},
+		{name: "import after a string with slashes", query: "x = \"//\"\nimport \"http\"\n" + base, wantErr: `import "http" is not allowed`},
+		{name: "to", query: base + ` |> to(bucket: "other")`, wantErr: "to is not allowed"},
+		{name: "range only in a comment", query: `from(bucket: "sensor_data") // |> range(start: -1h)`, wantErr: "range(start: ...) is required"},
+		{name: "range too old", query: `from(bucket: "sensor_data") |> range(start: -40d)`, wantErr: "within the last 30 days"},
+		{name: "range without start", query: `from(bucket: "sensor_data") |> range(stop: now())`, wantErr: "range() must set start"},
+		{name: "unterminated string", query: `from(bucket: "sensor_data) |> range(start: -1h)`, wantErr: "unterminated literal"},
+		{name: "empty", query: "  ", wantErr: "query is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFluxQuery(tt.query, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateFluxQuery rejected an allowed query: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errFluxRejected) {
+				t.Fatalf("validateFluxQuery = %v, want errFluxRejected", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateFluxQuery = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
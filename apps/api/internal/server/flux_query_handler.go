@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const fluxQueryTimeout = 30 * time.Second
+
+type fluxQueryRequest struct {
+	Query string `json:"query"`
+}
+
+// HandleFluxQuery runs a caller-supplied Flux query, after the same
+// validateFluxQuery guard the chatbot applies, and returns its records. At most
+// maxStructuredRecords rows are returned; "truncated" reports when more exist.
+func HandleFluxQuery(c *gin.Context, deps Dependencies) {
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+	var req fluxQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+		return
+	}
+	query := strings.TrimSpace(req.Query)
+	if err := validateFluxQuery(query, time.Now()); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), fluxQueryTimeout)
+	defer cancel()
+	// Read one extra record to tell a full page from a truncated one.
+	records, err := deps.Influx.QueryRecords(ctx, query, maxStructuredRecords+1)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			respondError(c, http.StatusGatewayTimeout, codeQueryTimeout, "flux query timed out")
+			return
+		}
+		requestLogger(c).Warn("manual flux query failed", "error", err, "query", query)
+		respondErrorDetails(c, http.StatusBadRequest, codeFluxQueryFailed, "flux query execution failed", gin.H{"reason": err.Error()})
+		return
+	}
+	truncated := len(records) > maxStructuredRecords
+	if truncated {
+		records = records[:maxStructuredRecords]
+	}
+	requestLogger(c).Info("manual flux query executed", "rows", len(records), "truncated", truncated)
+	c.JSON(http.StatusOK, gin.H{"records": records, "count": len(records), "truncated": truncated})
+}
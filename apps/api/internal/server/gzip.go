@@ -0,0 +1,124 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const enableGzipEnvKey = "ENABLE_GZIP"
+
+// gzipExcludedPaths stream responses that compression would buffer or break:
+// SSE relies on each event being flushed as-is, and the WebSocket upgrade
+// hijacks the connection.
+var gzipExcludedPaths = map[string]struct{}{
+	"/api/influx/stream": {},
+	"/api/influx/ws":     {},
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// GzipEnabledFromEnv reads ENABLE_GZIP; compression is on unless it is set to a
+// false value.
+func GzipEnabledFromEnv() bool {
+	raw := strings.TrimSpace(os.Getenv(enableGzipEnvKey))
+	if raw == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		slog.Warn("invalid gzip setting, compression enabled", "key", enableGzipEnvKey, "value", raw)
+		return true
+	}
+	return enabled
+}
+
+// gzipMiddleware compresses responses for clients that accept gzip, except on
+// streaming endpoints.
+func gzipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, excluded := gzipExcludedPaths[c.FullPath()]; excluded || !acceptsGzip(c.Request) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+		defer writer.close()
+		c.Next()
+	}
+}
+
+func acceptsGzip(req *http.Request) bool {
+	if req.Header.Get("Upgrade") != "" || strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipResponseWriter starts compressing on the first body write, so responses
+// without a body (204, 304, aborted requests) are sent untouched.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.gz == nil {
+		header := w.Header()
+		if header.Get("Content-Encoding") != "" {
+			return w.ResponseWriter.Write(data)
+		}
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	return w.gz.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	w.gz.Reset(io.Discard)
+	gzipWriterPool.Put(w.gz)
+	w.gz = nil
+}
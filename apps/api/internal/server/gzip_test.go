@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := metadatatest.New()
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 300; i++ {
+		store.PutLot(metadata.Lot{
+			LotNumber:   fmt.Sprintf("LOT-%04d", i),
+			MachineName: fmt.Sprintf("Machine-%02d", i%10),
+			Status:      metadata.LotStatusProcessing,
+			StartedAt:   base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	stub := influxtest.New(influxdb.SensorReading{Time: time.Now(), MachineName: "Machine-00", SensorName: "Temperature", Status: "running", Value: 180})
+	srv := httptest.NewServer(NewRouter(Dependencies{Metadata: store, Influx: stub, EnableGzip: true}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getGzip requests url asking for gzip. Setting Accept-Encoding by hand stops the
+// transport from decompressing, so the response arrives as sent.
+func getGzip(t *testing.T, ctx context.Context, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestGzipCompressesJSON(t *testing.T) {
+	srv := gzipTestServer(t)
+	resp := getGzip(t, context.Background(), srv.URL+"/api/lots")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	compressed, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(string(compressed)))
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	if len(compressed) >= len(plain)/2 {
+		t.Errorf("compressed %d bytes to %d", len(plain), len(compressed))
+	}
+	var body struct {
+		Lots []metadata.Lot `json:"lots"`
+	}
+	if err := json.Unmarshal(plain, &body); err != nil {
+		t.Fatalf("decode lots: %v\n%s", err, plain)
+	}
+	if len(body.Lots) == 0 {
+		t.Error("decompressed response lists no lots")
+	}
+}
+
+func TestGzipSkipsEventStream(t *testing.T) {
+	srv := gzipTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A lookback over the cap makes the stream send a warning event at once.
+	resp := getGzip(t, ctx, srv.URL+"/api/influx/stream?machine=Machine-00&lookback=48h")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none", got)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read first event: %v", err)
+	}
+	if line != "event:warning\n" {
+		t.Errorf("first line = %q, want the plain-text warning event", line)
+	}
+}
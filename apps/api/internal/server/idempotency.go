@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyTTL            = 24 * time.Hour
+	maxIdempotencyKeyLength   = 255
+)
+
+// idempotentRequest records the response of a request sent with an
+// Idempotency-Key header so that retries replay it instead of re-executing.
+type idempotentRequest struct {
+	repo  MetadataStore
+	scope string
+	key   string
+	hash  string
+}
+
+// startIdempotentRequest checks the Idempotency-Key header against stored
+// responses for scope. It reports handled when it has already written the
+// response (a replay or an error). The returned request is nil when no key was
+// sent; its respond method still writes the response in that case.
+func startIdempotentRequest(c *gin.Context, repo MetadataStore, scope string) (req *idempotentRequest, handled bool) {
+	key := strings.TrimSpace(c.GetHeader(idempotencyKeyHeader))
+	if key == "" {
+		return nil, false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "Idempotency-Key must be at most 255 characters")
+		return nil, true
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+		return nil, true
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	req = &idempotentRequest{repo: repo, scope: scope, key: key, hash: hex.EncodeToString(sum[:])}
+
+	stored, ok, err := repo.LookupIdempotentResponse(c.Request.Context(), scope, key, req.hash, idempotencyTTL)
+	switch {
+	case errors.Is(err, metadata.ErrIdempotencyKeyReused):
+		respondError(c, http.StatusUnprocessableEntity, codeIdempotencyReuse, err.Error())
+		return nil, true
+	case err != nil:
+		requestLogger(c).Warn("idempotency lookup failed, executing request", "error", err, "scope", scope)
+		return req, false
+	case ok:
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Body)
+		return nil, true
+	}
+	return req, false
+}
+
+// respond writes payload and, for requests carrying a key, stores it for replay.
+func (r *idempotentRequest) respond(c *gin.Context, status int, payload any) {
+	if r == nil {
+		c.JSON(status, payload)
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(status, payload)
+		return
+	}
+	resp := metadata.IdempotentResponse{StatusCode: status, Body: body, RequestHash: r.hash}
+	if err := r.repo.SaveIdempotentResponse(c.Request.Context(), r.scope, r.key, resp, idempotencyTTL); err != nil {
+		requestLogger(c).Warn("store idempotent response failed", "error", err, "scope", r.scope)
+	}
+	c.Data(status, "application/json; charset=utf-8", body)
+}
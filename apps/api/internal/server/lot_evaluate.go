@@ -0,0 +1,51 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleLotEvaluate runs the completion service's sensor-down check for one lot
+// and returns what it saw: each sensor's recent window, whether the lot is done
+// and, if not, which sensors held it back. The lot is not completed, so this
+// is safe to call while debugging a lot that will not finish.
+func HandleLotEvaluate(c *gin.Context, deps Dependencies) {
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+	if deps.Completion == nil {
+		respondError(c, http.StatusServiceUnavailable, codeCompletionUnavail, "lot completion service disabled")
+		return
+	}
+	lotNumber := strings.TrimSpace(c.Param("lotNumber"))
+	if lotNumber == "" {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "lot number is required")
+		return
+	}
+	logger := requestLogger(c)
+	lot, err := deps.Metadata.GetLotByNumber(c.Request.Context(), lotNumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrLotNotFound):
+			respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+		default:
+			logger.Error("get lot failed", "lot", lotNumber, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to get lot")
+		}
+		return
+	}
+
+	eval, err := deps.Completion.EvaluateLot(c.Request.Context(), lot)
+	if err != nil {
+		logger.Error("lot evaluation failed", "lot", lotNumber, "error", err)
+		respondError(c, http.StatusBadGateway, codeInfluxError, "failed to query sensor readings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": lot.Status, "evaluation": eval})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/processing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleLotEvaluate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now().UTC()
+	stub := influxtest.New()
+	for i := 0; i < 3; i++ {
+		at := now.Add(-time.Duration(i) * time.Second)
+		stub.Add(
+			influxdb.SensorReading{Time: at, MachineName: "Oven-01", SensorName: "Temperature", Status: "down", Value: 0.5},
+			influxdb.SensorReading{Time: at, MachineName: "Oven-01", SensorName: "Pressure", Status: "down", Value: 0.1},
+		)
+	}
+	store := metadatatest.New()
+	store.PutLot(metadata.Lot{LotNumber: "LOT-1", MachineName: "Oven-01", Status: metadata.LotStatusProcessing, StartedAt: now.Add(-time.Hour)})
+	router := NewRouter(Dependencies{Metadata: store, Influx: stub, Completion: processing.NewCompletionService(stub, store)})
+
+	tests := []struct {
+		name       string
+		router     http.Handler
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"evaluates", router, "/api/lots/LOT-1/evaluate", http.StatusOK, ""},
+		{"trims the lot number", router, "/api/lots/%20LOT-1%20/evaluate", http.StatusOK, ""},
+		{"blank lot number", router, "/api/lots/%20%20/evaluate", http.StatusBadRequest, codeInvalidRequest},
+		{"unknown lot", router, "/api/lots/LOT-9/evaluate", http.StatusNotFound, codeLotNotFound},
+		{"completion disabled", NewRouter(Dependencies{Metadata: store, Influx: stub}), "/api/lots/LOT-1/evaluate", http.StatusServiceUnavailable, codeCompletionUnavail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serveJSON(t, tt.router, http.MethodPost, tt.path, "")
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+			if code := errorCode(body); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q", code, tt.wantCode)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			eval, _ := body["evaluation"].(map[string]any)
+			if eval["lotNumber"] != "LOT-1" || eval["done"] != true {
+				t.Errorf("evaluation = %v, want LOT-1 done", eval)
+			}
+		})
+	}
+
+	// Evaluating reports the lot as done without completing it.
+	lot, err := store.GetLotByNumber(context.Background(), "LOT-1")
+	if err != nil {
+		t.Fatalf("GetLotByNumber: %v", err)
+	}
+	if lot.Status != metadata.LotStatusProcessing {
+		t.Errorf("lot status = %s after evaluating, want processing", lot.Status)
+	}
+}
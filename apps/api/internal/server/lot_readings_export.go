@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+const (
+	// maxLotExportRows caps a lot's raw readings export; a lot running for
+	// days at the simulator's 1s tick would otherwise be unbounded.
+	maxLotExportRows = 100_000
+	// truncatedHeader is "true" when an export stopped at its row cap.
+	truncatedHeader = "X-Export-Truncated"
+)
+
+// HandleLotReadingsCSV streams every reading of the lot's machine between its
+// start and its completion (or now while it is still processing) as a CSV
+// download, oldest first. Exports beyond maxLotExportRows are cut off at the
+// cap and flagged with X-Export-Truncated.
+func HandleLotReadingsCSV(c *gin.Context, deps Dependencies) {
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+	logger := requestLogger(c)
+	lotNumber := c.Param("lotNumber")
+	lot, err := deps.Metadata.GetLotByNumber(c.Request.Context(), lotNumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrLotNotFound):
+			respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+		default:
+			logger.Error("get lot failed", "lot", lotNumber, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to get lot")
+		}
+		return
+	}
+
+	end := time.Now().UTC()
+	if lot.CompletedAt.Valid {
+		end = lot.CompletedAt.Time
+	}
+	// Fetch one row past the cap to tell a full export from a truncated one.
+	readings, err := deps.Influx.MachineTrace(c.Request.Context(), simulation.MeasurementName(), lot.MachineName,
+		lot.StartedAt, end.Add(time.Nanosecond), maxLotExportRows+1)
+	if err != nil {
+		logger.Error("lot readings query failed", "lot", lotNumber, "error", err)
+		respondError(c, http.StatusBadGateway, codeInfluxError, "failed to query lot readings")
+		return
+	}
+	truncated := len(readings) > maxLotExportRows
+	if truncated {
+		readings = readings[:maxLotExportRows]
+	}
+	c.Header(truncatedHeader, strconv.FormatBool(truncated))
+
+	header := []string{"time", "sensor_name", "value", "status"}
+	filename := fmt.Sprintf("lot-%s-readings.csv", lot.LotNumber)
+	streamCSV(c, filename, header, len(readings), func(i int) []string {
+		reading := readings[i]
+		return []string{
+			reading.Time.UTC().Format(time.RFC3339Nano),
+			reading.SensorName,
+			formatCSVFloat(reading.Value),
+			reading.Status,
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+// statusSegment is a span during which a sensor kept one status.
+type statusSegment struct {
+	Status          string    `json:"status"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+type sensorTimeline struct {
+	SensorName string          `json:"sensorName"`
+	Segments   []statusSegment `json:"segments"`
+}
+
+// HandleLotTimeline returns, per sensor of the lot's machine, the status
+// segments recorded between the lot's start and its completion (or now while
+// it is still processing).
+func HandleLotTimeline(c *gin.Context, deps Dependencies) {
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+	logger := requestLogger(c)
+	lotNumber := c.Param("lotNumber")
+	lot, err := deps.Metadata.GetLotByNumber(c.Request.Context(), lotNumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrLotNotFound):
+			respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+		default:
+			logger.Error("get lot failed", "lot", lotNumber, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to get lot")
+		}
+		return
+	}
+
+	end := time.Now().UTC()
+	if lot.CompletedAt.Valid {
+		end = lot.CompletedAt.Time
+	}
+	// Range stop is exclusive; extend it so a change at completion is included.
+	changes, err := deps.Influx.StatusChanges(c.Request.Context(), simulation.MeasurementName(), lot.MachineName, lot.StartedAt, end.Add(time.Nanosecond))
+	if err != nil {
+		logger.Error("lot timeline query failed", "lot", lotNumber, "error", err)
+		respondError(c, http.StatusBadGateway, codeInfluxError, "failed to query lot status history")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lotNumber":   lot.LotNumber,
+		"machineName": lot.MachineName,
+		"start":       lot.StartedAt,
+		"end":         end,
+		"sensors":     buildStatusTimelines(changes, end),
+	})
+}
+
+// buildStatusTimelines turns per-sensor change points into segments, each
+// ending where the next begins and the last ending at end. Sensors are ordered
+// by name.
+func buildStatusTimelines(changes []influx.StatusChange, end time.Time) []sensorTimeline {
+	bySensor := map[string][]influx.StatusChange{}
+	for _, change := range changes {
+		bySensor[change.SensorName] = append(bySensor[change.SensorName], change)
+	}
+	names := make([]string, 0, len(bySensor))
+	for name := range bySensor {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timelines := make([]sensorTimeline, 0, len(names))
+	for _, name := range names {
+		points := bySensor[name]
+		segments := make([]statusSegment, 0, len(points))
+		for i, point := range points {
+			segmentEnd := end
+			if i+1 < len(points) {
+				segmentEnd = points[i+1].Time
+			}
+			segments = append(segments, statusSegment{
+				Status:          point.Status,
+				Start:           point.Time,
+				End:             segmentEnd,
+				DurationSeconds: segmentEnd.Sub(point.Time).Seconds(),
+			})
+		}
+		timelines = append(timelines, sensorTimeline{SensorName: name, Segments: segments})
+	}
+	return timelines
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+)
+
+// canonicalMachine returns the spelling of machine that its readings are
+// tagged with in InfluxDB, where tag filters are case-sensitive: the
+// simulator's spelling when it runs the machine, else the registered machine's,
+// else the name normalized as given. A blank name stays blank.
+func canonicalMachine(ctx context.Context, deps Dependencies, machine string) string {
+	machine = metadata.NormalizeMachineName(machine)
+	if machine == "" {
+		return ""
+	}
+	if deps.Simulator != nil {
+		if name, ok := deps.Simulator.CanonicalMachine(machine); ok {
+			return name
+		}
+	}
+	if deps.Metadata != nil {
+		if m, err := deps.Metadata.GetMachineByName(ctx, machine); err == nil {
+			return m.MachineName
+		}
+	}
+	return machine
+}
+
+// canonicalMachines applies canonicalMachine to each of machines in place.
+func canonicalMachines(ctx context.Context, deps Dependencies, machines []string) []string {
+	for i, machine := range machines {
+		machines[i] = canonicalMachine(ctx, deps, machine)
+	}
+	return machines
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+func TestCanonicalMachine(t *testing.T) {
+	ctx := context.Background()
+	store := metadatatest.New()
+	for _, name := range []string{"Furnace-01", "Press-01"} {
+		if _, err := store.CreateMachine(ctx, metadata.CreateMachineInput{MachineName: name}); err != nil {
+			t.Fatalf("CreateMachine %s: %v", name, err)
+		}
+	}
+	// The simulator spells Press-01 differently from its registration, as a
+	// machine renamed after its readings were written would be.
+	sim := simulation.New(nil, []*simulation.Sensor{
+		simulation.NewSensor("PRESS-01", "Pressure", 5, 0.1, 0),
+		simulation.NewSensor("Oven-01", "Temperature", 180, 1, 0),
+	})
+	withSim := Dependencies{Metadata: store, Simulator: sim}
+	withoutSim := Dependencies{Metadata: store}
+
+	tests := []struct {
+		name    string
+		deps    Dependencies
+		machine string
+		want    string
+	}{
+		{"simulator spelling", withSim, "oven-01", "Oven-01"},
+		{"simulator wins over registration", withSim, "press-01", "PRESS-01"},
+		{"registered spelling", withSim, " furnace-01 ", "Furnace-01"},
+		{"registered without simulator", withoutSim, "press-01", "Press-01"},
+		{"unknown machine", withSim, "  Mill   02 ", "Mill 02"},
+		{"no stores", Dependencies{}, "furnace-01", "furnace-01"},
+		{"blank", withSim, "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalMachine(ctx, tt.deps, tt.machine); got != tt.want {
+				t.Errorf("canonicalMachine(%q) = %q, want %q", tt.machine, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMachines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{"absent", "", []string{}, false},
+		{"single", "?machine=Furnace-01", []string{"Furnace-01"}, false},
+		{"case duplicates keep the first", "?machine=Furnace-01&machine=furnace-01&machine=FURNACE-01", []string{"Furnace-01"}, false},
+		{"spacing duplicates", "?machine=%20Paint%20%20Line%202&machine=paint%20line%202", []string{"Paint Line 2"}, false},
+		{"blanks skipped", "?machine=&machine=%20&machine=Oven-01", []string{"Oven-01"}, false},
+		{"request order", "?machine=Oven-01&machine=Furnace-01&machine=oven-01", []string{"Oven-01", "Furnace-01"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/readings/stream"+tt.query, nil)
+			got, err := queryMachines(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("queryMachines err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queryMachines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineUtilizationUsesCanonicalName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := metadatatest.New()
+	if _, err := store.CreateMachine(ctx, metadata.CreateMachineInput{MachineName: "Furnace-01"}); err != nil {
+		t.Fatalf("CreateMachine: %v", err)
+	}
+	now := time.Now().UTC()
+	stub := influxtest.New(
+		influxdb.SensorReading{Time: now.Add(-2 * time.Minute), MachineName: "Furnace-01", SensorName: "Temperature", Status: influxdb.StatusRunning, Value: 900},
+		influxdb.SensorReading{Time: now.Add(-time.Minute), MachineName: "Furnace-01", SensorName: "Temperature", Status: influxdb.StatusDown, Value: 20},
+	)
+	router := NewRouter(Dependencies{Metadata: store, Influx: stub})
+
+	status, body := serveJSON(t, router, http.MethodGet, "/api/machines/furnace-01/utilization", "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", status, http.StatusOK, body)
+	}
+	if body["machineName"] != "Furnace-01" || body["noData"] == true || body["runningSamples"] != float64(1) {
+		t.Errorf("utilization = %v, want one running sample of Furnace-01", body)
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The query helpers return def when a parameter is absent and an error naming
+// the parameter when it is present but invalid, which handlers answer with 400.
+
+// queryDuration reads a positive duration such as 30s or 5m.
+func queryDuration(c *gin.Context, key string, def time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 30s or 5m", key)
+	}
+	return d, nil
+}
+
+// queryInt reads an integer no smaller than lo. Values above hi are clamped to
+// it; a hi of zero leaves the value unbounded.
+func queryInt(c *gin.Context, key string, def, lo, hi int) (int, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo {
+		return 0, fmt.Errorf("%s must be an integer of at least %d", key, lo)
+	}
+	if hi > 0 && n > hi {
+		n = hi
+	}
+	return n, nil
+}
+
+const (
+	// fullPrecision leaves values unrounded.
+	fullPrecision = -1
+	// displayPrecision is the default for endpoints feeding the UI, which
+	// rounds values anyway.
+	displayPrecision = 3
+	maxPrecision     = 10
+)
+
+// queryPrecision reads ?precision=, the decimals values are rounded to, from 0
+// to maxPrecision.
+func queryPrecision(c *gin.Context, def int) (int, error) {
+	raw := strings.TrimSpace(c.Query("precision"))
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 || n > maxPrecision {
+		return 0, fmt.Errorf("precision must be an integer from 0 to %d", maxPrecision)
+	}
+	return n, nil
+}
+
+// roundTo rounds v to precision decimals; fullPrecision returns v unchanged.
+func roundTo(v float64, precision int) float64 {
+	if precision < 0 {
+		return v
+	}
+	scale := math.Pow10(precision)
+	return math.Round(v*scale) / scale
+}
+
+// maxQueryMachines caps the machines one readings request may name.
+const maxQueryMachines = 20
+
+// queryMachines reads ?machine=, which may be repeated, normalized and without
+// case-insensitive duplicates in request order. Blank values are skipped, so an
+// absent or empty parameter yields no machines; naming more than
+// maxQueryMachines is an error.
+func queryMachines(c *gin.Context) ([]string, error) {
+	raw := c.QueryArray("machine")
+	machines := make([]string, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, machine := range raw {
+		machine = metadata.NormalizeMachineName(machine)
+		if machine == "" {
+			continue
+		}
+		key := strings.ToLower(machine)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		machines = append(machines, machine)
+	}
+	if len(machines) > maxQueryMachines {
+		return nil, fmt.Errorf("machine may be given at most %d times", maxQueryMachines)
+	}
+	return machines, nil
+}
+
+// queryBool reads a boolean such as true, false, 1 or 0.
+func queryBool(c *gin.Context, key string, def bool) (bool, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean", key)
+	}
+	return b, nil
+}
+
+// queryTime reads a time accepted by parseTimeParam. An absent parameter yields
+// the zero time.
+func queryTime(c *gin.Context, key string) (time.Time, error) {
+	t, err := parseTimeParam(c.Query(key))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be RFC3339 or YYYY-MM-DD", key)
+	}
+	return t, nil
+}
+
+// queryRangeEnd reads the exclusive end of a time range. A date-only value
+// covers that whole day, so it resolves to the following midnight.
+func queryRangeEnd(c *gin.Context, key string) (time.Time, error) {
+	t, err := queryTime(c, key)
+	if err != nil || t.IsZero() {
+		return t, err
+	}
+	if isDateOnly(c.Query(key)) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// isDateOnly reports whether raw is a plain YYYY-MM-DD date.
+func isDateOnly(raw string) bool {
+	_, err := time.Parse(time.DateOnly, strings.TrimSpace(raw))
+	return err == nil
+}
+
+// queryTimeRange reads start and stop as a half-open range. stop defaults to
+// now and start to span before stop; start must precede stop.
+func queryTimeRange(c *gin.Context, span time.Duration) (start, stop time.Time, err error) {
+	if start, err = queryTime(c, "start"); err != nil {
+		return start, stop, err
+	}
+	if stop, err = queryRangeEnd(c, "stop"); err != nil {
+		return start, stop, err
+	}
+	if stop.IsZero() {
+		stop = time.Now().UTC()
+	}
+	if start.IsZero() {
+		start = stop.Add(-span)
+	}
+	if !start.Before(stop) {
+		return start, stop, fmt.Errorf("start must be before stop")
+	}
+	return start, stop, nil
+}
+
+// parseTimeParam accepts RFC3339 timestamps or plain YYYY-MM-DD dates (UTC midnight).
+// An empty value yields the zero time.
+func parseTimeParam(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, raw)
+}
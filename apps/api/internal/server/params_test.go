@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+// discardWriter accepts and drops every point.
+type discardWriter struct{}
+
+func (discardWriter) WriteRecord(ctx context.Context, line ...string) error       { return nil }
+func (discardWriter) WritePoint(ctx context.Context, point ...*write.Point) error { return nil }
+func (discardWriter) EnableBatching()                                             {}
+func (discardWriter) Flush(ctx context.Context) error                             { return nil }
+
+func TestBackfillHistoryDateOnlyEnd(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sim := simulation.New(discardWriter{}, []*simulation.Sensor{simulation.NewSensor("Oven-01", "Temperature", 180, 1, 0)})
+	router := NewRouter(Dependencies{Simulator: sim})
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+	date := func(t time.Time) string { return t.Format(time.DateOnly) }
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTo     time.Time // zero means now
+	}{
+		{"past day included", "?from=" + date(yesterday) + "&to=" + date(yesterday) + "&step=1h", http.StatusOK, today},
+		{"today stops at now", "?from=" + date(yesterday) + "&to=" + date(today) + "&step=1h", http.StatusOK, time.Time{}},
+		{"future day rejected", "?from=" + date(yesterday) + "&to=" + date(today.AddDate(0, 0, 1)) + "&step=1h", http.StatusBadRequest, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			status, body := serveJSON(t, router, http.MethodPost, "/api/simulation/backfill-history"+tt.query, "")
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			to, err := time.Parse(time.RFC3339Nano, body["to"].(string))
+			if err != nil {
+				t.Fatalf("parse to %v: %v", body["to"], err)
+			}
+			if tt.wantTo.IsZero() {
+				if to.Before(before.Add(-time.Second)) || to.After(time.Now()) {
+					t.Errorf("to = %s, want about now", to)
+				}
+			} else if !to.Equal(tt.wantTo) {
+				t.Errorf("to = %s, want %s", to, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+)
+
+const (
+	defaultStreamLookback     = time.Minute
+	defaultStreamPollInterval = 2 * time.Second
+	defaultStreamMaxPerPoll   = 500
+	streamKeepAliveInterval   = 30 * time.Second
+	defaultStreamMaxLookback  = 24 * time.Hour
+	streamMaxLookbackEnvKey   = "STREAM_MAX_LOOKBACK"
+)
+
+// StreamMaxLookbackFromEnv reads STREAM_MAX_LOOKBACK, the longest ?lookback= the
+// reading streams honour (default 24h). Longer requests are clamped to it, since
+// the first poll holds every reading in the lookback in memory.
+func StreamMaxLookbackFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv(streamMaxLookbackEnvKey))
+	if raw == "" {
+		return defaultStreamMaxLookback
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid stream max lookback, using default", "key", streamMaxLookbackEnvKey, "value", raw, "default", defaultStreamMaxLookback.String())
+		return defaultStreamMaxLookback
+	}
+	return d
+}
+
+// readingPayload is the wire format of a single streamed sensor reading.
+type readingPayload struct {
+	Time        string  `json:"time"`
+	MachineName string  `json:"machineName"`
+	SensorName  string  `json:"sensorName"`
+	Value       float64 `json:"value"`
+	Unit        string  `json:"unit,omitempty"`
+}
+
+// newReadingPayload builds the wire form of reading, rounding its value to
+// precision decimals unless precision is fullPrecision.
+func newReadingPayload(reading influx.SensorReading, units unitLookup, precision int) readingPayload {
+	return readingPayload{
+		Time:        reading.Time.UTC().Format(time.RFC3339Nano),
+		MachineName: reading.MachineName,
+		SensorName:  reading.SensorName,
+		Value:       roundTo(reading.Value, precision),
+		Unit:        units(reading.MachineName, reading.SensorName),
+	}
+}
+
+// unitLookup returns the unit of a machine's sensor, or "" when unknown.
+type unitLookup func(machine, sensor string) string
+
+// sensorUnits resolves units from the running simulator's sensors.
+func sensorUnits(deps Dependencies) unitLookup {
+	if deps.Simulator == nil {
+		return func(string, string) string { return "" }
+	}
+	return deps.Simulator.Unit
+}
+
+// streamOptions holds the query parameters shared by the SSE and WebSocket streams.
+type streamOptions struct {
+	bucket       string
+	measurement  string
+	lookback     time.Duration
+	pollInterval time.Duration
+	machine      string
+	// machines is set instead of machine when ?machine= names several machines.
+	machines []string
+	sensor   string
+	// maxPerPoll caps the readings emitted by a single poll.
+	maxPerPoll int
+	// smooth is the moving-average period; zero streams raw values.
+	smooth time.Duration
+	// precision rounds streamed values; analytics clients get full precision
+	// unless they ask otherwise.
+	precision int
+	// requestedLookback is the lookback asked for when it exceeded the maximum
+	// and was clamped; zero otherwise.
+	requestedLookback time.Duration
+}
+
+// lookbackWarning describes a clamped lookback for the stream's warning event.
+func (o streamOptions) lookbackWarning() (apiError, bool) {
+	if o.requestedLookback == 0 {
+		return apiError{}, false
+	}
+	return apiError{
+		Code:    codeLookbackClamped,
+		Message: fmt.Sprintf("lookback reduced from %s to the maximum of %s", o.requestedLookback, o.lookback),
+		Details: gin.H{"requested": o.requestedLookback.String(), "applied": o.lookback.String()},
+	}, true
+}
+
+// parseStreamOptions reads the stream query parameters, rejecting invalid
+// values; ?bucket= is checked by validateStreamBucket. A lookback above
+// deps.StreamMaxLookback is clamped to it (non-positive means the default
+// maximum). Machines are resolved to their canonical spelling.
+func parseStreamOptions(c *gin.Context, deps Dependencies) (streamOptions, error) {
+	opts := streamOptions{
+		bucket:      c.Query("bucket"),
+		measurement: c.DefaultQuery("measurement", "sensor_data"),
+		sensor:      c.Query("sensor"),
+	}
+	machines, err := queryMachines(c)
+	if err != nil {
+		return opts, err
+	}
+	machines = canonicalMachines(c.Request.Context(), deps, machines)
+	if len(machines) == 1 {
+		opts.machine = machines[0]
+	} else {
+		opts.machines = machines
+	}
+	if opts.lookback, err = queryDuration(c, "lookback", defaultStreamLookback); err != nil {
+		return opts, err
+	}
+	maxLookback := deps.StreamMaxLookback
+	if maxLookback <= 0 {
+		maxLookback = defaultStreamMaxLookback
+	}
+	if opts.lookback > maxLookback {
+		opts.requestedLookback = opts.lookback
+		opts.lookback = maxLookback
+	}
+	if opts.pollInterval, err = queryDuration(c, "interval", defaultStreamPollInterval); err != nil {
+		return opts, err
+	}
+	if opts.maxPerPoll, err = queryInt(c, "maxPerPoll", defaultStreamMaxPerPoll, 1, 0); err != nil {
+		return opts, err
+	}
+	if opts.smooth, err = queryDuration(c, "smooth", 0); err != nil {
+		return opts, err
+	}
+	if opts.smooth > 0 && opts.smooth < time.Second {
+		return opts, errors.New("smooth must be at least 1s")
+	}
+	opts.smooth = opts.smooth.Truncate(time.Second)
+	if opts.precision, err = queryPrecision(c, fullPrecision); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// readingPoller fetches readings newer than the last one it returned.
+type readingPoller struct {
+	client      TimeSeriesClient
+	bucket      string
+	measurement string
+	filters     map[string]string
+	// machines, when set, restricts readings to any of these machines in
+	// addition to filters.
+	machines   []string
+	smooth     time.Duration
+	maxPerPoll int
+	precision  int
+	units      unitLookup
+	logger     *slog.Logger
+	start      time.Time
+	lastSent   time.Time
+	// sentAtLast holds the series already sent at lastSent. Simulator timestamps
+	// may be truncated, so later writes can share lastSent; polls therefore
+	// resume at lastSent inclusive and skip only these series.
+	sentAtLast map[string]struct{}
+}
+
+func newReadingPoller(client TimeSeriesClient, opts streamOptions, units unitLookup, logger *slog.Logger) *readingPoller {
+	p := &readingPoller{
+		client:      client,
+		bucket:      opts.bucket,
+		measurement: opts.measurement,
+		smooth:      opts.smooth,
+		maxPerPoll:  opts.maxPerPoll,
+		precision:   opts.precision,
+		units:       units,
+		logger:      logger,
+		start:       time.Now().Add(-opts.lookback),
+	}
+	p.setFilters(opts.machine, opts.sensor)
+	p.machines = opts.machines
+	return p
+}
+
+// setFilters replaces the machine/sensor filters, including a set of machines;
+// empty values clear a filter.
+func (p *readingPoller) setFilters(machine, sensor string) {
+	p.machines = nil
+	filters := map[string]string{}
+	if machine != "" {
+		filters["machine_name"] = machine
+	}
+	if sensor != "" {
+		filters["sensor_name"] = sensor
+	}
+	if len(filters) == 0 {
+		filters = nil
+	}
+	p.filters = filters
+}
+
+func (p *readingPoller) poll(ctx context.Context) ([]readingPayload, error) {
+	start := p.start
+	if !p.lastSent.IsZero() {
+		start = p.lastSent
+	}
+
+	// The limit applies to the oldest readings across all series, so the cursor
+	// never moves past a series that was cut off; the rest follow next poll.
+	// The series already sent at lastSent come back first and are skipped, so
+	// the limit is raised by their count to make every poll progress.
+	limit := p.maxPerPoll
+	if limit > 0 {
+		limit += len(p.sentAtLast)
+	}
+	var (
+		readings []influx.SensorReading
+		err      error
+	)
+	if len(p.machines) > 0 {
+		readings, err = p.client.SensorReadingsSinceForMachines(ctx, p.bucket, p.measurement, p.machines, start, p.filters, p.smooth, limit)
+	} else {
+		readings, err = p.client.SensorReadingsSinceFromBucket(ctx, p.bucket, p.measurement, start, p.filters, p.smooth, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(readings) >= limit {
+		p.logger.Warn("reading stream is behind, poll limit reached", "maxPerPoll", p.maxPerPoll, "from", start)
+	}
+
+	// Readings are ordered within each series only, so find the new cursor after
+	// filtering.
+	payloads := make([]readingPayload, 0, len(readings))
+	sent := make([]influx.SensorReading, 0, len(readings))
+	for _, reading := range readings {
+		if reading.Time.IsZero() {
+			continue
+		}
+		if reading.Time.Equal(p.lastSent) {
+			if _, ok := p.sentAtLast[seriesKey(reading)]; ok {
+				continue
+			}
+		}
+		payloads = append(payloads, newReadingPayload(reading, p.units, p.precision))
+		sent = append(sent, reading)
+	}
+
+	for _, reading := range sent {
+		switch {
+		case reading.Time.After(p.lastSent):
+			p.lastSent = reading.Time
+			p.sentAtLast = map[string]struct{}{seriesKey(reading): {}}
+		case reading.Time.Equal(p.lastSent):
+			if p.sentAtLast == nil {
+				p.sentAtLast = map[string]struct{}{}
+			}
+			p.sentAtLast[seriesKey(reading)] = struct{}{}
+		}
+	}
+	return payloads, nil
+}
+
+func seriesKey(reading influx.SensorReading) string {
+	return reading.MachineName + "\x00" + reading.SensorName
+}
+
+// validateStreamBucket answers 400 and reports false when opts names a bucket
+// missing from INFLUX_BUCKETS.
+func validateStreamBucket(c *gin.Context, client TimeSeriesClient, opts streamOptions) bool {
+	if _, err := client.ResolveBucket(opts.bucket); err != nil {
+		respondErrorDetails(c, http.StatusBadRequest, codeUnknownBucket, err.Error(), gin.H{"buckets": client.BucketNames()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+)
+
+func TestReadingPollerProgressesPastSharedTimestamp(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	sensors := []string{"Temperature", "Pressure", "Humidity", "Vibration"}
+	stub := influxtest.New()
+	for step := 0; step < 2; step++ {
+		at := now.Add(time.Duration(step-2) * time.Second)
+		for _, sensor := range sensors {
+			stub.Add(influxdb.SensorReading{Time: at, MachineName: "Machine-00", SensorName: sensor, Status: "running", Value: 1})
+		}
+	}
+	opts := streamOptions{lookback: time.Minute, maxPerPoll: 1, precision: fullPrecision}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	poller := newReadingPoller(stub, opts, func(string, string) string { return "" }, logger)
+
+	seen := map[string]int{}
+	for i := 0; i < 2*len(sensors); i++ {
+		payloads, err := poller.poll(context.Background())
+		if err != nil {
+			t.Fatalf("poll %d: %v", i, err)
+		}
+		if len(payloads) != 1 {
+			t.Fatalf("poll %d returned %d readings, want 1", i, len(payloads))
+		}
+		seen[payloads[0].Time+"/"+payloads[0].SensorName]++
+	}
+	if len(seen) != 2*len(sensors) {
+		t.Fatalf("got %d distinct readings, want %d: %v", len(seen), 2*len(sensors), seen)
+	}
+	payloads, err := poller.poll(context.Background())
+	if err != nil {
+		t.Fatalf("final poll: %v", err)
+	}
+	if len(payloads) != 0 {
+		t.Fatalf("final poll returned %v, want nothing new", payloads)
+	}
+}
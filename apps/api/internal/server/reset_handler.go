@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+// resetConfirmation must be sent as {"confirm": "RESET"} for a reset to run.
+const resetConfirmation = "RESET"
+
+// resetRangeStart and resetRangeEnd span every timestamp the simulator could
+// have written, including backdated history.
+var (
+	resetRangeStart = time.Unix(0, 0).UTC()
+	resetRangeEnd   = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// HandleReset wipes the simulator's sensor data from Influx and deletes all lots,
+// for starting demos from a clean slate. Machines are kept unless keepMachines
+// is false. The body must confirm the reset with {"confirm": "RESET"}.
+func HandleReset(c *gin.Context, deps Dependencies) {
+	logger := requestLogger(c)
+	if deps.Metadata == nil {
+		respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+		return
+	}
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+
+	var req struct {
+		Confirm      string `json:"confirm"`
+		KeepMachines *bool  `json:"keepMachines"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+		return
+	}
+	if req.Confirm != resetConfirmation {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, `confirm must be "`+resetConfirmation+`"`)
+		return
+	}
+	keepMachines := req.KeepMachines == nil || *req.KeepMachines
+
+	ctx := c.Request.Context()
+	measurement := simulation.MeasurementName()
+	points, err := deps.Influx.CountPoints(ctx, measurement, resetRangeStart, resetRangeEnd)
+	if err != nil {
+		logger.Error("count points for reset failed", "error", err)
+		respondError(c, http.StatusBadGateway, codeInfluxError, "failed to count influx points")
+		return
+	}
+	// Influx data goes first so a failure leaves the lots describing it intact.
+	if err := deps.Influx.DeleteMeasurement(ctx, measurement, resetRangeStart, resetRangeEnd); err != nil {
+		logger.Error("delete points for reset failed", "error", err)
+		respondError(c, http.StatusBadGateway, codeInfluxError, "failed to delete influx points")
+		return
+	}
+	lots, machines, err := deps.Metadata.ResetLots(ctx, keepMachines)
+	if err != nil {
+		logger.Error("reset lots failed", "error", err)
+		respondErrorDetails(c, http.StatusInternalServerError, codeInternal, "influx data was cleared but lots could not be deleted", gin.H{"points": points})
+		return
+	}
+
+	logger.Warn("simulation data reset", "points", points, "lots", lots, "machines", machines, "keepMachines", keepMachines)
+	c.JSON(http.StatusOK, gin.H{"points": points, "lots": lots, "machines": machines, "keepMachines": keepMachines})
+}
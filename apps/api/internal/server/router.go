@@ -5,15 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"math"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/llm"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/logging"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	mysqlclient "github.com/Resanso/minerva-ericsson/apps/api/internal/mysql"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/processing"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
 
 	"github.com/gin-contrib/cors"
@@ -21,33 +25,60 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxBulkMachines caps the machines accepted by one POST /api/machines/bulk.
+const maxBulkMachines = 500
+
+// defaultUtilizationRange is the utilization window when start is omitted.
+const defaultUtilizationRange = 24 * time.Hour
+
 // Dependencies groups external services required by the HTTP handlers.
 type Dependencies struct {
-	Simulator *simulation.Simulator
-	Influx    *influx.Client
-	Metadata  *metadata.Repository
-	LLM       *llm.Client
+	Simulator   *simulation.Simulator
+	Coordinator *simulation.Coordinator
+	// Completion is the sensor-down lot completion service; nil when it is
+	// disabled.
+	Completion  *processing.CompletionService
+	Influx      TimeSeriesClient
+	Metadata    MetadataStore
+	MySQLPool   *mysqlclient.Pool
+	LLM         *llm.Client
+	Logger      *slog.Logger
+	CORSOrigins []string
+	ChatCache   *ChatCache
+	// ChatPromptMaxRows caps the CSV rows passed to the chat analysis prompt; zero
+	// passes the full result.
+	ChatPromptMaxRows int
+	// ChatPromptMaxSensors caps the sensors listed in the Flux system prompt; zero
+	// lists them all.
+	ChatPromptMaxSensors int
+	// AdminToken guards /api/admin endpoints; empty disables them.
+	AdminToken string
+	// EnableGzip compresses responses for clients that accept gzip.
+	EnableGzip bool
+	// StreamMaxLookback caps ?lookback= on the reading streams; zero uses the
+	// default of 24h.
+	StreamMaxLookback time.Duration
+	// RequestTimeout bounds every request outside timeoutExcludedPaths; zero
+	// uses the default of 30s.
+	RequestTimeout time.Duration
+}
+
+func (d Dependencies) logger() *slog.Logger {
+	return logging.OrDefault(d.Logger)
 }
 
 // NewRouter creates a gin.Engine configured with routes and middleware.
 func NewRouter(deps Dependencies) *gin.Engine {
 	r := gin.Default()
 
-	corsConfig := cors.Config{
-		AllowOrigins:     []string{
-			"http://localhost:3000",
-			"https://localhost:3000",
-			"https://minerva-ericsson.vercel.app",
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-		AllowPrivateNetwork: true,
-	}
-	corsConfig.AllowWildcard = true
-	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, "Authorization")
+	corsConfig := newCORSConfig(deps.CORSOrigins)
 	r.Use(cors.New(corsConfig))
+	r.Use(requestIDMiddleware(deps.logger()))
+	if deps.EnableGzip {
+		r.Use(gzipMiddleware())
+	}
+	// After gzip, so a replaced response is compressed like any other.
+	r.Use(requestTimeoutMiddleware(deps.RequestTimeout))
 
 	r.GET("/api/hello", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "Hello from Go Gin Backend!"})
@@ -57,50 +88,139 @@ func NewRouter(deps Dependencies) *gin.Engine {
 		HandleChatQuery(c, deps)
 	})
 
+	// Aggregate health of the backing services. The LLM is reported from its
+	// circuit breaker rather than probed, so this stays cheap during an outage.
+	// The simulator's pending writes are reported with Influx, so an outage
+	// shows how many points are waiting and how many were lost.
+	r.GET("/api/health", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		healthy := true
+		components := gin.H{}
+
+		switch {
+		case deps.Influx == nil:
+			components["influx"] = gin.H{"status": "missing client"}
+			healthy = false
+		default:
+			influxStatus := gin.H{"status": "ok"}
+			if err := deps.Influx.Ping(ctx); err != nil {
+				influxStatus["status"] = "unhealthy"
+				healthy = false
+			}
+			if deps.Simulator != nil {
+				writes := deps.Simulator.WriteStats()
+				influxStatus["simulatorWrites"] = gin.H{
+					"ready":    writes.Ready,
+					"buffered": writes.Buffered,
+					"dropped":  writes.Dropped,
+				}
+			}
+			components["influx"] = influxStatus
+		}
+
+		switch {
+		case deps.Metadata == nil:
+			components["mysql"] = gin.H{"status": "missing repository"}
+			healthy = false
+		default:
+			if err := deps.Metadata.Ping(ctx); err != nil {
+				components["mysql"] = gin.H{"status": "unhealthy"}
+				healthy = false
+			} else {
+				components["mysql"] = gin.H{"status": "ok"}
+			}
+		}
+
+		if deps.LLM == nil {
+			components["llm"] = gin.H{"status": "missing client"}
+		} else {
+			breaker := deps.LLM.BreakerStatus()
+			status := "ok"
+			if breaker.State != llm.BreakerClosed {
+				status = "degraded"
+			}
+			components["llm"] = gin.H{"status": status, "breaker": breaker}
+		}
+
+		code, status := http.StatusOK, "ok"
+		if !healthy {
+			code, status = http.StatusServiceUnavailable, "unhealthy"
+		}
+		c.JSON(code, gin.H{"status": status, "components": components})
+	})
+
 	r.GET("/api/influx/ping", func(c *gin.Context) {
 		if deps.Influx == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "missing client"})
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
 			return
 		}
 		if err := deps.Influx.Ping(c.Request.Context()); err != nil {
-			log.Printf("influx ping failed: %v", err)
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy"})
+			requestLogger(c).Error("influx ping failed", "error", err)
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnhealthy, "influx ping failed")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	r.GET("/api/influx/stream", func(c *gin.Context) {
+	r.GET("/api/influx/latest", func(c *gin.Context) {
 		if deps.Influx == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "influx client unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+			return
+		}
+		// ?machine= may be repeated to fetch several machines in one query.
+		machines, err := queryMachines(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if len(machines) == 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "machine query parameter is required")
 			return
 		}
+		machines = canonicalMachines(c.Request.Context(), deps, machines)
 
-		measurement := c.DefaultQuery("measurement", "sensor_data")
-		lookback := time.Minute
-		if raw := c.Query("lookback"); raw != "" {
-			if dur, err := time.ParseDuration(raw); err == nil && dur > 0 {
-				lookback = dur
-			}
+		precision, err := queryPrecision(c, displayPrecision)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
 		}
 
-		pollInterval := 2 * time.Second
-		if raw := c.Query("interval"); raw != "" {
-			if dur, err := time.ParseDuration(raw); err == nil && dur > 0 {
-				pollInterval = dur
-			}
+		measurement := c.DefaultQuery("measurement", simulation.MeasurementName())
+		var readings []influx.SensorReading
+		if len(machines) == 1 {
+			readings, err = deps.Influx.LatestPerSensor(c.Request.Context(), measurement, machines[0])
+		} else {
+			readings, err = deps.Influx.LatestPerSensorForMachines(c.Request.Context(), measurement, machines)
+		}
+		if err != nil {
+			requestLogger(c).Error("latest sensor readings failed", "machines", machines, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to query latest sensor readings")
+			return
 		}
 
-		filters := map[string]string{}
-		if machine := c.Query("machine"); machine != "" {
-			filters["machine_name"] = machine
+		units := sensorUnits(deps)
+		payloads := make([]readingPayload, 0, len(readings))
+		for _, reading := range readings {
+			payloads = append(payloads, newReadingPayload(reading, units, precision))
 		}
-		if sensor := c.Query("sensor"); sensor != "" {
-			filters["sensor_name"] = sensor
+		c.JSON(http.StatusOK, payloads)
+	})
+
+	r.GET("/api/influx/stream", func(c *gin.Context) {
+		if deps.Influx == nil {
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+			return
 		}
-		if len(filters) == 0 {
-			filters = nil
+
+		opts, err := parseStreamOptions(c, deps)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if !validateStreamBucket(c, deps.Influx, opts) {
+			return
 		}
+		poller := newReadingPoller(deps.Influx, opts, sensorUnits(deps), requestLogger(c))
 
 		ctx := c.Request.Context()
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
@@ -108,57 +228,36 @@ func NewRouter(deps Dependencies) *gin.Engine {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Header().Set("Transfer-Encoding", "chunked")
 
-		initialStart := time.Now().Add(-lookback)
-		var lastSent time.Time
+		if warning, ok := opts.lookbackWarning(); ok {
+			c.Render(-1, sse.Event{Event: "warning", Data: warning})
+			c.Writer.Flush()
+		}
 
-		pollTicker := time.NewTicker(pollInterval)
-		keepAliveTicker := time.NewTicker(30 * time.Second)
+		pollTicker := time.NewTicker(opts.pollInterval)
+		keepAliveTicker := time.NewTicker(streamKeepAliveInterval)
 		defer pollTicker.Stop()
 		defer keepAliveTicker.Stop()
 
-		type readingPayload struct {
-			Time        string  `json:"time"`
-			MachineName string  `json:"machineName"`
-			SensorName  string  `json:"sensorName"`
-			Value       float64 `json:"value"`
-		}
-
 		c.Stream(func(w io.Writer) bool {
 			select {
 			case <-ctx.Done():
 				return false
 			case <-pollTicker.C:
-				start := initialStart
-				if !lastSent.IsZero() {
-					start = lastSent.Add(time.Nanosecond)
-				}
-
-				readings, err := deps.Influx.SensorReadingsSince(ctx, measurement, start, filters, 0)
+				payloads, err := poller.poll(ctx)
 				if err != nil {
-					log.Printf("stream sensor readings failed: %v", err)
+					requestLogger(c).Error("stream sensor readings failed", "error", err)
 					c.Render(-1, sse.Event{
 						Event: "error",
-						Data:  "failed to query sensor readings",
+						Data:  apiError{Code: codeInfluxError, Message: "failed to query sensor readings"},
 					})
 					return true
 				}
 
-				for _, reading := range readings {
-					if reading.Time.IsZero() {
-						continue
-					}
+				for _, payload := range payloads {
 					c.Render(-1, sse.Event{
 						Event: "reading",
-						Data: readingPayload{
-							Time:        reading.Time.UTC().Format(time.RFC3339Nano),
-							MachineName: reading.MachineName,
-							SensorName:  reading.SensorName,
-							Value:       reading.Value,
-						},
+						Data:  payload,
 					})
-					if reading.Time.After(lastSent) {
-						lastSent = reading.Time
-					}
 				}
 				return true
 			case <-keepAliveTicker.C:
@@ -169,128 +268,663 @@ func NewRouter(deps Dependencies) *gin.Engine {
 		})
 	})
 
+	// Evenly spaced averages for charts: ?start=&stop=&points=200, with
+	// optional machine, sensor and measurement filters.
+	r.GET("/api/influx/chart", func(c *gin.Context) {
+		HandleChart(c, deps)
+	})
+
+	r.GET("/api/influx/ws", func(c *gin.Context) {
+		HandleReadingsWebSocket(c, deps)
+	})
+
+	// Manual Flux escape hatch; guarded like chatbot queries and admin-only.
+	r.POST("/api/influx/query", requireAdmin(deps.AdminToken), func(c *gin.Context) {
+		HandleFluxQuery(c, deps)
+	})
+
 	r.GET("/api/simulation/status", func(c *gin.Context) {
 		if deps.Simulator == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"running": false})
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		precision, err := queryPrecision(c, displayPrecision)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		response := gin.H{
+			"running":              true,
+			"enabled":              deps.Simulator.Enabled(),
+			"control":              simulationControlMode(deps),
+			"interval":             deps.Simulator.Interval().String(),
+			"sensors":              roundSnapshot(deps.Simulator.Snapshot(), precision),
+			"writes":               deps.Simulator.WriteStats(),
+			"pointsWritten":        deps.Simulator.PointsWritten(),
+			"sessionPointsWritten": deps.Simulator.SessionPointsWritten(),
+			"pausedMachines":       deps.Simulator.PausedMachines(),
+		}
+		if deps.Metadata != nil {
+			count, err := deps.Metadata.CountActiveLots(c.Request.Context())
+			if err != nil {
+				requestLogger(c).Warn("count active lots failed", "error", err)
+			} else {
+				response["activeLots"] = count
+			}
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// A lighter alternative to /api/simulation/status for building navigation:
+	// machines and their sensors without live values.
+	r.GET("/api/topology", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
 			return
 		}
+		topology := deps.Simulator.Topology()
+		names := make([]string, 0, len(topology))
+		for name := range topology {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		now := time.Now()
+		machines := make([]gin.H, 0, len(names))
+		sensorCount := 0
+		for _, name := range names {
+			sensors := topology[name]
+			sensorCount += len(sensors)
+			machines = append(machines, gin.H{
+				"machineName": name,
+				"active":      deps.Simulator.MachineActive(name, now),
+				"sensorCount": len(sensors),
+				"sensors":     sensors,
+			})
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"running":  true,
-			"interval": deps.Simulator.Interval().String(),
-			"sensors":  deps.Simulator.Snapshot(),
+			"machineCount": len(machines),
+			"sensorCount":  sensorCount,
+			"machines":     machines,
 		})
 	})
 
+	// Manual simulator control. Enabling or disabling switches the coordinator to
+	// manual mode, which persists until POST /api/simulation/auto restores
+	// coordinator-driven control.
+	r.POST("/api/simulation/enable", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		if deps.Coordinator != nil {
+			deps.Coordinator.SetManualControl(true)
+		}
+		deps.Simulator.Enable()
+		c.JSON(http.StatusOK, gin.H{"enabled": deps.Simulator.Enabled(), "control": simulationControlMode(deps)})
+	})
+
+	r.POST("/api/simulation/disable", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		if deps.Coordinator != nil {
+			deps.Coordinator.SetManualControl(true)
+		}
+		deps.Simulator.Disable()
+		c.JSON(http.StatusOK, gin.H{"enabled": deps.Simulator.Enabled(), "control": simulationControlMode(deps)})
+	})
+
+	r.POST("/api/simulation/auto", func(c *gin.Context) {
+		if deps.Simulator == nil || deps.Coordinator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeCoordinatorUnavail, "simulation coordinator unavailable")
+			return
+		}
+		if err := deps.Coordinator.ResumeAutomaticControl(c.Request.Context()); err != nil {
+			requestLogger(c).Error("resume simulation coordinator failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to resume coordinator control")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": deps.Simulator.Enabled(), "control": simulationControlMode(deps)})
+	})
+
+	r.GET("/api/simulation/machines/:machine/sensors", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		precision, err := queryPrecision(c, displayPrecision)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		machine := c.Param("machine")
+		sensors, ok := deps.Simulator.SnapshotForMachine(machine)
+		if !ok {
+			respondError(c, http.StatusNotFound, codeMachineNotFound, "machine not found")
+			return
+		}
+		sensors = roundSnapshot(sensors, precision)
+		type sensorView struct {
+			simulation.Sensor
+			TicksRemaining int `json:"ticksRemaining"`
+		}
+		views := make([]sensorView, len(sensors))
+		for i := range sensors {
+			views[i] = sensorView{Sensor: sensors[i], TicksRemaining: sensors[i].TicksRemaining()}
+		}
+		c.JSON(http.StatusOK, gin.H{"machine": machine, "sensors": views})
+	})
+
+	// Take one machine offline while the rest keep running, and bring it back.
+	r.POST("/api/simulation/machines/:machine/pause", func(c *gin.Context) {
+		setMachinePaused(c, deps, true)
+	})
+
+	r.POST("/api/simulation/machines/:machine/resume", func(c *gin.Context) {
+		setMachinePaused(c, deps, false)
+	})
+
+	// Discard accumulated baseline drift from sensor aging.
+	r.POST("/api/simulation/aging/reset", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		deps.Simulator.ResetAging()
+		c.JSON(http.StatusOK, gin.H{"sensors": deps.Simulator.Snapshot()})
+	})
+
+	// Generate backdated readings for ?from= to ?to= every ?step= (default 1m)
+	// without disturbing the live simulator. A date-only ?to= includes that day.
+	r.POST("/api/simulation/backfill-history", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		from, err := queryTime(c, "from")
+		if err != nil || from.IsZero() {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "from is required and must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+		to, err := queryRangeEnd(c, "to")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		// An absent end, or a date-only one naming today, stops at now rather
+		// than at the coming midnight.
+		now := time.Now()
+		if to.IsZero() || (isDateOnly(c.Query("to")) && to.After(now) && !to.AddDate(0, 0, -1).After(now)) {
+			to = now
+		}
+		step, err := queryDuration(c, "step", time.Minute)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		if err := deps.Simulator.GenerateHistorical(c.Request.Context(), from, to, step); err != nil {
+			if errors.Is(err, simulation.ErrInvalidHistoryRange) {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+				return
+			}
+			requestLogger(c).Error("historical generation failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to generate historical data")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"from": from.UTC(), "to": to.UTC(), "step": step.String()})
+	})
+
+	// Register a simulated sensor at runtime. Omitted duration ranges use the
+	// simulator defaults.
+	r.POST("/api/simulation/sensors", func(c *gin.Context) {
+		if deps.Simulator == nil {
+			respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+			return
+		}
+		var req struct {
+			MachineName   string                     `json:"machineName"`
+			SensorName    string                     `json:"sensorName"`
+			Baseline      float64                    `json:"baseline"`
+			Drift         float64                    `json:"drift"`
+			InitialSpread float64                    `json:"initialSpread"`
+			IdleValue     *float64                   `json:"idleValue"`
+			MinValue      *float64                   `json:"minValue"`
+			MaxValue      *float64                   `json:"maxValue"`
+			Durations     *simulation.StateDurations `json:"durations"`
+			AgingRate     float64                    `json:"agingRate"`
+			Unit          string                     `json:"unit"`
+			// MaxBaselineMultiple caps values at this multiple of baseline.
+			MaxBaselineMultiple float64 `json:"maxBaselineMultiple"`
+			// NoiseModel is "uniform" or "gaussian"; empty uses the simulator's.
+			NoiseModel string `json:"noiseModel"`
+			// Measurement overrides the Influx measurement the sensor writes to.
+			Measurement string `json:"measurement"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+			return
+		}
+		if req.Baseline < 0 || req.Drift < 0 || req.InitialSpread < 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "baseline, drift and initialSpread must be non-negative")
+			return
+		}
+		if req.AgingRate <= -1 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "agingRate must be greater than -1")
+			return
+		}
+
+		var opts []simulation.SensorOption
+		if req.IdleValue != nil {
+			opts = append(opts, simulation.WithIdleValue(*req.IdleValue))
+		}
+		if req.Durations != nil {
+			opts = append(opts, simulation.WithStateDurations(*req.Durations))
+		}
+		if req.MinValue != nil {
+			opts = append(opts, simulation.WithMinValue(*req.MinValue))
+		}
+		if req.MaxValue != nil {
+			opts = append(opts, simulation.WithMaxValue(*req.MaxValue))
+		}
+		if req.AgingRate != 0 {
+			opts = append(opts, simulation.WithAging(req.AgingRate))
+		}
+		if req.Unit != "" {
+			opts = append(opts, simulation.WithUnit(req.Unit))
+		}
+		if req.MaxBaselineMultiple != 0 {
+			opts = append(opts, simulation.WithMaxBaselineMultiple(req.MaxBaselineMultiple))
+		}
+		if req.Measurement != "" {
+			opts = append(opts, simulation.WithMeasurement(req.Measurement))
+		}
+		if req.NoiseModel != "" {
+			model, err := simulation.ParseNoiseModel(req.NoiseModel)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+				return
+			}
+			opts = append(opts, simulation.WithSensorNoiseModel(model))
+		}
+		sensor := simulation.NewSensor(strings.TrimSpace(req.MachineName), strings.TrimSpace(req.SensorName), req.Baseline, req.Drift, req.InitialSpread, opts...)
+		if err := deps.Simulator.AddSensor(sensor); err != nil {
+			switch {
+			case errors.Is(err, simulation.ErrInvalidSensor), errors.Is(err, simulation.ErrInvalidDurationRange), errors.Is(err, simulation.ErrInvalidSensorBounds):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			case errors.Is(err, simulation.ErrSensorExists):
+				respondError(c, http.StatusConflict, codeSensorExists, err.Error())
+			default:
+				requestLogger(c).Error("add simulated sensor failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to add sensor")
+			}
+			return
+		}
+
+		for _, snapshot := range deps.Simulator.Snapshot() {
+			if snapshot.MachineName == sensor.MachineName && snapshot.SensorName == sensor.SensorName {
+				c.JSON(http.StatusCreated, snapshot)
+				return
+			}
+		}
+		c.JSON(http.StatusCreated, gin.H{"machineName": sensor.MachineName, "sensorName": sensor.SensorName})
+	})
+
 	r.GET("/api/mysql/ping", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "missing repository"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
 		if err := deps.Metadata.Ping(c.Request.Context()); err != nil {
-			log.Printf("mysql ping failed: %v", err)
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy"})
+			requestLogger(c).Error("mysql ping failed", "error", err)
+			respondError(c, http.StatusServiceUnavailable, codeMySQLUnhealthy, "mysql ping failed")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	r.GET("/api/mysql/stats", func(c *gin.Context) {
+		if deps.MySQLPool == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMySQLPoolUnavail, "mysql pool unavailable")
+			return
+		}
+		c.JSON(http.StatusOK, deps.MySQLPool.Stats())
+	})
+
+	admin := r.Group("/api/admin", requireAdmin(deps.AdminToken))
+
+	// Tune the MySQL pool without redeploying; omitted limits are left unchanged.
+	admin.PUT("/mysql/pool", func(c *gin.Context) {
+		if deps.MySQLPool == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMySQLPoolUnavail, "mysql pool unavailable")
+			return
+		}
+		var req struct {
+			MaxOpenConns *int `json:"maxOpenConns"`
+			MaxIdleConns *int `json:"maxIdleConns"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+			return
+		}
+		if err := deps.MySQLPool.SetLimits(req.MaxOpenConns, req.MaxIdleConns); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "limits must be non-negative and maxIdleConns must not exceed maxOpenConns")
+			return
+		}
+		stats := deps.MySQLPool.Stats()
+		requestLogger(c).Info("mysql pool limits updated", "maxOpenConns", stats.MaxOpenConns, "maxIdleConns", stats.MaxIdleConns)
+		c.JSON(http.StatusOK, stats)
+	})
+
+	admin.POST("/cleanup", func(c *gin.Context) {
+		HandleLotCleanup(c, deps)
+	})
+
+	admin.POST("/reset", func(c *gin.Context) {
+		HandleReset(c, deps)
+	})
+
+	// Pick up a rotated Influx token or switch org without a restart. Omitted
+	// fields keep their current values; the new connection is validated first.
+	admin.POST("/influx/reconnect", func(c *gin.Context) {
+		if deps.Influx == nil {
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+			return
+		}
+		var req struct {
+			Token string `json:"token"`
+			Org   string `json:"org"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+			return
+		}
+		cfg := deps.Influx.Config()
+		if token := strings.TrimSpace(req.Token); token != "" {
+			cfg.Token = token
+		}
+		if org := strings.TrimSpace(req.Org); org != "" {
+			cfg.Org = org
+		}
+		if err := deps.Influx.Reconnect(c.Request.Context(), cfg); err != nil {
+			requestLogger(c).Warn("influx reconnect failed", "org", cfg.Org, "error", err)
+			switch {
+			case errors.Is(err, influx.ErrInfluxUnauthorized), errors.Is(err, influx.ErrInfluxBucketNotFound):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			default:
+				respondError(c, http.StatusBadGateway, codeInfluxError, err.Error())
+			}
+			return
+		}
+		requestLogger(c).Info("influx reconnected", "org", cfg.Org, "bucket", cfg.Bucket)
+		c.JSON(http.StatusOK, gin.H{"org": cfg.Org, "bucket": cfg.Bucket})
+	})
+
 	r.GET("/api/lots", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"lots": []metadata.Lot{}})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
-		lots, err := deps.Metadata.ListLots(c.Request.Context())
+		opts, err := parseListLotsOptions(c)
 		if err != nil {
-			log.Printf("list lots failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list lots"})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		asCSV, err := wantsCSV(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		lots, err := deps.Metadata.ListLots(c.Request.Context(), opts)
+		if err != nil {
+			requestLogger(c).Error("list lots failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list lots")
+			return
+		}
+		if asCSV {
+			writeLotsCSV(c, lots)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"lots": lots})
 	})
 
+	r.GET("/api/lots/active/count", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		count, err := deps.Metadata.CountActiveLots(c.Request.Context())
+		if err != nil {
+			requestLogger(c).Error("count active lots failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to count active lots")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"count": count})
+	})
+
+	// Lots completed in [?start=, ?stop=); a date-only stop includes that day.
+	r.GET("/api/lots/completed", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		start, err := queryTime(c, "start")
+		if err != nil || start.IsZero() {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "start is required and must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+		stop, err := queryRangeEnd(c, "stop")
+		if err != nil || stop.IsZero() {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "stop is required and must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+		if stop.Before(start) {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "stop must not be before start")
+			return
+		}
+		lots, err := deps.Metadata.ListLotsCompletedBetween(c.Request.Context(), start, stop)
+		if err != nil {
+			requestLogger(c).Error("list completed lots failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list completed lots")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"start": start, "stop": stop, "count": len(lots), "lots": lots})
+	})
+
+	r.GET("/api/lots/:lotNumber", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		lotNumber := strings.TrimSpace(c.Param("lotNumber"))
+		if lotNumber == "" {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "lot number is required")
+			return
+		}
+		lot, err := deps.Metadata.GetLotByNumber(c.Request.Context(), lotNumber)
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrLotNotFound):
+				respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+			default:
+				requestLogger(c).Error("get lot failed", "lot", lotNumber, "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to get lot")
+			}
+			return
+		}
+		c.JSON(http.StatusOK, lot)
+	})
+
 	r.GET("/api/products", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"products": []metadata.ProductData{}})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
-		products, err := deps.Metadata.ListProductData(c.Request.Context())
+		opts, err := parseListLotsOptions(c)
 		if err != nil {
-			log.Printf("list products failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list products"})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		asCSV, err := wantsCSV(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		page, err := deps.Metadata.ListProductData(c.Request.Context(), opts)
+		if err != nil {
+			requestLogger(c).Error("list products failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list products")
+			return
+		}
+		if asCSV {
+			writeProductsCSV(c, page)
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	})
+
+	r.GET("/api/products/summary", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		filter := metadata.YieldFilter{MachineName: c.Query("machine")}
+		var err error
+		if filter.From, err = queryTime(c, "from"); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if filter.To, err = queryRangeEnd(c, "to"); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		summary, err := deps.Metadata.AggregateYield(c.Request.Context(), filter)
+		if err != nil {
+			requestLogger(c).Error("aggregate yield failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to aggregate yield")
+			return
+		}
+		c.JSON(http.StatusOK, summary)
+	})
+
+	r.GET("/api/products/:lotNumber", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		lotNumber := strings.TrimSpace(c.Param("lotNumber"))
+		if lotNumber == "" {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "lot number is required")
+			return
+		}
+		product, err := deps.Metadata.GetProductData(c.Request.Context(), lotNumber)
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrLotNotFound):
+				respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+			default:
+				requestLogger(c).Error("get product failed", "lot", lotNumber, "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to get product")
+			}
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"products": products})
+		c.JSON(http.StatusOK, product)
 	})
 
 	// (DELETE /api/products/:lotNumber) -- handler preserved later in file; avoid duplicate registration.
 
 	r.POST("/api/products", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metadata repository unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		idem, handled := startIdempotentRequest(c, deps.Metadata, "POST /api/products")
+		if handled {
 			return
 		}
 		var req struct {
-			LotNumber       string          `json:"lotNumber"`
-			MachineName     string          `json:"machineName"`
-			ActiveMachineID *string         `json:"activeMachineId"`
-			Averages        json.RawMessage `json:"averages"`
-			OperationHour   *string         `json:"operationHour"`
-			GoodProduct     *int            `json:"goodProduct"`
-			DefectProduct   *int            `json:"defectProduct"`
-			Conclusion      *string         `json:"conclusion"`
-			IsConclusion    *bool           `json:"isConclusion"`
+			LotNumber          string                       `json:"lotNumber"`
+			MachineName        string                       `json:"machineName"`
+			ActiveMachineID    *string                      `json:"activeMachineId"`
+			Averages           json.RawMessage              `json:"averages"`
+			OperationHour      *string                      `json:"operationHour"`
+			GoodProduct        *int                         `json:"goodProduct"`
+			DefectProduct      *int                         `json:"defectProduct"`
+			Conclusion         *string                      `json:"conclusion"`
+			IsConclusion       *bool                        `json:"isConclusion"`
+			ConclusionCategory *metadata.ConclusionCategory `json:"conclusionCategory"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("invalid product payload: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			requestLogger(c).Error("invalid product payload", "error", err)
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
 			return
 		}
 
 		product, err := deps.Metadata.UpsertLotProduct(c.Request.Context(), metadata.ProductInput{
-			LotNumber:       req.LotNumber,
-			MachineName:     req.MachineName,
-			ActiveMachineID: req.ActiveMachineID,
-			Averages:        req.Averages,
-			OperationHour:   req.OperationHour,
-			GoodProduct:     req.GoodProduct,
-			DefectProduct:   req.DefectProduct,
-				Conclusion:      req.Conclusion,
-				IsConclusion:    req.IsConclusion,
+			LotNumber:          req.LotNumber,
+			MachineName:        req.MachineName,
+			ActiveMachineID:    req.ActiveMachineID,
+			Averages:           req.Averages,
+			OperationHour:      req.OperationHour,
+			GoodProduct:        req.GoodProduct,
+			DefectProduct:      req.DefectProduct,
+			Conclusion:         req.Conclusion,
+			IsConclusion:       req.IsConclusion,
+			ConclusionCategory: req.ConclusionCategory,
 		})
 		if err != nil {
 			switch {
-			case errors.Is(err, metadata.ErrLotNumberRequired):
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, metadata.ErrLotNumberRequired), errors.Is(err, metadata.ErrInvalidProductData):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			case errors.Is(err, metadata.ErrLotDeleted):
+				respondError(c, http.StatusConflict, codeLotDeleted, err.Error())
 			default:
-				log.Printf("upsert product failed: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upsert product"})
+				requestLogger(c).Error("upsert product failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to upsert product")
 			}
 			return
 		}
 
-		c.JSON(http.StatusOK, product)
+		idem.respond(c, http.StatusOK, product)
 	})
 
-	// DELETE a product (lot) by its lot number
+	// DELETE a product (lot) by its lot number. Lots are soft-deleted and can be
+	// restored unless ?hard=true is passed.
 	r.DELETE("/api/products/:lotNumber", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metadata repository unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
 
 		lot := c.Param("lotNumber")
 		if strings.TrimSpace(lot) == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "lot number is required"})
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "lot number is required")
 			return
 		}
 
-		if err := deps.Metadata.DeleteLotByNumber(c.Request.Context(), lot); err != nil {
+		hard := false
+		if raw := c.Query("hard"); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "hard must be a boolean")
+				return
+			}
+			hard = parsed
+		}
+
+		remove := deps.Metadata.DeleteLotByNumber
+		if hard {
+			remove = deps.Metadata.PurgeLotByNumber
+		}
+		if err := remove(c.Request.Context(), lot); err != nil {
 			switch {
 			case errors.Is(err, metadata.ErrLotNotFound):
-				c.JSON(http.StatusNotFound, gin.H{"error": "lot not found"})
+				respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
 			default:
-				log.Printf("delete lot failed: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete lot"})
+				requestLogger(c).Error("delete lot failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to delete lot")
 			}
 			return
 		}
@@ -298,9 +932,41 @@ func NewRouter(deps Dependencies) *gin.Engine {
 		c.Status(http.StatusNoContent)
 	})
 
+	r.POST("/api/products/:lotNumber/restore", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+
+		lot := strings.TrimSpace(c.Param("lotNumber"))
+		if lot == "" {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "lot number is required")
+			return
+		}
+
+		if err := deps.Metadata.RestoreLot(c.Request.Context(), lot); err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrLotNotFound):
+				respondError(c, http.StatusNotFound, codeLotNotFound, "deleted lot not found")
+			default:
+				requestLogger(c).Error("restore lot failed", "lot", lot, "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to restore lot")
+			}
+			return
+		}
+
+		product, err := deps.Metadata.GetProductData(c.Request.Context(), lot)
+		if err != nil {
+			requestLogger(c).Error("get restored product failed", "lot", lot, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to load restored lot")
+			return
+		}
+		c.JSON(http.StatusOK, product)
+	})
+
 	r.POST("/api/lots", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metadata repository unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
 		var req struct {
@@ -308,7 +974,7 @@ func NewRouter(deps Dependencies) *gin.Engine {
 			MachineName string `json:"machineName"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
 			return
 		}
 		lot, err := deps.Metadata.CreateLot(c.Request.Context(), metadata.CreateLotInput{
@@ -318,12 +984,14 @@ func NewRouter(deps Dependencies) *gin.Engine {
 		if err != nil {
 			switch {
 			case errors.Is(err, metadata.ErrLotNumberRequired):
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
 			case errors.Is(err, metadata.ErrLotExists):
-				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				respondError(c, http.StatusConflict, codeLotExists, err.Error())
+			case errors.Is(err, metadata.ErrLotDeleted):
+				respondError(c, http.StatusConflict, codeLotDeleted, err.Error())
 			default:
-				log.Printf("create lot failed: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create lot"})
+				requestLogger(c).Error("create lot failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to create lot")
 			}
 			return
 		}
@@ -332,13 +1000,13 @@ func NewRouter(deps Dependencies) *gin.Engine {
 
 	r.GET("/api/machines", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"machines": []metadata.Machine{}})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
-		machines, err := deps.Metadata.ListMachines(c.Request.Context())
+		machines, err := deps.Metadata.ListMachines(c.Request.Context(), c.Query("category"))
 		if err != nil {
-			log.Printf("list machines failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list machines"})
+			requestLogger(c).Error("list machines failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list machines")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"machines": machines})
@@ -346,125 +1014,370 @@ func NewRouter(deps Dependencies) *gin.Engine {
 
 	r.POST("/api/machines", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metadata repository unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
 		var req struct {
 			MachineName string `json:"machineName"`
 			Location    string `json:"location"`
+			Category    string `json:"category"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
 			return
 		}
 		created, err := deps.Metadata.CreateMachine(c.Request.Context(), metadata.CreateMachineInput{
 			MachineName: req.MachineName,
 			Location:    req.Location,
+			Category:    req.Category,
 		})
 		if err != nil {
 			if errors.Is(err, metadata.ErrMachineNameRequired) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+				return
+			}
+			if errors.Is(err, metadata.ErrMachineExists) {
+				respondError(c, http.StatusConflict, codeMachineExists, err.Error())
 				return
 			}
-			log.Printf("create machine failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create machine"})
+			requestLogger(c).Error("create machine failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to create machine")
 			return
 		}
 		c.JSON(http.StatusCreated, created)
 	})
 
-	// Backfill computed product fields (operation_hour, averages_json) for completed lots
-	r.POST("/api/lots/backfill", func(c *gin.Context) {
+	r.POST("/api/machines/bulk", func(c *gin.Context) {
 		if deps.Metadata == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metadata repository unavailable"})
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
 			return
 		}
-		if deps.Influx == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "influx client unavailable"})
+		var req struct {
+			Machines []struct {
+				MachineName string `json:"machineName"`
+				Location    string `json:"location"`
+				Category    string `json:"category"`
+			} `json:"machines"`
+			SkipExisting bool `json:"skipExisting"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
 			return
 		}
+		if len(req.Machines) == 0 || len(req.Machines) > maxBulkMachines {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("machines must contain between 1 and %d entries", maxBulkMachines))
+			return
+		}
+		inputs := make([]metadata.CreateMachineInput, len(req.Machines))
+		for i, m := range req.Machines {
+			inputs[i] = metadata.CreateMachineInput{MachineName: m.MachineName, Location: m.Location, Category: m.Category}
+		}
+		created, err := deps.Metadata.CreateMachinesBatch(c.Request.Context(), inputs, req.SkipExisting)
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrMachineNameRequired):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			case errors.Is(err, metadata.ErrMachineExists):
+				respondError(c, http.StatusConflict, codeMachineExists, err.Error())
+			default:
+				requestLogger(c).Error("bulk create machines failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to create machines")
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"machines": created, "created": len(created), "skipped": len(inputs) - len(created)})
+	})
 
-		ctx := c.Request.Context()
-		candidates, err := deps.Metadata.ListCompletedLotsMissingData(ctx)
+	r.GET("/api/machines/categories", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		categories, err := deps.Metadata.ListMachineCategories(c.Request.Context())
 		if err != nil {
-			log.Printf("list backfill candidates failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list backfill candidates"})
+			requestLogger(c).Error("list machine categories failed", "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list machine categories")
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"categories": categories})
+	})
 
-		measurement := c.DefaultQuery("measurement", "sensor_data")
-		updated := []string{}
-		for _, cand := range candidates {
-			if !cand.CompletedAt.Valid {
-				continue
-			}
-			start := cand.StartedAt.UTC()
-			end := cand.CompletedAt.Time.UTC()
-			if end.Before(start) {
-				// skip invalid ranges
-				continue
+	r.GET("/api/machines/:name", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		machine, err := deps.Metadata.GetMachineByName(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrMachineNameRequired):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			case errors.Is(err, metadata.ErrMachineNotFound):
+				respondError(c, http.StatusNotFound, codeMachineNotFound, err.Error())
+			default:
+				requestLogger(c).Error("get machine failed", "machine", c.Param("name"), "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to load machine")
 			}
+			return
+		}
+		c.JSON(http.StatusOK, machine)
+	})
+
+	// Share of status samples in "running" over ?start=&stop= (default the
+	// last 24 hours). Machines without samples report 0% with noData set.
+	r.GET("/api/machines/:name/utilization", func(c *gin.Context) {
+		if deps.Influx == nil {
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+			return
+		}
+		name := canonicalMachine(c.Request.Context(), deps, c.Param("name"))
+		start, stop, err := queryTimeRange(c, defaultUtilizationRange)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		measurement := c.DefaultQuery("measurement", simulation.MeasurementName())
+		usage, err := deps.Influx.MachineUtilization(c.Request.Context(), measurement, name, start, stop)
+		if err != nil {
+			requestLogger(c).Error("machine utilization failed", "machine", name, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to query machine utilization")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"machineName":    name,
+			"start":          start.UTC().Format(time.RFC3339),
+			"stop":           stop.UTC().Format(time.RFC3339),
+			"runningPercent": usage.Percent(usage.Running),
+			"downPercent":    usage.Percent(usage.Down),
+			"runningSamples": usage.Running,
+			"downSamples":    usage.Down,
+			"totalSamples":   usage.Total,
+			"statusCounts":   usage.Counts,
+			"noData":         usage.Total == 0,
+		})
+	})
 
-			// compute operation hours (one decimal place)
-			hours := end.Sub(start).Hours()
-			hours = math.Round(hours*10) / 10
-			opStr := fmt.Sprintf("%.1f", hours)
-
-			// build flux query to compute mean per sensor_name
-			flux := fmt.Sprintf(`from(bucket: %q)
-|> range(start: time(v: %q), stop: time(v: %q))
-|> filter(fn: (r) => r["_measurement"] == %q)
-|> filter(fn: (r) => r["_field"] == "value")
-|> filter(fn: (r) => r[%q] == %q)
-|> group(columns: ["sensor_name"])
-|> mean()
-|> keep(columns: ["sensor_name", "_value"])`, deps.Influx.Config().Bucket, start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano), measurement, "machine_name", cand.MachineName)
-
-			result, qerr := deps.Influx.QueryAPI().Query(ctx, flux)
-			if qerr != nil {
-				log.Printf("influx query failed for lot %s: %v", cand.LotNumber, qerr)
-				continue
+	r.GET("/api/machines/:name/gaps", func(c *gin.Context) {
+		HandleMachineGaps(c, deps)
+	})
+
+	r.GET("/api/machines/:name/lots", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		opts, err := parseListLotsOptions(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		lots, err := deps.Metadata.ListLotsByMachine(c.Request.Context(), c.Param("name"), opts)
+		if err != nil {
+			if errors.Is(err, metadata.ErrMachineNameRequired) {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+				return
 			}
+			requestLogger(c).Error("list machine lots failed", "machine", c.Param("name"), "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to list lots")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"lots": lots})
+	})
 
-			averages := map[string]float64{}
-			for result.Next() {
-				rec := result.Record()
-				// sensor name
-				sName := fmt.Sprint(rec.ValueByKey("sensor_name"))
-				// value is in _value
-				valAny := rec.Value()
-				var v float64
-				switch t := valAny.(type) {
-				case float64:
-					v = t
-				case int64:
-					v = float64(t)
-				case uint64:
-					v = float64(t)
-				default:
-					continue
-				}
-				if sName != "" {
-					averages[sName] = v
-				}
+	// Create a lot together with its initial product data in one transaction.
+	r.POST("/api/lots/full", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		var req struct {
+			LotNumber          string                       `json:"lotNumber"`
+			MachineName        string                       `json:"machineName"`
+			ActiveMachineID    *string                      `json:"activeMachineId"`
+			Averages           json.RawMessage              `json:"averages"`
+			OperationHour      *string                      `json:"operationHour"`
+			GoodProduct        *int                         `json:"goodProduct"`
+			DefectProduct      *int                         `json:"defectProduct"`
+			Conclusion         *string                      `json:"conclusion"`
+			IsConclusion       *bool                        `json:"isConclusion"`
+			ConclusionCategory *metadata.ConclusionCategory `json:"conclusionCategory"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidPayload, "invalid payload")
+			return
+		}
+
+		product, err := deps.Metadata.CreateLotWithProduct(c.Request.Context(),
+			metadata.CreateLotInput{LotNumber: req.LotNumber, MachineName: req.MachineName},
+			metadata.ProductInput{
+				ActiveMachineID:    req.ActiveMachineID,
+				Averages:           req.Averages,
+				OperationHour:      req.OperationHour,
+				GoodProduct:        req.GoodProduct,
+				DefectProduct:      req.DefectProduct,
+				Conclusion:         req.Conclusion,
+				IsConclusion:       req.IsConclusion,
+				ConclusionCategory: req.ConclusionCategory,
+			})
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrLotNumberRequired), errors.Is(err, metadata.ErrInvalidProductData):
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			case errors.Is(err, metadata.ErrLotExists):
+				respondError(c, http.StatusConflict, codeLotExists, err.Error())
+			case errors.Is(err, metadata.ErrLotDeleted):
+				respondError(c, http.StatusConflict, codeLotDeleted, err.Error())
+			default:
+				requestLogger(c).Error("create lot with product failed", "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to create lot")
 			}
-			if err := result.Err(); err != nil {
-				log.Printf("iterate influx result failed for lot %s: %v", cand.LotNumber, err)
+			return
+		}
+		c.JSON(http.StatusCreated, product)
+	})
+
+	r.GET("/api/lots/:lotNumber/timeline", func(c *gin.Context) {
+		HandleLotTimeline(c, deps)
+	})
+
+	r.GET("/api/lots/:lotNumber/readings.csv", func(c *gin.Context) {
+		HandleLotReadingsCSV(c, deps)
+	})
+
+	r.POST("/api/lots/:lotNumber/evaluate", func(c *gin.Context) {
+		HandleLotEvaluate(c, deps)
+	})
+
+	// Rebuild a completed lot's summary from its Influx history, keeping any
+	// product counts and conclusion already stored in it.
+	r.POST("/api/lots/:lotNumber/resummarize", func(c *gin.Context) {
+		if deps.Metadata == nil {
+			respondError(c, http.StatusServiceUnavailable, codeMetadataUnavail, "metadata repository unavailable")
+			return
+		}
+		if deps.Influx == nil {
+			respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+			return
+		}
+		logger := requestLogger(c)
+		lotNumber := c.Param("lotNumber")
+		lot, err := deps.Metadata.GetLotByNumber(c.Request.Context(), lotNumber)
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrLotNotFound):
+				respondError(c, http.StatusNotFound, codeLotNotFound, "lot not found")
+			default:
+				logger.Error("get lot failed", "lot", lotNumber, "error", err)
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to get lot")
 			}
+			return
+		}
+		if lot.Status != metadata.LotStatusCompleted || !lot.CompletedAt.Valid {
+			respondErrorDetails(c, http.StatusConflict, codeLotNotCompleted, "only completed lots can be resummarized", gin.H{"status": lot.Status})
+			return
+		}
 
-			// marshal averages to JSON
-			avgJSON, _ := json.Marshal(averages)
-			avgStr := string(avgJSON)
+		summary, err := processing.SummarizeLot(c.Request.Context(), deps.Influx, simulation.MeasurementName(), lot, 0)
+		if err != nil {
+			logger.Error("resummarize lot failed", "lot", lotNumber, "error", err)
+			respondError(c, http.StatusBadGateway, codeInfluxError, "failed to query lot history")
+			return
+		}
+		if previous, err := lot.Summary(); err == nil && previous != nil {
+			summary.GoodProduct = previous.GoodProduct
+			summary.DefectProduct = previous.DefectProduct
+			summary.Conclusion = previous.Conclusion
+		}
 
-			if err := deps.Metadata.UpdateLotComputedFields(ctx, cand.ID, &opStr, &avgStr); err != nil {
-				log.Printf("update lot computed failed for lot %s: %v", cand.LotNumber, err)
-				continue
+		if err := deps.Metadata.ReplaceLotSummary(c.Request.Context(), lot.ID, summary); err != nil {
+			if errors.Is(err, metadata.ErrLotNotCompleted) {
+				respondError(c, http.StatusConflict, codeLotNotCompleted, err.Error())
+				return
 			}
-			updated = append(updated, cand.LotNumber)
+			logger.Error("store lot summary failed", "lot", lotNumber, "error", err)
+			respondError(c, http.StatusInternalServerError, codeInternal, "failed to store lot summary")
+			return
 		}
+		logger.Info("lot summary regenerated", "lot", lotNumber, "sensors", len(summary.Sensors))
+		c.JSON(http.StatusOK, gin.H{"lotNumber": lot.LotNumber, "summary": summary})
+	})
 
-		c.JSON(http.StatusOK, gin.H{"updated": updated})
+	// Backfill computed product fields (operation_hour, averages_json) for completed lots
+	r.POST("/api/lots/backfill", func(c *gin.Context) {
+		HandleLotBackfill(c, deps)
 	})
 
 	return r
 }
+
+// setMachinePaused pauses or resumes the machine named in the path and replies
+// with the machines now paused.
+func setMachinePaused(c *gin.Context, deps Dependencies, paused bool) {
+	if deps.Simulator == nil {
+		respondError(c, http.StatusServiceUnavailable, codeSimulatorUnavail, "simulator unavailable")
+		return
+	}
+	machine := c.Param("machine")
+	set := deps.Simulator.ResumeMachine
+	if paused {
+		set = deps.Simulator.PauseMachine
+	}
+	if err := set(machine); err != nil {
+		if errors.Is(err, simulation.ErrUnknownMachine) {
+			respondError(c, http.StatusNotFound, codeMachineNotFound, "machine not found")
+			return
+		}
+		requestLogger(c).Error("set machine paused failed", "machine", machine, "error", err)
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to update machine")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"machine":        machine,
+		"paused":         paused,
+		"pausedMachines": deps.Simulator.PausedMachines(),
+	})
+}
+
+// roundSnapshot rounds the values of snapshot, which is already a copy of the
+// simulator's state, to precision decimals.
+func roundSnapshot(snapshot []simulation.Sensor, precision int) []simulation.Sensor {
+	for i := range snapshot {
+		snapshot[i].CurrentValue = roundTo(snapshot[i].CurrentValue, precision)
+		snapshot[i].EffectiveBaseline = roundTo(snapshot[i].EffectiveBaseline, precision)
+	}
+	return snapshot
+}
+
+// simulationControlMode reports who currently drives the simulator's enabled state.
+func simulationControlMode(deps Dependencies) string {
+	if deps.Coordinator == nil || deps.Coordinator.ManualControl() {
+		return "manual"
+	}
+	return "coordinator"
+}
+
+// parseListLotsOptions reads the ?status=, ?limit= and ?offset= lot list parameters.
+func parseListLotsOptions(c *gin.Context) (metadata.ListLotsOptions, error) {
+	var opts metadata.ListLotsOptions
+	switch status := metadata.LotStatus(strings.ToLower(strings.TrimSpace(c.Query("status")))); status {
+	case "", metadata.LotStatusProcessing, metadata.LotStatusCompleted:
+		opts.Status = status
+	default:
+		return opts, fmt.Errorf("status must be %q or %q", metadata.LotStatusProcessing, metadata.LotStatusCompleted)
+	}
+	if raw := c.Query("conclusionCategory"); raw != "" {
+		category, err := metadata.ParseConclusionCategory(raw)
+		if err != nil {
+			return opts, errors.New("conclusionCategory must be pass, fail, rework or none")
+		}
+		opts.ConclusionCategory = category
+	}
+	var err error
+	if opts.Limit, err = queryInt(c, "limit", 0, 0, 0); err != nil {
+		return opts, err
+	}
+	if opts.Offset, err = queryInt(c, "offset", 0, 0, 0); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
+)
+
+const (
+	// defaultGapRange is the gap window when start is omitted.
+	defaultGapRange = 24 * time.Hour
+	// defaultGapInterval is the expected reading interval when the simulator is
+	// not running to say otherwise.
+	defaultGapInterval = time.Minute
+	// defaultGapFactor is how many expected intervals a span must exceed to
+	// count as a gap, leaving room for write jitter and slow batches.
+	defaultGapFactor = 3
+)
+
+type gapView struct {
+	Start           string  `json:"start"`
+	End             string  `json:"end"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+type sensorGapsView struct {
+	SensorName   string    `json:"sensorName"`
+	FirstReading *string   `json:"firstReading"`
+	LastReading  *string   `json:"lastReading"`
+	Gaps         []gapView `json:"gaps"`
+	GapSeconds   float64   `json:"gapSeconds"`
+	// AvailabilityPercent is the share of the range not covered by gaps.
+	AvailabilityPercent float64 `json:"availabilityPercent"`
+}
+
+// HandleMachineGaps reports, per sensor of a machine, the spans within
+// ?start=&stop= (default the last 24 hours) where no readings arrived for longer
+// than ?factor= (default 3) times ?expectedInterval=. The expected interval
+// defaults to the simulator's longest gap between a sensor's readings, or
+// defaultGapInterval without a simulator. Simulated sensors with no readings at
+// all in the range are listed with a single gap covering it.
+func HandleMachineGaps(c *gin.Context, deps Dependencies) {
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+	ctx := c.Request.Context()
+	name := canonicalMachine(ctx, deps, c.Param("name"))
+	start, stop, err := queryTimeRange(c, defaultGapRange)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	interval := defaultGapInterval
+	if deps.Simulator != nil {
+		interval = deps.Simulator.MaxReadingInterval()
+	}
+	if interval, err = queryDuration(c, "expectedInterval", interval); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	factor, err := queryInt(c, "factor", defaultGapFactor, 1, 0)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	threshold := interval * time.Duration(factor)
+
+	measurement := c.DefaultQuery("measurement", simulation.MeasurementName())
+	sensors, err := deps.Influx.SensorGaps(ctx, measurement, name, start, stop, threshold)
+	if err != nil {
+		requestLogger(c).Error("sensor gaps failed", "machine", name, "error", err)
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to query sensor gaps")
+		return
+	}
+
+	// Gaps end at now for a range reaching into the future, so availability is
+	// measured over the part of the range that has happened.
+	end := stop
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	if deps.Simulator != nil {
+		sensors = appendSilentSensors(sensors, deps.Simulator.Topology()[name], start, end)
+	}
+	views := make([]sensorGapsView, len(sensors))
+	for i, sensor := range sensors {
+		views[i] = newSensorGapsView(sensor, end.Sub(start))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"machineName":      name,
+		"start":            start.UTC().Format(time.RFC3339),
+		"stop":             stop.UTC().Format(time.RFC3339),
+		"expectedInterval": interval.String(),
+		"threshold":        threshold.String(),
+		"sensors":          views,
+	})
+}
+
+// appendSilentSensors adds a gap over [start, end) for every sensor in metas
+// that has no entry in sensors.
+func appendSilentSensors(sensors []influx.SensorGaps, metas []simulation.SensorMeta, start, end time.Time) []influx.SensorGaps {
+	reported := make(map[string]struct{}, len(sensors))
+	for _, sensor := range sensors {
+		reported[sensor.SensorName] = struct{}{}
+	}
+	for _, meta := range metas {
+		if _, ok := reported[meta.SensorName]; ok || !start.Before(end) {
+			continue
+		}
+		sensors = append(sensors, influx.SensorGaps{
+			SensorName: meta.SensorName,
+			Gaps:       []influx.Gap{{Start: start, End: end}},
+		})
+	}
+	return sensors
+}
+
+func newSensorGapsView(sensor influx.SensorGaps, span time.Duration) sensorGapsView {
+	view := sensorGapsView{SensorName: sensor.SensorName, Gaps: make([]gapView, len(sensor.Gaps))}
+	if !sensor.First.IsZero() {
+		first := sensor.First.UTC().Format(time.RFC3339Nano)
+		last := sensor.Last.UTC().Format(time.RFC3339Nano)
+		view.FirstReading, view.LastReading = &first, &last
+	}
+	var total time.Duration
+	for i, gap := range sensor.Gaps {
+		total += gap.Duration()
+		view.Gaps[i] = gapView{
+			Start:           gap.Start.UTC().Format(time.RFC3339Nano),
+			End:             gap.End.UTC().Format(time.RFC3339Nano),
+			DurationSeconds: gap.Duration().Seconds(),
+		}
+	}
+	view.GapSeconds = total.Seconds()
+	if span > 0 {
+		view.AvailabilityPercent = max(0, 100*(1-float64(total)/float64(span)))
+	}
+	return view
+}
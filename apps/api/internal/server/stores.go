@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/processing"
+)
+
+// MetadataStore is the lot, machine and product storage the handlers use.
+// *metadata.Repository implements it against MySQL; metadatatest.Store is an
+// in-memory fake for handler tests.
+type MetadataStore interface {
+	Ping(ctx context.Context) error
+
+	CreateLot(ctx context.Context, input metadata.CreateLotInput) (metadata.Lot, error)
+	CreateLotWithProduct(ctx context.Context, lot metadata.CreateLotInput, product metadata.ProductInput) (metadata.ProductData, error)
+	GetLotByNumber(ctx context.Context, lotNumber string) (metadata.Lot, error)
+	ListLots(ctx context.Context, opts metadata.ListLotsOptions) ([]metadata.Lot, error)
+	ListLotsByMachine(ctx context.Context, machineName string, opts metadata.ListLotsOptions) ([]metadata.Lot, error)
+	ListLotsCompletedBetween(ctx context.Context, start, stop time.Time) ([]metadata.Lot, error)
+	CountActiveLots(ctx context.Context) (int, error)
+	ReplaceLotSummary(ctx context.Context, lotID int64, summary metadata.LotSummary) error
+	UpdateLotComputedFields(ctx context.Context, id int64, opHour *string, averagesJSON *string) error
+	UpdateLotSensorRanges(ctx context.Context, id int64, ranges map[string]metadata.SensorRange) error
+	MarkLotRangesChecked(ctx context.Context, id int64) error
+	ListCompletedLotsMissingData(ctx context.Context) ([]metadata.BackfillCandidate, error)
+	DeleteLotByNumber(ctx context.Context, lotNumber string) error
+	PurgeLotByNumber(ctx context.Context, lotNumber string) error
+	RestoreLot(ctx context.Context, lotNumber string) error
+
+	ListCompletedLotsBefore(ctx context.Context, cutoff time.Time) ([]metadata.Lot, error)
+	DeleteCompletedLotsBefore(ctx context.Context, cutoff time.Time) (int, error)
+	RetainedLotOverlaps(ctx context.Context, machineName string, start, stop, cutoff time.Time) (bool, error)
+	ResetLots(ctx context.Context, keepMachines bool) (lots, machines int, err error)
+
+	UpsertLotProduct(ctx context.Context, input metadata.ProductInput) (metadata.ProductData, error)
+	GetProductData(ctx context.Context, lotNumber string) (metadata.ProductData, error)
+	ListProductData(ctx context.Context, opts metadata.ListLotsOptions) (metadata.ProductPage, error)
+	AggregateYield(ctx context.Context, filter metadata.YieldFilter) (metadata.YieldSummary, error)
+
+	CreateMachine(ctx context.Context, input metadata.CreateMachineInput) (metadata.Machine, error)
+	CreateMachinesBatch(ctx context.Context, inputs []metadata.CreateMachineInput, skipExisting bool) ([]metadata.Machine, error)
+	GetMachineByName(ctx context.Context, name string) (metadata.Machine, error)
+	ListMachines(ctx context.Context, category string) ([]metadata.Machine, error)
+	ListMachineCategories(ctx context.Context) ([]metadata.MachineCategoryCount, error)
+
+	LookupIdempotentResponse(ctx context.Context, scope, key, requestHash string, ttl time.Duration) (metadata.IdempotentResponse, bool, error)
+	SaveIdempotentResponse(ctx context.Context, scope, key string, resp metadata.IdempotentResponse, ttl time.Duration) error
+}
+
+var _ MetadataStore = (*metadata.Repository)(nil)
+
+// TimeSeriesClient is the InfluxDB surface the handlers use: sensor history
+// queries, the raw Flux endpoints, bucket lookup and the admin operations.
+// *influx.Client implements it; influxtest.Stub serves canned readings for
+// handler tests.
+type TimeSeriesClient interface {
+	processing.ReadingsSource
+
+	Ping(ctx context.Context) error
+	Config() influx.Config
+	Reconnect(ctx context.Context, cfg influx.Config) error
+	ResolveBucket(name string) (string, error)
+	BucketNames() []string
+
+	SensorReadingsSinceFromBucket(ctx context.Context, bucketName, measurement string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]influx.SensorReading, error)
+	SensorReadingsSinceForMachines(ctx context.Context, bucketName, measurement string, machines []string, start time.Time, filters map[string]string, smooth time.Duration, limit int) ([]influx.SensorReading, error)
+	LatestPerSensor(ctx context.Context, measurement, machineName string) ([]influx.SensorReading, error)
+	LatestPerSensorForMachines(ctx context.Context, measurement string, machines []string) ([]influx.SensorReading, error)
+	MachineTrace(ctx context.Context, measurement, machineName string, start, stop time.Time, limit int) ([]influx.SensorReading, error)
+	SampledReadings(ctx context.Context, measurement string, start, stop time.Time, filters map[string]string, buckets int) ([]influx.SensorReading, error)
+	MeanBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (map[string]float64, error)
+	MinMaxBySensorFromBucket(ctx context.Context, bucketName, measurement, machineName string, start, stop time.Time) (mins, maxs map[string]float64, err error)
+	StatusChanges(ctx context.Context, measurement, machineName string, start, stop time.Time) ([]influx.StatusChange, error)
+	MachineUtilization(ctx context.Context, measurement, machineName string, start, stop time.Time) (influx.Utilization, error)
+	SensorGaps(ctx context.Context, measurement, machineName string, start, stop time.Time, threshold time.Duration) ([]influx.SensorGaps, error)
+
+	QueryRaw(ctx context.Context, flux string) (string, error)
+	QueryRecords(ctx context.Context, flux string, limit int) ([]map[string]any, error)
+
+	CountPoints(ctx context.Context, measurement string, start, stop time.Time) (int64, error)
+	CountMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) (int64, error)
+	DeleteMeasurement(ctx context.Context, measurement string, start, stop time.Time) error
+	DeleteMachinePoints(ctx context.Context, measurement, machineName string, start, stop time.Time) error
+}
+
+var _ TimeSeriesClient = (*influx.Client)(nil)
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb/influxtest"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata/metadatatest"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	_ MetadataStore    = (*metadatatest.Store)(nil)
+	_ TimeSeriesClient = (*influxtest.Stub)(nil)
+)
+
+// serveJSON sends method path with body to router and decodes the JSON reply.
+func serveJSON(t *testing.T, router http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var decoded map[string]any
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code, decoded
+}
+
+// errorCode returns the code of an error reply.
+func errorCode(body map[string]any) string {
+	apiErr, _ := body["error"].(map[string]any)
+	code, _ := apiErr["code"].(string)
+	return code
+}
+
+func TestLotHandlersWithMemoryStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := NewRouter(Dependencies{Metadata: metadatatest.New()})
+
+	// The steps share the store, so each builds on the ones before it.
+	steps := []struct {
+		name         string
+		method, path string
+		body         string
+		wantStatus   int
+		wantCode     string
+		check        func(t *testing.T, body map[string]any)
+	}{
+		{
+			name: "create", method: http.MethodPost, path: "/api/lots",
+			body:       `{"lotNumber":"LOT-1","machineName":"Oven-01"}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, body map[string]any) {
+				if body["lotNumber"] != "LOT-1" || body["status"] != "processing" {
+					t.Errorf("created lot = %v", body)
+				}
+			},
+		},
+		{
+			name: "create duplicate", method: http.MethodPost, path: "/api/lots",
+			body:       `{"lotNumber":"LOT-1"}`,
+			wantStatus: http.StatusConflict, wantCode: codeLotExists,
+		},
+		{
+			name: "create without number", method: http.MethodPost, path: "/api/lots",
+			body:       `{"lotNumber":"  "}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidRequest,
+		},
+		{
+			name: "create with bad json", method: http.MethodPost, path: "/api/lots",
+			body:       `{"lotNumber":`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidPayload,
+		},
+		{
+			name: "get", method: http.MethodGet, path: "/api/lots/LOT-1",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body map[string]any) {
+				if body["machineName"] != "Oven-01" {
+					t.Errorf("lot = %v, want machine Oven-01", body)
+				}
+			},
+		},
+		{
+			name: "get missing", method: http.MethodGet, path: "/api/lots/LOT-9",
+			wantStatus: http.StatusNotFound, wantCode: codeLotNotFound,
+		},
+		{
+			name: "count active", method: http.MethodGet, path: "/api/lots/active/count",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body map[string]any) {
+				if body["count"] != float64(1) {
+					t.Errorf("active count = %v, want 1", body["count"])
+				}
+			},
+		},
+		{
+			name: "delete", method: http.MethodDelete, path: "/api/products/LOT-1",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "list after delete", method: http.MethodGet, path: "/api/lots",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body map[string]any) {
+				if lots, _ := body["lots"].([]any); len(lots) != 0 {
+					t.Errorf("listed %d lots after delete, want 0", len(lots))
+				}
+			},
+		},
+		{
+			name: "recreate deleted", method: http.MethodPost, path: "/api/lots",
+			body:       `{"lotNumber":"LOT-1"}`,
+			wantStatus: http.StatusConflict, wantCode: codeLotDeleted,
+		},
+	}
+	for _, step := range steps {
+		status, body := serveJSON(t, router, step.method, step.path, step.body)
+		if status != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %v", step.name, status, step.wantStatus, body)
+		}
+		if code := errorCode(body); code != step.wantCode {
+			t.Errorf("%s: error code = %q, want %q", step.name, code, step.wantCode)
+		}
+		if step.check != nil {
+			step.check(t, body)
+		}
+	}
+}
+
+func TestProductSummaryDateRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := metadatatest.New()
+	day := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	for i, started := range []time.Time{day.Add(-time.Hour), day.Add(9 * time.Hour), day.Add(23 * time.Hour), day.AddDate(0, 0, 1)} {
+		store.PutLot(metadata.Lot{LotNumber: fmt.Sprintf("LOT-%d", i), MachineName: "Oven-01", Status: metadata.LotStatusCompleted, StartedAt: started})
+	}
+	router := NewRouter(Dependencies{Metadata: store})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantLots float64
+	}{
+		{"same day", "?from=2026-01-31&to=2026-01-31", 2},
+		{"through the last day", "?from=2026-01-30&to=2026-01-31", 3},
+		{"timestamp end stays exclusive", "?from=2026-01-31&to=2026-01-31T23:00:00Z", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serveJSON(t, router, http.MethodGet, "/api/products/summary"+tt.query, "")
+			if status != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %v", status, http.StatusOK, body)
+			}
+			if body["lots"] != tt.wantLots {
+				t.Errorf("lots = %v, want %v", body["lots"], tt.wantLots)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestTimeoutEnvKey  = "REQUEST_TIMEOUT"
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// timeoutExcludedPaths keep the request context as it is: the reading streams
+// stay open for as long as the client listens, the chatbot sets its own, longer
+// deadline for the LLM round trips, and a history backfill cut short would
+// leave its range half written. The lot backfill takes minutes when hundreds of
+// lots need computing, and the admin cleanup and reset run Influx deletes over
+// whole ranges.
+var timeoutExcludedPaths = map[string]struct{}{
+	"/api/influx/stream":               {},
+	"/api/influx/ws":                   {},
+	"/api/chatbot/query":               {},
+	"/api/simulation/backfill-history": {},
+	"/api/lots/backfill":               {},
+	"/api/admin/cleanup":               {},
+	"/api/admin/reset":                 {},
+}
+
+// RequestTimeoutFromEnv reads REQUEST_TIMEOUT, how long a request may run before
+// its context is cancelled (default 30s).
+func RequestTimeoutFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv(requestTimeoutEnvKey))
+	if raw == "" {
+		return defaultRequestTimeout
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid request timeout, using default", "key", requestTimeoutEnvKey, "value", raw, "default", defaultRequestTimeout.String())
+		return defaultRequestTimeout
+	}
+	return d
+}
+
+// requestTimeoutMiddleware gives each request a context that expires after
+// timeout, so handlers passing c.Request.Context() downstream cannot wait on a
+// slow MySQL or Influx query forever. A request that runs out of time answers
+// 504: the server error a handler writes once its query is cancelled is
+// replaced, and so is an empty response. Successful responses are left alone.
+func requestTimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	return func(c *gin.Context) {
+		if _, excluded := timeoutExcludedPaths[c.FullPath()]; excluded {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		writer := &timeoutResponseWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.timedOut || (!c.Writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+			requestLogger(c).Warn("request timed out", "path", c.FullPath(), "timeout", timeout.String())
+			respondError(c, http.StatusGatewayTimeout, codeRequestTimeout, "request timed out")
+		}
+	}
+}
+
+// timeoutResponseWriter drops a server error written after ctx's deadline, so
+// the middleware can answer with a 504 instead.
+type timeoutResponseWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	if !w.timedOut && code >= http.StatusInternalServerError && !w.ResponseWriter.Written() &&
+		errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+	}
+	if w.timedOut {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) WriteHeaderNow() {
+	if !w.timedOut {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutResponseWriter) Write(data []byte) (int, error) {
+	if w.timedOut {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutResponseWriter) WriteString(s string) (int, error) {
+	if w.timedOut {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
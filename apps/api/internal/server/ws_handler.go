@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+	wsMaxMessage = 4096
+)
+
+// wsFrame is the envelope for every server-to-client WebSocket message.
+type wsFrame struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// wsControlMessage changes the stream filters. An omitted field keeps the current
+// filter and an empty string clears it.
+type wsControlMessage struct {
+	Machine *string `json:"machine"`
+	Sensor  *string `json:"sensor"`
+}
+
+// HandleReadingsWebSocket streams sensor readings over a WebSocket using the same
+// polling as the SSE stream. Clients may send wsControlMessage frames to change the
+// machine/sensor filter without reconnecting.
+func HandleReadingsWebSocket(c *gin.Context, deps Dependencies) {
+	if deps.Influx == nil {
+		respondError(c, http.StatusServiceUnavailable, codeInfluxUnavail, "influx client unavailable")
+		return
+	}
+
+	logger := requestLogger(c)
+	opts, err := parseStreamOptions(c, deps)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if !validateStreamBucket(c, deps.Influx, opts) {
+		return
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return originAllowed(deps.CORSOrigins, r.Header.Get("Origin"))
+		},
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	if warning, ok := opts.lookbackWarning(); ok {
+		if err := writeWSFrame(conn, "warning", warning); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	current := wsControlMessage{Machine: &opts.machine, Sensor: &opts.sensor}
+	controls := make(chan wsControlMessage, 1)
+	go readWSControls(ctx, cancel, conn, controls)
+
+	poller := newReadingPoller(deps.Influx, opts, sensorUnits(deps), logger)
+	pollTicker := time.NewTicker(opts.pollInterval)
+	pingTicker := time.NewTicker(wsPingPeriod)
+	defer pollTicker.Stop()
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-controls:
+			if msg.Machine != nil {
+				machine := canonicalMachine(ctx, deps, *msg.Machine)
+				current.Machine = &machine
+			}
+			if msg.Sensor != nil {
+				current.Sensor = msg.Sensor
+			}
+			poller.setFilters(*current.Machine, *current.Sensor)
+			if err := writeWSFrame(conn, "filter", gin.H{"machine": *current.Machine, "sensor": *current.Sensor}); err != nil {
+				return
+			}
+		case <-pollTicker.C:
+			payloads, err := poller.poll(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("websocket sensor readings failed", "error", err)
+				if err := writeWSFrame(conn, "error", apiError{Code: codeInfluxError, Message: "failed to query sensor readings"}); err != nil {
+					return
+				}
+				continue
+			}
+			for _, payload := range payloads {
+				if err := writeWSFrame(conn, "reading", payload); err != nil {
+					return
+				}
+			}
+		case <-pingTicker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readWSControls decodes inbound control messages until the connection closes,
+// then cancels the stream.
+func readWSControls(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, out chan<- wsControlMessage) {
+	defer cancel()
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg wsControlMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			// ignore malformed control frames
+			continue
+		}
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func writeWSFrame(conn *websocket.Conn, frameType string, data any) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(wsFrame{Type: frameType, Data: data})
+}
+
+// originAllowed applies the CORS origin list to WebSocket handshakes. Requests
+// without an Origin header come from non-browser clients and are accepted.
+func originAllowed(allowed []string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	if len(allowed) == 0 {
+		allowed = DefaultCORSOrigins
+	}
+	for _, pattern := range allowed {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(pattern, "*"); ok {
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
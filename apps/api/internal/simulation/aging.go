@@ -0,0 +1,49 @@
+package simulation
+
+// WithAging makes the sensor's effective baseline creep by ratePerCycle (a
+// fraction, e.g. 0.0001 for +0.01%) each time the simulator completes a cycle,
+// mimicking calibration drift. Rates at or below -1 are ignored.
+func WithAging(ratePerCycle float64) SensorOption {
+	return func(s *Sensor) {
+		if ratePerCycle > -1 {
+			s.AgingRate = ratePerCycle
+		}
+	}
+}
+
+// recordInitialBaseline remembers the configured baseline so ResetAging can
+// restore it.
+func (s *Sensor) recordInitialBaseline() {
+	if !s.hasInitialBaseline {
+		s.initialBaseline = s.Baseline
+		s.hasInitialBaseline = true
+	}
+}
+
+// age applies one cycle of baseline drift. Callers hold the simulator lock.
+func (s *Sensor) age() {
+	if s.AgingRate != 0 {
+		s.Baseline *= 1 + s.AgingRate
+	}
+}
+
+// ageSensors advances every sensor's baseline by one cycle of drift. Callers
+// hold s.mu.
+func (s *Simulator) ageSensors() {
+	for _, sensor := range s.sensors {
+		sensor.age()
+	}
+}
+
+// ResetAging restores every sensor's configured baseline, discarding
+// accumulated drift.
+func (s *Simulator) ResetAging() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sensor := range s.sensors {
+		if sensor.hasInitialBaseline {
+			sensor.Baseline = sensor.initialBaseline
+		}
+	}
+	s.logger.Info("sensor aging reset", "sensors", len(s.sensors))
+}
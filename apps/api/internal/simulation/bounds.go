@@ -0,0 +1,83 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSensorBounds indicates a sensor's baseline lies outside its value bounds.
+var ErrInvalidSensorBounds = errors.New("invalid sensor bounds")
+
+// WithMinValue sets the lowest value the sensor may report. NewSensor defaults it to 0.
+func WithMinValue(value float64) SensorOption {
+	return func(s *Sensor) {
+		s.MinValue = &value
+	}
+}
+
+// WithoutMinValue removes the lower bound so the sensor may go negative, e.g. for
+// a temperature delta or pressure differential.
+func WithoutMinValue() SensorOption {
+	return func(s *Sensor) {
+		s.MinValue = nil
+	}
+}
+
+// WithMaxValue sets the highest value the sensor may report.
+func WithMaxValue(value float64) SensorOption {
+	return func(s *Sensor) {
+		s.MaxValue = &value
+	}
+}
+
+// WithMaxBaselineMultiple caps values at multiple times the sensor's baseline,
+// e.g. 1.5, rejecting spikes the random walk and drift could otherwise produce.
+// The cap follows the baseline as it ages.
+func WithMaxBaselineMultiple(multiple float64) SensorOption {
+	return func(s *Sensor) {
+		s.MaxBaselineMultiple = multiple
+	}
+}
+
+// validateBounds requires MinValue <= Baseline <= MaxValue for the bounds that are set.
+func (s *Sensor) validateBounds() error {
+	if s.MinValue != nil && s.MaxValue != nil && *s.MinValue > *s.MaxValue {
+		return fmt.Errorf("%w: minValue %g exceeds maxValue %g", ErrInvalidSensorBounds, *s.MinValue, *s.MaxValue)
+	}
+	if s.MinValue != nil && s.Baseline < *s.MinValue {
+		return fmt.Errorf("%w: baseline %g is below minValue %g", ErrInvalidSensorBounds, s.Baseline, *s.MinValue)
+	}
+	if s.MaxValue != nil && s.Baseline > *s.MaxValue {
+		return fmt.Errorf("%w: baseline %g is above maxValue %g", ErrInvalidSensorBounds, s.Baseline, *s.MaxValue)
+	}
+	if s.MaxBaselineMultiple != 0 && s.MaxBaselineMultiple < 1 {
+		return fmt.Errorf("%w: maxBaselineMultiple %g must be at least 1", ErrInvalidSensorBounds, s.MaxBaselineMultiple)
+	}
+	return nil
+}
+
+// validateIdle requires the value the sensor settles at while down to lie within
+// the bounds that are set.
+func (s *Sensor) validateIdle() error {
+	if s.MinValue != nil && s.downTarget < *s.MinValue {
+		return fmt.Errorf("%w: idle value %g is below minValue %g", ErrInvalidSensorBounds, s.downTarget, *s.MinValue)
+	}
+	if s.MaxValue != nil && s.downTarget > *s.MaxValue {
+		return fmt.Errorf("%w: idle value %g is above maxValue %g", ErrInvalidSensorBounds, s.downTarget, *s.MaxValue)
+	}
+	return nil
+}
+
+// clamp limits value to the sensor's bounds, including the baseline multiple cap.
+func (s *Sensor) clamp(value float64) float64 {
+	if s.MinValue != nil && value < *s.MinValue {
+		value = *s.MinValue
+	}
+	if s.MaxBaselineMultiple > 0 && s.Baseline > 0 && value > s.Baseline*s.MaxBaselineMultiple {
+		value = s.Baseline * s.MaxBaselineMultiple
+	}
+	if s.MaxValue != nil && value > *s.MaxValue {
+		value = *s.MaxValue
+	}
+	return value
+}
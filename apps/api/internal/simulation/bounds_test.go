@@ -0,0 +1,55 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIdleValueWithinBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []SensorOption
+		wantErr  bool
+		wantIdle float64
+	}{
+		{"positive idle", []SensorOption{WithIdleValue(22)}, false, 22},
+		{"negative idle without lower bound", []SensorOption{WithoutMinValue(), WithIdleValue(-5)}, false, -5},
+		{"negative idle above negative bound", []SensorOption{WithMinValue(-10), WithIdleValue(-5)}, false, -5},
+		{"negative idle with default bound", []SensorOption{WithIdleValue(-5)}, true, 0},
+		{"idle below bound", []SensorOption{WithMinValue(-10), WithIdleValue(-15)}, true, 0},
+		{"idle above bound", []SensorOption{WithMaxValue(50), WithIdleValue(60)}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := New(&pointWriter{}, nil)
+			sensor := NewSensor("Oven-01", "Delta", 20, 1, 0, tt.opts...)
+			err := sim.AddSensor(sensor)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSensorBounds) {
+					t.Fatalf("AddSensor err = %v, want ErrInvalidSensorBounds", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddSensor: %v", err)
+			}
+			if got := sensor.DownTarget(); got != tt.wantIdle {
+				t.Errorf("DownTarget = %g, want %g", got, tt.wantIdle)
+			}
+		})
+	}
+}
+
+func TestNewReplacesIdleValueOutsideBounds(t *testing.T) {
+	sim := New(&pointWriter{}, []*Sensor{
+		NewSensor("Oven-01", "Temperature", 100, 1, 0, WithIdleValue(-5)),
+		NewSensor("Oven-01", "Delta", 10, 1, 0, WithMinValue(-20), WithIdleValue(-5)),
+	})
+	snapshot := sim.Snapshot()
+	if got, want := snapshot[0].DownTarget(), 100*defaultDownRatio; got != want {
+		t.Errorf("rejected idle DownTarget = %g, want the default %g", got, want)
+	}
+	if got := snapshot[1].DownTarget(); got != -5 {
+		t.Errorf("bounded idle DownTarget = %g, want -5", got)
+	}
+}
@@ -1,7 +1,7 @@
 package simulation
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"time"
@@ -10,6 +10,13 @@ import (
 const (
 	intervalEnvKey          = "SIMULATION_INTERVAL"
 	machineIterationsEnvKey = "SIMULATION_MACHINE_ITERATIONS"
+	writePrecisionEnvKey    = "SIMULATION_WRITE_PRECISION"
+	writeFailuresEnvKey     = "SIMULATION_WRITE_FAILURE_THRESHOLD"
+	writePauseEnvKey        = "SIMULATION_WRITE_FAILURE_PAUSE"
+	noiseModelEnvKey        = "SIMULATION_NOISE_MODEL"
+	startDelayEnvKey        = "SIMULATION_START_DELAY"
+	writeJitterEnvKey       = "SIMULATION_WRITE_JITTER"
+	writeBufferEnvKey       = "SIMULATION_WRITE_BUFFER"
 	defaultMachineIters     = 2
 )
 
@@ -25,11 +32,11 @@ func IntervalFromString(raw string) time.Duration {
 	}
 	dur, err := time.ParseDuration(raw)
 	if err != nil {
-		log.Printf("invalid %s value %q: %v, using default %s", intervalEnvKey, raw, err, defaultInterval)
+		slog.Warn("invalid simulation interval, using default", "key", intervalEnvKey, "value", raw, "error", err, "default", defaultInterval.String())
 		return defaultInterval
 	}
 	if dur <= 0 {
-		log.Printf("non-positive %s value %q, using default %s", intervalEnvKey, raw, defaultInterval)
+		slog.Warn("non-positive simulation interval, using default", "key", intervalEnvKey, "value", raw, "default", defaultInterval.String())
 		return defaultInterval
 	}
 	return dur
@@ -44,12 +51,111 @@ func MachineIterationsFromEnv() int {
 	}
 	iterations, err := strconv.Atoi(raw)
 	if err != nil {
-		log.Printf("invalid %s value %q: %v, using default %d", machineIterationsEnvKey, raw, err, defaultMachineIters)
+		slog.Warn("invalid machine iterations, using default", "key", machineIterationsEnvKey, "value", raw, "error", err, "default", defaultMachineIters)
 		return defaultMachineIters
 	}
 	if iterations <= 0 {
-		log.Printf("non-positive %s value %q, using default %d", machineIterationsEnvKey, raw, defaultMachineIters)
+		slog.Warn("non-positive machine iterations, using default", "key", machineIterationsEnvKey, "value", raw, "default", defaultMachineIters)
 		return defaultMachineIters
 	}
 	return iterations
 }
+
+// WritePrecisionFromEnv reads the timestamp precision for written points, e.g.
+// "1s". Unset or invalid values disable truncation.
+func WritePrecisionFromEnv() time.Duration {
+	raw := os.Getenv(writePrecisionEnvKey)
+	if raw == "" {
+		return 0
+	}
+	dur, err := time.ParseDuration(raw)
+	if err != nil || dur < 0 {
+		slog.Warn("invalid write precision, writing full timestamps", "key", writePrecisionEnvKey, "value", raw)
+		return 0
+	}
+	return dur
+}
+
+// WriteFailureThresholdFromEnv reads how many consecutive write failures are
+// tolerated before the simulator logs an error (default 10) and how long it then
+// pauses (default 0, no pause).
+func WriteFailureThresholdFromEnv() (threshold int, pause time.Duration) {
+	threshold = defaultWriteFailureThreshold
+	if raw := os.Getenv(writeFailuresEnvKey); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			slog.Warn("invalid write failure threshold, using default", "key", writeFailuresEnvKey, "value", raw, "default", defaultWriteFailureThreshold)
+		} else {
+			threshold = n
+		}
+	}
+	if raw := os.Getenv(writePauseEnvKey); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			slog.Warn("invalid write failure pause, not pausing", "key", writePauseEnvKey, "value", raw)
+		} else {
+			pause = d
+		}
+	}
+	return threshold, pause
+}
+
+// NoiseModelFromEnv reads SIMULATION_NOISE_MODEL, "uniform" (the default) or
+// "gaussian".
+func NoiseModelFromEnv() NoiseModel {
+	raw := os.Getenv(noiseModelEnvKey)
+	if raw == "" {
+		return NoiseUniform
+	}
+	model, err := ParseNoiseModel(raw)
+	if err != nil {
+		slog.Warn("invalid noise model, using default", "key", noiseModelEnvKey, "value", raw, "default", NoiseUniform)
+		return NoiseUniform
+	}
+	return model
+}
+
+// StartDelayFromEnv reads SIMULATION_START_DELAY, how long the simulator waits
+// before its first tick. It defaults to no delay.
+func StartDelayFromEnv() time.Duration {
+	raw := os.Getenv(startDelayEnvKey)
+	if raw == "" {
+		return 0
+	}
+	delay, err := time.ParseDuration(raw)
+	if err != nil || delay < 0 {
+		slog.Warn("invalid simulation start delay, starting immediately", "key", startDelayEnvKey, "value", raw)
+		return 0
+	}
+	return delay
+}
+
+// WriteJitterFromEnv reads SIMULATION_WRITE_JITTER, the largest random offset
+// added to point timestamps, e.g. "5ms". It defaults to no jitter.
+func WriteJitterFromEnv() time.Duration {
+	raw := os.Getenv(writeJitterEnvKey)
+	if raw == "" {
+		return 0
+	}
+	jitter, err := time.ParseDuration(raw)
+	if err != nil || jitter < 0 {
+		slog.Warn("invalid simulation write jitter, jitter disabled", "key", writeJitterEnvKey, "value", raw)
+		return 0
+	}
+	return jitter
+}
+
+// WriteBufferFromEnv reads SIMULATION_WRITE_BUFFER, how many unwritten points
+// the simulator keeps for later (default 10000). Zero disables the buffer.
+func WriteBufferFromEnv() int {
+	raw := os.Getenv(writeBufferEnvKey)
+	if raw == "" {
+		return defaultWriteBufferSize
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		slog.Warn("invalid simulation write buffer, using default", "key", writeBufferEnvKey, "value", raw, "default", defaultWriteBufferSize)
+		return defaultWriteBufferSize
+	}
+	return n
+}
@@ -2,12 +2,13 @@ package simulation
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/notify"
 )
 
 const defaultCoordinatorPollInterval = 5 * time.Second
@@ -19,6 +20,12 @@ type Coordinator struct {
 	simulator    *Simulator
 	repo         *metadata.Repository
 	pollInterval time.Duration
+	logger       *slog.Logger
+	manual       atomic.Bool
+
+	notifier        notify.Notifier
+	defectThreshold float64
+	completeLots    bool
 }
 
 // CoordinatorOption customises coordinator behaviour.
@@ -33,12 +40,43 @@ func WithCoordinatorPollInterval(d time.Duration) CoordinatorOption {
 	}
 }
 
+// WithCoordinatorLogger sets the structured logger used by the coordinator.
+func WithCoordinatorLogger(logger *slog.Logger) CoordinatorOption {
+	return func(c *Coordinator) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithCoordinatorNotifier publishes lot completion events to n. When
+// defectThreshold is positive, lots completing with a higher defect ratio also
+// raise an alert event.
+func WithCoordinatorNotifier(n notify.Notifier, defectThreshold float64) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.notifier = n
+		c.defectThreshold = defectThreshold
+	}
+}
+
+// WithCoordinatorCompletion controls whether a finished simulator cycle
+// completes the processing lots (the default). The coordinator can only write
+// a summary without sensor snapshots, so disable this when a sensor-down
+// CompletionService runs and lots should complete with its summary instead.
+func WithCoordinatorCompletion(enabled bool) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.completeLots = enabled
+	}
+}
+
 // NewCoordinator wires the simulator with the metadata repository to control lifecycle.
 func NewCoordinator(sim *Simulator, repo *metadata.Repository, opts ...CoordinatorOption) *Coordinator {
 	coord := &Coordinator{
 		simulator:    sim,
 		repo:         repo,
 		pollInterval: defaultCoordinatorPollInterval,
+		logger:       slog.Default(),
+		completeLots: true,
 	}
 	for _, opt := range opts {
 		opt(coord)
@@ -52,12 +90,12 @@ func NewCoordinator(sim *Simulator, repo *metadata.Repository, opts ...Coordinat
 // Start begins background orchestration until the context is cancelled.
 func (c *Coordinator) Start(ctx context.Context) {
 	if c.simulator == nil || c.repo == nil {
-		log.Printf("simulation coordinator inactive (simulator or repository missing)")
+		c.logger.Warn("simulation coordinator inactive (simulator or repository missing)")
 		return
 	}
 
 	if err := c.syncSimulation(ctx); err != nil {
-		log.Printf("simulation coordinator initial sync error: %v", err)
+		c.logger.Error("simulation coordinator initial sync failed", "error", err)
 	}
 
 	go c.run(ctx)
@@ -70,17 +108,44 @@ func (c *Coordinator) run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("simulation coordinator stopped")
+			c.logger.Info("simulation coordinator stopped")
 			return
 		case <-ticker.C:
 			if err := c.syncSimulation(ctx); err != nil {
-				log.Printf("simulation coordinator sync error: %v", err)
+				c.logger.Error("simulation coordinator sync failed", "error", err)
 			}
 		}
 	}
 }
 
+// SetManualControl suspends (true) or resumes (false) automatic syncing of the
+// simulator with active lots. While manual, the coordinator leaves the simulator's
+// enabled state alone; manual control persists until it is explicitly resumed.
+func (c *Coordinator) SetManualControl(manual bool) {
+	if c.manual.Swap(manual) != manual {
+		c.logger.Info("simulation coordinator control changed", "manual", manual)
+	}
+}
+
+// ManualControl reports whether automatic syncing is currently suspended.
+func (c *Coordinator) ManualControl() bool {
+	return c.manual.Load()
+}
+
+// ResumeAutomaticControl clears manual control and immediately syncs the simulator
+// with the current set of active lots.
+func (c *Coordinator) ResumeAutomaticControl(ctx context.Context) error {
+	c.SetManualControl(false)
+	if c.simulator == nil || c.repo == nil {
+		return nil
+	}
+	return c.syncSimulation(ctx)
+}
+
 func (c *Coordinator) syncSimulation(ctx context.Context) error {
+	if c.ManualControl() {
+		return nil
+	}
 	active, err := c.repo.HasActiveLots(ctx)
 	if err != nil {
 		return err
@@ -94,15 +159,15 @@ func (c *Coordinator) syncSimulation(ctx context.Context) error {
 }
 
 // OnCycleComplete marks processing lots as completed when the simulator
-// finishes a full machine cycle.
+// finishes a full machine cycle, unless WithCoordinatorCompletion disabled it.
 func (c *Coordinator) OnCycleComplete(ctx context.Context, completedAt time.Time, lastMachine string) {
-	if c.repo == nil {
+	if c.repo == nil || !c.completeLots {
 		return
 	}
 
 	lots, err := c.repo.ListActiveLots(ctx)
 	if err != nil {
-		log.Printf("simulation coordinator list active lots error: %v", err)
+		c.logger.Error("simulation coordinator list active lots failed", "error", err)
 		return
 	}
 	if len(lots) == 0 {
@@ -114,27 +179,34 @@ func (c *Coordinator) OnCycleComplete(ctx context.Context, completedAt time.Time
 		completionTime = time.Now().UTC()
 	}
 
+	// The coordinator only knows the cycle ended, so it completes lots with a
+	// bare summary; FinalizeLot lets the completion service's richer summary
+	// replace it, and skips lots that service has already completed.
+	bare := func(_ context.Context, locked metadata.Lot) (*metadata.LotSummary, error) {
+		return &metadata.LotSummary{CompletedAt: completionTime, MachineName: locked.MachineName}, nil
+	}
 	for _, lot := range lots {
-		summary := metadata.LotSummary{
-			CompletedAt: completionTime,
-			MachineName: lot.MachineName,
-		}
-		if err := c.repo.MarkLotCompleted(ctx, lot.ID, summary); err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
+		result, _, err := c.repo.FinalizeLot(ctx, lot.ID, bare)
+		if err != nil {
+			if errors.Is(err, metadata.ErrLotNotFound) {
 				continue
 			}
-			log.Printf("simulation coordinator mark lot complete failed lot=%s: %v", lot.LotNumber, err)
+			c.logger.Error("simulation coordinator mark lot complete failed", "lot", lot.LotNumber, "error", err)
+			continue
+		}
+		if result != metadata.FinalizeCompleted {
 			continue
 		}
-		log.Printf("simulation coordinator lot=%s marked completed (lastMachine=%s)", lot.LotNumber, lastMachine)
+		c.logger.Info("simulation coordinator marked lot completed", "lot", lot.LotNumber, "lastMachine", lastMachine)
+		notify.LotCompleted(ctx, c.notifier, c.logger, lot, completionTime, c.defectThreshold)
 	}
 
 	remaining, err := c.repo.HasActiveLots(ctx)
 	if err != nil {
-		log.Printf("simulation coordinator post-completion check error: %v", err)
+		c.logger.Error("simulation coordinator post-completion check failed", "error", err)
 		return
 	}
-	if !remaining {
+	if !remaining && !c.ManualControl() {
 		c.simulator.Disable()
 	}
 }
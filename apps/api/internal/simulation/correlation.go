@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	"fmt"
+)
+
+// WithCorrelation makes the sensor follow driver's relative level with the given
+// coefficient, clamped to [0, 1].
+func WithCorrelation(driver *Sensor, coefficient float64) SensorOption {
+	return func(s *Sensor) {
+		if coefficient < 0 {
+			coefficient = 0
+		}
+		if coefficient > 1 {
+			coefficient = 1
+		}
+		s.CorrelatedWith = driver
+		s.CorrelationCoefficient = coefficient
+	}
+}
+
+// ValidateCorrelations reports an error when sensor correlations form a cycle.
+func ValidateCorrelations(sensors []*Sensor) error {
+	for _, sensor := range sensors {
+		if sensor == nil {
+			continue
+		}
+		if inCorrelationCycle(sensor) {
+			return fmt.Errorf("sensor correlation cycle detected at machine=%s sensor=%s", sensor.MachineName, sensor.SensorName)
+		}
+	}
+	return nil
+}
+
+func inCorrelationCycle(sensor *Sensor) bool {
+	seen := map[*Sensor]struct{}{}
+	for cur := sensor.CorrelatedWith; cur != nil; cur = cur.CorrelatedWith {
+		if cur == sensor {
+			return true
+		}
+		if _, ok := seen[cur]; ok {
+			// cycle further along the chain; reported for the sensors inside it
+			return false
+		}
+		seen[cur] = struct{}{}
+	}
+	return false
+}
+
+// correlationTarget maps the driver's position relative to its baseline onto the
+// dependent sensor's baseline.
+func correlationTarget(sensor *Sensor) (float64, bool) {
+	driver := sensor.CorrelatedWith
+	if driver == nil || sensor.CorrelationCoefficient <= 0 || driver.Baseline <= 0 {
+		return 0, false
+	}
+	return sensor.Baseline * (driver.CurrentValue / driver.Baseline), true
+}
+
+// orderByCorrelation returns sensors in declaration order, except that drivers are
+// placed before the sensors that depend on them so each tick reads fresh values.
+func orderByCorrelation(sensors []*Sensor) []*Sensor {
+	members := make(map[*Sensor]struct{}, len(sensors))
+	for _, sensor := range sensors {
+		members[sensor] = struct{}{}
+	}
+
+	ordered := make([]*Sensor, 0, len(sensors))
+	placed := make(map[*Sensor]bool, len(sensors))
+	var visit func(sensor *Sensor)
+	visit = func(sensor *Sensor) {
+		if placed[sensor] {
+			return
+		}
+		placed[sensor] = true
+		if driver := sensor.CorrelatedWith; driver != nil {
+			if _, ok := members[driver]; ok {
+				visit(driver)
+			}
+		}
+		ordered = append(ordered, sensor)
+	}
+	for _, sensor := range sensors {
+		visit(sensor)
+	}
+	return ordered
+}
@@ -0,0 +1,102 @@
+package simulation
+
+import (
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	deadbandsEnvKey          = "SIMULATION_DEADBANDS"
+	deadbandHeartbeatEnvKey  = "SIMULATION_DEADBAND_HEARTBEAT"
+	defaultDeadbandHeartbeat = time.Minute
+)
+
+// WithDeadband makes the sensor write on change: a tick only writes a point
+// when the value moved by more than delta since the sensor's last written point
+// or its status changed. The heartbeat (see WithDeadbandHeartbeat) still writes
+// a point when the sensor has gone that long without one, so a steady value
+// does not look like a gap. While the sensor is shutting down or down it writes
+// on every tick regardless, since lot completion counts consecutive down
+// samples within a lookback far shorter than the heartbeat. Names match
+// case-insensitively; sensors without a deadband write on every tick.
+func WithDeadband(machine, sensor string, delta float64) Option {
+	return func(s *Simulator) {
+		if delta <= 0 || math.IsNaN(delta) || math.IsInf(delta, 0) {
+			return
+		}
+		if s.deadbands == nil {
+			s.deadbands = make(map[string]float64)
+		}
+		s.deadbands[deadbandKey(machine, sensor)] = delta
+	}
+}
+
+// WithDeadbandHeartbeat sets the longest a sensor with a deadband goes without
+// a written point, one minute by default.
+func WithDeadbandHeartbeat(d time.Duration) Option {
+	return func(s *Simulator) {
+		if d > 0 {
+			s.deadbandHeartbeat = d
+		}
+	}
+}
+
+func deadbandKey(machine, sensor string) string {
+	return strings.ToLower(strings.TrimSpace(machine)) + "/" + strings.ToLower(strings.TrimSpace(sensor))
+}
+
+// shouldWrite reports whether sensor's current value is due for a point at t
+// and, if so, records it as the last written. Callers hold s.mu.
+func (s *Simulator) shouldWrite(sensor *Sensor, t time.Time) bool {
+	delta, ok := s.deadbands[deadbandKey(sensor.MachineName, sensor.SensorName)]
+	stopping := sensor.state == stateShuttingDown || sensor.state == stateDown
+	if ok && !stopping && !sensor.lastWrittenAt.IsZero() &&
+		math.Abs(sensor.CurrentValue-sensor.lastWrittenValue) <= delta &&
+		sensor.Status == sensor.lastWrittenStatus &&
+		t.Sub(sensor.lastWrittenAt) < s.deadbandHeartbeat {
+		return false
+	}
+	sensor.lastWrittenValue = sensor.CurrentValue
+	sensor.lastWrittenStatus = sensor.Status
+	sensor.lastWrittenAt = t
+	return true
+}
+
+// DeadbandsFromEnv reads SIMULATION_DEADBANDS, a list of "machine:sensor:delta"
+// entries such as "Weightning-01:Accuracy:0.05", into WithDeadband options, and
+// SIMULATION_DEADBAND_HEARTBEAT, e.g. "5m", into WithDeadbandHeartbeat.
+// Malformed entries are logged and skipped.
+func DeadbandsFromEnv() []Option {
+	var opts []Option
+	for _, entry := range strings.Split(os.Getenv(deadbandsEnvKey), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			slog.Warn("invalid simulation deadband, skipping", "key", deadbandsEnvKey, "value", entry)
+			continue
+		}
+		machine, sensor := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		delta, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if machine == "" || sensor == "" || err != nil || delta <= 0 {
+			slog.Warn("invalid simulation deadband, skipping", "key", deadbandsEnvKey, "value", entry)
+			continue
+		}
+		opts = append(opts, WithDeadband(machine, sensor, delta))
+	}
+	if raw := os.Getenv(deadbandHeartbeatEnvKey); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			slog.Warn("invalid simulation deadband heartbeat, using default", "key", deadbandHeartbeatEnvKey, "value", raw, "default", defaultDeadbandHeartbeat.String())
+		} else {
+			opts = append(opts, WithDeadbandHeartbeat(d))
+		}
+	}
+	return opts
+}
@@ -0,0 +1,133 @@
+package simulation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShouldWrite(t *testing.T) {
+	type step struct {
+		after  time.Duration // since the previous step
+		state  sensorState
+		value  float64
+		wantOK bool
+	}
+	tests := []struct {
+		name     string
+		deadband float64
+		steps    []step
+	}{
+		{
+			name:     "no deadband writes every tick",
+			deadband: 0,
+			steps: []step{
+				{0, stateRunning, 100, true},
+				{time.Second, stateRunning, 100, true},
+				{time.Second, stateRunning, 100, true},
+			},
+		},
+		{
+			name:     "steady value within the deadband",
+			deadband: 0.5,
+			steps: []step{
+				{0, stateRunning, 100, true},
+				{time.Second, stateRunning, 100.3, false},
+				{time.Second, stateRunning, 99.6, false},
+				// Compared with the last written value, not the last tick.
+				{time.Second, stateRunning, 100.6, true},
+				{time.Second, stateRunning, 100.2, false},
+			},
+		},
+		{
+			name:     "status change",
+			deadband: 0.5,
+			steps: []step{
+				{0, stateStartup, 100, true},
+				{time.Second, stateRunning, 100, true},
+				{time.Second, stateRunning, 100, false},
+			},
+		},
+		{
+			name:     "heartbeat",
+			deadband: 0.5,
+			steps: []step{
+				{0, stateRunning, 100, true},
+				{5 * time.Second, stateRunning, 100, false},
+				{4 * time.Second, stateRunning, 100, false},
+				{time.Second, stateRunning, 100, true},
+				{9 * time.Second, stateRunning, 100, false},
+				{time.Second, stateRunning, 100, true},
+			},
+		},
+		{
+			name:     "steady while shutting down and down",
+			deadband: 0.5,
+			steps: []step{
+				{0, stateRunning, 100, true},
+				{time.Second, stateShuttingDown, 100, true},
+				{time.Second, stateShuttingDown, 100, true},
+				{time.Second, stateDown, 0.2, true},
+				{time.Second, stateDown, 0.2, true},
+				{time.Second, stateDown, 0.2, true},
+				{time.Second, stateStartup, 0.2, true},
+				{time.Second, stateStartup, 0.2, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor := NewSensor("Oven-01", "Temperature", 100, 1, 0)
+			opts := []Option{WithDeadbandHeartbeat(10 * time.Second)}
+			if tt.deadband > 0 {
+				opts = append(opts, WithDeadband(" oven-01", "TEMPERATURE ", tt.deadband))
+			}
+			sim := New(&pointWriter{}, []*Sensor{sensor}, opts...)
+			at := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+			for i, step := range tt.steps {
+				at = at.Add(step.after)
+				sim.enterState(sensor, step.state)
+				sensor.CurrentValue = step.value
+				if got := sim.shouldWrite(sensor, at); got != step.wantOK {
+					t.Fatalf("step %d (%s, %v): shouldWrite = %v, want %v", i, sensor.Status, step.value, got, step.wantOK)
+				}
+			}
+		})
+	}
+}
+
+// TestDeadbandWritesEveryDownTick ticks a sensor whose deadband never lets a
+// running value through, and checks that every tick spent shutting down or
+// down still writes a point.
+func TestDeadbandWritesEveryDownTick(t *testing.T) {
+	writer := &pointWriter{}
+	sim := New(writer, []*Sensor{NewSensor("Oven-01", "Temperature", 100, 1, 0)},
+		WithDeadband("Oven-01", "Temperature", 1e9), WithDeadbandHeartbeat(time.Hour))
+	sim.ready.Store(true)
+	sim.Enable()
+	ctx := context.Background()
+	at := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	var stoppingTicks, otherTicks, otherPoints int
+	for i := 0; i < 300; i++ {
+		before := len(writer.written())
+		sim.tick(ctx, at.Add(time.Duration(i)*time.Second))
+		wrote := len(writer.written()) - before
+		switch status := sim.Snapshot()[0].Status; status {
+		case "shutting_down", "down":
+			stoppingTicks++
+			if wrote != 1 {
+				t.Fatalf("tick %d while %s wrote %d points, want 1", i, status, wrote)
+			}
+		default:
+			otherTicks++
+			otherPoints += wrote
+		}
+	}
+	if stoppingTicks == 0 {
+		t.Fatal("the sensor never shut down")
+	}
+	if otherPoints >= otherTicks {
+		t.Errorf("wrote %d points in %d starting and running ticks; the deadband should skip most", otherPoints, otherTicks)
+	}
+}
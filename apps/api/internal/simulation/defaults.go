@@ -1,14 +1,42 @@
 package simulation
 
+const (
+	furnacePressureCorrelation = 0.5
+
+	// ambientTemperature is where idle temperature sensors settle instead of zero.
+	ambientTemperature = 22.0
+
+	// hotProcessTempCap bounds furnace and casting temperatures relative to baseline.
+	hotProcessTempCap = 1.5
+)
+
+// defaultSensorUnits maps default sensor names to the unit of their values.
+var defaultSensorUnits = map[string]string{
+	"Temperature":  "°C",
+	"Pressure":     "bar",
+	"LevelMetal":   "%",
+	"Speed":        "m/min",
+	"Accuracy":     "%",
+	"FlowRate":     "m³/h",
+	"LoadCapacity": "kg",
+	"Volume":       "L",
+	"BladeSpeed":   "rpm",
+	"Weight":       "kg",
+}
+
 // DefaultSensors returns a baseline set of simulated sensors.
 func DefaultSensors() []*Sensor {
-	return []*Sensor{
+	// Furnace pressure follows the furnace temperature.
+	furnace1Temp := NewSensor("Furnace-01", "Temperature", 1200.0, 10.0, 20.0, WithMaxBaselineMultiple(hotProcessTempCap))
+	furnace2Temp := NewSensor("Furnace-02", "Temperature", 1200.0, 10.0, 20.0, WithMaxBaselineMultiple(hotProcessTempCap))
+
+	sensors := []*Sensor{
 		// Furnace sensors
-		NewSensor("Furnace-01", "Temperature", 1200.0, 10.0, 20.0),
-		NewSensor("Furnace-01", "Pressure", 100.0, 2.0, 5.0),
+		furnace1Temp,
+		NewSensor("Furnace-01", "Pressure", 100.0, 2.0, 5.0, WithCorrelation(furnace1Temp, furnacePressureCorrelation)),
 		NewSensor("Furnace-01", "LevelMetal", 85.0, 2.0, 5.0),
-		NewSensor("Furnace-02", "Temperature", 1200.0, 10.0, 20.0),
-		NewSensor("Furnace-02", "Pressure", 100.0, 2.0, 5.0),
+		furnace2Temp,
+		NewSensor("Furnace-02", "Pressure", 100.0, 2.0, 5.0, WithCorrelation(furnace2Temp, furnacePressureCorrelation)),
 		NewSensor("Furnace-02", "LevelMetal", 85.0, 2.0, 5.0),
 
 		// Rod Feeder sensors
@@ -16,22 +44,22 @@ func DefaultSensors() []*Sensor {
 		NewSensor("Rod-Feeder-01", "Speed", 50.0, 1.0, 3.0),
 
 		// UT (Ultrasonic Testing) sensors
-		NewSensor("UT-01", "Temperature", 25.0, 1.0, 2.0),
+		NewSensor("UT-01", "Temperature", 25.0, 1.0, 2.0, WithIdleValue(ambientTemperature)),
 		NewSensor("UT-01", "Accuracy", 98.0, 0.5, 1.0),
 
 		// Casting Machine sensors
-		NewSensor("Casting-Machine-01", "Temperature", 800.0, 5.0, 10.0),
+		NewSensor("Casting-Machine-01", "Temperature", 800.0, 5.0, 10.0, WithMaxBaselineMultiple(hotProcessTempCap)),
 		NewSensor("Casting-Machine-01", "Pressure", 150.0, 2.0, 5.0),
 		NewSensor("Casting-Machine-01", "Speed", 30.0, 1.0, 3.0),
 
 		// CT (Cooling Tower) sensors
-		NewSensor("CT-01", "Temperature", 80.0, 2.0, 5.0),
+		NewSensor("CT-01", "Temperature", 80.0, 2.0, 5.0, WithIdleValue(ambientTemperature)),
 		NewSensor("CT-01", "FlowRate", 200.0, 5.0, 10.0),
-		NewSensor("CT-02", "Temperature", 80.0, 2.0, 5.0),
+		NewSensor("CT-02", "Temperature", 80.0, 2.0, 5.0, WithIdleValue(ambientTemperature)),
 		NewSensor("CT-02", "FlowRate", 200.0, 5.0, 10.0),
-		NewSensor("CT-03", "Temperature", 80.0, 2.0, 5.0),
+		NewSensor("CT-03", "Temperature", 80.0, 2.0, 5.0, WithIdleValue(ambientTemperature)),
 		NewSensor("CT-03", "FlowRate", 200.0, 5.0, 10.0),
-		NewSensor("CT-04", "Temperature", 80.0, 2.0, 5.0),
+		NewSensor("CT-04", "Temperature", 80.0, 2.0, 5.0, WithIdleValue(ambientTemperature)),
 		NewSensor("CT-04", "FlowRate", 200.0, 5.0, 10.0),
 
 		// Homogenizing sensors
@@ -55,4 +83,8 @@ func DefaultSensors() []*Sensor {
 		NewSensor("Weightning-01", "Weight", 1000.0, 10.0, 20.0),
 		NewSensor("Weightning-01", "Accuracy", 99.5, 0.1, 0.5),
 	}
+	for _, sensor := range sensors {
+		sensor.Unit = defaultSensorUnits[sensor.SensorName]
+	}
+	return sensors
 }
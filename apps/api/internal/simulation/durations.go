@@ -0,0 +1,94 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDurationRange indicates a state duration range is out of bounds.
+var ErrInvalidDurationRange = errors.New("invalid state duration range")
+
+// DurationRange bounds how many ticks a sensor stays in a state; the actual
+// length is drawn uniformly from [Min, Max].
+type DurationRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// StateDurations holds the duration range of every simulated state.
+type StateDurations struct {
+	Startup  DurationRange `json:"startup"`
+	Run      DurationRange `json:"run"`
+	Shutdown DurationRange `json:"shutdown"`
+	Down     DurationRange `json:"down"`
+}
+
+// DefaultStateDurations returns the ranges used when a sensor does not set its own.
+func DefaultStateDurations() StateDurations {
+	return StateDurations{
+		Startup:  DurationRange{Min: 3, Max: 6},
+		Run:      DurationRange{Min: 6, Max: 24},
+		Shutdown: DurationRange{Min: 3, Max: 6},
+		Down:     DurationRange{Min: 6, Max: 14},
+	}
+}
+
+func (r DurationRange) isZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// Validate requires 1 <= Min <= Max.
+func (r DurationRange) Validate() error {
+	if r.Min < 1 {
+		return fmt.Errorf("%w: min must be at least 1, got %d", ErrInvalidDurationRange, r.Min)
+	}
+	if r.Max < r.Min {
+		return fmt.Errorf("%w: max %d is below min %d", ErrInvalidDurationRange, r.Max, r.Min)
+	}
+	return nil
+}
+
+// Validate checks every state range.
+func (d StateDurations) Validate() error {
+	ranges := []struct {
+		state string
+		r     DurationRange
+	}{
+		{"startup", d.Startup},
+		{"run", d.Run},
+		{"shutdown", d.Shutdown},
+		{"down", d.Down},
+	}
+	for _, entry := range ranges {
+		if err := entry.r.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", entry.state, err)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills unset (zero) ranges from DefaultStateDurations.
+func (d StateDurations) withDefaults() StateDurations {
+	defaults := DefaultStateDurations()
+	if d.Startup.isZero() {
+		d.Startup = defaults.Startup
+	}
+	if d.Run.isZero() {
+		d.Run = defaults.Run
+	}
+	if d.Shutdown.isZero() {
+		d.Shutdown = defaults.Shutdown
+	}
+	if d.Down.isZero() {
+		d.Down = defaults.Down
+	}
+	return d
+}
+
+// WithStateDurations overrides the sensor's state duration ranges. Zero ranges
+// keep their defaults.
+func WithStateDurations(durations StateDurations) SensorOption {
+	return func(s *Sensor) {
+		s.Durations = durations.withDefaults()
+	}
+}
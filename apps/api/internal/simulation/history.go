@@ -0,0 +1,121 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const (
+	historyBatchSize   = 5000
+	maxHistoricalSteps = 200000
+)
+
+// ErrInvalidHistoryRange indicates a historical generation range that is empty,
+// in the future, uses a non-positive step or has too many steps.
+var ErrInvalidHistoryRange = errors.New("invalid historical range")
+
+// GenerateHistorical writes backdated readings for every sensor at each step
+// in [from, to). It runs on copies of the sensors seeded from a fresh startup
+// state, so the live ticker and its sensor state are left untouched. Every
+// machine advances on every step; machines outside their schedule are skipped
+// for that step.
+func (s *Simulator) GenerateHistorical(ctx context.Context, from, to time.Time, step time.Duration) error {
+	switch {
+	case step <= 0:
+		return fmt.Errorf("%w: step must be positive", ErrInvalidHistoryRange)
+	case !from.Before(to):
+		return fmt.Errorf("%w: from must be before to", ErrInvalidHistoryRange)
+	case to.After(time.Now()):
+		return fmt.Errorf("%w: to must not be in the future", ErrInvalidHistoryRange)
+	case to.Sub(from)/step > maxHistoricalSteps:
+		return fmt.Errorf("%w: at most %d steps allowed", ErrInvalidHistoryRange, maxHistoricalSteps)
+	}
+
+	s.mu.RLock()
+	sensors := cloneSensors(s.sensors)
+	schedule := s.schedule
+	s.mu.RUnlock()
+
+	// A private generator keeps the live rng free of concurrent use.
+	gen := &Simulator{
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:         s.logger,
+		writePrecision: s.writePrecision,
+		noiseModel:     s.noiseModel,
+	}
+	machineOrder := MachineNames(sensors)
+	machineSensors := make(map[string][]*Sensor, len(machineOrder))
+	for _, sensor := range sensors {
+		gen.enterState(sensor, stateStartup)
+		machineSensors[sensor.MachineName] = append(machineSensors[sensor.MachineName], sensor)
+	}
+	for machine, members := range machineSensors {
+		machineSensors[machine] = orderByCorrelation(members)
+	}
+
+	batch := make([]*write.Point, 0, historyBatchSize)
+	written := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.writer.WritePoint(ctx, batch...); err != nil {
+			return fmt.Errorf("write historical batch: %w", err)
+		}
+		written += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for ts := from; ts.Before(to); ts = ts.Add(step) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, machine := range machineOrder {
+			if !schedule.Active(machine, ts) {
+				continue
+			}
+			for _, sensor := range machineSensors[machine] {
+				value := gen.nextValue(sensor)
+				batch = append(batch, newSensorPoint(sensor.measurement(), sensor.MachineName, sensor.SensorName, sensor.Status, value, gen.pointTime(ts)))
+			}
+		}
+		if len(batch) >= historyBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	s.logger.Info("historical sensor data generated", "from", from, "to", to, "step", step.String(), "points", written)
+	return nil
+}
+
+// cloneSensors deep-copies sensors, rewiring correlations to the copies.
+func cloneSensors(sensors []*Sensor) []*Sensor {
+	clones := make([]*Sensor, len(sensors))
+	byOriginal := make(map[*Sensor]*Sensor, len(sensors))
+	for i, sensor := range sensors {
+		clone := *sensor
+		clones[i] = &clone
+		byOriginal[sensor] = &clone
+	}
+	for _, clone := range clones {
+		if clone.CorrelatedWith == nil {
+			continue
+		}
+		if driver, ok := byOriginal[clone.CorrelatedWith]; ok {
+			clone.CorrelatedWith = driver
+		} else {
+			clone.CorrelatedWith = nil
+		}
+	}
+	return clones
+}
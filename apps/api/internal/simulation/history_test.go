@@ -0,0 +1,69 @@
+package simulation
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestGenerateHistoricalUsesNoiseModel recovers the unit noise of each running
+// step from the backfilled values. Uniform noise never leaves [-0.5, 0.5), so
+// Gaussian history must show draws beyond it.
+func TestGenerateHistoricalUsesNoiseModel(t *testing.T) {
+	const (
+		baseline = 1000.0
+		drift    = 1.0
+		steps    = 2000
+	)
+	for _, tt := range []struct {
+		model       NoiseModel
+		wantOutside bool
+	}{
+		{NoiseUniform, false},
+		{NoiseGaussian, true},
+	} {
+		t.Run(string(tt.model), func(t *testing.T) {
+			writer := &pointWriter{}
+			durations := StateDurations{Run: DurationRange{Min: 10 * steps, Max: 10 * steps}}
+			sim := New(writer, []*Sensor{NewSensor("Oven-01", "Temperature", baseline, drift, 0, WithStateDurations(durations))}, WithNoiseModel(tt.model))
+			to := time.Now().Add(-time.Minute).Truncate(time.Second)
+			if err := sim.GenerateHistorical(context.Background(), to.Add(-steps*time.Second), to, time.Second); err != nil {
+				t.Fatalf("GenerateHistorical: %v", err)
+			}
+
+			var (
+				previous float64
+				running  bool
+				samples  int
+				outside  int
+			)
+			for _, point := range writer.written() {
+				var value float64
+				var status string
+				for _, field := range point.FieldList() {
+					switch field.Key {
+					case "value":
+						value = field.Value.(float64)
+					case "status":
+						status = field.Value.(string)
+					}
+				}
+				if running && status == "running" {
+					noise := ((value-rebindCoefficient*baseline)/(1-rebindCoefficient) - previous) / drift
+					samples++
+					if math.Abs(noise) > 0.5+1e-6 {
+						outside++
+					}
+				}
+				previous, running = value, status == "running"
+			}
+			if samples < steps/2 {
+				t.Fatalf("recovered %d running steps, want at least %d", samples, steps/2)
+			}
+			if got := outside > 0; got != tt.wantOutside {
+				t.Errorf("%d of %d noise draws outside [-0.5, 0.5), want outside draws %v", outside, samples, tt.wantOutside)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package simulation
+
+import "time"
+
+// WithWriteJitter offsets each point's timestamp by a random amount in
+// [0, maxJitter), so sensors of the active machine stop sharing one timestamp
+// per tick. maxJitter is capped at the tick interval. Zero, the default, disables
+// jitter. Write precision is applied after jitter, so a coarse precision
+// truncates it away.
+func WithWriteJitter(maxJitter time.Duration) Option {
+	return func(s *Simulator) {
+		if maxJitter >= 0 {
+			s.writeJitter = maxJitter
+		}
+	}
+}
+
+// jitteredTime returns ts plus the sensor's jitter, kept strictly after the
+// sensor's previous point so its timestamps stay monotonic even when ticks
+// arrive early. Callers hold s.mu.
+func (s *Simulator) jitteredTime(sensor *Sensor, ts time.Time) time.Time {
+	jitter := min(s.writeJitter, s.interval)
+	if jitter <= 0 {
+		return ts
+	}
+	t := ts.Add(time.Duration(s.rng.Int63n(int64(jitter))))
+	if !t.After(sensor.lastPointTime) {
+		t = sensor.lastPointTime.Add(time.Nanosecond)
+	}
+	sensor.lastPointTime = t
+	return t
+}
@@ -0,0 +1,67 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// NoiseModel selects the distribution random noise is drawn from.
+type NoiseModel string
+
+const (
+	// NoiseUniform draws from [-0.5, 0.5), the original behaviour.
+	NoiseUniform NoiseModel = "uniform"
+	// NoiseGaussian draws from a normal distribution with the same standard
+	// deviation as NoiseUniform, so Drift and the noise scales keep their meaning.
+	NoiseGaussian NoiseModel = "gaussian"
+)
+
+// gaussianSigma is the standard deviation of the uniform [-0.5, 0.5) draw.
+var gaussianSigma = 1 / math.Sqrt(12)
+
+// ParseNoiseModel accepts "uniform" or "gaussian", case-insensitively.
+func ParseNoiseModel(raw string) (NoiseModel, error) {
+	switch model := NoiseModel(strings.ToLower(strings.TrimSpace(raw))); model {
+	case NoiseUniform, NoiseGaussian:
+		return model, nil
+	}
+	return "", fmt.Errorf("noise model must be %q or %q", NoiseUniform, NoiseGaussian)
+}
+
+// sample draws one unit noise value; the empty model is uniform.
+func (m NoiseModel) sample(rng *rand.Rand) float64 {
+	if m == NoiseGaussian {
+		return rng.NormFloat64() * gaussianSigma
+	}
+	return rng.Float64() - 0.5
+}
+
+// WithNoiseModel sets the noise distribution for sensors that do not choose
+// their own with WithSensorNoiseModel. The default is NoiseUniform.
+func WithNoiseModel(model NoiseModel) Option {
+	return func(s *Simulator) {
+		if model != "" {
+			s.noiseModel = model
+		}
+	}
+}
+
+// WithSensorNoiseModel overrides the simulator-wide noise distribution for one
+// sensor.
+func WithSensorNoiseModel(model NoiseModel) SensorOption {
+	return func(s *Sensor) {
+		s.NoiseModel = model
+	}
+}
+
+// noise draws a unit noise value for sensor from its model, falling back to
+// the simulator's.
+func (s *Simulator) noise(sensor *Sensor) float64 {
+	model := sensor.NoiseModel
+	if model == "" {
+		model = s.noiseModel
+	}
+	return model.sample(s.rng)
+}
@@ -0,0 +1,97 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const scheduleFileEnvKey = "SIMULATION_SCHEDULE_FILE"
+
+// TimeWindow is a daily active period in the server's local time. A window whose
+// end is before its start runs overnight, e.g. 22:00–06:00.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Schedule maps machine names to their active window. Machines without an entry
+// run around the clock.
+type Schedule map[string]TimeWindow
+
+// ScheduleFromEnv loads the schedule file named by SIMULATION_SCHEDULE_FILE, or
+// returns a nil schedule when the variable is unset.
+func ScheduleFromEnv() (Schedule, error) {
+	path := strings.TrimSpace(os.Getenv(scheduleFileEnvKey))
+	if path == "" {
+		return nil, nil
+	}
+	return LoadScheduleFile(path)
+}
+
+// LoadScheduleFile reads a JSON object of the form
+//
+//	{"Furnace-01": {"start": "08:00", "end": "17:00"}}
+func LoadScheduleFile(path string) (Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schedule file: %w", err)
+	}
+	var raw map[string]struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse schedule file: %w", err)
+	}
+
+	schedule := make(Schedule, len(raw))
+	for machine, window := range raw {
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("schedule for %s: start: %w", machine, err)
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return nil, fmt.Errorf("schedule for %s: end: %w", machine, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("schedule for %s: start and end must differ", machine)
+		}
+		schedule[machine] = TimeWindow{Start: start, End: end}
+	}
+	return schedule, nil
+}
+
+func parseClock(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", raw)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t's local time of day falls inside the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	local := t.Local()
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	if w.Start < w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	return clock >= w.Start || clock < w.End
+}
+
+// Active reports whether machine should run at t.
+func (s Schedule) Active(machine string, t time.Time) bool {
+	window, ok := s[machine]
+	return !ok || window.Contains(t)
+}
+
+// WithSchedule restricts machines to their scheduled windows.
+func WithSchedule(schedule Schedule) Option {
+	return func(s *Simulator) {
+		s.schedule = schedule
+	}
+}
@@ -2,13 +2,19 @@ package simulation
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	api "github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 )
 
 const (
@@ -35,40 +41,68 @@ const (
 	stateDown
 )
 
+var (
+	// ErrInvalidSensor indicates a sensor is missing its machine or sensor name.
+	ErrInvalidSensor = errors.New("machine and sensor names are required")
+	// ErrSensorExists indicates the machine already has a sensor with that name.
+	ErrSensorExists = errors.New("sensor already exists")
+)
+
 // CycleListener observes when the simulator finishes iterating across all machines once.
 type CycleListener interface {
 	OnCycleComplete(ctx context.Context, completedAt time.Time, lastMachine string)
 }
 
-type durationRange struct {
-	min int
-	max int
-}
-
-var (
-	defaultStartupRange  = durationRange{min: 3, max: 6}
-	defaultRunRange      = durationRange{min: 6, max: 24}
-	defaultShutdownRange = durationRange{min: 3, max: 6}
-	defaultDownRange     = durationRange{min: 6, max: 14}
-)
-
 // Sensor describes a simulated sensor configuration with downtime behaviour.
 type Sensor struct {
 	MachineName  string  `json:"machineName"`
 	SensorName   string  `json:"sensorName"`
 	CurrentValue float64 `json:"currentValue"`
 	Status       string  `json:"status"`
+	// Unit labels values, e.g. "°C" or "bar"; empty when unknown.
+	Unit string `json:"unit,omitempty"`
+	// Measurement is the Influx measurement the sensor writes to; empty uses
+	// MeasurementName(). Snapshots always report the effective measurement.
+	Measurement string `json:"measurement,omitempty"`
 
 	Baseline float64 `json:"-"`
 	Drift    float64 `json:"-"`
 
-	state          sensorState
-	ticksRemaining int
-	startupRange   durationRange
-	runRange       durationRange
-	shutdownRange  durationRange
-	downRange      durationRange
-	downTarget     float64
+	// EffectiveBaseline reports Baseline, including aging drift, in snapshots.
+	EffectiveBaseline float64 `json:"effectiveBaseline"`
+	// AgingRate is the fractional baseline change applied per completed cycle.
+	AgingRate float64 `json:"agingRate,omitempty"`
+
+	// CorrelatedWith optionally names a driver sensor whose relative level this
+	// sensor follows while running; CorrelationCoefficient sets how strongly.
+	CorrelatedWith         *Sensor `json:"-"`
+	CorrelationCoefficient float64 `json:"-"`
+
+	// Durations bounds how many ticks the sensor spends in each state.
+	Durations StateDurations `json:"durations"`
+
+	// MinValue and MaxValue bound every generated value; nil leaves that side open.
+	MinValue *float64 `json:"minValue,omitempty"`
+	MaxValue *float64 `json:"maxValue,omitempty"`
+	// MaxBaselineMultiple caps values at this multiple of the current baseline;
+	// zero leaves the high side unbounded.
+	MaxBaselineMultiple float64 `json:"maxBaselineMultiple,omitempty"`
+	// NoiseModel overrides the simulator's noise distribution; empty uses it.
+	NoiseModel NoiseModel `json:"noiseModel,omitempty"`
+
+	state              sensorState
+	ticksRemaining     int
+	downTarget         float64
+	initialBaseline    float64
+	hasInitialBaseline bool
+	// lastPointTime is the timestamp of the sensor's previous point, which
+	// jittered timestamps must stay after.
+	lastPointTime time.Time
+	// The value, status and tick of the sensor's last written point, which a
+	// deadband compares against.
+	lastWrittenValue  float64
+	lastWrittenStatus string
+	lastWrittenAt     time.Time
 }
 
 // Simulator generates time-series data for configured sensors.
@@ -85,6 +119,25 @@ type Simulator struct {
 	mu                sync.RWMutex
 	rng               *rand.Rand
 	interval          time.Duration
+	logger            *slog.Logger
+	schedule          Schedule
+	writePrecision    time.Duration
+	writes            *writeHealth
+	buffer            *writeBuffer
+	noiseModel        NoiseModel
+	startDelay        time.Duration
+	readyCheck        ReadyCheck
+	writeJitter       time.Duration
+	pausedMachines    map[string]struct{}
+	deadbands         map[string]float64
+	deadbandHeartbeat time.Duration
+	// pointsWritten counts successful writes since the process started and
+	// sessionPoints since the last Enable; both are updated without s.mu.
+	pointsWritten atomic.Int64
+	sessionPoints atomic.Int64
+	// ready is set once the ready check passes; until then points are
+	// buffered rather than written.
+	ready atomic.Bool
 }
 
 // Option customizes Simulator creation.
@@ -109,6 +162,39 @@ func WithMachineIterations(iterations int) Option {
 	}
 }
 
+// WithWritePrecision truncates written point timestamps to a multiple of p, e.g.
+// time.Second. Zero, the default, writes full nanosecond timestamps.
+//
+// Points of one series that land in the same truncated instant overwrite each
+// other in Influx, so p should not exceed the interval. Points of different
+// series often share a timestamp; readers resuming from the last timestamp they
+// saw must include that instant rather than skip past it (the readings stream
+// does so and de-duplicates by series).
+func WithWritePrecision(p time.Duration) Option {
+	return func(s *Simulator) {
+		if p >= 0 {
+			s.writePrecision = p
+		}
+	}
+}
+
+// pointTime applies the configured write precision to ts.
+func (s *Simulator) pointTime(ts time.Time) time.Time {
+	if s.writePrecision > 0 {
+		return ts.Truncate(s.writePrecision)
+	}
+	return ts
+}
+
+// WithLogger sets the structured logger used by the simulator.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Simulator) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
 // New creates a new Simulator.
 func New(writer api.WriteAPIBlocking, sensors []*Sensor, opts ...Option) *Simulator {
 	sim := &Simulator{
@@ -118,24 +204,39 @@ func New(writer api.WriteAPIBlocking, sensors []*Sensor, opts ...Option) *Simula
 		machineIterations: MachineIterationsFromEnv(),
 		rng:               rand.New(rand.NewSource(time.Now().UnixNano())),
 		interval:          defaultInterval,
+		noiseModel:        NoiseUniform,
+		logger:            slog.Default(),
+		writes:            &writeHealth{threshold: defaultWriteFailureThreshold},
+		buffer:            &writeBuffer{capacity: defaultWriteBufferSize},
+		deadbandHeartbeat: defaultDeadbandHeartbeat,
 	}
 	for _, opt := range opts {
 		opt(sim)
 	}
+	sim.prepareSensors()
 	sim.initializeSensors()
 	return sim
 }
 
-// Start begins periodic data generation until ctx cancels.
+// Start begins periodic data generation until ctx cancels. The first tick waits
+// for WithStartDelay and WithReadyCheck; ticks write only while the simulator
+// is enabled.
 func (s *Simulator) Start(ctx context.Context) {
-	log.Printf("sensor simulator running; interval=%s sensors=%d", s.interval, len(s.sensors))
-	ticker := time.NewTicker(s.interval)
 	go func() {
+		if !s.waitToStart(ctx) {
+			s.logger.Info("sensor simulator stopped before starting")
+			return
+		}
+		s.mu.RLock()
+		sensors, enabled := len(s.sensors), s.enabled
+		s.mu.RUnlock()
+		s.logger.Info("sensor simulator running", "interval", s.interval.String(), "sensors", sensors, "enabled", enabled)
+		ticker := time.NewTicker(s.interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
-				log.Println("sensor simulator stopped")
+				s.logger.Info("sensor simulator stopped")
 				return
 			case ts := <-ticker.C:
 				s.tick(ctx, ts)
@@ -145,26 +246,51 @@ func (s *Simulator) Start(ctx context.Context) {
 }
 
 func (s *Simulator) tick(ctx context.Context, ts time.Time) {
+	if s.writes.paused(ts) {
+		return
+	}
 	s.mu.Lock()
 	if !s.enabled || len(s.machineOrder) == 0 {
 		s.mu.Unlock()
 		return
 	}
 
+	// Skip paused machines and those outside their scheduled window without
+	// advancing their sensors.
+	cycleComplete := false
+	skipped := 0
+	for ; skipped < len(s.machineOrder) && !s.machineRuns(s.machineOrder[s.machineIndex], ts); skipped++ {
+		s.machineIteration = 0
+		s.machineIndex = (s.machineIndex + 1) % len(s.machineOrder)
+		if s.machineIndex == 0 {
+			cycleComplete = true
+		}
+	}
+	if skipped == len(s.machineOrder) {
+		// every machine is paused or off shift
+		s.mu.Unlock()
+		return
+	}
+
 	currentMachine := s.machineOrder[s.machineIndex]
 	activeSensors := s.machineSensors[currentMachine]
-	readings := make([]Sensor, len(activeSensors))
-	for i, sensor := range activeSensors {
+	readings := make([]Sensor, 0, len(activeSensors))
+	times := make([]time.Time, 0, len(activeSensors))
+	for _, sensor := range activeSensors {
 		value := s.nextValue(sensor)
-		readings[i] = Sensor{
+		if !s.shouldWrite(sensor, ts) {
+			continue
+		}
+		readings = append(readings, Sensor{
 			MachineName:  sensor.MachineName,
 			SensorName:   sensor.SensorName,
 			CurrentValue: value,
 			Status:       sensor.Status,
-		}
+			Measurement:  sensor.measurement(),
+		})
+		times = append(times, s.jitteredTime(sensor, ts))
 	}
 
-	cycleComplete := false
 	lastMachine := currentMachine
 	s.machineIteration++
 	if s.machineIteration >= s.machineIterations {
@@ -174,25 +300,36 @@ func (s *Simulator) tick(ctx context.Context, ts time.Time) {
 			cycleComplete = true
 		}
 	}
+	if cycleComplete {
+		s.ageSensors()
+	}
 	s.mu.Unlock()
 
-	for _, reading := range readings {
-		point := influxdb2.NewPoint(
-			measurementName,
-			map[string]string{
-				"machine_name": reading.MachineName,
-				"sensor_name":  reading.SensorName,
-			},
-			map[string]interface{}{
-				"value": reading.CurrentValue,
-			},
-			ts,
-		)
+	points := make([]*write.Point, len(readings))
+	for i, reading := range readings {
+		points[i] = newSensorPoint(reading.Measurement, reading.MachineName, reading.SensorName, reading.Status, reading.CurrentValue, s.pointTime(times[i]))
+	}
+	// Until Influx is ready, and while older points are still waiting, new
+	// points join the buffer so they are written in order.
+	if !s.ready.Load() || !s.flushBuffer(ctx, ts) {
+		s.bufferPoints(points...)
+		points = nil
+	}
+	for i, point := range points {
+		reading := readings[i]
 		if err := s.writer.WritePoint(ctx, point); err != nil {
-			log.Printf("write sensor data failed: %v", err)
+			s.logger.Error("write sensor data failed", "machine", reading.MachineName, "sensor", reading.SensorName, "error", err)
+			if s.recordWriteFailure(err, ts) {
+				s.bufferPoints(points[i:]...)
+				break
+			}
+			s.bufferPoints(point)
 			continue
 		}
-		log.Printf("sensor simulated: machine=%s sensor=%s status=%s value=%.2f", reading.MachineName, reading.SensorName, reading.Status, reading.CurrentValue)
+		s.writes.recordSuccess(1)
+		s.pointsWritten.Add(1)
+		s.sessionPoints.Add(1)
+		s.logger.Debug("sensor simulated", "machine", reading.MachineName, "sensor", reading.SensorName, "status", reading.Status, "value", reading.CurrentValue)
 	}
 
 	if cycleComplete {
@@ -200,6 +337,27 @@ func (s *Simulator) tick(ctx context.Context, ts time.Time) {
 	}
 }
 
+// newSensorPoint builds a reading point in measurement. The sensor's state is
+// written as a separate "status" field so queries on the "value" field are
+// unaffected.
+func newSensorPoint(measurement, machine, sensor, status string, value float64, ts time.Time) *write.Point {
+	fields := map[string]interface{}{
+		"value": value,
+	}
+	if status != "" {
+		fields["status"] = status
+	}
+	return influxdb2.NewPoint(
+		measurement,
+		map[string]string{
+			"machine_name": machine,
+			"sensor_name":  sensor,
+		},
+		fields,
+		ts,
+	)
+}
+
 func (s *Simulator) nextValue(sensor *Sensor) float64 {
 	if sensor.ticksRemaining <= 0 {
 		switch sensor.state {
@@ -221,17 +379,20 @@ func (s *Simulator) nextValue(sensor *Sensor) float64 {
 		if sensor.Baseline > 0 {
 			sensor.CurrentValue += (sensor.Baseline - sensor.CurrentValue) * startupRampCoefficient
 		}
-		sensor.CurrentValue += (s.rng.Float64() - 0.5) * sensor.Drift * startupNoiseScale
+		sensor.CurrentValue += s.noise(sensor) * sensor.Drift * startupNoiseScale
 	case stateRunning:
-		change := (s.rng.Float64() - 0.5) * sensor.Drift
+		change := s.noise(sensor) * sensor.Drift
 		sensor.CurrentValue += change
 		sensor.CurrentValue += (sensor.Baseline - sensor.CurrentValue) * rebindCoefficient
+		if target, ok := correlationTarget(sensor); ok {
+			sensor.CurrentValue += (target - sensor.CurrentValue) * sensor.CorrelationCoefficient
+		}
 	case stateShuttingDown:
 		sensor.CurrentValue += (sensor.downTarget - sensor.CurrentValue) * shutdownCoefficient
-		sensor.CurrentValue += (s.rng.Float64() - 0.5) * sensor.Drift * shutdownNoiseScale
+		sensor.CurrentValue += s.noise(sensor) * sensor.Drift * shutdownNoiseScale
 	case stateDown:
 		sensor.CurrentValue += (sensor.downTarget - sensor.CurrentValue) * downCoefficient
-		sensor.CurrentValue += (s.rng.Float64() - 0.5) * sensor.Drift * downNoiseScale
+		sensor.CurrentValue += s.noise(sensor) * sensor.Drift * downNoiseScale
 		if sensor.CurrentValue < sensor.downTarget {
 			sensor.CurrentValue = sensor.downTarget
 		}
@@ -240,9 +401,7 @@ func (s *Simulator) nextValue(sensor *Sensor) float64 {
 		}
 	}
 
-	if sensor.CurrentValue < 0 {
-		sensor.CurrentValue = 0
-	}
+	sensor.CurrentValue = sensor.clamp(sensor.CurrentValue)
 	return sensor.CurrentValue
 }
 
@@ -251,42 +410,39 @@ func (s *Simulator) enterState(sensor *Sensor, newState sensorState) {
 	switch newState {
 	case stateStartup:
 		sensor.Status = "starting"
-		sensor.ticksRemaining = s.randomTicks(sensor.startupRange)
+		sensor.ticksRemaining = s.randomTicks(sensor.Durations.Startup)
 		if sensor.Baseline > 0 {
-			base := sensor.Baseline * startupInitialRatio
-			noise := (s.rng.Float64() - 0.5) * sensor.Drift * startupNoiseScale
-			sensor.CurrentValue = base + noise
-			if sensor.CurrentValue < 0 {
-				sensor.CurrentValue = 0
-			}
+			base := math.Max(sensor.Baseline*startupInitialRatio, sensor.downTarget)
+			noise := s.noise(sensor) * sensor.Drift * startupNoiseScale
+			sensor.CurrentValue = sensor.clamp(base + noise)
 			if sensor.CurrentValue > sensor.Baseline {
 				sensor.CurrentValue = sensor.Baseline
 			}
 		}
 	case stateRunning:
 		sensor.Status = "running"
-		sensor.ticksRemaining = s.randomTicks(sensor.runRange)
+		sensor.ticksRemaining = s.randomTicks(sensor.Durations.Run)
 		if sensor.Baseline > 0 && sensor.CurrentValue < sensor.Baseline*0.8 {
 			sensor.CurrentValue += (sensor.Baseline - sensor.CurrentValue) * 0.3
 		}
 	case stateShuttingDown:
 		sensor.Status = "shutting_down"
-		sensor.ticksRemaining = s.randomTicks(sensor.shutdownRange)
+		sensor.ticksRemaining = s.randomTicks(sensor.Durations.Shutdown)
 	case stateDown:
 		sensor.Status = "down"
-		sensor.ticksRemaining = s.randomTicks(sensor.downRange)
+		sensor.ticksRemaining = s.randomTicks(sensor.Durations.Down)
 		if sensor.CurrentValue > sensor.downTarget {
 			sensor.CurrentValue = sensor.downTarget
 		}
 	}
 }
 
-func (s *Simulator) randomTicks(r durationRange) int {
-	min := r.min
+func (s *Simulator) randomTicks(r DurationRange) int {
+	min := r.Min
 	if min < 1 {
 		min = 1
 	}
-	max := r.max
+	max := r.Max
 	if max < min {
 		max = min
 	}
@@ -297,6 +453,39 @@ func (s *Simulator) randomTicks(r durationRange) int {
 	return min + s.rng.Intn(span)
 }
 
+// prepareSensors repairs invalid sensor configuration once, in New. The
+// simulator keeps writing the sensors afterwards under s.mu: per-tick state on
+// every tick, and Baseline whenever ageSensors or ResetAging runs. Callers that
+// built the sensors must read them through Snapshot rather than directly.
+func (s *Simulator) prepareSensors() {
+	for _, sensor := range s.sensors {
+		if sensor.CorrelatedWith != nil && inCorrelationCycle(sensor) {
+			s.logger.Error("sensor correlation cycle rejected", "machine", sensor.MachineName, "sensor", sensor.SensorName)
+			sensor.CorrelatedWith = nil
+			sensor.CorrelationCoefficient = 0
+		}
+		sensor.Durations = sensor.Durations.withDefaults()
+		if err := sensor.Durations.Validate(); err != nil {
+			s.logger.Error("sensor state durations rejected, using defaults", "machine", sensor.MachineName, "sensor", sensor.SensorName, "error", err)
+			sensor.Durations = DefaultStateDurations()
+		}
+		if err := sensor.validateBounds(); err != nil {
+			s.logger.Error("sensor bounds rejected, clearing them", "machine", sensor.MachineName, "sensor", sensor.SensorName, "error", err)
+			sensor.MinValue, sensor.MaxValue = nil, nil
+		}
+		if err := sensor.validateIdle(); err != nil {
+			s.logger.Error("sensor idle value rejected, using default", "machine", sensor.MachineName, "sensor", sensor.SensorName, "error", err)
+			sensor.downTarget = sensor.clamp(math.Max(sensor.Baseline*defaultDownRatio, 0))
+		}
+		if sensor.downTarget == 0 && sensor.Baseline > 0 {
+			sensor.downTarget = sensor.Baseline * defaultDownRatio
+		}
+		sensor.recordInitialBaseline()
+	}
+}
+
+// initializeSensors restarts every sensor and rebuilds the machine rotation.
+// Callers other than New hold s.mu.
 func (s *Simulator) initializeSensors() {
 	s.machineSensors = make(map[string][]*Sensor)
 	s.machineOrder = s.machineOrder[:0]
@@ -307,22 +496,6 @@ func (s *Simulator) initializeSensors() {
 	s.machineIteration = 0
 
 	for _, sensor := range s.sensors {
-		if sensor.startupRange.min == 0 && sensor.startupRange.max == 0 {
-			sensor.startupRange = defaultStartupRange
-		}
-		if sensor.runRange.min == 0 && sensor.runRange.max == 0 {
-			sensor.runRange = defaultRunRange
-		}
-		if sensor.shutdownRange.min == 0 && sensor.shutdownRange.max == 0 {
-			sensor.shutdownRange = defaultShutdownRange
-		}
-		if sensor.downRange.min == 0 && sensor.downRange.max == 0 {
-			sensor.downRange = defaultDownRange
-		}
-		if sensor.downTarget == 0 && sensor.Baseline > 0 {
-			sensor.downTarget = sensor.Baseline * defaultDownRatio
-		}
-
 		s.enterState(sensor, stateStartup)
 
 		if _, exists := s.machineSensors[sensor.MachineName]; !exists {
@@ -330,6 +503,9 @@ func (s *Simulator) initializeSensors() {
 		}
 		s.machineSensors[sensor.MachineName] = append(s.machineSensors[sensor.MachineName], sensor)
 	}
+	for machine, sensors := range s.machineSensors {
+		s.machineSensors[machine] = orderByCorrelation(sensors)
+	}
 }
 
 // Enable activates the simulator and resets sensor state.
@@ -341,10 +517,11 @@ func (s *Simulator) Enable() {
 	}
 	s.initializeSensors()
 	s.enabled = true
+	s.sessionPoints.Store(0)
 	machines := len(s.machineOrder)
 	iters := s.machineIterations
 	s.mu.Unlock()
-	log.Printf("sensor simulator enabled; machines=%d iterationsPerMachine=%d", machines, iters)
+	s.logger.Info("sensor simulator enabled", "machines", machines, "iterationsPerMachine", iters)
 }
 
 // Disable pauses all sensor generation.
@@ -358,7 +535,20 @@ func (s *Simulator) Disable() {
 	s.machineIndex = 0
 	s.machineIteration = 0
 	s.mu.Unlock()
-	log.Println("sensor simulator disabled")
+	s.logger.Info("sensor simulator disabled")
+}
+
+// PointsWritten returns how many points the simulator has written successfully
+// since the process started. It is cumulative: enabling and disabling the
+// simulator does not reset it.
+func (s *Simulator) PointsWritten() int64 {
+	return s.pointsWritten.Load()
+}
+
+// SessionPointsWritten returns how many points were written since the
+// simulator was last enabled.
+func (s *Simulator) SessionPointsWritten() int64 {
+	return s.sessionPoints.Load()
 }
 
 // Enabled reports whether the simulator is currently generating data.
@@ -391,45 +581,202 @@ func (s *Simulator) notifyCycleComplete(ctx context.Context, ts time.Time, lastM
 func (s *Simulator) Snapshot() []Sensor {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	now := time.Now()
 	snapshot := make([]Sensor, len(s.sensors))
 	for i, sensor := range s.sensors {
-		snapshot[i] = *sensor
+		snapshot[i] = s.snapshotSensor(sensor, now)
 	}
 	return snapshot
 }
 
+// snapshotSensor copies sensor, reporting it as down while its machine is off
+// shift. The copy drops its correlation driver, which would otherwise point
+// at a live sensor that keeps changing after the lock is released.
+func (s *Simulator) snapshotSensor(sensor *Sensor, now time.Time) Sensor {
+	copied := *sensor
+	copied.CorrelatedWith = nil
+	copied.EffectiveBaseline = sensor.Baseline
+	copied.Measurement = sensor.measurement()
+	if _, paused := s.pausedMachines[sensor.MachineName]; paused {
+		copied.Status = statusPaused
+	} else if !s.schedule.Active(sensor.MachineName, now) {
+		copied.Status = "down"
+	}
+	return copied
+}
+
+// Unit returns the unit of a machine's sensor, or "" when it is unknown.
+func (s *Simulator) Unit(machine, sensor string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, candidate := range s.machineSensors[machine] {
+		if candidate.SensorName == sensor {
+			return candidate.Unit
+		}
+	}
+	return ""
+}
+
+// SnapshotForMachine copies only machine's sensors, in tick order. machine
+// matches as in CanonicalMachine; ok is false when it is not part of the rotation.
+func (s *Simulator) SnapshotForMachine(machine string) (snapshot []Sensor, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	machine, ok = s.resolveMachine(machine)
+	if !ok {
+		return nil, false
+	}
+	sensors := s.machineSensors[machine]
+	now := time.Now()
+	snapshot = make([]Sensor, len(sensors))
+	for i, sensor := range sensors {
+		snapshot[i] = s.snapshotSensor(sensor, now)
+	}
+	return snapshot, true
+}
+
+// AddSensor registers a sensor at runtime. It starts in the startup state and
+// joins its machine's rotation, appending the machine if it is new. A machine
+// name matching an existing machine case-insensitively takes its spelling.
+func (s *Simulator) AddSensor(sensor *Sensor) error {
+	if sensor == nil || sensor.MachineName == "" || sensor.SensorName == "" {
+		return ErrInvalidSensor
+	}
+	sensor.Durations = sensor.Durations.withDefaults()
+	if err := sensor.Durations.Validate(); err != nil {
+		return err
+	}
+	if err := sensor.validateBounds(); err != nil {
+		return err
+	}
+	if err := sensor.validateIdle(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if machine, ok := s.resolveMachine(sensor.MachineName); ok {
+		sensor.MachineName = machine
+	}
+	for _, existing := range s.machineSensors[sensor.MachineName] {
+		if existing.SensorName == sensor.SensorName {
+			return fmt.Errorf("%w: machine=%s sensor=%s", ErrSensorExists, sensor.MachineName, sensor.SensorName)
+		}
+	}
+
+	sensor.recordInitialBaseline()
+	s.enterState(sensor, stateStartup)
+	s.sensors = append(s.sensors, sensor)
+	if _, exists := s.machineSensors[sensor.MachineName]; !exists {
+		s.machineOrder = append(s.machineOrder, sensor.MachineName)
+	}
+	s.machineSensors[sensor.MachineName] = orderByCorrelation(append(s.machineSensors[sensor.MachineName], sensor))
+	s.logger.Info("sensor added", "machine", sensor.MachineName, "sensor", sensor.SensorName)
+	return nil
+}
+
 // Interval returns the configured simulation interval.
 func (s *Simulator) Interval() time.Duration {
 	return s.interval
 }
 
+// SensorOption customizes a Sensor created by NewSensor.
+type SensorOption func(*Sensor)
+
+// WithIdleRatio sets the value a sensor settles at while down as a fraction of its baseline.
+func WithIdleRatio(ratio float64) SensorOption {
+	return func(s *Sensor) {
+		if ratio >= 0 {
+			s.downTarget = s.Baseline * ratio
+		}
+	}
+}
+
+// WithUnit sets the unit the sensor's values are measured in.
+func WithUnit(unit string) SensorOption {
+	return func(s *Sensor) {
+		s.Unit = strings.TrimSpace(unit)
+	}
+}
+
+// WithMeasurement writes the sensor's points to measurement instead of the
+// shared MeasurementName(), e.g. to give a machine type its own retention.
+func WithMeasurement(measurement string) SensorOption {
+	return func(s *Sensor) {
+		s.Measurement = strings.TrimSpace(measurement)
+	}
+}
+
+// measurement returns the Influx measurement the sensor writes to.
+func (s *Sensor) measurement() string {
+	if s.Measurement != "" {
+		return s.Measurement
+	}
+	return measurementName
+}
+
+// WithIdleValue sets the absolute value a sensor settles at while down, e.g. ambient temperature.
+// It may be negative for a sensor without a lower bound, but must lie within the
+// sensor's bounds: AddSensor rejects it otherwise, and New falls back to the default.
+func WithIdleValue(value float64) SensorOption {
+	return func(s *Sensor) {
+		s.downTarget = value
+	}
+}
+
 // NewSensor helper constructs a Sensor with a randomized start.
-func NewSensor(machine, sensor string, baseline, drift, initialSpread float64) *Sensor {
+func NewSensor(machine, sensor string, baseline, drift, initialSpread float64, opts ...SensorOption) *Sensor {
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	downTarget := baseline * defaultDownRatio
-	if downTarget < 0 {
-		downTarget = 0
+	s := &Sensor{
+		MachineName: machine,
+		SensorName:  sensor,
+		Baseline:    baseline,
+		Drift:       drift,
+		Durations:   DefaultStateDurations(),
+		MinValue:    new(float64),
+		downTarget:  math.Max(baseline*defaultDownRatio, 0),
 	}
-	initialValue := baseline * startupInitialRatio
-	initialValue += (rng.Float64() - 0.5) * initialSpread
-	if initialValue < 0 {
-		initialValue = 0
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	initialValue := math.Max(baseline*startupInitialRatio, s.downTarget)
+	initialValue += s.NoiseModel.sample(rng) * initialSpread
+	initialValue = s.clamp(initialValue)
 	if baseline > 0 && initialValue > baseline {
 		initialValue = baseline
 	}
-	return &Sensor{
-		MachineName:   machine,
-		SensorName:    sensor,
-		Baseline:      baseline,
-		Drift:         drift,
-		CurrentValue:  initialValue,
-		startupRange:  defaultStartupRange,
-		runRange:      defaultRunRange,
-		shutdownRange: defaultShutdownRange,
-		downRange:     defaultDownRange,
-		downTarget:    downTarget,
+	s.CurrentValue = initialValue
+	return s
+}
+
+// MachineNames returns the distinct machine names of sensors in first-seen order.
+func MachineNames(sensors []*Sensor) []string {
+	seen := make(map[string]struct{}, len(sensors))
+	names := make([]string, 0, len(sensors))
+	for _, sensor := range sensors {
+		if sensor == nil {
+			continue
+		}
+		if _, ok := seen[sensor.MachineName]; ok {
+			continue
+		}
+		seen[sensor.MachineName] = struct{}{}
+		names = append(names, sensor.MachineName)
 	}
+	return names
+}
+
+// TicksRemaining returns how many more ticks the sensor stays in its current
+// state. Call it on a Snapshot copy; a running simulator changes it every tick.
+func (s *Sensor) TicksRemaining() int {
+	return s.ticksRemaining
+}
+
+// DownTarget returns the value the sensor settles at while down. It is fixed
+// once the sensor is passed to New or AddSensor.
+func (s *Sensor) DownTarget() float64 {
+	return s.downTarget
 }
 
 // MeasurementName returns the measurement identifier used for simulated sensor writes.
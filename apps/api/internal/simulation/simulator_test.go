@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// pointWriter records the points written to it.
+type pointWriter struct {
+	mu     sync.Mutex
+	points []*write.Point
+}
+
+func (w *pointWriter) WriteRecord(ctx context.Context, line ...string) error {
+	return nil
+}
+
+func (w *pointWriter) WritePoint(ctx context.Context, point ...*write.Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.points = append(w.points, point...)
+	return nil
+}
+
+func (w *pointWriter) EnableBatching() {}
+
+func (w *pointWriter) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (w *pointWriter) written() []*write.Point {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*write.Point(nil), w.points...)
+}
+
+// TestTickAndSnapshotConcurrently runs ticks, which age the baselines at every
+// cycle, alongside Snapshot and ResetAging. Run it with -race.
+func TestTickAndSnapshotConcurrently(t *testing.T) {
+	baselines := map[string]float64{"Temperature": 180, "Pressure": 3, "Force": 50}
+	sim := New(&pointWriter{}, []*Sensor{
+		NewSensor("Oven-01", "Temperature", baselines["Temperature"], 2, 5, WithAging(0.001)),
+		NewSensor("Oven-01", "Pressure", baselines["Pressure"], 0.1, 0.5, WithAging(0.001)),
+		NewSensor("Press-01", "Force", baselines["Force"], 1, 2, WithAging(-0.001)),
+	}, WithMachineIterations(1))
+	sim.Enable()
+	ctx := context.Background()
+	ts := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 400; i++ {
+			sim.tick(ctx, ts.Add(time.Duration(i)*time.Second))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 400; i++ {
+			for _, sensor := range sim.Snapshot() {
+				if sensor.EffectiveBaseline <= 0 {
+					t.Errorf("%s baseline = %v", sensor.SensorName, sensor.EffectiveBaseline)
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 40; i++ {
+			sim.ResetAging()
+		}
+	}()
+	wg.Wait()
+
+	// Two machines at one tick each complete a cycle every other tick.
+	for i := 0; i < 4; i++ {
+		sim.tick(ctx, ts.Add(time.Duration(400+i)*time.Second))
+	}
+	for _, sensor := range sim.Snapshot() {
+		if sensor.EffectiveBaseline == baselines[sensor.SensorName] {
+			t.Errorf("%s baseline did not age", sensor.SensorName)
+		}
+	}
+	sim.ResetAging()
+	for _, sensor := range sim.Snapshot() {
+		if sensor.EffectiveBaseline != baselines[sensor.SensorName] {
+			t.Errorf("%s baseline after reset = %v, want %v", sensor.SensorName, sensor.EffectiveBaseline, baselines[sensor.SensorName])
+		}
+	}
+}
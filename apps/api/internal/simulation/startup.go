@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"context"
+	"time"
+)
+
+// readyRetryInterval is how often Start retries a failing readiness check.
+const readyRetryInterval = 2 * time.Second
+
+// ReadyCheck reports whether the simulator's dependencies can accept writes,
+// e.g. an Influx ping. A non-nil error delays the first tick.
+type ReadyCheck func(ctx context.Context) error
+
+// WithStartDelay makes Start wait d before the first tick.
+func WithStartDelay(d time.Duration) Option {
+	return func(s *Simulator) {
+		if d >= 0 {
+			s.startDelay = d
+		}
+	}
+}
+
+// WithReadyCheck makes Start hold the first tick until check succeeds, retrying
+// every two seconds, so a slow Influx does not produce a burst of failed writes
+// on boot. The check runs after any start delay. With a write buffer (see
+// WithWriteBuffer) the simulator ticks while not ready and buffers its points
+// instead of waiting.
+func WithReadyCheck(check ReadyCheck) Option {
+	return func(s *Simulator) {
+		s.readyCheck = check
+	}
+}
+
+// WithStartEnabled sets whether the simulator generates data as soon as it
+// starts ticking. It starts disabled by default, leaving the Coordinator to
+// enable it while lots are active; ticks before then write nothing.
+func WithStartEnabled(enabled bool) Option {
+	return func(s *Simulator) {
+		s.enabled = enabled
+	}
+}
+
+// waitToStart blocks until the start delay has passed and, without a write
+// buffer, the ready check succeeds. With one, the check continues in the
+// background. It returns false when ctx is cancelled first.
+func (s *Simulator) waitToStart(ctx context.Context) bool {
+	if s.startDelay > 0 {
+		s.logger.Info("sensor simulator waiting before first tick", "delay", s.startDelay.String())
+		timer := time.NewTimer(s.startDelay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+		}
+	}
+	if s.readyCheck == nil {
+		s.ready.Store(true)
+		return true
+	}
+	if s.buffer.capacity > 0 {
+		go s.awaitReady(ctx)
+		return true
+	}
+	return s.awaitReady(ctx)
+}
+
+// awaitReady retries the ready check until it succeeds, then marks the
+// simulator ready. It returns false when ctx is cancelled first.
+func (s *Simulator) awaitReady(ctx context.Context) bool {
+	for attempt := 1; ; attempt++ {
+		err := s.readyCheck(ctx)
+		if err == nil {
+			s.ready.Store(true)
+			if attempt > 1 {
+				s.logger.Info("sensor simulator dependencies ready", "attempts", attempt)
+			}
+			return true
+		}
+		if attempt == 1 {
+			msg := "sensor simulator waiting for dependencies"
+			if s.buffer.capacity > 0 {
+				msg = "sensor simulator not ready, buffering points"
+			}
+			s.logger.Warn(msg, "error", err, "retry", readyRetryInterval.String())
+		} else {
+			s.logger.Debug("sensor simulator dependencies still unavailable", "attempt", attempt, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(readyRetryInterval):
+		}
+	}
+}
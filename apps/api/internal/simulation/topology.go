@@ -0,0 +1,93 @@
+package simulation
+
+import (
+	"strings"
+	"time"
+)
+
+// SensorMeta describes a sensor without its live state, so it only changes when
+// sensors are added.
+type SensorMeta struct {
+	SensorName  string `json:"sensorName"`
+	Unit        string `json:"unit,omitempty"`
+	Measurement string `json:"measurement"`
+	// Baseline is the configured baseline, before any aging drift.
+	Baseline float64 `json:"baseline"`
+}
+
+// Topology returns each machine's sensors in tick order, keyed by machine name.
+// It is much cheaper than Snapshot and stable between ticks.
+func (s *Simulator) Topology() map[string][]SensorMeta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topology := make(map[string][]SensorMeta, len(s.machineSensors))
+	for machine, sensors := range s.machineSensors {
+		metas := make([]SensorMeta, len(sensors))
+		for i, sensor := range sensors {
+			baseline := sensor.Baseline
+			if sensor.hasInitialBaseline {
+				baseline = sensor.initialBaseline
+			}
+			metas[i] = SensorMeta{
+				SensorName:  sensor.SensorName,
+				Unit:        sensor.Unit,
+				Measurement: sensor.measurement(),
+				Baseline:    baseline,
+			}
+		}
+		topology[machine] = metas
+	}
+	return topology
+}
+
+// MachineActive reports whether machine is in the rotation, not paused and
+// inside its scheduled window at t.
+func (s *Simulator) MachineActive(machine string, t time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	machine, ok := s.resolveMachine(machine)
+	if !ok {
+		return false
+	}
+	return s.machineRuns(machine, t)
+}
+
+// CanonicalMachine returns the simulator's spelling of machine, which is the
+// machine_name tag its readings carry. Names match case-insensitively after
+// trimming and collapsing whitespace; ok is false when no machine matches.
+func (s *Simulator) CanonicalMachine(machine string) (name string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolveMachine(machine)
+}
+
+// resolveMachine is CanonicalMachine for callers holding s.mu.
+func (s *Simulator) resolveMachine(machine string) (string, bool) {
+	if _, ok := s.machineSensors[machine]; ok {
+		return machine, true
+	}
+	machine = strings.Join(strings.Fields(machine), " ")
+	for name := range s.machineSensors {
+		if strings.EqualFold(name, machine) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// MaxReadingInterval is the longest a running sensor goes between readings:
+// its machine writes on each tick of its turn and then waits while every other
+// machine takes a turn. Paused and off-shift machines are skipped, which only
+// shortens the wait. With deadbands configured, a steady sensor may also wait
+// out the heartbeat before its next turn writes.
+func (s *Simulator) MaxReadingInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	iterations := max(s.machineIterations, 1)
+	others := max(len(s.machineOrder)-1, 0)
+	interval := s.interval * time.Duration(iterations*others+1)
+	if len(s.deadbands) > 0 {
+		interval += s.deadbandHeartbeat
+	}
+	return interval
+}
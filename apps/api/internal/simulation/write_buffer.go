@@ -0,0 +1,121 @@
+package simulation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const (
+	defaultWriteBufferSize = 10000
+	// flushBatchSize bounds the points sent in one request when draining the
+	// buffer.
+	flushBatchSize = 500
+)
+
+// writeBuffer holds points that could not be written, oldest first. Once full,
+// each new point evicts the oldest one, which is counted as dropped. It has its
+// own lock so buffering does not contend with sensor generation.
+type writeBuffer struct {
+	mu       sync.Mutex
+	capacity int
+	points   []*write.Point
+	dropped  uint64
+}
+
+// WithWriteBuffer keeps up to n points that could not be written, either
+// because the ready check has not passed yet or because a write failed, and
+// writes them ahead of new points once Influx accepts writes again. Zero
+// disables buffering, so such points are lost. The default is 10000.
+//
+// With a buffer, Start no longer holds the first tick for WithReadyCheck: the
+// simulator ticks while not ready, buffering its points, and turns ready once
+// the check succeeds.
+func WithWriteBuffer(n int) Option {
+	return func(s *Simulator) {
+		if n >= 0 {
+			s.buffer.capacity = n
+		}
+	}
+}
+
+// add appends points, evicting the oldest beyond capacity. It reports how many
+// points were dropped.
+func (b *writeBuffer) add(points ...*write.Point) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.capacity <= 0 {
+		b.dropped += uint64(len(points))
+		return len(points)
+	}
+	b.points = append(b.points, points...)
+	over := len(b.points) - b.capacity
+	if over <= 0 {
+		return 0
+	}
+	// Copy rather than reslice so evicted points do not pin the backing array.
+	b.points = append([]*write.Point(nil), b.points[over:]...)
+	b.dropped += uint64(over)
+	return over
+}
+
+// flush writes buffered points in batches of flushBatchSize, oldest first,
+// stopping at the first failed batch, which stays buffered. It returns the
+// number of points written. Only the ticking goroutine adds and flushes, so the
+// buffer cannot change while a batch is in flight.
+func (b *writeBuffer) flush(ctx context.Context, writePoints func(context.Context, ...*write.Point) error) (int, error) {
+	written := 0
+	for {
+		b.mu.Lock()
+		batch := b.points[:min(len(b.points), flushBatchSize)]
+		b.mu.Unlock()
+		if len(batch) == 0 {
+			return written, nil
+		}
+		if err := writePoints(ctx, batch...); err != nil {
+			return written, err
+		}
+		b.mu.Lock()
+		b.points = b.points[len(batch):]
+		b.mu.Unlock()
+		written += len(batch)
+	}
+}
+
+// bufferPoints keeps points for a later write, logging any evicted to make
+// room.
+func (s *Simulator) bufferPoints(points ...*write.Point) {
+	if len(points) == 0 {
+		return
+	}
+	if dropped := s.buffer.add(points...); dropped > 0 {
+		buffered, total := s.buffer.stats()
+		s.logger.Warn("simulator write buffer full, dropping oldest points", "dropped", dropped, "droppedTotal", total, "buffered", buffered)
+	}
+}
+
+// flushBuffer writes the buffered points and reports whether the buffer is now
+// empty. A failed write counts towards the write failure threshold.
+func (s *Simulator) flushBuffer(ctx context.Context, ts time.Time) bool {
+	written, err := s.buffer.flush(ctx, s.writer.WritePoint)
+	if written > 0 {
+		s.pointsWritten.Add(int64(written))
+		s.sessionPoints.Add(int64(written))
+		s.writes.recordSuccess(written)
+		s.logger.Info("buffered sensor points written", "points", written)
+	}
+	if err != nil {
+		s.logger.Error("write buffered sensor data failed", "error", err)
+		s.recordWriteFailure(err, ts)
+		return false
+	}
+	return true
+}
+
+func (b *writeBuffer) stats() (buffered int, dropped uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.points), b.dropped
+}
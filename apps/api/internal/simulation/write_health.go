@@ -0,0 +1,120 @@
+package simulation
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultWriteFailureThreshold = 10
+
+// WriteStats summarises the health of the simulator's Influx writes.
+type WriteStats struct {
+	Written             uint64     `json:"written"`
+	Failed              uint64     `json:"failed"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	// PausedUntil is set while writes are paused after too many failures.
+	PausedUntil *time.Time `json:"pausedUntil,omitempty"`
+	// Ready is false until the ready check passes.
+	Ready bool `json:"ready"`
+	// Buffered counts points waiting to be written and Dropped those evicted
+	// from the full buffer.
+	Buffered int    `json:"buffered"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// writeHealth tracks write outcomes; it has its own lock so recording does not
+// contend with sensor generation.
+type writeHealth struct {
+	mu          sync.Mutex
+	threshold   int
+	pause       time.Duration
+	stats       WriteStats
+	pausedUntil time.Time
+}
+
+// WithWriteFailureThreshold logs an error once n consecutive writes fail and,
+// when pause is positive, stops generating data for that long before retrying.
+func WithWriteFailureThreshold(n int, pause time.Duration) Option {
+	return func(s *Simulator) {
+		if n > 0 {
+			s.writes.threshold = n
+		}
+		if pause >= 0 {
+			s.writes.pause = pause
+		}
+	}
+}
+
+// recordSuccess counts n points written successfully and clears the failure
+// streak.
+func (w *writeHealth) recordSuccess(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.Written += uint64(n)
+	w.stats.ConsecutiveFailures = 0
+}
+
+// recordFailure counts a failed write. tripped reports whether this failure
+// reached the threshold; pausedUntil is non-zero when writes are now paused.
+func (w *writeHealth) recordFailure(err error, at time.Time) (tripped bool, consecutive int, pausedUntil time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.Failed++
+	w.stats.ConsecutiveFailures++
+	w.stats.LastError = err.Error()
+	w.stats.LastErrorAt = &at
+	if w.threshold <= 0 || w.stats.ConsecutiveFailures != w.threshold {
+		return false, w.stats.ConsecutiveFailures, time.Time{}
+	}
+	if w.pause > 0 {
+		w.pausedUntil = at.Add(w.pause)
+		// Count the streak afresh once writes resume.
+		w.stats.ConsecutiveFailures = 0
+	}
+	return true, w.threshold, w.pausedUntil
+}
+
+// paused reports whether writes are paused at t.
+func (w *writeHealth) paused(t time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return t.Before(w.pausedUntil)
+}
+
+// recordWriteFailure records a failed write at ts and logs when failures reach
+// the threshold. It reports whether writes are now paused.
+func (s *Simulator) recordWriteFailure(err error, ts time.Time) bool {
+	tripped, consecutive, pausedUntil := s.writes.recordFailure(err, ts)
+	switch {
+	case !tripped:
+		return false
+	case pausedUntil.IsZero():
+		s.logger.Error("influx writes failing repeatedly", "consecutiveFailures", consecutive, "error", err)
+		return false
+	default:
+		s.logger.Error("influx writes failing repeatedly, pausing simulator", "consecutiveFailures", consecutive, "pausedUntil", pausedUntil, "error", err)
+		return true
+	}
+}
+
+func (w *writeHealth) snapshot(now time.Time) WriteStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	if now.Before(w.pausedUntil) {
+		until := w.pausedUntil
+		stats.PausedUntil = &until
+	}
+	return stats
+}
+
+// WriteStats reports write counts, the most recent error, any active pause and
+// the state of the write buffer.
+func (s *Simulator) WriteStats() WriteStats {
+	stats := s.writes.snapshot(time.Now())
+	stats.Ready = s.ready.Load()
+	stats.Buffered, stats.Dropped = s.buffer.stats()
+	return stats
+}
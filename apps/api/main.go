@@ -4,35 +4,65 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	influx "github.com/Resanso/minerva-ericsson/apps/api/internal/influxdb"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/llm"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/logging"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/metadata"
 	mysqlclient "github.com/Resanso/minerva-ericsson/apps/api/internal/mysql"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/notify"
+	"github.com/Resanso/minerva-ericsson/apps/api/internal/processing"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/server"
 	"github.com/Resanso/minerva-ericsson/apps/api/internal/simulation"
 )
 
+const (
+	// shutdownTimeout bounds how long in-flight requests and background passes
+	// may take to finish once a stop signal arrives.
+	shutdownTimeout = 10 * time.Second
+	// influxRedialInterval is how often an unreachable Influx is retried.
+	influxRedialInterval = 10 * time.Second
+)
+
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("warning: .env file not loaded: %v", err)
+	envErr := godotenv.Load()
+
+	logger := logging.New(os.Stdout, logging.FromEnv())
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Warn(".env file not loaded", "error", envErr)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	cfg, err := influx.FromEnv()
 	if err != nil {
-		log.Fatalf("influx config error: %v", err)
+		fatal(logger, "influx config error", err)
 	}
 
+	// An unreachable server is tolerated: the client reports unhealthy and is
+	// redialled in the background while the simulator buffers its points. A
+	// rejected token or missing bucket is a misconfiguration and aborts.
 	client, err := influx.New(ctx, cfg)
-	if err != nil {
-		log.Fatalf("influx connection error: %v", err)
+	switch {
+	case err == nil:
+	case errors.Is(err, influx.ErrInfluxUnreachable):
+		logger.Warn("influx unreachable, starting disconnected", "error", err)
+		client = influx.NewUnverified(cfg)
+		go client.Redial(ctx, influxRedialInterval, logger)
+	default:
+		fatal(logger, "influx connection error", err)
 	}
 	defer client.Close()
 
@@ -40,68 +70,175 @@ func main() {
 	if llmCfg, err := llm.FromEnv(); err != nil {
 		switch {
 		case errors.Is(err, llm.ErrMissingAPIKey):
-			log.Printf("warning: LLM client disabled: %v", err)
+			logger.Warn("LLM client disabled", "error", err)
 		default:
-			log.Fatalf("llm config error: %v", err)
+			fatal(logger, "llm config error", err)
 		}
 	} else {
 		llmClient, err = llm.New(ctx, llmCfg)
 		if err != nil {
-			log.Fatalf("llm client error: %v", err)
+			fatal(logger, "llm client error", err)
 		}
 		defer func() {
 			if err := llmClient.Close(); err != nil {
-				log.Printf("warning: llm client close error: %v", err)
+				logger.Warn("llm client close error", "error", err)
 			}
 		}()
 	}
 
-	interval := simulation.IntervalFromEnv()
-	machineIterations := simulation.MachineIterationsFromEnv()
 	sensors := simulation.DefaultSensors()
-	simulator := simulation.New(
-		client.WriteAPI(),
-		sensors,
-		simulation.WithInterval(interval),
-		simulation.WithMachineIterations(machineIterations),
-	)
+	if err := simulation.ValidateCorrelations(sensors); err != nil {
+		fatal(logger, "simulation sensor config error", err)
+	}
+
+	schedule, err := simulation.ScheduleFromEnv()
+	if err != nil {
+		fatal(logger, "simulation schedule error", err)
+	}
+
+	writeFailureThreshold, writeFailurePause := simulation.WriteFailureThresholdFromEnv()
+	simulatorOpts := []simulation.Option{
+		simulation.WithInterval(simulation.IntervalFromEnv()),
+		simulation.WithMachineIterations(simulation.MachineIterationsFromEnv()),
+		simulation.WithLogger(logger),
+		simulation.WithSchedule(schedule),
+		simulation.WithWritePrecision(simulation.WritePrecisionFromEnv()),
+		simulation.WithWriteFailureThreshold(writeFailureThreshold, writeFailurePause),
+		simulation.WithNoiseModel(simulation.NoiseModelFromEnv()),
+		simulation.WithStartDelay(simulation.StartDelayFromEnv()),
+		simulation.WithWriteJitter(simulation.WriteJitterFromEnv()),
+		simulation.WithReadyCheck(client.Ping),
+		simulation.WithWriteBuffer(simulation.WriteBufferFromEnv()),
+	}
+	simulatorOpts = append(simulatorOpts, simulation.DeadbandsFromEnv()...)
+	simulator := simulation.New(client.WriteAPI(), sensors, simulatorOpts...)
 
 	// Log all sensors on startup for debugging
-	log.Printf("📊 Simulator initialized with %d sensors:", len(sensors))
+	logger.Info("simulator initialized", "sensors", len(sensors))
 	for _, sensor := range sensors {
-		log.Printf("   - Machine: '%s', Sensor: '%s', Baseline: %.2f", sensor.MachineName, sensor.SensorName, sensor.Baseline)
+		logger.Debug("simulator sensor", "machine", sensor.MachineName, "sensor", sensor.SensorName, "baseline", sensor.Baseline)
 	}
 
 	simulator.Start(ctx)
 
 	mysqlCfg, err := mysqlclient.FromEnv()
 	if err != nil {
-		log.Fatalf("mysql config error: %v", err)
+		fatal(logger, "mysql config error", err)
 	}
 
 	sqlDB, err := mysqlclient.New(ctx, mysqlCfg)
 	if err != nil {
-		log.Fatalf("mysql connection error: %v", err)
+		fatal(logger, "mysql connection error", err)
 	}
 	defer sqlDB.Close()
 
+	mysqlPool := mysqlclient.NewPool(sqlDB, mysqlCfg)
+	mysqlPool.Monitor(ctx, time.Minute, logger)
+
 	metadataRepo := metadata.NewRepository(sqlDB)
 	if err := metadataRepo.EnsureSchema(ctx); err != nil {
-		log.Fatalf("mysql ensure schema error: %v", err)
+		fatal(logger, "mysql ensure schema error", err)
 	}
 
-	coordinator := simulation.NewCoordinator(simulator, metadataRepo)
+	if seed, _ := strconv.ParseBool(os.Getenv("SEED_MACHINES")); seed {
+		seeded, err := metadataRepo.SeedMachines(ctx, simulation.MachineNames(sensors))
+		if err != nil {
+			fatal(logger, "mysql seed machines error", err)
+		}
+		logger.Info("machines seeded from simulator sensors", "added", seeded)
+	}
+
+	notifier := notify.FromEnv(logger)
+	defectThreshold := notify.DefectAlertThresholdFromEnv()
+
+	completionEnabled, _ := strconv.ParseBool(os.Getenv("LOT_COMPLETION_ENABLED"))
+
+	// With sensor-down completion running, leave lots to it so they keep
+	// their per-sensor summary rather than the coordinator's bare one.
+	coordinator := simulation.NewCoordinator(simulator, metadataRepo,
+		simulation.WithCoordinatorLogger(logger),
+		simulation.WithCoordinatorNotifier(notifier, defectThreshold),
+		simulation.WithCoordinatorCompletion(!completionEnabled),
+	)
 	coordinator.Start(ctx)
 
+	var completion *processing.CompletionService
+	if completionEnabled {
+		idleValues := make(map[string]float64, len(sensors))
+		expected := make(map[string][]string)
+		for _, sensor := range sensors {
+			idleValues[processing.SensorKey(sensor.MachineName, sensor.SensorName)] = sensor.DownTarget()
+			// Completion only reads the default measurement, so sensors
+			// writing elsewhere cannot be required to report.
+			if sensor.Measurement == "" || sensor.Measurement == simulation.MeasurementName() {
+				expected[sensor.MachineName] = append(expected[sensor.MachineName], sensor.SensorName)
+			}
+		}
+		completionOpts := []processing.CompletionOption{
+			processing.WithIdleValues(idleValues),
+			processing.WithInterval(processing.IntervalFromEnv()),
+			processing.WithMinRunDuration(processing.MinRunDurationFromEnv()),
+			processing.WithRecoverThreshold(processing.RecoverThresholdFromEnv()),
+			processing.WithLogger(logger),
+			processing.WithNotifier(notifier, defectThreshold),
+		}
+		for machine, names := range expected {
+			completionOpts = append(completionOpts, processing.WithExpectedSensors(machine, names))
+		}
+		completionOpts = append(completionOpts, processing.CounterSensorsFromEnv()...)
+		completion = processing.NewCompletionService(client, metadataRepo, completionOpts...)
+		completion.Start(ctx)
+	}
+
+	corsOrigins, err := server.CORSOriginsFromEnv()
+	if err != nil {
+		fatal(logger, "cors config error", err)
+	}
+	logger.Info("cors allowed origins", "origins", corsOrigins)
+
 	router := server.NewRouter(server.Dependencies{
-		Simulator: simulator,
-		Influx:    client,
-		Metadata:  metadataRepo,
-		LLM:       llmClient,
+		Simulator:            simulator,
+		Coordinator:          coordinator,
+		Completion:           completion,
+		Influx:               client,
+		Metadata:             metadataRepo,
+		MySQLPool:            mysqlPool,
+		LLM:                  llmClient,
+		Logger:               logger,
+		CORSOrigins:          corsOrigins,
+		ChatCache:            server.ChatCacheFromEnv(),
+		ChatPromptMaxRows:    server.ChatPromptMaxRowsFromEnv(),
+		ChatPromptMaxSensors: server.ChatPromptMaxSensorsFromEnv(),
+		AdminToken:           server.AdminTokenFromEnv(),
+		EnableGzip:           server.GzipEnabledFromEnv(),
+		StreamMaxLookback:    server.StreamMaxLookbackFromEnv(),
+		RequestTimeout:       server.RequestTimeoutFromEnv(),
 	})
 
-	fmt.Println("Starting Go Gin server on :8080...")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal(err)
+	srv := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		logger.Info("starting Go Gin server", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "http server error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("http server shutdown error", "error", err)
 	}
+	if completion != nil {
+		if err := completion.Stop(shutdownCtx); err != nil {
+			logger.Warn("lot completion service shutdown error", "error", err)
+		}
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }